@@ -0,0 +1,333 @@
+package artifacts
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stevexciv/scad-server/ids"
+	"github.com/stevexciv/scad-server/metrics"
+	"github.com/stevexciv/scad-server/models"
+)
+
+const (
+	// DefaultTTL is how long artifacts are kept when no TTL is configured
+	DefaultTTL = 24 * time.Hour
+	// dataExt and metaExt are the suffixes of an artifact's content and
+	// metadata files
+	dataExt = ".bin"
+	metaExt = ".json"
+)
+
+var (
+	// ErrNotFound is returned for artifacts that don't exist or have expired
+	ErrNotFound = errors.New("artifact not found")
+	// ErrStorageFull is returned when storing an artifact would exceed the
+	// storage cap
+	ErrStorageFull = errors.New("artifact storage full")
+	// ErrBadSignature is returned for download links that are unsigned,
+	// tampered with or expired
+	ErrBadSignature = errors.New("download link signature missing, invalid or expired")
+)
+
+// idPattern matches artifact IDs, which are also their file names
+var idPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// orphanAge is how long an object without metadata may exist before Sweep
+// treats it as left over from an interrupted write
+const orphanAge = 10 * time.Minute
+
+// Config contains artifact store settings
+type Config struct {
+	// Backend is BackendFilesystem (the default) or BackendS3
+	Backend string
+	// Dir is the directory holding artifacts with the filesystem backend
+	Dir string
+	// S3 is the bucket holding artifacts with the S3 backend
+	S3 S3Config
+	// TTL is how long an artifact and its download links stay valid
+	TTL time.Duration
+	// MaxBytes caps the total size of stored artifacts (0 for unlimited)
+	MaxBytes int64
+	// SigningKey is the HMAC key for download links
+	SigningKey []byte
+}
+
+// Store keeps rendered files in a Backend until they expire
+type Store struct {
+	backend  Backend
+	ttl      time.Duration
+	maxBytes int64
+	key      []byte
+
+	// mu guards used, which includes space reserved by writes in progress
+	mu   sync.Mutex
+	used int64
+	now  func() time.Time
+}
+
+// New opens the configured backend and counts the artifacts already stored
+// there towards the storage cap
+func New(ctx context.Context, cfg Config) (*Store, error) {
+	var backend Backend
+	var err error
+	switch cfg.Backend {
+	case "", BackendFilesystem:
+		backend, err = newFilesystemBackend(cfg.Dir)
+	case BackendS3:
+		backend, err = newS3Backend(cfg.S3)
+	default:
+		err = fmt.Errorf("unknown artifact backend %q", cfg.Backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewWithBackend(ctx, backend, cfg)
+}
+
+// NewWithBackend creates a store on backend, ignoring the backend settings
+// of cfg
+func NewWithBackend(ctx context.Context, backend Backend, cfg Config) (*Store, error) {
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultTTL
+	}
+	if len(cfg.SigningKey) == 0 {
+		return nil, errors.New("artifact signing key must not be empty")
+	}
+
+	objects, err := backend.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list artifacts: %w", err)
+	}
+	s := &Store{
+		backend:  backend,
+		ttl:      cfg.TTL,
+		maxBytes: cfg.MaxBytes,
+		key:      cfg.SigningKey,
+		now:      time.Now,
+	}
+	for _, obj := range objects {
+		if strings.HasSuffix(obj.Name, dataExt) {
+			s.used += obj.Size
+		}
+	}
+	metrics.ArtifactStorageBytes.Set(float64(s.used))
+	return s, nil
+}
+
+// Put stores data described by meta and returns the complete description.
+// The store assigns the ID, size and timestamps; the artifact expires after
+// the configured TTL.
+func (s *Store) Put(ctx context.Context, data []byte, meta models.Artifact) (*models.Artifact, error) {
+	id, err := ids.New()
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	a := meta
+	a.ID = id
+	a.Size = int64(len(data))
+	a.CreatedAt = now
+	a.ExpiresAt = now.Add(s.ttl)
+	encoded, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.reserve(a.Size); err != nil {
+		return nil, err
+	}
+	if err := s.backend.Put(ctx, id+dataExt, data, a.ContentType); err != nil {
+		s.release(a.Size)
+		return nil, fmt.Errorf("failed to store artifact: %w", err)
+	}
+	// The metadata is written last, so an artifact only exists once complete
+	if err := s.backend.Put(ctx, id+metaExt, encoded, "application/json"); err != nil {
+		s.backend.Delete(ctx, id+dataExt)
+		s.release(a.Size)
+		return nil, fmt.Errorf("failed to store artifact: %w", err)
+	}
+	return &a, nil
+}
+
+// Open returns the description and content of an unexpired artifact. The
+// caller closes the content.
+func (s *Store) Open(ctx context.Context, id string) (*models.Artifact, io.ReadSeekCloser, error) {
+	a, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := s.backend.Get(ctx, id+dataExt)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			err = fmt.Errorf("failed to open artifact: %w", err)
+		}
+		return nil, nil, err
+	}
+	return a, content, nil
+}
+
+// Get returns the description of an unexpired artifact
+func (s *Store) Get(ctx context.Context, id string) (*models.Artifact, error) {
+	if !idPattern.MatchString(id) {
+		return nil, ErrNotFound
+	}
+	a, err := s.read(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !s.now().Before(a.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	return a, nil
+}
+
+// Used returns the bytes taken by stored artifacts
+func (s *Store) Used() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.used
+}
+
+// Sign returns the signature authorizing downloads of id until expires
+func (s *Store) Sign(id string, expires time.Time) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(id + "\n" + strconv.FormatInt(expires.Unix(), 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a download link's signature and that it hasn't expired;
+// expires is in Unix seconds
+func (s *Store) Verify(id, expires, sig string) error {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrBadSignature
+	}
+	deadline := time.Unix(unix, 0)
+	want := s.Sign(id, deadline)
+	if !hmac.Equal([]byte(sig), []byte(want)) || !s.now().Before(deadline) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// Sweep deletes expired artifacts, along with objects left behind by
+// interrupted writes, and returns how many artifacts it removed
+func (s *Store) Sweep(ctx context.Context) (int, error) {
+	objects, err := s.backend.List(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list artifacts: %w", err)
+	}
+
+	now := s.now()
+	removed := 0
+	for _, obj := range objects {
+		if strings.HasPrefix(obj.Name, tempPrefix) {
+			// A temporary file whose write was cut short by a crash
+			if now.Sub(obj.ModTime) >= orphanAge {
+				if err := s.backend.Delete(ctx, obj.Name); err != nil {
+					slog.Warn("Failed to remove interrupted upload", "name", obj.Name, "error", err)
+				}
+			}
+			continue
+		}
+		id, ok := strings.CutSuffix(obj.Name, dataExt)
+		if !ok || !idPattern.MatchString(id) {
+			continue
+		}
+		a, err := s.read(ctx, id)
+		switch {
+		case err == nil && now.Before(a.ExpiresAt):
+			continue
+		case errors.Is(err, ErrNotFound) && now.Sub(obj.ModTime) < orphanAge:
+			// The metadata may still be on its way
+			continue
+		case err != nil && !errors.Is(err, ErrNotFound):
+			slog.Warn("Failed to read artifact metadata", "id", id, "error", err)
+			continue
+		}
+
+		if err := s.backend.Delete(ctx, id+metaExt); err != nil {
+			slog.Warn("Failed to remove expired artifact", "id", id, "error", err)
+			continue
+		}
+		if err := s.backend.Delete(ctx, id+dataExt); err != nil {
+			slog.Warn("Failed to remove expired artifact", "id", id, "error", err)
+			continue
+		}
+		s.release(obj.Size)
+		removed++
+	}
+	return removed, nil
+}
+
+// RunJanitor calls Sweep every interval until ctx is done
+func (s *Store) RunJanitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			removed, err := s.Sweep(ctx)
+			if err != nil {
+				slog.Error("Artifact cleanup failed", "error", err)
+			} else if removed > 0 {
+				slog.Info("Removed expired artifacts", "count", removed, "bytes_used", s.Used())
+			}
+		}
+	}
+}
+
+// reserve claims size bytes of the storage cap for a write
+func (s *Store) reserve(size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxBytes > 0 && s.used+size > s.maxBytes {
+		return fmt.Errorf("%w: %d of %d bytes used, artifact is %d bytes", ErrStorageFull, s.used, s.maxBytes, size)
+	}
+	s.used += size
+	metrics.ArtifactStorageBytes.Set(float64(s.used))
+	return nil
+}
+
+// release returns size bytes to the storage cap
+func (s *Store) release(size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.used = max(s.used-size, 0)
+	metrics.ArtifactStorageBytes.Set(float64(s.used))
+}
+
+// read loads an artifact's metadata
+func (s *Store) read(ctx context.Context, id string) (*models.Artifact, error) {
+	obj, err := s.backend.Get(ctx, id+metaExt)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read artifact: %w", err)
+	}
+	defer obj.Close()
+
+	var a models.Artifact
+	if err := json.NewDecoder(obj).Decode(&a); err != nil {
+		return nil, fmt.Errorf("failed to read artifact: %w", err)
+	}
+	return &a, nil
+}
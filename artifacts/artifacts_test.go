@@ -0,0 +1,198 @@
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+func newTestStore(t *testing.T, maxBytes int64) *Store {
+	t.Helper()
+	store, err := New(context.Background(), Config{Dir: t.TempDir(), TTL: time.Hour, MaxBytes: maxBytes, SigningKey: []byte("key")})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	return store
+}
+
+// storeDir returns the directory of a store on the filesystem backend
+func storeDir(store *Store) string {
+	return store.backend.(*filesystemBackend).dir
+}
+
+// put stores data with just a file name, failing the test on error
+func put(t *testing.T, store *Store, data, filename string) *models.Artifact {
+	t.Helper()
+	a, err := store.Put(context.Background(), []byte(data), models.Artifact{Filename: filename})
+	if err != nil {
+		t.Fatalf("Failed to store %s: %v", filename, err)
+	}
+	return a
+}
+
+func TestPutOpen(t *testing.T) {
+	store := newTestStore(t, 0)
+
+	ctx := context.Background()
+
+	a, err := store.Put(ctx, []byte("solid cube"), models.Artifact{
+		Filename:      "model.stl",
+		ContentType:   "application/octet-stream",
+		Format:        "stl_ascii",
+		SourceHash:    "sha256:abc",
+		Parameters:    map[string]any{"size": 10.0},
+		RenderSeconds: 1.5,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if a.Size != 10 || a.Filename != "model.stl" || a.ExpiresAt.Sub(a.CreatedAt) != time.Hour {
+		t.Errorf("Expected 10 byte model.stl valid for 1h, got %+v", a)
+	}
+
+	got, f, err := store.Open(ctx, a.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer f.Close()
+	data, _ := io.ReadAll(f)
+	if string(data) != "solid cube" || got.ContentType != "application/octet-stream" {
+		t.Errorf("Expected stored content and metadata, got %q %+v", data, got)
+	}
+	if got.Format != "stl_ascii" || got.SourceHash != "sha256:abc" || got.RenderSeconds != 1.5 || got.Parameters["size"] != 10.0 {
+		t.Errorf("Expected render metadata to be kept, got %+v", got)
+	}
+
+	for _, id := range []string{"0123456789abcdef0123456789abcdef", "../etc/passwd", ""} {
+		if _, _, err := store.Open(ctx, id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for %q, got %v", id, err)
+		}
+	}
+}
+
+func TestPut_StorageCap(t *testing.T) {
+	store := newTestStore(t, 10)
+
+	ctx := context.Background()
+
+	put(t, store, "123456", "a.stl")
+	if _, err := store.Put(ctx, []byte("123456"), models.Artifact{Filename: "b.stl"}); !errors.Is(err, ErrStorageFull) {
+		t.Errorf("Expected ErrStorageFull, got %v", err)
+	}
+	if _, err := store.Put(ctx, []byte("1234"), models.Artifact{Filename: "c.stl"}); err != nil {
+		t.Errorf("Expected artifact filling the cap to fit, got %v", err)
+	}
+
+	// Reopening the directory counts what is already stored
+	reopened, err := New(ctx, Config{Dir: storeDir(store), MaxBytes: 10, SigningKey: []byte("key")})
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	if reopened.Used() != 10 {
+		t.Errorf("Expected 10 bytes used, got %d", reopened.Used())
+	}
+}
+
+func TestSweep(t *testing.T) {
+	store := newTestStore(t, 0)
+	ctx := context.Background()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	old := put(t, store, "old", "old.stl")
+	now = now.Add(30 * time.Minute)
+	fresh := put(t, store, "fresh", "fresh.stl")
+	// Data left behind by an interrupted write
+	orphan := "fedcba9876543210fedcba9876543210"
+	os.WriteFile(filepath.Join(storeDir(store), orphan+dataExt), []byte("x"), 0644)
+	store.used++
+	// Temporary files left by a crash mid-write
+	upload := filepath.Join(storeDir(store), tempPrefix+"123")
+	os.WriteFile(upload, []byte("partial"), 0644)
+	os.Chtimes(upload, now, now)
+	recent := filepath.Join(storeDir(store), tempPrefix+"456")
+	os.WriteFile(recent, []byte("partial"), 0644)
+
+	now = now.Add(45 * time.Minute)
+	os.Chtimes(recent, now, now)
+	if _, _, err := store.Open(ctx, old.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected expired artifact to be unavailable, got %v", err)
+	}
+
+	removed, err := store.Sweep(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("Expected 2 removed, got %d", removed)
+	}
+	if store.Used() != fresh.Size {
+		t.Errorf("Expected %d bytes used, got %d", fresh.Size, store.Used())
+	}
+	if _, err := os.Stat(filepath.Join(storeDir(store), old.ID+dataExt)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected expired data to be deleted, got %v", err)
+	}
+	if _, f, err := store.Open(ctx, fresh.ID); err != nil {
+		t.Errorf("Expected unexpired artifact to be kept, got %v", err)
+	} else {
+		f.Close()
+	}
+	if _, err := os.Stat(upload); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected stale temporary file to be deleted, got %v", err)
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Errorf("Expected recent temporary file to be kept, got %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	store := newTestStore(t, 0)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	id := "0123456789abcdef0123456789abcdef"
+	expires := now.Add(time.Hour)
+	unix := strconv.FormatInt(expires.Unix(), 10)
+	sig := store.Sign(id, expires)
+
+	other, _ := New(context.Background(), Config{Dir: t.TempDir(), SigningKey: []byte("other")})
+
+	tests := []struct {
+		name    string
+		id      string
+		expires string
+		sig     string
+		wantErr bool
+	}{
+		{"Valid", id, unix, sig, false},
+		{"Other ID", "fedcba9876543210fedcba9876543210", unix, sig, true},
+		{"Extended expiry", id, strconv.FormatInt(expires.Unix()+3600, 10), sig, true},
+		{"Other key", id, unix, other.Sign(id, expires), true},
+		{"Missing signature", id, unix, "", true},
+		{"Bad expiry", id, "soon", sig, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := store.Verify(tt.id, tt.expires, tt.sig)
+			if tt.wantErr != (err != nil) {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil && !errors.Is(err, ErrBadSignature) {
+				t.Errorf("Expected ErrBadSignature, got %v", err)
+			}
+		})
+	}
+
+	now = expires
+	if err := store.Verify(id, unix, sig); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected expired link to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// BackendFilesystem keeps artifacts in a local directory
+	BackendFilesystem = "filesystem"
+	// BackendS3 keeps artifacts in an S3-compatible bucket
+	BackendS3 = "s3"
+
+	// tempPrefix starts the names of files being written by the filesystem
+	// backend
+	tempPrefix = ".upload-"
+)
+
+// Backend stores artifact objects by name
+type Backend interface {
+	// Put stores data under name, replacing any existing object
+	Put(ctx context.Context, name string, data []byte, contentType string) error
+	// Get opens the named object, returning ErrNotFound if it doesn't exist
+	Get(ctx context.Context, name string) (io.ReadSeekCloser, error)
+	// Delete removes the named object; missing objects are not an error
+	Delete(ctx context.Context, name string) error
+	// List describes every stored object
+	List(ctx context.Context) ([]ObjectInfo, error)
+}
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// filesystemBackend stores objects as files in a directory
+type filesystemBackend struct {
+	dir string
+}
+
+// newFilesystemBackend creates dir if needed and stores objects in it
+func newFilesystemBackend(dir string) (*filesystemBackend, error) {
+	if dir == "" {
+		return nil, errors.New("artifact directory must not be empty")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create artifact directory: %w", err)
+	}
+	return &filesystemBackend{dir: dir}, nil
+}
+
+func (b *filesystemBackend) Put(ctx context.Context, name string, data []byte, contentType string) error {
+	// Write to a temporary file first so readers never see partial objects
+	f, err := os.CreateTemp(b.dir, tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	_, writeErr := f.Write(data)
+	if err := errors.Join(writeErr, f.Close()); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), filepath.Join(b.dir, name)); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+func (b *filesystemBackend) Get(ctx context.Context, name string) (io.ReadSeekCloser, error) {
+	f, err := os.Open(filepath.Join(b.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (b *filesystemBackend) Delete(ctx context.Context, name string) error {
+	err := os.Remove(filepath.Join(b.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (b *filesystemBackend) List(ctx context.Context) ([]ObjectInfo, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]ObjectInfo, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		objects = append(objects, ObjectInfo{Name: e.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return objects, nil
+}
@@ -0,0 +1,114 @@
+package artifacts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config contains the settings of an S3-compatible bucket
+type S3Config struct {
+	// Endpoint is the service URL, e.g. https://s3.eu-central-1.amazonaws.com
+	// or http://minio:9000
+	Endpoint string
+	// Region is the bucket's region ("" lets the client discover it)
+	Region string
+	// Bucket holds the artifacts
+	Bucket string
+	// Prefix is prepended to every object name, e.g. "artifacts/"
+	Prefix string
+	// AccessKeyID and SecretAccessKey are static credentials; when empty,
+	// credentials come from the AWS environment variables or instance role
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// s3Backend stores objects in an S3-compatible bucket
+type s3Backend struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// newS3Backend creates a client for the configured bucket. Buckets are
+// addressed by path, which every S3-compatible service supports.
+func newS3Backend(cfg S3Config) (*s3Backend, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("artifact bucket must not be empty")
+	}
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("artifact S3 endpoint must be an http(s) URL, got %q", cfg.Endpoint)
+	}
+
+	creds := credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, "")
+	if cfg.AccessKeyID == "" {
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.IAM{},
+		})
+	}
+	client, err := minio.New(u.Host, &minio.Options{
+		Creds:        creds,
+		Secure:       u.Scheme == "https",
+		Region:       cfg.Region,
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+	return &s3Backend{client: client, bucket: cfg.Bucket, prefix: cfg.Prefix}, nil
+}
+
+func (b *s3Backend) Put(ctx context.Context, name string, data []byte, contentType string) error {
+	_, err := b.client.PutObject(ctx, b.bucket, b.prefix+name, bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+func (b *s3Backend) Get(ctx context.Context, name string) (io.ReadSeekCloser, error) {
+	obj, err := b.client.GetObject(ctx, b.bucket, b.prefix+name, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	// GetObject is lazy; Stat makes the request so missing objects fail here
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		return nil, s3Error(err)
+	}
+	return obj, nil
+}
+
+func (b *s3Backend) Delete(ctx context.Context, name string) error {
+	return b.client.RemoveObject(ctx, b.bucket, b.prefix+name, minio.RemoveObjectOptions{})
+}
+
+func (b *s3Backend) List(ctx context.Context) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	for obj := range b.client.ListObjects(ctx, b.bucket, minio.ListObjectsOptions{Prefix: b.prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		name := strings.TrimPrefix(obj.Key, b.prefix)
+		if strings.Contains(name, "/") {
+			continue
+		}
+		objects = append(objects, ObjectInfo{Name: name, Size: obj.Size, ModTime: obj.LastModified})
+	}
+	return objects, nil
+}
+
+// s3Error maps missing objects to ErrNotFound
+func s3Error(err error) error {
+	if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+		return ErrNotFound
+	}
+	return err
+}
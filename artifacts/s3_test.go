@@ -0,0 +1,259 @@
+package artifacts
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+// fakeS3 is an in-memory stand-in for an S3-compatible service such as MinIO,
+// implementing the object calls the s3 backend makes on a single bucket
+type fakeS3 struct {
+	bucket string
+
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+}
+
+func newFakeS3(t *testing.T, bucket string) (*fakeS3, string) {
+	t.Helper()
+	s := &fakeS3{bucket: bucket, objects: make(map[string]fakeObject)}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return s, server.URL
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != s.bucket {
+		s.error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case key == "" && r.Method == http.MethodGet && r.URL.Query().Has("location"):
+		w.Write([]byte(`<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">us-east-1</LocationConstraint>`))
+	case key == "" && r.Method == http.MethodGet:
+		s.list(w, r.URL.Query().Get("prefix"))
+	case r.Method == http.MethodPut:
+		data, err := readPayload(r)
+		if err != nil {
+			s.error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		s.objects[key] = fakeObject{data: data, contentType: r.Header.Get("Content-Type"), modTime: time.Now().UTC()}
+		w.Header().Set("ETag", `"`+strconv.Itoa(len(data))+`"`)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		obj, ok := s.objects[key]
+		if !ok {
+			s.error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Header().Set("ETag", `"`+strconv.Itoa(len(obj.data))+`"`)
+		http.ServeContent(w, r, key, obj.modTime, bytes.NewReader(obj.data))
+	case r.Method == http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// list answers a ListObjectsV2 request in a single page
+func (s *fakeS3) list(w http.ResponseWriter, prefix string) {
+	type content struct {
+		Key          string
+		LastModified string
+		Size         int64
+		ETag         string
+	}
+	result := struct {
+		XMLName  xml.Name `xml:"ListBucketResult"`
+		Name     string
+		Prefix   string
+		KeyCount int
+		MaxKeys  int
+		Contents []content
+	}{Name: s.bucket, Prefix: prefix, MaxKeys: 1000}
+
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		obj := s.objects[key]
+		result.Contents = append(result.Contents, content{
+			Key:          key,
+			LastModified: obj.modTime.Format(time.RFC3339),
+			Size:         int64(len(obj.data)),
+			ETag:         `"` + strconv.Itoa(len(obj.data)) + `"`,
+		})
+	}
+	result.KeyCount = len(keys)
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+func (s *fakeS3) error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func (s *fakeS3) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// readPayload returns an upload's content, decoding aws-chunked bodies
+func readPayload(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var data []byte
+	body := bufio.NewReader(r.Body)
+	for {
+		header, err := body.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(header), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		chunk := make([]byte, size+2)
+		if _, err := io.ReadFull(body, chunk); err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return data, nil
+		}
+		data = append(data, chunk[:size]...)
+	}
+}
+
+func newS3TestStore(t *testing.T, endpoint string) *Store {
+	t.Helper()
+	store, err := New(context.Background(), Config{
+		Backend: BackendS3,
+		S3: S3Config{
+			Endpoint:        endpoint,
+			Region:          "us-east-1",
+			Bucket:          "renders",
+			Prefix:          "artifacts/",
+			AccessKeyID:     "minioadmin",
+			SecretAccessKey: "minioadmin",
+		},
+		TTL:        time.Hour,
+		SigningKey: []byte("key"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	return store
+}
+
+func TestS3Store(t *testing.T) {
+	fake, endpoint := newFakeS3(t, "renders")
+	store := newS3TestStore(t, endpoint)
+	ctx := context.Background()
+
+	a, err := store.Put(ctx, []byte("solid cube"), models.Artifact{Filename: "model.stl", ContentType: "model/stl", Format: "stl_ascii"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	wantKeys := []string{"artifacts/" + a.ID + ".bin", "artifacts/" + a.ID + ".json"}
+	if keys := fake.keys(); strings.Join(keys, ",") != strings.Join(wantKeys, ",") {
+		t.Errorf("Expected objects %v, got %v", wantKeys, keys)
+	}
+
+	got, content, err := store.Open(ctx, a.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer content.Close()
+	data, _ := io.ReadAll(content)
+	if string(data) != "solid cube" || got.Format != "stl_ascii" {
+		t.Errorf("Expected stored content and metadata, got %q %+v", data, got)
+	}
+	if _, err := content.Seek(6, io.SeekStart); err != nil {
+		t.Fatalf("Expected seekable content, got %v", err)
+	}
+	if rest, _ := io.ReadAll(content); string(rest) != "cube" {
+		t.Errorf("Expected %q after seeking, got %q", "cube", rest)
+	}
+
+	if _, _, err := store.Open(ctx, "0123456789abcdef0123456789abcdef"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	// A new store counts the bucket's contents towards the cap
+	if reopened := newS3TestStore(t, endpoint); reopened.Used() != a.Size {
+		t.Errorf("Expected %d bytes used, got %d", a.Size, reopened.Used())
+	}
+
+	store.now = func() time.Time { return a.ExpiresAt }
+	removed, err := store.Sweep(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if removed != 1 || len(fake.keys()) != 0 {
+		t.Errorf("Expected expired artifact to be deleted, got %d removed and objects %v", removed, fake.keys())
+	}
+}
+
+func TestNew_S3Config(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     S3Config
+		wantErr string
+	}{
+		{"Missing bucket", S3Config{Endpoint: "http://minio:9000"}, "artifact bucket must not be empty"},
+		{"Missing scheme", S3Config{Endpoint: "minio:9000", Bucket: "renders"}, "must be an http(s) URL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(context.Background(), Config{Backend: BackendS3, S3: tt.cfg, SigningKey: []byte("key")})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,854 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-yaml"
+	"github.com/stevexciv/scad-server/artifacts"
+	"github.com/stevexciv/scad-server/designs"
+	"github.com/stevexciv/scad-server/history"
+	"github.com/stevexciv/scad-server/logging"
+	"github.com/stevexciv/scad-server/mesh"
+	"github.com/stevexciv/scad-server/ratelimit"
+	"github.com/stevexciv/scad-server/services"
+	"github.com/stevexciv/scad-server/sweep"
+	"github.com/stevexciv/scad-server/webhooks"
+)
+
+// Config is the complete server configuration.
+//
+// Settings are resolved with the following precedence, highest first:
+// command-line flags, SCADSRV_* environment variables, the YAML config file
+// (--config or SCADSRV_CONFIG), then built-in defaults.
+type Config struct {
+	Server       ServerConfig       `yaml:"server"`
+	OpenSCAD     OpenSCADConfig     `yaml:"openscad"`
+	Images       ImagesConfig       `yaml:"images"`
+	Logging      LoggingConfig      `yaml:"logging"`
+	RateLimit    RateLimitConfig    `yaml:"rate_limit"`
+	Auth         AuthConfig         `yaml:"auth"`
+	Tracing      TracingConfig      `yaml:"tracing"`
+	Health       HealthConfig       `yaml:"health"`
+	Fonts        FontsConfig        `yaml:"fonts"`
+	Assets       AssetsConfig       `yaml:"assets"`
+	RenderURL    RenderURLConfig    `yaml:"render_url"`
+	Artifacts    ArtifactsConfig    `yaml:"artifacts"`
+	Webhooks     WebhooksConfig     `yaml:"webhooks"`
+	History      HistoryConfig      `yaml:"history"`
+	Designs      DesignsConfig      `yaml:"designs"`
+	Templates    TemplatesConfig    `yaml:"templates"`
+	Sweep        SweepConfig        `yaml:"sweep"`
+	Printability PrintabilityConfig `yaml:"printability"`
+}
+
+// ServerConfig contains HTTP server settings
+type ServerConfig struct {
+	Port                int           `yaml:"port"`
+	GinMode             string        `yaml:"gin_mode"`
+	ShutdownGracePeriod time.Duration `yaml:"shutdown_grace_period"`
+	TrustedProxies      []string      `yaml:"trusted_proxies"`
+}
+
+// OpenSCADConfig contains render settings
+type OpenSCADConfig struct {
+	Binary               string            `yaml:"binary"`
+	Versions             map[string]string `yaml:"versions"`
+	DefaultVersion       string            `yaml:"default_version"`
+	Timeout              time.Duration     `yaml:"timeout"`
+	TempDir              string            `yaml:"temp_dir"`
+	MaxConcurrentRenders int               `yaml:"max_concurrent_renders"`
+	Backend              string            `yaml:"backend"`
+	Features             []string          `yaml:"features"`
+	AllowedFeatures      []string          `yaml:"allowed_features"`
+}
+
+// ImagesConfig contains PNG conversion settings
+type ImagesConfig struct {
+	WebPQuality int `yaml:"webp_quality"`
+	AVIFQuality int `yaml:"avif_quality"`
+}
+
+// LoggingConfig contains log output settings
+type LoggingConfig struct {
+	Format string `yaml:"format"`
+	Level  string `yaml:"level"`
+}
+
+// RateLimitConfig contains per-client quota settings
+type RateLimitConfig struct {
+	RequestsPerMinute   int           `yaml:"requests_per_minute"`
+	RenderBudgetSeconds float64       `yaml:"render_budget_seconds"`
+	RenderBudgetPeriod  time.Duration `yaml:"render_budget_period"`
+}
+
+// AuthConfig contains the API keys clients identify with
+type AuthConfig struct {
+	APIKeys   []string `yaml:"api_keys"`
+	AdminKeys []string `yaml:"admin_keys"`
+}
+
+// TracingConfig contains OpenTelemetry settings
+type TracingConfig struct {
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
+}
+
+// HealthConfig contains readiness check settings
+type HealthConfig struct {
+	CheckTimeout     time.Duration `yaml:"check_timeout"`
+	MinFreeDiskMB    int           `yaml:"min_free_disk_mb"`
+	MaxQueuedRenders int           `yaml:"max_queued_renders"`
+	SelfTest         bool          `yaml:"self_test"`
+	SelfTestInterval time.Duration `yaml:"self_test_interval"`
+}
+
+// FontsConfig contains font management settings
+type FontsConfig struct {
+	Dir         string `yaml:"dir"`
+	MaxUploadMB int    `yaml:"max_upload_mb"`
+	MaxTotalMB  int    `yaml:"max_total_mb"`
+	Strict      bool   `yaml:"strict"`
+}
+
+// AssetsConfig contains limits for files uploaded with a render request
+type AssetsConfig struct {
+	MaxSizeMB   int `yaml:"max_size_mb"`
+	MaxCount    int `yaml:"max_count"`
+	MaxSourceMB int `yaml:"max_source_mb"`
+}
+
+// RenderURLConfig contains settings for the GET render URL endpoint
+type RenderURLConfig struct {
+	Secret string        `yaml:"secret"`
+	MaxAge time.Duration `yaml:"max_age"`
+}
+
+// ArtifactsConfig contains settings for stored renders and their download links
+type ArtifactsConfig struct {
+	Backend         string            `yaml:"backend"`
+	Dir             string            `yaml:"dir"`
+	S3              ArtifactsS3Config `yaml:"s3"`
+	TTL             time.Duration     `yaml:"ttl"`
+	MaxStorageMB    int               `yaml:"max_storage_mb"`
+	SigningKey      string            `yaml:"signing_key"`
+	JanitorInterval time.Duration     `yaml:"janitor_interval"`
+	BaseURL         string            `yaml:"base_url"`
+}
+
+// ArtifactsS3Config contains the S3-compatible bucket used by the s3
+// artifact backend
+type ArtifactsS3Config struct {
+	Endpoint        string `yaml:"endpoint"`
+	Region          string `yaml:"region"`
+	Bucket          string `yaml:"bucket"`
+	Prefix          string `yaml:"prefix"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+}
+
+// WebhooksConfig contains settings for render completion callbacks
+type WebhooksConfig struct {
+	AllowedHosts   []string      `yaml:"allowed_hosts"`
+	Secret         string        `yaml:"secret"`
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	Timeout        time.Duration `yaml:"timeout"`
+	MaxPending     int           `yaml:"max_pending"`
+	DeadLetterFile string        `yaml:"dead_letter_file"`
+}
+
+// HistoryConfig contains settings for the render history database
+type HistoryConfig struct {
+	DB            string        `yaml:"db"`
+	Retention     time.Duration `yaml:"retention"`
+	MaxEntries    int           `yaml:"max_entries"`
+	PruneInterval time.Duration `yaml:"prune_interval"`
+}
+
+// DesignsConfig contains settings for saved designs
+type DesignsConfig struct {
+	DB        string `yaml:"db"`
+	MaxSizeMB int    `yaml:"max_size_mb"`
+}
+
+// TemplatesConfig contains settings for the template catalog
+type TemplatesConfig struct {
+	Dir string `yaml:"dir"`
+}
+
+// SweepConfig contains parameter sweep settings
+type SweepConfig struct {
+	MaxVariants int `yaml:"max_variants"`
+}
+
+// PrintabilityConfig contains the default printer settings of printability
+// reports
+type PrintabilityConfig struct {
+	OverhangAngle    float64 `yaml:"overhang_angle"`
+	NozzleWidth      float64 `yaml:"nozzle_width"`
+	MinWallThickness float64 `yaml:"min_wall_thickness"`
+	BuildVolumeX     float64 `yaml:"build_volume_x"`
+	BuildVolumeY     float64 `yaml:"build_volume_y"`
+	BuildVolumeZ     float64 `yaml:"build_volume_z"`
+}
+
+// Default returns the built-in configuration
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:                8000,
+			GinMode:             gin.ReleaseMode,
+			ShutdownGracePeriod: 30 * time.Second,
+		},
+		OpenSCAD: OpenSCADConfig{
+			Binary:               "openscad",
+			Timeout:              5 * time.Minute,
+			MaxConcurrentRenders: runtime.NumCPU(),
+			AllowedFeatures:      slices.Clone(services.DefaultAllowedFeatures),
+		},
+		Images: ImagesConfig{
+			WebPQuality: 80,
+			AVIFQuality: 60,
+		},
+		Logging: LoggingConfig{
+			Format: "text",
+			Level:  "info",
+		},
+		RateLimit: RateLimitConfig{
+			RenderBudgetPeriod: time.Hour,
+		},
+		Health: HealthConfig{
+			CheckTimeout:     5 * time.Second,
+			MinFreeDiskMB:    100,
+			SelfTestInterval: 5 * time.Minute,
+		},
+		Fonts: FontsConfig{
+			MaxUploadMB: 10,
+			MaxTotalMB:  100,
+		},
+		Assets: AssetsConfig{
+			MaxSizeMB:   services.DefaultMaxAssetBytes >> 20,
+			MaxCount:    services.DefaultMaxAssets,
+			MaxSourceMB: 10,
+		},
+		RenderURL: RenderURLConfig{
+			MaxAge: 24 * time.Hour,
+		},
+		Artifacts: ArtifactsConfig{
+			Backend:         artifacts.BackendFilesystem,
+			TTL:             artifacts.DefaultTTL,
+			MaxStorageMB:    1024,
+			JanitorInterval: 5 * time.Minute,
+		},
+		Webhooks: WebhooksConfig{
+			MaxAttempts:    webhooks.DefaultMaxAttempts,
+			InitialBackoff: webhooks.DefaultInitialBackoff,
+			MaxBackoff:     webhooks.DefaultMaxBackoff,
+			Timeout:        webhooks.DefaultTimeout,
+			MaxPending:     webhooks.DefaultMaxPending,
+		},
+		History: HistoryConfig{
+			Retention:     30 * 24 * time.Hour,
+			PruneInterval: time.Hour,
+		},
+		Designs: DesignsConfig{
+			MaxSizeMB: designs.DefaultMaxBytes >> 20,
+		},
+		Sweep: SweepConfig{
+			MaxVariants: sweep.DefaultMaxVariants,
+		},
+		Printability: PrintabilityConfig{
+			OverhangAngle:    mesh.DefaultPrintOptions.OverhangAngle,
+			NozzleWidth:      mesh.DefaultPrintOptions.NozzleWidth,
+			MinWallThickness: mesh.DefaultPrintOptions.MinWallThickness,
+			BuildVolumeX:     mesh.DefaultPrintOptions.BuildVolume[0],
+			BuildVolumeY:     mesh.DefaultPrintOptions.BuildVolume[1],
+			BuildVolumeZ:     mesh.DefaultPrintOptions.BuildVolume[2],
+		},
+	}
+}
+
+// setting binds one configuration value to its flag and environment variable
+type setting struct {
+	flag   string
+	env    string
+	usage  string
+	target any
+}
+
+// define registers the setting's flag on fs; boolean settings may be given
+// without a value
+func (st setting) define(fs *flag.FlagSet, fn func(string) error) {
+	usage := fmt.Sprintf("%s (env %s)", st.usage, st.env)
+	if _, ok := st.target.(*bool); ok {
+		fs.BoolFunc(st.flag, usage, fn)
+		return
+	}
+	fs.Func(st.flag, usage, fn)
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"port", "SCADSRV_PORT", "HTTP port to listen on", &c.Server.Port},
+		{"gin-mode", "SCADSRV_GIN_MODE", "Gin mode: debug, release or test", &c.Server.GinMode},
+		{"shutdown-grace-period", "SCADSRV_SHUTDOWN_GRACE_PERIOD", "time to let in-flight renders finish on SIGTERM before killing them", &c.Server.ShutdownGracePeriod},
+		{"trusted-proxies", "SCADSRV_TRUSTED_PROXIES", "comma-separated proxy IPs or CIDRs whose X-Forwarded-For is trusted for client IPs (empty trusts none)", &c.Server.TrustedProxies},
+		{"openscad-binary", "SCADSRV_OPENSCAD_BINARY", "OpenSCAD executable name or path, used when no versions are configured", &c.OpenSCAD.Binary},
+		{"openscad-versions", "SCADSRV_OPENSCAD_VERSIONS", "named OpenSCAD installations as name=path,name=path", &c.OpenSCAD.Versions},
+		{"openscad-default-version", "SCADSRV_OPENSCAD_DEFAULT_VERSION", "installation used when a request doesn't set openscad_version", &c.OpenSCAD.DefaultVersion},
+		{"render-timeout", "SCADSRV_RENDER_TIMEOUT", "maximum duration of one OpenSCAD invocation", &c.OpenSCAD.Timeout},
+		{"temp-dir", "SCADSRV_TEMP_DIR", "directory for render working directories (default: OS temp dir)", &c.OpenSCAD.TempDir},
+		{"max-concurrent-renders", "SCADSRV_MAX_CONCURRENT_RENDERS", "OpenSCAD processes allowed to run at once", &c.OpenSCAD.MaxConcurrentRenders},
+		{"backend", "SCADSRV_BACKEND", "geometry backend used when a request doesn't set one: cgal or manifold (default: OpenSCAD's own)", &c.OpenSCAD.Backend},
+		{"features", "SCADSRV_FEATURES", "comma-separated experimental features enabled when a request doesn't set any", &c.OpenSCAD.Features},
+		{"allowed-features", "SCADSRV_ALLOWED_FEATURES", "comma-separated experimental features requests may enable", &c.OpenSCAD.AllowedFeatures},
+		{"webp-quality", "SCADSRV_WEBP_QUALITY", "WebP encoder quality (0-100)", &c.Images.WebPQuality},
+		{"avif-quality", "SCADSRV_AVIF_QUALITY", "AVIF encoder quality (0-100)", &c.Images.AVIFQuality},
+		{"log-format", "SCADSRV_LOG_FORMAT", "log format: text or json", &c.Logging.Format},
+		{"log-level", "SCADSRV_LOG_LEVEL", "log level: debug, info, warn or error", &c.Logging.Level},
+		{"rate-limit-rpm", "SCADSRV_RATE_LIMIT_RPM", "requests per minute per client (0 for unlimited)", &c.RateLimit.RequestsPerMinute},
+		{"render-budget-seconds", "SCADSRV_RENDER_BUDGET_SECONDS", "OpenSCAD seconds per client per budget period (0 for unlimited)", &c.RateLimit.RenderBudgetSeconds},
+		{"render-budget-period", "SCADSRV_RENDER_BUDGET_PERIOD", "length of the render budget period", &c.RateLimit.RenderBudgetPeriod},
+		{"api-keys", "SCADSRV_API_KEYS", "comma-separated API keys clients may identify with through X-API-Key; other callers are limited by IP", &c.Auth.APIKeys},
+		{"admin-keys", "SCADSRV_ADMIN_KEYS", "comma-separated API keys allowed to upload and delete fonts (empty disables those endpoints)", &c.Auth.AdminKeys},
+		{"otlp-endpoint", "SCADSRV_OTLP_ENDPOINT", "OTLP/HTTP endpoint for trace export (empty disables export)", &c.Tracing.OTLPEndpoint},
+		{"health-check-timeout", "SCADSRV_HEALTH_CHECK_TIMEOUT", "time each readiness check may take", &c.Health.CheckTimeout},
+		{"health-min-free-disk-mb", "SCADSRV_HEALTH_MIN_FREE_DISK_MB", "free space required in the temp dir for readiness, in MiB", &c.Health.MinFreeDiskMB},
+		{"health-max-queued-renders", "SCADSRV_HEALTH_MAX_QUEUED_RENDERS", "renders waiting for a slot before readiness fails (0 for twice max-concurrent-renders)", &c.Health.MaxQueuedRenders},
+		{"health-self-test", "SCADSRV_HEALTH_SELF_TEST", "render a cube as part of the readiness check", &c.Health.SelfTest},
+		{"health-self-test-interval", "SCADSRV_HEALTH_SELF_TEST_INTERVAL", "how long a self-test result is reused", &c.Health.SelfTestInterval},
+		{"font-dir", "SCADSRV_FONT_DIR", "directory for uploaded fonts, made visible to OpenSCAD (empty disables font uploads)", &c.Fonts.Dir},
+		{"font-max-upload-mb", "SCADSRV_FONT_MAX_UPLOAD_MB", "largest font file accepted for upload, in MiB", &c.Fonts.MaxUploadMB},
+		{"font-max-total-mb", "SCADSRV_FONT_MAX_TOTAL_MB", "total size of the uploaded fonts, in MiB", &c.Fonts.MaxTotalMB},
+		{"strict-fonts", "SCADSRV_STRICT_FONTS", "fail renders whose source names a font that isn't installed", &c.Fonts.Strict},
+		{"asset-max-size-mb", "SCADSRV_ASSET_MAX_SIZE_MB", "largest asset a render request may carry, in MiB", &c.Assets.MaxSizeMB},
+		{"max-assets", "SCADSRV_MAX_ASSETS", "number of assets a render request may carry", &c.Assets.MaxCount},
+		{"source-max-size-mb", "SCADSRV_SOURCE_MAX_SIZE_MB", "largest SCAD source a render request may carry, in MiB", &c.Assets.MaxSourceMB},
+		{"render-url-secret", "SCADSRV_RENDER_URL_SECRET", "HMAC key render URLs must be signed with (empty allows unsigned URLs)", &c.RenderURL.Secret},
+		{"render-url-max-age", "SCADSRV_RENDER_URL_MAX_AGE", "how long clients and proxies may cache render URL responses", &c.RenderURL.MaxAge},
+		{"artifact-backend", "SCADSRV_ARTIFACT_BACKEND", "where stored renders are kept: filesystem or s3", &c.Artifacts.Backend},
+		{"artifact-dir", "SCADSRV_ARTIFACT_DIR", "directory for stored renders with the filesystem backend (empty disables artifact storage)", &c.Artifacts.Dir},
+		{"artifact-s3-endpoint", "SCADSRV_ARTIFACT_S3_ENDPOINT", "S3-compatible endpoint URL for the s3 backend", &c.Artifacts.S3.Endpoint},
+		{"artifact-s3-region", "SCADSRV_ARTIFACT_S3_REGION", "bucket region for the s3 backend", &c.Artifacts.S3.Region},
+		{"artifact-s3-bucket", "SCADSRV_ARTIFACT_S3_BUCKET", "bucket for stored renders with the s3 backend (empty disables artifact storage)", &c.Artifacts.S3.Bucket},
+		{"artifact-s3-prefix", "SCADSRV_ARTIFACT_S3_PREFIX", "object name prefix for the s3 backend", &c.Artifacts.S3.Prefix},
+		{"artifact-s3-access-key-id", "SCADSRV_ARTIFACT_S3_ACCESS_KEY_ID", "access key for the s3 backend (default: AWS environment or instance role)", &c.Artifacts.S3.AccessKeyID},
+		{"artifact-s3-secret-access-key", "SCADSRV_ARTIFACT_S3_SECRET_ACCESS_KEY", "secret key for the s3 backend", &c.Artifacts.S3.SecretAccessKey},
+		{"artifact-ttl", "SCADSRV_ARTIFACT_TTL", "how long stored renders and their download links stay valid", &c.Artifacts.TTL},
+		{"artifact-max-storage-mb", "SCADSRV_ARTIFACT_MAX_STORAGE_MB", "total size of stored renders, in MiB (0 for unlimited)", &c.Artifacts.MaxStorageMB},
+		{"artifact-signing-key", "SCADSRV_ARTIFACT_SIGNING_KEY", "HMAC key for download links (default: random, links break on restart)", &c.Artifacts.SigningKey},
+		{"artifact-janitor-interval", "SCADSRV_ARTIFACT_JANITOR_INTERVAL", "how often expired artifacts are deleted", &c.Artifacts.JanitorInterval},
+		{"artifact-base-url", "SCADSRV_ARTIFACT_BASE_URL", "public URL prefix of download links (default: from the request)", &c.Artifacts.BaseURL},
+		{"callback-allowed-hosts", "SCADSRV_CALLBACK_ALLOWED_HOSTS", "comma-separated hosts callback_url may point to, e.g. ci.example.com,*.hooks.example.com (empty disables callbacks)", &c.Webhooks.AllowedHosts},
+		{"callback-secret", "SCADSRV_CALLBACK_SECRET", "HMAC key signing callbacks", &c.Webhooks.Secret},
+		{"callback-max-attempts", "SCADSRV_CALLBACK_MAX_ATTEMPTS", "delivery attempts before a callback is dead-lettered", &c.Webhooks.MaxAttempts},
+		{"callback-initial-backoff", "SCADSRV_CALLBACK_INITIAL_BACKOFF", "wait before the first callback retry, doubling for each further retry", &c.Webhooks.InitialBackoff},
+		{"callback-max-backoff", "SCADSRV_CALLBACK_MAX_BACKOFF", "longest wait between callback retries", &c.Webhooks.MaxBackoff},
+		{"callback-timeout", "SCADSRV_CALLBACK_TIMEOUT", "timeout of each callback delivery attempt", &c.Webhooks.Timeout},
+		{"callback-max-pending", "SCADSRV_CALLBACK_MAX_PENDING", "callbacks rendering or awaiting delivery at once before new ones are rejected with 503", &c.Webhooks.MaxPending},
+		{"callback-dead-letter-file", "SCADSRV_CALLBACK_DEAD_LETTER_FILE", "file receiving a JSON line per undeliverable callback (empty only logs them)", &c.Webhooks.DeadLetterFile},
+		{"history-db", "SCADSRV_HISTORY_DB", "SQLite database recording every export and summary request (empty disables history)", &c.History.DB},
+		{"history-retention", "SCADSRV_HISTORY_RETENTION", "how long history entries are kept (0 keeps them forever)", &c.History.Retention},
+		{"history-max-entries", "SCADSRV_HISTORY_MAX_ENTRIES", "number of history entries kept, oldest deleted first (0 for unlimited)", &c.History.MaxEntries},
+		{"history-prune-interval", "SCADSRV_HISTORY_PRUNE_INTERVAL", "how often old history entries are deleted", &c.History.PruneInterval},
+		{"design-db", "SCADSRV_DESIGN_DB", "SQLite database holding saved designs, which may be the history database (empty disables designs)", &c.Designs.DB},
+		{"design-max-size-mb", "SCADSRV_DESIGN_MAX_SIZE_MB", "total size of the files and assets of a design version, in MiB", &c.Designs.MaxSizeMB},
+		{"template-dir", "SCADSRV_TEMPLATE_DIR", "directory of parametric SCAD templates with customizer annotations (empty disables templates)", &c.Templates.Dir},
+		{"sweep-max-variants", "SCADSRV_SWEEP_MAX_VARIANTS", "parameter sets a sweep request may render", &c.Sweep.MaxVariants},
+		{"printability-overhang-angle", "SCADSRV_PRINTABILITY_OVERHANG_ANGLE", "steepest overhang in degrees from vertical that prints without support", &c.Printability.OverhangAngle},
+		{"printability-nozzle-width", "SCADSRV_PRINTABILITY_NOZZLE_WIDTH", "nozzle width in mm; thinner features are reported as unprintable", &c.Printability.NozzleWidth},
+		{"printability-min-wall-thickness", "SCADSRV_PRINTABILITY_MIN_WALL_THICKNESS", "thinnest wall in mm not reported as thin", &c.Printability.MinWallThickness},
+		{"printability-build-volume-x", "SCADSRV_PRINTABILITY_BUILD_VOLUME_X", "printer build volume width in mm", &c.Printability.BuildVolumeX},
+		{"printability-build-volume-y", "SCADSRV_PRINTABILITY_BUILD_VOLUME_Y", "printer build volume depth in mm", &c.Printability.BuildVolumeY},
+		{"printability-build-volume-z", "SCADSRV_PRINTABILITY_BUILD_VOLUME_Z", "printer build volume height in mm", &c.Printability.BuildVolumeZ},
+	}
+}
+
+// Load resolves the configuration from args (without the program name),
+// environment variables looked up through getenv and the optional config file
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	cfg := Default()
+	settings := cfg.settings()
+
+	// Flags are collected first and applied last so that they take precedence
+	fs := flag.NewFlagSet("scad-server", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	configPath := fs.String("config", getenv("SCADSRV_CONFIG"), "path to a YAML config file (env SCADSRV_CONFIG)")
+	flagValues := make(map[string]string)
+	for _, st := range settings {
+		name := st.flag
+		st.define(fs, func(v string) error {
+			flagValues[name] = v
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configPath != "" {
+		if err := cfg.loadFile(*configPath); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, st := range settings {
+		if v := getenv(st.env); v != "" {
+			if err := setValue(st.target, v); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", st.env, err)
+			}
+		}
+	}
+
+	for _, st := range settings {
+		if v, ok := flagValues[st.flag]; ok {
+			if err := setValue(st.target, v); err != nil {
+				return nil, fmt.Errorf("invalid --%s: %w", st.flag, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Usage writes the flag documentation to w
+func Usage(w io.Writer) {
+	fs := flag.NewFlagSet("scad-server", flag.ContinueOnError)
+	fs.SetOutput(w)
+	fs.String("config", "", "path to a YAML config file (env SCADSRV_CONFIG)")
+	for _, st := range Default().settings() {
+		st.define(fs, func(string) error { return nil })
+	}
+	fs.PrintDefaults()
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := yaml.UnmarshalWithOptions(data, c, yaml.Strict()); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func setValue(target any, v string) error {
+	switch t := target.(type) {
+	case *string:
+		*t = v
+	case *[]string:
+		var list []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*t = list
+	case *map[string]string:
+		m := make(map[string]string)
+		for _, pair := range strings.Split(v, ",") {
+			name, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("expected name=value, got %q", pair)
+			}
+			m[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+		*t = m
+	case *bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*t = b
+	case *int:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*t = n
+	case *float64:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		*t = f
+	case *time.Duration:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*t = d
+	default:
+		return fmt.Errorf("unsupported setting type %T", target)
+	}
+	return nil
+}
+
+// Validate checks that every setting is within its allowed range
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Port >= 1 && c.Server.Port <= 65535, "server.port must be between 1 and 65535, got %d", c.Server.Port)
+	switch c.Server.GinMode {
+	case gin.DebugMode, gin.ReleaseMode, gin.TestMode:
+	default:
+		errs = append(errs, fmt.Errorf("server.gin_mode must be debug, release or test, got %q", c.Server.GinMode))
+	}
+	check(c.Server.ShutdownGracePeriod >= 0, "server.shutdown_grace_period must not be negative, got %s", c.Server.ShutdownGracePeriod)
+	for _, proxy := range c.Server.TrustedProxies {
+		_, _, cidrErr := net.ParseCIDR(proxy)
+		check(cidrErr == nil || net.ParseIP(proxy) != nil, "server.trusted_proxies must be IP addresses or CIDRs, got %q", proxy)
+	}
+
+	check(c.OpenSCAD.Binary != "", "openscad.binary must not be empty")
+	for name, binary := range c.OpenSCAD.Versions {
+		check(name != "", "openscad.versions names must not be empty")
+		check(binary != "", "openscad.versions.%s must name a binary", name)
+	}
+	if len(c.OpenSCAD.Versions) == 0 {
+		check(c.OpenSCAD.DefaultVersion == "" || c.OpenSCAD.DefaultVersion == services.DefaultVersionName,
+			"openscad.default_version %q requires openscad.versions", c.OpenSCAD.DefaultVersion)
+	} else if c.OpenSCAD.DefaultVersion == "" {
+		check(len(c.OpenSCAD.Versions) == 1, "openscad.default_version is required when several versions are configured")
+	} else {
+		_, ok := c.OpenSCAD.Versions[c.OpenSCAD.DefaultVersion]
+		check(ok, "openscad.default_version %q is not one of openscad.versions", c.OpenSCAD.DefaultVersion)
+	}
+	check(c.OpenSCAD.Timeout > 0, "openscad.timeout must be positive, got %s", c.OpenSCAD.Timeout)
+	check(c.OpenSCAD.MaxConcurrentRenders >= 1, "openscad.max_concurrent_renders must be at least 1, got %d", c.OpenSCAD.MaxConcurrentRenders)
+	if c.OpenSCAD.TempDir != "" {
+		info, err := os.Stat(c.OpenSCAD.TempDir)
+		check(err == nil && info.IsDir(), "openscad.temp_dir %q must be an existing directory", c.OpenSCAD.TempDir)
+	}
+	switch c.OpenSCAD.Backend {
+	case "", services.BackendCGAL, services.BackendManifold:
+	default:
+		errs = append(errs, fmt.Errorf("openscad.backend must be %s or %s, got %q", services.BackendCGAL, services.BackendManifold, c.OpenSCAD.Backend))
+	}
+	for _, f := range c.OpenSCAD.Features {
+		check(slices.Contains(c.OpenSCAD.AllowedFeatures, f), "openscad.features: %q is not in openscad.allowed_features", f)
+	}
+
+	check(c.Images.WebPQuality >= 0 && c.Images.WebPQuality <= 100, "images.webp_quality must be between 0 and 100, got %d", c.Images.WebPQuality)
+	check(c.Images.AVIFQuality >= 0 && c.Images.AVIFQuality <= 100, "images.avif_quality must be between 0 and 100, got %d", c.Images.AVIFQuality)
+
+	check(c.Logging.Format == "text" || c.Logging.Format == "json", "logging.format must be text or json, got %q", c.Logging.Format)
+	if _, err := logging.ParseLevel(c.Logging.Level); err != nil {
+		errs = append(errs, fmt.Errorf("logging.level: %w", err))
+	}
+
+	check(c.RateLimit.RequestsPerMinute >= 0, "rate_limit.requests_per_minute must not be negative, got %d", c.RateLimit.RequestsPerMinute)
+	check(c.RateLimit.RenderBudgetSeconds >= 0, "rate_limit.render_budget_seconds must not be negative, got %v", c.RateLimit.RenderBudgetSeconds)
+	check(c.RateLimit.RenderBudgetPeriod > 0, "rate_limit.render_budget_period must be positive, got %s", c.RateLimit.RenderBudgetPeriod)
+
+	if c.Tracing.OTLPEndpoint != "" {
+		u, err := url.Parse(c.Tracing.OTLPEndpoint)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"tracing.otlp_endpoint must be an http(s) URL, got %q", c.Tracing.OTLPEndpoint)
+	}
+
+	check(c.Health.CheckTimeout > 0, "health.check_timeout must be positive, got %s", c.Health.CheckTimeout)
+	check(c.Health.MinFreeDiskMB >= 0, "health.min_free_disk_mb must not be negative, got %d", c.Health.MinFreeDiskMB)
+	check(c.Health.MaxQueuedRenders >= 0, "health.max_queued_renders must not be negative, got %d", c.Health.MaxQueuedRenders)
+	check(c.Health.SelfTestInterval > 0, "health.self_test_interval must be positive, got %s", c.Health.SelfTestInterval)
+
+	if c.Fonts.Dir != "" {
+		info, err := os.Stat(c.Fonts.Dir)
+		check(err == nil && info.IsDir(), "fonts.dir %q must be an existing directory", c.Fonts.Dir)
+	}
+	check(c.Fonts.MaxUploadMB >= 1, "fonts.max_upload_mb must be at least 1, got %d", c.Fonts.MaxUploadMB)
+	check(c.Fonts.MaxTotalMB >= c.Fonts.MaxUploadMB,
+		"fonts.max_total_mb must be at least fonts.max_upload_mb (%d), got %d", c.Fonts.MaxUploadMB, c.Fonts.MaxTotalMB)
+
+	check(c.Assets.MaxSizeMB >= 1, "assets.max_size_mb must be at least 1, got %d", c.Assets.MaxSizeMB)
+	check(c.Assets.MaxCount >= 0, "assets.max_count must not be negative, got %d", c.Assets.MaxCount)
+	check(c.Assets.MaxSourceMB >= 1, "assets.max_source_mb must be at least 1, got %d", c.Assets.MaxSourceMB)
+
+	check(c.RenderURL.MaxAge >= 0, "render_url.max_age must not be negative, got %s", c.RenderURL.MaxAge)
+
+	check(c.Artifacts.Backend == artifacts.BackendFilesystem || c.Artifacts.Backend == artifacts.BackendS3,
+		"artifacts.backend must be %s or %s, got %q", artifacts.BackendFilesystem, artifacts.BackendS3, c.Artifacts.Backend)
+	if c.Artifacts.Backend == artifacts.BackendS3 && c.Artifacts.S3.Bucket != "" {
+		u, err := url.Parse(c.Artifacts.S3.Endpoint)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"artifacts.s3.endpoint must be an http(s) URL, got %q", c.Artifacts.S3.Endpoint)
+		check((c.Artifacts.S3.AccessKeyID == "") == (c.Artifacts.S3.SecretAccessKey == ""),
+			"artifacts.s3.access_key_id and artifacts.s3.secret_access_key must be set together")
+	}
+	check(c.Artifacts.TTL > 0, "artifacts.ttl must be positive, got %s", c.Artifacts.TTL)
+	check(c.Artifacts.MaxStorageMB >= 0, "artifacts.max_storage_mb must not be negative, got %d", c.Artifacts.MaxStorageMB)
+	check(c.Artifacts.JanitorInterval > 0, "artifacts.janitor_interval must be positive, got %s", c.Artifacts.JanitorInterval)
+	if c.Artifacts.BaseURL != "" {
+		u, err := url.Parse(c.Artifacts.BaseURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"artifacts.base_url must be an http(s) URL, got %q", c.Artifacts.BaseURL)
+	}
+
+	if len(c.Webhooks.AllowedHosts) > 0 {
+		check(c.Webhooks.Secret != "", "webhooks.secret must be set when webhooks.allowed_hosts is")
+	}
+	check(c.Webhooks.MaxAttempts >= 1, "webhooks.max_attempts must be at least 1, got %d", c.Webhooks.MaxAttempts)
+	check(c.Webhooks.InitialBackoff > 0, "webhooks.initial_backoff must be positive, got %s", c.Webhooks.InitialBackoff)
+	check(c.Webhooks.MaxBackoff >= c.Webhooks.InitialBackoff,
+		"webhooks.max_backoff must be at least webhooks.initial_backoff, got %s", c.Webhooks.MaxBackoff)
+	check(c.Webhooks.Timeout > 0, "webhooks.timeout must be positive, got %s", c.Webhooks.Timeout)
+	check(c.Webhooks.MaxPending >= 1, "webhooks.max_pending must be at least 1, got %d", c.Webhooks.MaxPending)
+
+	check(c.History.Retention >= 0, "history.retention must not be negative, got %s", c.History.Retention)
+	check(c.History.MaxEntries >= 0, "history.max_entries must not be negative, got %d", c.History.MaxEntries)
+	check(c.History.PruneInterval > 0, "history.prune_interval must be positive, got %s", c.History.PruneInterval)
+
+	check(c.Designs.MaxSizeMB >= 1, "designs.max_size_mb must be at least 1, got %d", c.Designs.MaxSizeMB)
+
+	check(c.Sweep.MaxVariants >= 1, "sweep.max_variants must be at least 1, got %d", c.Sweep.MaxVariants)
+
+	p := c.Printability
+	check(p.OverhangAngle > 0 && p.OverhangAngle < 90,
+		"printability.overhang_angle must be between 0 and 90 degrees, got %g", p.OverhangAngle)
+	check(p.NozzleWidth > 0, "printability.nozzle_width must be positive, got %g", p.NozzleWidth)
+	check(p.MinWallThickness >= 0, "printability.min_wall_thickness must not be negative, got %g", p.MinWallThickness)
+	check(p.BuildVolumeX > 0 && p.BuildVolumeY > 0 && p.BuildVolumeZ > 0,
+		"printability build volume must be positive, got %g × %g × %g", p.BuildVolumeX, p.BuildVolumeY, p.BuildVolumeZ)
+
+	return errors.Join(errs...)
+}
+
+// RestartRequired lists the settings that differ between c and next but can
+// only take effect after a restart. Timeouts, image qualities, the default
+// backend and features, the feature allowlist, strict font mode, the log level
+// and rate limits are applied on reload; everything else is not.
+func (c *Config) RestartRequired(next *Config) []string {
+	var changed []string
+	if c.Server.Port != next.Server.Port || c.Server.GinMode != next.Server.GinMode ||
+		c.Server.ShutdownGracePeriod != next.Server.ShutdownGracePeriod || !slices.Equal(c.Server.TrustedProxies, next.Server.TrustedProxies) {
+		changed = append(changed, "server")
+	}
+	if c.OpenSCAD.Binary != next.OpenSCAD.Binary {
+		changed = append(changed, "openscad.binary")
+	}
+	if !maps.Equal(c.OpenSCAD.Versions, next.OpenSCAD.Versions) || c.OpenSCAD.DefaultVersion != next.OpenSCAD.DefaultVersion {
+		changed = append(changed, "openscad.versions")
+	}
+	if c.OpenSCAD.TempDir != next.OpenSCAD.TempDir {
+		changed = append(changed, "openscad.temp_dir")
+	}
+	if c.OpenSCAD.MaxConcurrentRenders != next.OpenSCAD.MaxConcurrentRenders {
+		changed = append(changed, "openscad.max_concurrent_renders")
+	}
+	if c.Logging.Format != next.Logging.Format {
+		changed = append(changed, "logging.format")
+	}
+	if c.Tracing != next.Tracing {
+		changed = append(changed, "tracing")
+	}
+	if c.Health != next.Health {
+		changed = append(changed, "health")
+	}
+	if c.Fonts.Dir != next.Fonts.Dir {
+		changed = append(changed, "fonts.dir")
+	}
+	if c.Fonts.MaxUploadMB != next.Fonts.MaxUploadMB {
+		changed = append(changed, "fonts.max_upload_mb")
+	}
+	if c.Fonts.MaxTotalMB != next.Fonts.MaxTotalMB {
+		changed = append(changed, "fonts.max_total_mb")
+	}
+	if c.Assets != next.Assets {
+		changed = append(changed, "assets")
+	}
+	if c.RenderURL != next.RenderURL {
+		changed = append(changed, "render_url")
+	}
+	if c.Artifacts != next.Artifacts {
+		changed = append(changed, "artifacts")
+	}
+	if !slices.Equal(c.Auth.APIKeys, next.Auth.APIKeys) || !slices.Equal(c.Auth.AdminKeys, next.Auth.AdminKeys) {
+		changed = append(changed, "auth")
+	}
+	cur, nxt := c.Webhooks, next.Webhooks
+	if !slices.Equal(cur.AllowedHosts, nxt.AllowedHosts) || cur.Secret != nxt.Secret ||
+		cur.MaxAttempts != nxt.MaxAttempts || cur.InitialBackoff != nxt.InitialBackoff ||
+		cur.MaxBackoff != nxt.MaxBackoff || cur.Timeout != nxt.Timeout || cur.MaxPending != nxt.MaxPending || cur.DeadLetterFile != nxt.DeadLetterFile {
+		changed = append(changed, "webhooks")
+	}
+	if c.History != next.History {
+		changed = append(changed, "history")
+	}
+	if c.Designs != next.Designs {
+		changed = append(changed, "designs")
+	}
+	if c.Templates != next.Templates {
+		changed = append(changed, "templates")
+	}
+	if c.Sweep != next.Sweep {
+		changed = append(changed, "sweep")
+	}
+	if c.Printability != next.Printability {
+		changed = append(changed, "printability")
+	}
+	return changed
+}
+
+// Reloader tracks the configurations loaded on reload so that a setting
+// needing a restart is reported by the reload that changes it rather than by
+// every reload after it
+type Reloader struct {
+	running *Config
+	last    *Config
+}
+
+// NewReloader returns a reloader for a server started with running
+func NewReloader(running *Config) *Reloader {
+	return &Reloader{running: running, last: running}
+}
+
+// Reload records next as the last configuration loaded and lists the
+// settings that differ from the running configuration and need a restart,
+// leaving out those that are unchanged since the previous reload
+func (r *Reloader) Reload(next *Config) []string {
+	sinceLast := r.last.RestartRequired(next)
+	r.last = next
+
+	var changed []string
+	for _, setting := range r.running.RestartRequired(next) {
+		if slices.Contains(sinceLast, setting) {
+			changed = append(changed, setting)
+		}
+	}
+	return changed
+}
+
+// Service returns the settings for the OpenSCAD service
+func (c *Config) Service() services.Config {
+	return services.Config{
+		Binary:               c.OpenSCAD.Binary,
+		Versions:             c.OpenSCAD.Versions,
+		DefaultVersion:       c.OpenSCAD.DefaultVersion,
+		Timeout:              c.OpenSCAD.Timeout,
+		TempDir:              c.OpenSCAD.TempDir,
+		MaxConcurrentRenders: c.OpenSCAD.MaxConcurrentRenders,
+		WebPQuality:          c.Images.WebPQuality,
+		AVIFQuality:          c.Images.AVIFQuality,
+		Backend:              c.OpenSCAD.Backend,
+		Features:             c.OpenSCAD.Features,
+		AllowedFeatures:      c.OpenSCAD.AllowedFeatures,
+		FontDir:              c.Fonts.Dir,
+		StrictFonts:          c.Fonts.Strict,
+		MaxFontBytes:         int64(c.Fonts.MaxTotalMB) << 20,
+		MaxAssetBytes:        int64(c.Assets.MaxSizeMB) << 20,
+		MaxAssets:            c.Assets.MaxCount,
+	}
+}
+
+// PrintOptions returns the default printer settings of printability reports
+func (c *Config) PrintOptions() mesh.PrintOptions {
+	return mesh.PrintOptions{
+		OverhangAngle:    c.Printability.OverhangAngle,
+		NozzleWidth:      c.Printability.NozzleWidth,
+		MinWallThickness: c.Printability.MinWallThickness,
+		BuildVolume:      mesh.Vec{c.Printability.BuildVolumeX, c.Printability.BuildVolumeY, c.Printability.BuildVolumeZ},
+	}
+}
+
+// MaxQueuedRenders returns the readiness queue limit, defaulting to twice the
+// render concurrency
+func (c *Config) MaxQueuedRenders() int {
+	if c.Health.MaxQueuedRenders > 0 {
+		return c.Health.MaxQueuedRenders
+	}
+	return 2 * c.OpenSCAD.MaxConcurrentRenders
+}
+
+// Limits returns the settings for the per-client rate limiter
+func (c *Config) Limits() ratelimit.Config {
+	return ratelimit.Config{
+		RequestsPerMinute: c.RateLimit.RequestsPerMinute,
+		RenderSeconds:     c.RateLimit.RenderBudgetSeconds,
+		RenderPeriod:      c.RateLimit.RenderBudgetPeriod,
+	}
+}
+
+// ArtifactStore returns the settings for the artifact store, or false when
+// artifact storage is disabled
+func (c *Config) ArtifactStore() (artifacts.Config, bool) {
+	switch {
+	case c.Artifacts.Backend == artifacts.BackendS3 && c.Artifacts.S3.Bucket == "":
+		return artifacts.Config{}, false
+	case c.Artifacts.Backend != artifacts.BackendS3 && c.Artifacts.Dir == "":
+		return artifacts.Config{}, false
+	}
+	return artifacts.Config{
+		Backend:    c.Artifacts.Backend,
+		Dir:        c.Artifacts.Dir,
+		S3:         artifacts.S3Config(c.Artifacts.S3),
+		TTL:        c.Artifacts.TTL,
+		MaxBytes:   int64(c.Artifacts.MaxStorageMB) << 20,
+		SigningKey: []byte(c.Artifacts.SigningKey),
+	}, true
+}
+
+// Callbacks returns the settings for callback delivery, or false when no
+// callback hosts are allowed
+func (c *Config) Callbacks() (webhooks.Config, bool) {
+	if len(c.Webhooks.AllowedHosts) == 0 {
+		return webhooks.Config{}, false
+	}
+	return webhooks.Config{
+		AllowedHosts:   c.Webhooks.AllowedHosts,
+		Secret:         []byte(c.Webhooks.Secret),
+		MaxAttempts:    c.Webhooks.MaxAttempts,
+		InitialBackoff: c.Webhooks.InitialBackoff,
+		MaxBackoff:     c.Webhooks.MaxBackoff,
+		Timeout:        c.Webhooks.Timeout,
+		MaxPending:     c.Webhooks.MaxPending,
+		DeadLetterFile: c.Webhooks.DeadLetterFile,
+	}, true
+}
+
+// HistoryStore returns the settings for the render history, or false when no
+// history database is configured
+func (c *Config) HistoryStore() (history.Config, bool) {
+	if c.History.DB == "" {
+		return history.Config{}, false
+	}
+	return history.Config{
+		Path:       c.History.DB,
+		Retention:  c.History.Retention,
+		MaxEntries: c.History.MaxEntries,
+	}, true
+}
+
+// DesignStore returns the settings for saved designs, or false when no
+// design database is configured
+func (c *Config) DesignStore() (designs.Config, bool) {
+	if c.Designs.DB == "" {
+		return designs.Config{}, false
+	}
+	return designs.Config{
+		Path:     c.Designs.DB,
+		MaxBytes: int64(c.Designs.MaxSizeMB) << 20,
+		MaxFiles: c.Assets.MaxCount,
+	}, true
+}
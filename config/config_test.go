@@ -0,0 +1,475 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// envMap returns a getenv function backed by m
+func envMap(m map[string]string) func(string) string {
+	return func(key string) string { return m[key] }
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := Load(nil, envMap(nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if cfg.Server.Port != 8000 {
+		t.Errorf("Expected port 8000, got %d", cfg.Server.Port)
+	}
+	if cfg.OpenSCAD.Binary != "openscad" {
+		t.Errorf("Expected binary openscad, got %s", cfg.OpenSCAD.Binary)
+	}
+	if cfg.OpenSCAD.Timeout != 5*time.Minute {
+		t.Errorf("Expected timeout 5m, got %s", cfg.OpenSCAD.Timeout)
+	}
+	if cfg.RateLimit.RenderBudgetPeriod != time.Hour {
+		t.Errorf("Expected render budget period 1h, got %s", cfg.RateLimit.RenderBudgetPeriod)
+	}
+	if cfg.RenderURL.MaxAge != 24*time.Hour || cfg.RenderURL.Secret != "" {
+		t.Errorf("Expected unsigned render URLs cached for 24h, got %+v", cfg.RenderURL)
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeConfigFile(t, `
+server:
+  port: 9000
+openscad:
+  timeout: 2m
+  binary: /opt/openscad/bin/openscad
+logging:
+  level: warn
+`)
+
+	env := map[string]string{
+		"SCADSRV_CONFIG":         path,
+		"SCADSRV_RENDER_TIMEOUT": "90s",
+		"SCADSRV_LOG_LEVEL":      "debug",
+	}
+	args := []string{"--log-level", "error"}
+
+	cfg, err := Load(args, envMap(env))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// File overrides defaults
+	if cfg.Server.Port != 9000 {
+		t.Errorf("Expected port 9000 from file, got %d", cfg.Server.Port)
+	}
+	if cfg.OpenSCAD.Binary != "/opt/openscad/bin/openscad" {
+		t.Errorf("Expected binary from file, got %s", cfg.OpenSCAD.Binary)
+	}
+	// Environment overrides file
+	if cfg.OpenSCAD.Timeout != 90*time.Second {
+		t.Errorf("Expected timeout 90s from env, got %s", cfg.OpenSCAD.Timeout)
+	}
+	// Flags override environment
+	if cfg.Logging.Level != "error" {
+		t.Errorf("Expected log level error from flag, got %s", cfg.Logging.Level)
+	}
+}
+
+func TestLoad_ConfigFlagOverridesEnv(t *testing.T) {
+	envPath := writeConfigFile(t, "server:\n  port: 9000\n")
+	flagPath := writeConfigFile(t, "server:\n  port: 9100\n")
+
+	cfg, err := Load([]string{"--config", flagPath}, envMap(map[string]string{"SCADSRV_CONFIG": envPath}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.Server.Port != 9100 {
+		t.Errorf("Expected port 9100, got %d", cfg.Server.Port)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		file    string
+		wantErr string
+	}{
+		{
+			name:    "Unknown flag",
+			args:    []string{"--nope"},
+			wantErr: "flag provided but not defined",
+		},
+		{
+			name:    "Invalid flag value",
+			args:    []string{"--port", "abc"},
+			wantErr: "invalid --port",
+		},
+		{
+			name:    "Invalid env value",
+			env:     map[string]string{"SCADSRV_RENDER_TIMEOUT": "soon"},
+			wantErr: "invalid SCADSRV_RENDER_TIMEOUT",
+		},
+		{
+			name:    "Negative render URL max age",
+			args:    []string{"--render-url-max-age", "-1h"},
+			wantErr: "render_url.max_age must not be negative",
+		},
+		{
+			name:    "Relative artifact base URL",
+			env:     map[string]string{"SCADSRV_ARTIFACT_BASE_URL": "/downloads"},
+			wantErr: "artifacts.base_url must be an http(s) URL",
+		},
+		{
+			name:    "Unknown artifact backend",
+			args:    []string{"--artifact-backend", "ftp"},
+			wantErr: "artifacts.backend must be filesystem or s3",
+		},
+		{
+			name:    "S3 artifact backend without endpoint",
+			args:    []string{"--artifact-backend", "s3", "--artifact-s3-bucket", "renders"},
+			wantErr: "artifacts.s3.endpoint must be an http(s) URL",
+		},
+		{
+			name:    "Callback hosts without secret",
+			env:     map[string]string{"SCADSRV_CALLBACK_ALLOWED_HOSTS": "ci.example.com"},
+			wantErr: "webhooks.secret must be set",
+		},
+		{
+			name:    "No pending callbacks allowed",
+			args:    []string{"--callback-max-pending", "0"},
+			wantErr: "webhooks.max_pending must be at least 1",
+		},
+		{
+			name:    "Source size below one MiB",
+			args:    []string{"--source-max-size-mb", "0"},
+			wantErr: "assets.max_source_mb must be at least 1",
+		},
+		{
+			name:    "Negative history retention",
+			args:    []string{"--history-retention", "-1h"},
+			wantErr: "history.retention must not be negative",
+		},
+		{
+			name:    "Design size limit too small",
+			env:     map[string]string{"SCADSRV_DESIGN_MAX_SIZE_MB": "0"},
+			wantErr: "designs.max_size_mb must be at least 1",
+		},
+		{
+			name:    "No sweep variants",
+			args:    []string{"--sweep-max-variants", "0"},
+			wantErr: "sweep.max_variants must be at least 1",
+		},
+		{
+			name:    "Bad trusted proxy",
+			args:    []string{"--trusted-proxies", "10.0.0.0/8,proxy.local"},
+			wantErr: "server.trusted_proxies must be IP addresses or CIDRs",
+		},
+		{
+			name:    "Font quota below the upload limit",
+			args:    []string{"--font-max-upload-mb", "20", "--font-max-total-mb", "10"},
+			wantErr: "fonts.max_total_mb must be at least fonts.max_upload_mb",
+		},
+		{
+			name:    "Flat overhang angle",
+			args:    []string{"--printability-overhang-angle", "90"},
+			wantErr: "printability.overhang_angle must be between 0 and 90 degrees",
+		},
+		{
+			name:    "Empty build volume",
+			file:    "printability:\n  build_volume_z: 0\n",
+			wantErr: "printability build volume must be positive",
+		},
+		{
+			name:    "Unknown file key",
+			file:    "server:\n  prot: 9000\n",
+			wantErr: "failed to parse config file",
+		},
+		{
+			name:    "Out of range",
+			args:    []string{"--webp-quality", "150"},
+			wantErr: "images.webp_quality must be between 0 and 100",
+		},
+		{
+			name:    "Bad log level",
+			env:     map[string]string{"SCADSRV_LOG_LEVEL": "loud"},
+			wantErr: "unsupported log level",
+		},
+		{
+			name:    "Bad OTLP endpoint",
+			args:    []string{"--otlp-endpoint", "localhost:4318"},
+			wantErr: "tracing.otlp_endpoint must be an http(s) URL",
+		},
+		{
+			name:    "Missing temp dir",
+			args:    []string{"--temp-dir", "/does/not/exist"},
+			wantErr: "openscad.temp_dir",
+		},
+		{
+			name:    "Missing font dir",
+			env:     map[string]string{"SCADSRV_FONT_DIR": "/does/not/exist"},
+			wantErr: "fonts.dir",
+		},
+		{
+			name:    "Zero asset size limit",
+			file:    "assets:\n  max_size_mb: 0\n",
+			wantErr: "assets.max_size_mb must be at least 1",
+		},
+		{
+			name:    "Zero font upload limit",
+			args:    []string{"--font-max-upload-mb", "0"},
+			wantErr: "fonts.max_upload_mb must be at least 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{}
+			for k, v := range tt.env {
+				env[k] = v
+			}
+			if tt.file != "" {
+				env["SCADSRV_CONFIG"] = writeConfigFile(t, tt.file)
+			}
+
+			_, err := Load(tt.args, envMap(env))
+			if err == nil {
+				t.Fatalf("Expected error containing %q, got nil", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoad_Help(t *testing.T) {
+	_, err := Load([]string{"--help"}, envMap(nil))
+	if !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("Expected flag.ErrHelp, got %v", err)
+	}
+}
+
+func TestValidate_ReportsAllErrors(t *testing.T) {
+	cfg := Default()
+	cfg.Server.Port = 0
+	cfg.OpenSCAD.MaxConcurrentRenders = 0
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected validation error, got nil")
+	}
+	for _, want := range []string{"server.port", "openscad.max_concurrent_renders"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %s, got %v", want, err)
+		}
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	current := Default()
+
+	reloadable := Default()
+	reloadable.OpenSCAD.Timeout = time.Minute
+	reloadable.Logging.Level = "debug"
+	reloadable.RateLimit.RequestsPerMinute = 10
+	if changed := current.RestartRequired(reloadable); len(changed) != 0 {
+		t.Errorf("Expected no restart-only changes, got %v", changed)
+	}
+
+	restart := Default()
+	restart.Server.Port = 9000
+	restart.OpenSCAD.Binary = "openscad-nightly"
+	changed := current.RestartRequired(restart)
+	if len(changed) != 2 || changed[0] != "server" || changed[1] != "openscad.binary" {
+		t.Errorf("Expected [server openscad.binary], got %v", changed)
+	}
+}
+
+func TestReloader(t *testing.T) {
+	r := NewReloader(Default())
+
+	port := Default()
+	port.Server.Port = 9000
+	binary := Default()
+	binary.Server.Port = 9000
+	binary.OpenSCAD.Binary = "openscad-nightly"
+
+	tests := []struct {
+		name string
+		next *Config
+		want []string
+	}{
+		{name: "First change", next: port, want: []string{"server"}},
+		{name: "Same again", next: port, want: nil},
+		{name: "Another change", next: binary, want: []string{"openscad.binary"}},
+		{name: "Back to running", next: Default(), want: nil},
+		{name: "Changed again", next: port, want: []string{"server"}},
+	}
+
+	for _, tt := range tests {
+		if got := r.Reload(tt.next); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	var b strings.Builder
+	Usage(&b)
+
+	for _, want := range []string{"-config", "-render-timeout", "SCADSRV_RENDER_TIMEOUT"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("Expected usage to contain %q", want)
+		}
+	}
+}
+
+func TestLoad_BoolFlag(t *testing.T) {
+	cfg, err := Load([]string{"--health-self-test"}, envMap(nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !cfg.Health.SelfTest {
+		t.Errorf("Expected self-test to be enabled by bare flag")
+	}
+
+	cfg, err = Load([]string{"--health-self-test=false"}, envMap(map[string]string{"SCADSRV_HEALTH_SELF_TEST": "true"}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.Health.SelfTest {
+		t.Errorf("Expected flag to override env")
+	}
+}
+
+func TestMaxQueuedRenders(t *testing.T) {
+	cfg := Default()
+	cfg.OpenSCAD.MaxConcurrentRenders = 3
+	if got := cfg.MaxQueuedRenders(); got != 6 {
+		t.Errorf("Expected default of 6, got %d", got)
+	}
+
+	cfg.Health.MaxQueuedRenders = 10
+	if got := cfg.MaxQueuedRenders(); got != 10 {
+		t.Errorf("Expected 10, got %d", got)
+	}
+}
+
+func TestLoad_Versions(t *testing.T) {
+	cfg, err := Load([]string{
+		"--openscad-versions", "stable=/usr/bin/openscad, nightly=/opt/nightly/openscad",
+		"--openscad-default-version", "nightly",
+	}, envMap(nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.OpenSCAD.Versions["nightly"] != "/opt/nightly/openscad" || len(cfg.OpenSCAD.Versions) != 2 {
+		t.Errorf("Expected two versions, got %v", cfg.OpenSCAD.Versions)
+	}
+	if svc := cfg.Service(); svc.DefaultVersion != "nightly" {
+		t.Errorf("Expected service default nightly, got %s", svc.DefaultVersion)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"Malformed pair", []string{"--openscad-versions", "stable"}, "expected name=value"},
+		{"Missing default", []string{"--openscad-versions", "a=/a,b=/b"}, "openscad.default_version is required"},
+		{"Unknown default", []string{"--openscad-versions", "a=/a", "--openscad-default-version", "b"}, "is not one of openscad.versions"},
+		{"Default without versions", []string{"--openscad-default-version", "b"}, "requires openscad.versions"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.args, envMap(nil))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoad_Features(t *testing.T) {
+	cfg, err := Load([]string{"--backend", "manifold", "--features", "lazy-union, roof"}, envMap(nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.OpenSCAD.Backend != "manifold" {
+		t.Errorf("Expected backend manifold, got %s", cfg.OpenSCAD.Backend)
+	}
+	if len(cfg.OpenSCAD.Features) != 2 || cfg.OpenSCAD.Features[1] != "roof" {
+		t.Errorf("Expected [lazy-union roof], got %v", cfg.OpenSCAD.Features)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		wantErr string
+	}{
+		{"Unknown backend", []string{"--backend", "opencsg"}, nil, "openscad.backend must be cgal or manifold"},
+		{"Feature not allowed", []string{"--features", "manifold"}, nil, `"manifold" is not in openscad.allowed_features`},
+		{"Narrowed allowlist", []string{"--features", "roof"}, map[string]string{"SCADSRV_ALLOWED_FEATURES": "lazy-union"}, `"roof" is not in openscad.allowed_features`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.args, envMap(tt.env))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestArtifactStore(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		wantEnabled bool
+		wantBackend string
+	}{
+		{name: "Disabled by default", wantEnabled: false},
+		{name: "Filesystem", args: []string{"--artifact-dir", t.TempDir()}, wantEnabled: true, wantBackend: "filesystem"},
+		{name: "S3 without bucket", args: []string{"--artifact-backend", "s3"}, wantEnabled: false},
+		{
+			name:        "S3",
+			args:        []string{"--artifact-backend", "s3", "--artifact-s3-endpoint", "http://minio:9000", "--artifact-s3-bucket", "renders"},
+			wantEnabled: true,
+			wantBackend: "s3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(tt.args, envMap(nil))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			store, enabled := cfg.ArtifactStore()
+			if enabled != tt.wantEnabled {
+				t.Errorf("Expected enabled %v, got %v", tt.wantEnabled, enabled)
+			}
+			if enabled && store.Backend != tt.wantBackend {
+				t.Errorf("Expected backend %q, got %q", tt.wantBackend, store.Backend)
+			}
+		})
+	}
+}
@@ -0,0 +1,536 @@
+package designs
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/stevexciv/scad-server/ids"
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/services"
+	"github.com/stevexciv/scad-server/sqlitedb"
+)
+
+const (
+	// DefaultMain is the entry file of designs that don't name one
+	DefaultMain = "main.scad"
+	// DefaultMaxBytes bounds the files and assets of a version when no limit
+	// is configured
+	DefaultMaxBytes = 10 << 20
+	// maxNameLength bounds design names
+	maxNameLength = 200
+	// schemaVersion is the version of the current schema
+	schemaVersion = 1
+)
+
+// schema creates the tables of schemaVersion. Versions are never updated
+// once written.
+const schema = `
+CREATE TABLE IF NOT EXISTS designs (
+	id             TEXT    PRIMARY KEY,
+	name           TEXT    NOT NULL,
+	description    TEXT    NOT NULL DEFAULT '',
+	latest_version INTEGER NOT NULL,
+	created_at     INTEGER NOT NULL,
+	updated_at     INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS designs_updated_at ON designs (updated_at);
+CREATE TABLE IF NOT EXISTS design_versions (
+	design_id   TEXT    NOT NULL,
+	version     INTEGER NOT NULL,
+	created_at  INTEGER NOT NULL,
+	message     TEXT    NOT NULL DEFAULT '',
+	main        TEXT    NOT NULL,
+	files       TEXT    NOT NULL,
+	assets      TEXT,
+	parameters  TEXT,
+	format      TEXT    NOT NULL DEFAULT '',
+	options     TEXT    NOT NULL,
+	source_hash TEXT    NOT NULL,
+	PRIMARY KEY (design_id, version)
+);
+CREATE TRIGGER IF NOT EXISTS design_versions_immutable BEFORE UPDATE ON design_versions
+BEGIN
+	SELECT RAISE(ABORT, 'design versions are immutable');
+END;
+`
+
+// fileExtensions lists the file types of design files, which are rendered
+// as libraries next to the main file
+var fileExtensions = []string{".scad"}
+
+var (
+	// ErrNotFound is returned for designs and versions that don't exist
+	ErrNotFound = errors.New("design not found")
+	// ErrInvalid is returned for design content that can't be rendered
+	ErrInvalid = errors.New("invalid design")
+	// ErrTooLarge is returned when a version's files and assets exceed the
+	// size limit
+	ErrTooLarge = errors.New("design too large")
+)
+
+// Config contains design store settings
+type Config struct {
+	// Path is the SQLite database file
+	Path string
+	// MaxBytes bounds the files and assets of a version, DefaultMaxBytes when 0
+	MaxBytes int64
+	// MaxFiles bounds the files and assets of a version together, matching
+	// the number of assets a render accepts; services.DefaultMaxAssets when 0
+	MaxFiles int
+}
+
+// Store keeps designs and their versions in an SQLite database
+type Store struct {
+	db       *sql.DB
+	maxBytes int64
+	maxFiles int
+	now      func() time.Time
+}
+
+// Open opens or creates the database at cfg.Path
+func Open(ctx context.Context, cfg Config) (*Store, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("design database path must not be empty")
+	}
+	db, err := sqlitedb.Open(cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open design database: %w", err)
+	}
+	if err := sqlitedb.Migrate(ctx, db, "designs", schemaVersion, schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open design database: %w", err)
+	}
+	maxBytes := cfg.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	maxFiles := cfg.MaxFiles
+	if maxFiles <= 0 {
+		maxFiles = services.DefaultMaxAssets
+	}
+	return &Store{db: db, maxBytes: maxBytes, maxFiles: maxFiles, now: time.Now}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Create saves a new design with req as its first version
+func (s *Store) Create(ctx context.Context, req *models.DesignRequest) (*models.Design, error) {
+	version, err := s.newVersion(req)
+	if err != nil {
+		return nil, err
+	}
+	id, err := ids.New()
+	if err != nil {
+		return nil, err
+	}
+	version.Version = 1
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save design: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO designs (id, name, description, latest_version, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		id, req.Name, req.Description, version.Version, version.CreatedAt.UnixMilli(), version.CreatedAt.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("failed to save design: %w", err)
+	}
+	if err := insertVersion(ctx, tx, id, version); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to save design: %w", err)
+	}
+
+	return &models.Design{
+		ID:            id,
+		Name:          req.Name,
+		Description:   req.Description,
+		LatestVersion: version.Version,
+		CreatedAt:     version.CreatedAt,
+		UpdatedAt:     version.CreatedAt,
+		Latest:        version,
+	}, nil
+}
+
+// Update saves req as a new version of the design with the given ID, also
+// replacing its name and description
+func (s *Store) Update(ctx context.Context, id string, req *models.DesignRequest) (*models.Design, error) {
+	version, err := s.newVersion(req)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save design: %w", err)
+	}
+	defer tx.Rollback()
+
+	var createdAt int64
+	err = tx.QueryRowContext(ctx, "SELECT latest_version, created_at FROM designs WHERE id = ?", id).
+		Scan(&version.Version, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save design: %w", err)
+	}
+	version.Version++
+
+	_, err = tx.ExecContext(ctx, "UPDATE designs SET name = ?, description = ?, latest_version = ?, updated_at = ? WHERE id = ?",
+		req.Name, req.Description, version.Version, version.CreatedAt.UnixMilli(), id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save design: %w", err)
+	}
+	if err := insertVersion(ctx, tx, id, version); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to save design: %w", err)
+	}
+
+	return &models.Design{
+		ID:            id,
+		Name:          req.Name,
+		Description:   req.Description,
+		LatestVersion: version.Version,
+		CreatedAt:     time.UnixMilli(createdAt).UTC(),
+		UpdatedAt:     version.CreatedAt,
+		Latest:        version,
+	}, nil
+}
+
+// Get returns the design with the given ID and its latest version
+func (s *Store) Get(ctx context.Context, id string) (*models.Design, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+designColumns+" FROM designs WHERE id = ?", id)
+	d, err := scanDesign(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read design: %w", err)
+	}
+	d.Latest, err = s.Version(ctx, id, d.LatestVersion)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// List returns all designs, most recently updated first
+func (s *Store) List(ctx context.Context) ([]models.Design, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+designColumns+" FROM designs ORDER BY updated_at DESC, id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list designs: %w", err)
+	}
+	defer rows.Close()
+
+	designs := []models.Design{}
+	for rows.Next() {
+		d, err := scanDesign(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list designs: %w", err)
+		}
+		designs = append(designs, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list designs: %w", err)
+	}
+	return designs, nil
+}
+
+// Delete removes the design with the given ID and all its versions
+func (s *Store) Delete(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete design: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM designs WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete design: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM design_versions WHERE design_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete design: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to delete design: %w", err)
+	}
+	return nil
+}
+
+// Versions lists the versions of the design with the given ID, newest
+// first, without their files and assets
+func (s *Store) Versions(ctx context.Context, id string) ([]models.DesignVersion, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT version, created_at, message, main, NULL, NULL, parameters, format, options, source_hash
+		FROM design_versions WHERE design_id = ? ORDER BY version DESC`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list design versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []models.DesignVersion{}
+	for rows.Next() {
+		v, err := scanVersion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list design versions: %w", err)
+		}
+		versions = append(versions, *v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list design versions: %w", err)
+	}
+	if len(versions) == 0 {
+		return nil, ErrNotFound
+	}
+	return versions, nil
+}
+
+// Version returns a version of the design with the given ID
+func (s *Store) Version(ctx context.Context, id string, version int) (*models.DesignVersion, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT version, created_at, message, main, files, assets, parameters, format, options, source_hash
+		FROM design_versions WHERE design_id = ? AND version = ?`, id, version)
+	v, err := scanVersion(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: no version %d", ErrNotFound, version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read design version: %w", err)
+	}
+	return v, nil
+}
+
+// ExportRequest returns the request rendering version v with req's
+// overrides: the version's main file is the SCAD source, its other files and
+// assets are assets, and parameters are merged over its defaults
+func ExportRequest(v *models.DesignVersion, req *models.DesignExportRequest) *models.ExportRequest {
+	export := &models.ExportRequest{
+		Format:          cmp.Or(req.Format, v.Format),
+		OpenSCADVersion: req.OpenSCADVersion,
+		Backend:         req.Backend,
+		Features:        req.Features,
+		Options:         v.Options,
+		Store:           req.Store,
+		CallbackURL:     req.CallbackURL,
+	}
+	if req.Options != nil {
+		export.Options = *req.Options
+	}
+	if len(v.Parameters)+len(req.Parameters) > 0 {
+		export.Parameters = make(map[string]any, len(v.Parameters)+len(req.Parameters))
+		maps.Copy(export.Parameters, v.Parameters)
+		maps.Copy(export.Parameters, req.Parameters)
+	}
+	for _, f := range v.Files {
+		if f.Name == v.Main {
+			export.ScadContent = f.Content
+			continue
+		}
+		export.Libraries = append(export.Libraries, models.Asset{Name: f.Name, Data: []byte(f.Content)})
+	}
+	export.Assets = append(export.Assets, v.Assets...)
+	return export
+}
+
+// newVersion validates req and returns it as an unnumbered version
+func (s *Store) newVersion(req *models.DesignRequest) (*models.DesignVersion, error) {
+	if err := s.validate(req); err != nil {
+		return nil, err
+	}
+
+	files := slices.Clone(req.Files)
+	slices.SortFunc(files, func(a, b models.DesignFile) int { return strings.Compare(a.Name, b.Name) })
+	assets := slices.Clone(req.Assets)
+	slices.SortFunc(assets, func(a, b models.Asset) int { return strings.Compare(a.Name, b.Name) })
+	main := mainFile(req)
+
+	// The hash covers everything that is rendered, so equal hashes render
+	// the same model
+	source, err := json.Marshal(struct {
+		Main   string              `json:"main"`
+		Files  []models.DesignFile `json:"files"`
+		Assets []models.Asset      `json:"assets"`
+	}{main, files, assets})
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(source)
+
+	return &models.DesignVersion{
+		Message:    req.Message,
+		Main:       main,
+		Files:      files,
+		Assets:     assets,
+		Parameters: req.Parameters,
+		Format:     req.Format,
+		Options:    req.Options,
+		SourceHash: "sha256:" + hex.EncodeToString(sum[:]),
+		CreatedAt:  s.now().UTC().Truncate(time.Millisecond),
+	}, nil
+}
+
+// validate checks req's name, file names and size before it is saved
+func (s *Store) validate(req *models.DesignRequest) error {
+	if strings.TrimSpace(req.Name) == "" || len(req.Name) > maxNameLength {
+		return fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalid, maxNameLength)
+	}
+	if len(req.Files) == 0 {
+		return fmt.Errorf("%w: at least one file is required", ErrInvalid)
+	}
+	// The main file is sent as the source, the others count as assets
+	if n := len(req.Files) - 1 + len(req.Assets); n > s.maxFiles {
+		return fmt.Errorf("%w: %d files and assets besides the main file, at most %d allowed", ErrInvalid, n, s.maxFiles)
+	}
+
+	var size int64
+	seen := make(map[string]bool, len(req.Files)+len(req.Assets))
+	check := func(name string, n int, extensions []string) error {
+		if err := services.ValidateAssetName(name, extensions); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalid, err)
+		}
+		key := strings.ToLower(name)
+		if seen[key] {
+			return fmt.Errorf("%w: duplicate name %q", ErrInvalid, name)
+		}
+		seen[key] = true
+		size += int64(n)
+		return nil
+	}
+	for _, f := range req.Files {
+		if !slices.Contains(fileExtensions, strings.ToLower(path.Ext(f.Name))) {
+			return fmt.Errorf("%w: file %q must be a .scad file, send other files as assets", ErrInvalid, f.Name)
+		}
+		if err := check(f.Name, len(f.Content), fileExtensions); err != nil {
+			return err
+		}
+	}
+	for _, a := range req.Assets {
+		if err := check(a.Name, len(a.Data), services.AssetExtensions); err != nil {
+			return err
+		}
+	}
+	if size > s.maxBytes {
+		return fmt.Errorf("%w: files and assets are %d bytes, limit is %d", ErrTooLarge, size, s.maxBytes)
+	}
+
+	// The main file becomes the SCAD source in the root of the work
+	// directory, so paths in include and use resolve as in the design
+	main := mainFile(req)
+	if strings.Contains(main, "/") {
+		return fmt.Errorf("%w: main file %q must be at the top level", ErrInvalid, main)
+	}
+	if !slices.ContainsFunc(req.Files, func(f models.DesignFile) bool { return f.Name == main }) {
+		return fmt.Errorf("%w: main file %q is not among the files", ErrInvalid, main)
+	}
+	return nil
+}
+
+// mainFile returns the entry file of req: the one it names, its only file,
+// or DefaultMain
+func mainFile(req *models.DesignRequest) string {
+	switch {
+	case req.Main != "":
+		return req.Main
+	case len(req.Files) == 1:
+		return req.Files[0].Name
+	default:
+		return DefaultMain
+	}
+}
+
+// insertVersion writes v of the design with the given ID
+func insertVersion(ctx context.Context, tx *sql.Tx, id string, v *models.DesignVersion) error {
+	files, err := json.Marshal(v.Files)
+	if err != nil {
+		return err
+	}
+	options, err := json.Marshal(v.Options)
+	if err != nil {
+		return err
+	}
+	assets, err := sqlitedb.NullJSON(v.Assets, len(v.Assets) == 0)
+	if err != nil {
+		return err
+	}
+	parameters, err := sqlitedb.NullJSON(v.Parameters, len(v.Parameters) == 0)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO design_versions
+		(design_id, version, created_at, message, main, files, assets, parameters, format, options, source_hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, v.Version, v.CreatedAt.UnixMilli(), v.Message, v.Main, string(files), assets, parameters, v.Format,
+		string(options), v.SourceHash)
+	if err != nil {
+		return fmt.Errorf("failed to save design version: %w", err)
+	}
+	return nil
+}
+
+// designColumns are the designs columns read by scanDesign, in order
+const designColumns = "id, name, description, latest_version, created_at, updated_at"
+
+// scanDesign reads a row selected with designColumns
+func scanDesign(row interface{ Scan(...any) error }) (models.Design, error) {
+	var d models.Design
+	var createdAt, updatedAt int64
+	if err := row.Scan(&d.ID, &d.Name, &d.Description, &d.LatestVersion, &createdAt, &updatedAt); err != nil {
+		return d, err
+	}
+	d.CreatedAt = time.UnixMilli(createdAt).UTC()
+	d.UpdatedAt = time.UnixMilli(updatedAt).UTC()
+	return d, nil
+}
+
+// scanVersion reads a design_versions row
+func scanVersion(row interface{ Scan(...any) error }) (*models.DesignVersion, error) {
+	var v models.DesignVersion
+	var createdAt int64
+	var files, assets, parameters sql.NullString
+	var options string
+	err := row.Scan(&v.Version, &createdAt, &v.Message, &v.Main, &files, &assets, &parameters, &v.Format, &options, &v.SourceHash)
+	if err != nil {
+		return nil, err
+	}
+
+	v.CreatedAt = time.UnixMilli(createdAt).UTC()
+	for _, column := range []struct {
+		value  sql.NullString
+		target any
+	}{
+		{files, &v.Files},
+		{assets, &v.Assets},
+		{parameters, &v.Parameters},
+		{sql.NullString{String: options, Valid: true}, &v.Options},
+	} {
+		if !column.value.Valid {
+			continue
+		}
+		if err := json.Unmarshal([]byte(column.value.String), column.target); err != nil {
+			return nil, err
+		}
+	}
+	return &v, nil
+}
@@ -0,0 +1,212 @@
+package designs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/services"
+)
+
+func openTestStore(t *testing.T, maxBytes int64) *Store {
+	t.Helper()
+	store, err := Open(context.Background(), Config{Path: filepath.Join(t.TempDir(), "designs.db"), MaxBytes: maxBytes})
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func gearbox(wall float64) *models.DesignRequest {
+	return &models.DesignRequest{
+		Name: "Gearbox",
+		Files: []models.DesignFile{
+			{Name: "main.scad", Content: "include <lib/gears.scad>\ngear(teeth);"},
+			{Name: "lib/gears.scad", Content: "module gear(t) { cylinder(r=t, h=2); }"},
+		},
+		Assets:     []models.Asset{{Name: "parts/shaft.stl", Data: []byte("solid shaft")}},
+		Parameters: map[string]any{"teeth": 12.0, "wall": wall},
+		Format:     "stl_binary",
+		Message:    "Initial",
+	}
+}
+
+func TestVersioning(t *testing.T) {
+	store := openTestStore(t, 0)
+	ctx := context.Background()
+
+	created, err := store.Create(ctx, gearbox(2))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if created.ID == "" || created.LatestVersion != 1 || created.Latest.Main != "main.scad" {
+		t.Fatalf("Expected version 1 with main.scad, got %+v", created)
+	}
+
+	next := gearbox(3)
+	next.Name = "Gearbox v2"
+	updated, err := store.Update(ctx, created.ID, next)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if updated.LatestVersion != 2 || updated.Name != "Gearbox v2" || !updated.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("Expected version 2 renamed with the original creation time, got %+v", updated)
+	}
+	if updated.Latest.SourceHash != created.Latest.SourceHash {
+		t.Errorf("Expected equal sources to hash equally, got %s and %s", created.Latest.SourceHash, updated.Latest.SourceHash)
+	}
+
+	first, err := store.Version(ctx, created.ID, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if first.Parameters["wall"] != 2.0 || len(first.Files) != 2 || string(first.Assets[0].Data) != "solid shaft" {
+		t.Errorf("Expected version 1 to keep its content, got %+v", first)
+	}
+
+	got, err := store.Get(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got.Name != "Gearbox v2" || got.Latest == nil || got.Latest.Version != 2 || got.Latest.Parameters["wall"] != 3.0 {
+		t.Errorf("Expected the latest version, got %+v", got)
+	}
+
+	versions, err := store.Versions(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(versions) != 2 || versions[0].Version != 2 || versions[1].Version != 1 || versions[0].Files != nil {
+		t.Errorf("Expected versions 2 and 1 without files, got %+v", versions)
+	}
+
+	if _, err := store.db.ExecContext(ctx, "UPDATE design_versions SET main = 'x.scad'"); err == nil {
+		t.Errorf("Expected versions to be immutable")
+	}
+
+	if err := store.Delete(ctx, created.ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, err := range []error{
+		func() error { _, err := store.Get(ctx, created.ID); return err }(),
+		func() error { _, err := store.Version(ctx, created.ID, 1); return err }(),
+		func() error { _, err := store.Update(ctx, created.ID, gearbox(4)); return err }(),
+		store.Delete(ctx, created.ID),
+	} {
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound after deletion, got %v", err)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	store := openTestStore(t, 0)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	ctx := context.Background()
+	first, _ := store.Create(ctx, gearbox(2))
+	second, _ := store.Create(ctx, gearbox(2))
+	if _, err := store.Update(ctx, first.ID, gearbox(3)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	list, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(list) != 2 || list[0].ID != first.ID || list[1].ID != second.ID || list[0].Latest != nil {
+		t.Errorf("Expected the updated design first without versions, got %+v", list)
+	}
+}
+
+func TestCreate_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(r *models.DesignRequest)
+		wantErr error
+	}{
+		{"No name", func(r *models.DesignRequest) { r.Name = " " }, ErrInvalid},
+		{"No files", func(r *models.DesignRequest) { r.Files = nil }, ErrInvalid},
+		{"Not SCAD", func(r *models.DesignRequest) { r.Files[1].Name = "lib/gears.stl" }, ErrInvalid},
+		{"Unsafe name", func(r *models.DesignRequest) { r.Files[1].Name = "../gears.scad" }, ErrInvalid},
+		{"Duplicate", func(r *models.DesignRequest) { r.Files[1].Name = "MAIN.scad" }, ErrInvalid},
+		{"Missing main", func(r *models.DesignRequest) { r.Main = "box.scad" }, ErrInvalid},
+		{"Nested main", func(r *models.DesignRequest) { r.Main = "lib/gears.scad" }, ErrInvalid},
+		{"Too large", func(r *models.DesignRequest) { r.Assets[0].Data = make([]byte, 2048) }, ErrTooLarge},
+		{"SCAD asset", func(r *models.DesignRequest) { r.Assets[0].Name = "parts/shaft.scad" }, ErrInvalid},
+		{"Too many files", func(r *models.DesignRequest) {
+			for i := range services.DefaultMaxAssets {
+				r.Assets = append(r.Assets, models.Asset{Name: fmt.Sprintf("parts/%d.stl", i)})
+			}
+		}, ErrInvalid},
+	}
+
+	store := openTestStore(t, 1024)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := gearbox(2)
+			tt.modify(req)
+			if _, err := store.Create(context.Background(), req); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestExportRequest(t *testing.T) {
+	store := openTestStore(t, 0)
+	design, err := store.Create(context.Background(), gearbox(2))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	width := 640
+
+	tests := []struct {
+		name       string
+		req        models.DesignExportRequest
+		wantFormat string
+		wantTeeth  any
+		wantWidth  bool
+	}{
+		{"Defaults", models.DesignExportRequest{}, "stl_binary", 12.0, false},
+		{"Overrides", models.DesignExportRequest{
+			Format:     "png",
+			Parameters: map[string]any{"teeth": 20},
+			Options:    &models.ExportOptions{PNG: &models.PNGOptions{Width: &width}},
+		}, "png", 20, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			export := ExportRequest(design.Latest, &tt.req)
+			if export.Format != tt.wantFormat || export.Parameters["teeth"] != tt.wantTeeth || export.Parameters["wall"] != 2.0 {
+				t.Errorf("Expected %s with teeth %v over the defaults, got %s %v", tt.wantFormat, tt.wantTeeth, export.Format, export.Parameters)
+			}
+			if tt.wantWidth != (export.Options.PNG != nil) {
+				t.Errorf("Expected PNG options %v, got %+v", tt.wantWidth, export.Options)
+			}
+			if !strings.HasPrefix(export.ScadContent, "include <lib/gears.scad>") {
+				t.Errorf("Expected main.scad as the source, got %q", export.ScadContent)
+			}
+			if len(export.Libraries) != 1 || export.Libraries[0].Name != "lib/gears.scad" {
+				t.Errorf("Expected the other file as a library, got %+v", export.Libraries)
+			}
+			if len(export.Assets) != 1 || export.Assets[0].Name != "parts/shaft.stl" {
+				t.Errorf("Expected the assets, got %+v", export.Assets)
+			}
+		})
+	}
+
+	if design.Latest.Parameters["teeth"] != 12.0 {
+		t.Errorf("Expected overrides to leave the version unchanged, got %v", design.Latest.Parameters)
+	}
+}
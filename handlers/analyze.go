@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/logging"
+	"github.com/stevexciv/scad-server/mesh"
+	"github.com/stevexciv/scad-server/models"
+)
+
+// defaultAnalyzeFormat is the mesh format models are analyzed in when the
+// request doesn't choose one
+const defaultAnalyzeFormat = "stl_binary"
+
+// Analyze handles the analyze endpoint
+// @Summary Analyze the exported mesh
+// @Description Exports OpenSCAD content as a mesh and measures it: triangle and vertex counts, volume, surface area, bounding box, centroid, inertia tensor about the centroid for unit density, shells, open and non-manifold edges and degenerate triangles
+// @Description "format" chooses the mesh the model is exported as (stl_binary by default). Lengths are in millimeters
+// @Description Accepts assets, form fields and raw application/x-openscad bodies the same way as the export endpoint
+// @Tags analyze
+// @Accept json,mpfd,application/x-openscad
+// @Produce json
+// @Param request body models.AnalyzeRequest true "Analyze request"
+// @Success 200 {object} models.MeshAnalysis "Mesh analysis"
+// @Failure 400 {object} models.ErrorResponse "Bad Request"
+// @Failure 413 {object} models.ErrorResponse "Asset Too Large"
+// @Failure 429 {object} models.ErrorResponse "Quota Exceeded"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 503 {object} models.ErrorResponse "Shutting Down"
+// @Router /openscad/v1/analyze [post]
+func (h *Handler) Analyze(c *gin.Context) {
+	var req models.AnalyzeRequest
+
+	if err := bindRenderRequest(c, h.limits, &req, &req.Assets); err != nil {
+		respondError(c, bindErrorStatus(err), "invalid request", err)
+		return
+	}
+	m, ok := h.exportMesh(c, &req)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, mesh.Analyze(m))
+}
+
+// exportMesh exports the model of req in its mesh format, stl_binary unless
+// it chooses another, and parses the result. It responds with the error and
+// returns false if any step fails.
+func (h *Handler) exportMesh(c *gin.Context, req *models.AnalyzeRequest) (*mesh.Mesh, bool) {
+	if req.Format == "" {
+		req.Format = defaultAnalyzeFormat
+	}
+	if err := mesh.ValidateFormat(req.Format); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request", err)
+		return nil, false
+	}
+
+	logger := logging.FromContext(c.Request.Context())
+	result, err := h.openscadService.Export(c.Request.Context(), &models.ExportRequest{
+		ScadContent:     req.ScadContent,
+		Format:          req.Format,
+		OpenSCADVersion: req.OpenSCADVersion,
+		Backend:         req.Backend,
+		Features:        req.Features,
+		Parameters:      req.Parameters,
+		Assets:          req.Assets,
+		Options:         req.Options,
+	})
+	if err != nil {
+		logger.Error("export failed", "format", req.Format, "error", err)
+		respondError(c, renderErrorStatus(err), "export failed", err)
+		return nil, false
+	}
+
+	m, err := mesh.Parse(req.Format, result.Data)
+	if err != nil {
+		logger.Error("mesh analysis failed", "format", req.Format, "error", err)
+		respondError(c, http.StatusInternalServerError, "mesh analysis failed", err)
+		return nil, false
+	}
+	return m, true
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/models"
+)
+
+// tetrahedronSTL is a closed ASCII STL of the unit corner tetrahedron
+const tetrahedronSTL = `solid t
+facet normal 0 0 -1
+outer loop
+vertex 0 0 0
+vertex 0 1 0
+vertex 1 0 0
+endloop
+endfacet
+facet normal 0 -1 0
+outer loop
+vertex 0 0 0
+vertex 1 0 0
+vertex 0 0 1
+endloop
+endfacet
+facet normal -1 0 0
+outer loop
+vertex 0 0 0
+vertex 0 0 1
+vertex 0 1 0
+endloop
+endfacet
+facet normal 1 1 1
+outer loop
+vertex 1 0 0
+vertex 0 1 0
+vertex 0 0 1
+endloop
+endfacet
+endsolid t
+`
+
+func setupAnalyzeRouter(exporter *MockOpenSCADExporter) *gin.Engine {
+	h := NewHandlerWithService(exporter)
+	router := gin.New()
+	router.POST("/openscad/v1/analyze", h.Analyze)
+	router.POST("/openscad/v1/export", h.Export)
+	return router
+}
+
+func TestAnalyze(t *testing.T) {
+	var format string
+	router := setupAnalyzeRouter(&MockOpenSCADExporter{
+		ExportFunc: func(req *models.ExportRequest) ([]byte, string, error) {
+			format = req.Format
+			return []byte(tetrahedronSTL), "model/stl", nil
+		},
+	})
+
+	w := sendJSON(router, "POST", "/openscad/v1/analyze", map[string]string{"scad_content": "polyhedron(...);", "format": "stl_ascii"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var analysis models.MeshAnalysis
+	if err := json.Unmarshal(w.Body.Bytes(), &analysis); err != nil {
+		t.Fatalf("Expected a mesh analysis, got %v", err)
+	}
+	if format != "stl_ascii" || analysis.Triangles != 4 || analysis.Vertices != 4 || !analysis.Manifold {
+		t.Errorf("Expected a closed tetrahedron exported as stl_ascii, got %s %+v", format, analysis)
+	}
+	if math.Abs(analysis.Volume-1.0/6) > 1e-9 {
+		t.Errorf("Expected volume 1/6, got %g", analysis.Volume)
+	}
+
+	sendJSON(router, "POST", "/openscad/v1/analyze", map[string]string{"scad_content": "cube(1);"})
+	if format != defaultAnalyzeFormat {
+		t.Errorf("Expected the default format %s, got %s", defaultAnalyzeFormat, format)
+	}
+}
+
+func TestAnalyze_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       map[string]string
+		exportErr  error
+		wantStatus int
+	}{
+		{"Missing source", map[string]string{"format": "stl_ascii"}, nil, http.StatusBadRequest},
+		{"Not a mesh format", map[string]string{"scad_content": "cube(1);", "format": "png"}, nil, http.StatusBadRequest},
+		{"Unparsable output", map[string]string{"scad_content": "cube(1);", "format": "stl_binary"}, nil, http.StatusInternalServerError},
+		{"Render failure", map[string]string{"scad_content": "cube(1);", "format": "stl_ascii"}, errors.New("openscad command failed"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupAnalyzeRouter(&MockOpenSCADExporter{
+				ExportFunc: func(req *models.ExportRequest) ([]byte, string, error) {
+					return []byte("not a mesh"), "model/stl", tt.exportErr
+				},
+			})
+			if w := sendJSON(router, "POST", "/openscad/v1/analyze", tt.body); w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestExport_Analyze(t *testing.T) {
+	router := setupAnalyzeRouter(&MockOpenSCADExporter{
+		ExportFunc: func(req *models.ExportRequest) ([]byte, string, error) {
+			return []byte(tetrahedronSTL), "model/stl", nil
+		},
+	})
+
+	w := sendJSON(router, "POST", "/openscad/v1/export", map[string]any{"scad_content": "cube(1);", "format": "stl_ascii", "analyze": true})
+	if w.Code != http.StatusOK || w.Body.String() != tetrahedronSTL {
+		t.Fatalf("Expected the exported file, got %d: %s", w.Code, w.Body.String())
+	}
+	var analysis models.MeshAnalysis
+	if err := json.Unmarshal([]byte(w.Header().Get("X-Mesh-Analysis")), &analysis); err != nil || analysis.Triangles != 4 {
+		t.Errorf("Expected the analysis in X-Mesh-Analysis, got %q", w.Header().Get("X-Mesh-Analysis"))
+	}
+
+	w = sendJSON(router, "POST", "/openscad/v1/export", map[string]any{"scad_content": "cube(1);", "format": "png", "analyze": true})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for analyzing a PNG export, got %d", w.Code)
+	}
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/artifacts"
+	"github.com/stevexciv/scad-server/logging"
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/services"
+)
+
+// errArtifactsDisabled is returned when no artifact store is configured
+var errArtifactsDisabled = errors.New("artifact storage is disabled")
+
+// ArtifactHandler saves renders for download through signed, expiring links
+type ArtifactHandler struct {
+	exporter services.OpenSCADExporter
+	store    *artifacts.Store
+	baseURL  string
+	limits   RequestLimits
+}
+
+// NewArtifactHandler creates a new artifact handler. A nil store disables the
+// endpoints. Download links start with baseURL, or with the scheme and host
+// of the request when baseURL is empty.
+func NewArtifactHandler(exporter services.OpenSCADExporter, store *artifacts.Store, baseURL string) *ArtifactHandler {
+	return &ArtifactHandler{
+		exporter: exporter,
+		store:    store,
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		limits:   DefaultRequestLimits,
+	}
+}
+
+// WithRequestLimits refuses render requests uploading more than limits allow
+func (h *ArtifactHandler) WithRequestLimits(limits RequestLimits) *ArtifactHandler {
+	h.limits = limits
+	return h
+}
+
+// Create handles the artifact creation endpoint
+// @Summary Render and store an artifact
+// @Description Renders an export request like the export endpoint, stores the result with its source hash, format, options and render time, and returns a signed download link that is valid until the artifact expires
+// @Tags artifacts
+// @Accept json,mpfd,application/x-openscad
+// @Produce json
+// @Param request body models.ExportRequest true "Export request"
+// @Success 201 {object} models.ArtifactResponse "Stored artifact"
+// @Failure 400 {object} models.ErrorResponse "Bad Request"
+// @Failure 413 {object} models.ErrorResponse "Asset Too Large"
+// @Failure 429 {object} models.ErrorResponse "Quota Exceeded"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 501 {object} models.ErrorResponse "Artifact storage disabled"
+// @Failure 503 {object} models.ErrorResponse "Shutting Down"
+// @Failure 507 {object} models.ErrorResponse "Storage Full"
+// @Router /openscad/v1/artifacts [post]
+func (h *ArtifactHandler) Create(c *gin.Context) {
+	if h.store == nil {
+		respondError(c, http.StatusNotImplemented, "artifact creation failed", errArtifactsDisabled)
+		return
+	}
+
+	var req models.ExportRequest
+	if err := bindRenderRequest(c, h.limits, &req, &req.Assets); err != nil {
+		respondError(c, bindErrorStatus(err), "invalid request", err)
+		return
+	}
+	if req.CallbackURL != "" {
+		respondError(c, http.StatusBadRequest, "invalid request", errors.New("callback_url is not supported here, send it to the export endpoint"))
+		return
+	}
+	if req.SummaryType != "" || req.Analyze {
+		respondError(c, http.StatusBadRequest, "invalid request", errors.New("summary_type and analyze are not supported here, send them to the export endpoint"))
+		return
+	}
+
+	logger := logging.FromContext(c.Request.Context())
+	start := time.Now()
+	result, err := h.exporter.Export(c.Request.Context(), &req)
+	if err != nil {
+		logger.Error("export failed", "format", req.Format, "error", err)
+		respondError(c, exportErrorStatus(err, req.Format), "export failed", err)
+		return
+	}
+
+	resp, err := h.save(c.Request.Context(), h.linkBase(c), &req, result.Data, result.ContentType, time.Since(start))
+	if err != nil {
+		respondError(c, artifactErrorStatus(err), "artifact storage failed", err)
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// Metadata handles the artifact metadata endpoint
+// @Summary Describe a stored artifact
+// @Description Returns how a stored artifact was rendered to holders of its signed download link, which is passed along unchanged
+// @Tags artifacts
+// @Produce json
+// @Param id path string true "Artifact ID"
+// @Param expires query int true "Link expiry as Unix seconds"
+// @Param sig query string true "Link signature"
+// @Success 200 {object} models.ArtifactResponse "Stored artifact"
+// @Failure 403 {object} models.ErrorResponse "Invalid or expired link"
+// @Failure 404 {object} models.ErrorResponse "Artifact not found"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 501 {object} models.ErrorResponse "Artifact storage disabled"
+// @Router /openscad/v1/artifacts/{id}/metadata [get]
+func (h *ArtifactHandler) Metadata(c *gin.Context) {
+	if h.store == nil {
+		respondError(c, http.StatusNotImplemented, "artifact lookup failed", errArtifactsDisabled)
+		return
+	}
+
+	id := c.Param("id")
+	if err := h.store.Verify(id, c.Query("expires"), c.Query("sig")); err != nil {
+		respondError(c, http.StatusForbidden, "artifact lookup failed", err)
+		return
+	}
+
+	a, err := h.store.Get(c.Request.Context(), id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, artifacts.ErrNotFound) {
+			status = http.StatusNotFound
+		} else {
+			logging.FromContext(c.Request.Context()).Error("artifact lookup failed", "id", id, "error", err)
+		}
+		respondError(c, status, "artifact lookup failed", err)
+		return
+	}
+	c.JSON(http.StatusOK, models.ArtifactResponse{Artifact: *a, URL: h.downloadURL(h.linkBase(c), a)})
+}
+
+// Download handles the artifact download endpoint
+// @Summary Download a stored artifact
+// @Description Serves a stored artifact to holders of a signed download link. Range requests are supported.
+// @Tags artifacts
+// @Produce octet-stream
+// @Param id path string true "Artifact ID"
+// @Param expires query int true "Link expiry as Unix seconds"
+// @Param sig query string true "Link signature"
+// @Success 200 {file} binary "Artifact content"
+// @Failure 403 {object} models.ErrorResponse "Invalid or expired link"
+// @Failure 404 {object} models.ErrorResponse "Artifact not found"
+// @Failure 501 {object} models.ErrorResponse "Artifact storage disabled"
+// @Router /openscad/v1/artifacts/{id} [get]
+func (h *ArtifactHandler) Download(c *gin.Context) {
+	if h.store == nil {
+		respondError(c, http.StatusNotImplemented, "download failed", errArtifactsDisabled)
+		return
+	}
+
+	id := c.Param("id")
+	if err := h.store.Verify(id, c.Query("expires"), c.Query("sig")); err != nil {
+		respondError(c, http.StatusForbidden, "download failed", err)
+		return
+	}
+
+	a, f, err := h.store.Open(c.Request.Context(), id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, artifacts.ErrNotFound) {
+			status = http.StatusNotFound
+		} else {
+			logging.FromContext(c.Request.Context()).Error("artifact download failed", "id", id, "error", err)
+		}
+		respondError(c, status, "download failed", err)
+		return
+	}
+	defer f.Close()
+
+	c.Header("Content-Type", a.ContentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(time.Until(a.ExpiresAt).Seconds())))
+	http.ServeContent(c.Writer, c.Request, a.Filename, a.CreatedAt, f)
+}
+
+// enabled reports whether renders can be stored
+func (h *ArtifactHandler) enabled() bool {
+	return h != nil && h.store != nil
+}
+
+// save stores a render of req along with how it was produced, returning it
+// with a download link starting with base
+func (h *ArtifactHandler) save(ctx context.Context, base string, req *models.ExportRequest, data []byte, contentType string, elapsed time.Duration) (*models.ArtifactResponse, error) {
+	sum := sha256.Sum256([]byte(req.ScadContent))
+	a, err := h.store.Put(ctx, data, models.Artifact{
+		Filename:        "model." + services.FileExtension(req.Format),
+		ContentType:     contentType,
+		Format:          req.Format,
+		SourceHash:      "sha256:" + hex.EncodeToString(sum[:]),
+		OpenSCADVersion: req.OpenSCADVersion,
+		Options:         req.Options,
+		Parameters:      req.Parameters,
+		RenderSeconds:   elapsed.Seconds(),
+	})
+	logger := logging.FromContext(ctx)
+	if err != nil {
+		logger.Error("artifact storage failed", "error", err)
+		return nil, err
+	}
+	logger.Info("artifact stored", "id", a.ID, "bytes", a.Size, "expires_at", a.ExpiresAt)
+	return &models.ArtifactResponse{Artifact: *a, URL: h.downloadURL(base, a)}, nil
+}
+
+// artifactErrorStatus maps a storage error to its HTTP status
+func artifactErrorStatus(err error) int {
+	if errors.Is(err, artifacts.ErrStorageFull) {
+		return http.StatusInsufficientStorage
+	}
+	return http.StatusInternalServerError
+}
+
+// linkBase returns the prefix of download links: the configured base URL, or
+// the scheme and host of the request
+func (h *ArtifactHandler) linkBase(c *gin.Context) string {
+	if h.baseURL != "" {
+		return h.baseURL
+	}
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// downloadURL returns the signed download link of a, starting with base
+func (h *ArtifactHandler) downloadURL(base string, a *models.Artifact) string {
+	query := url.Values{
+		"expires": {strconv.FormatInt(a.ExpiresAt.Unix(), 10)},
+		"sig":     {h.store.Sign(a.ID, a.ExpiresAt)},
+	}
+	return base + "/openscad/v1/artifacts/" + a.ID + "?" + query.Encode()
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/artifacts"
+	"github.com/stevexciv/scad-server/models"
+)
+
+func setupArtifactRouter(t *testing.T, maxBytes int64, baseURL string) *gin.Engine {
+	t.Helper()
+	var store *artifacts.Store
+	if maxBytes >= 0 {
+		var err error
+		store, err = artifacts.New(context.Background(), artifacts.Config{Dir: t.TempDir(), TTL: time.Hour, MaxBytes: maxBytes, SigningKey: []byte("key")})
+		if err != nil {
+			t.Fatalf("Failed to create store: %v", err)
+		}
+	}
+	exporter := &MockOpenSCADExporter{
+		ExportFunc: func(req *models.ExportRequest) ([]byte, string, error) {
+			return []byte("solid cube"), "application/octet-stream", nil
+		},
+	}
+
+	router := gin.New()
+	stored := NewArtifactHandler(exporter, store, baseURL)
+	h := NewHandlerWithService(exporter).WithArtifacts(stored)
+	router.POST("/openscad/v1/export", h.Export)
+	router.POST("/openscad/v1/artifacts", stored.Create)
+	router.GET("/openscad/v1/artifacts/:id", stored.Download)
+	router.GET("/openscad/v1/artifacts/:id/metadata", stored.Metadata)
+	return router
+}
+
+func createArtifact(router *gin.Engine) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/openscad/v1/artifacts", bytes.NewBufferString(`{"scad_content": "cube(1);", "format": "stl_binary"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestArtifacts_CreateDownload(t *testing.T) {
+	router := setupArtifactRouter(t, 0, "")
+
+	w := createArtifact(router)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created models.ArtifactResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if created.Filename != "model.stl" || created.Size != 10 || !strings.HasPrefix(created.URL, "http://example.com/openscad/v1/artifacts/"+created.ID+"?") {
+		t.Errorf("Expected model.stl with a link on the request host, got %+v", created)
+	}
+
+	link, err := url.Parse(created.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse link: %v", err)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", link.RequestURI(), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Body.String() != "solid cube" {
+		t.Errorf("Expected artifact content, got %q", w.Body.String())
+	}
+	if cd := w.Header().Get("Content-Disposition"); cd != "attachment; filename=model.stl" {
+		t.Errorf("Expected attachment disposition, got %q", cd)
+	}
+
+	// Range requests let clients resume large downloads
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", link.RequestURI(), nil)
+	req.Header.Set("Range", "bytes=6-")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusPartialContent || w.Body.String() != "cube" {
+		t.Errorf("Expected partial content \"cube\", got %d %q", w.Code, w.Body.String())
+	}
+
+	// Tampering with the link invalidates it
+	query := link.Query()
+	query.Set("expires", "9999999999")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", link.Path+"?"+query.Encode(), nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for a tampered link, got %d", w.Code)
+	}
+}
+
+func TestArtifacts_Metadata(t *testing.T) {
+	router := setupArtifactRouter(t, 0, "")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/openscad/v1/artifacts", bytes.NewBufferString(
+		`{"scad_content": "cube(size);", "format": "stl_binary", "parameters": {"size": 5}}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	var created models.ArtifactResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+	link, err := url.Parse(created.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse download link: %v", err)
+	}
+
+	// The ID alone doesn't reveal the artifact or mint a new link
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/openscad/v1/artifacts/"+created.ID+"/metadata", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without a signature, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/openscad/v1/artifacts/"+created.ID+"/metadata?"+link.RawQuery, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var got models.ArtifactResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	sum := sha256.Sum256([]byte("cube(size);"))
+	wantHash := "sha256:" + hex.EncodeToString(sum[:])
+	if got.ID != created.ID || got.Format != "stl_binary" || got.SourceHash != wantHash || got.Parameters["size"] != 5.0 {
+		t.Errorf("Expected render metadata, got %+v", got)
+	}
+	if got.URL != created.URL {
+		t.Errorf("Expected the same download link, got %s", got.URL)
+	}
+}
+
+func TestExport_Store(t *testing.T) {
+	router := setupArtifactRouter(t, 0, "")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/openscad/v1/export", bytes.NewBufferString(`{"scad_content": "cube(1);", "format": "stl_binary", "store": true}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Body.String() != "solid cube" {
+		t.Errorf("Expected the exported file, got %q", w.Body.String())
+	}
+	id := w.Header().Get("X-Artifact-ID")
+	link, err := url.Parse(w.Header().Get("X-Artifact-URL"))
+	if id == "" || err != nil || !strings.HasSuffix(link.Path, "/"+id) {
+		t.Fatalf("Expected artifact headers, got %v", w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", link.RequestURI(), nil))
+	if w.Code != http.StatusOK || w.Body.String() != "solid cube" {
+		t.Errorf("Expected stored export to download, got %d %q", w.Code, w.Body.String())
+	}
+
+	// Without store the export isn't kept
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/openscad/v1/export?format=stl_binary", strings.NewReader("cube(1);"))
+	req.Header.Set("Content-Type", "application/x-openscad")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("X-Artifact-ID") != "" {
+		t.Errorf("Expected an unstored export, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestArtifacts_BaseURL(t *testing.T) {
+	router := setupArtifactRouter(t, 0, "https://scad.example.com/")
+
+	w := createArtifact(router)
+	var created models.ArtifactResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if !strings.HasPrefix(created.URL, "https://scad.example.com/openscad/v1/artifacts/") {
+		t.Errorf("Expected link on the base URL, got %s", created.URL)
+	}
+}
+
+func TestArtifacts_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxBytes   int64
+		wantStatus int
+	}{
+		{"Disabled", -1, http.StatusNotImplemented},
+		{"Storage full", 4, http.StatusInsufficientStorage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupArtifactRouter(t, tt.maxBytes, "")
+			if w := createArtifact(router); w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	router := setupArtifactRouter(t, 0, "")
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/openscad/v1/artifacts", bytes.NewBufferString(`{"scad_content": "cube(1);", "format": "stl_binary", "summary_type": "all"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for artifacts with a summary type, got %d", w.Code)
+	}
+
+	router = setupArtifactRouter(t, -1, "")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/openscad/v1/artifacts/0123456789abcdef0123456789abcdef", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status 501 for downloads without a store, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/openscad/v1/export?format=stl_binary&store=true", strings.NewReader("cube(1);"))
+	req.Header.Set("Content-Type", "application/x-openscad")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status 501 for stored exports without a store, got %d", w.Code)
+	}
+}
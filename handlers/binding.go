@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/services"
+)
+
+// MIMEOpenSCAD is the content type of raw SCAD source request bodies
+const MIMEOpenSCAD = "application/x-openscad"
+
+const (
+	// requestField is the multipart field holding the JSON request
+	requestField = "request"
+	// assetsField is the multipart field whose file parts become assets
+	assetsField = "assets"
+	// sourceField is the multipart field whose file part holds the SCAD source
+	sourceField = "file"
+)
+
+// errRequestTooLarge is returned for request bodies over the request limits
+var errRequestTooLarge = errors.New("request too large")
+
+// RequestLimits bounds what a render request may upload, so that oversized
+// requests are refused before they are read into memory
+type RequestLimits struct {
+	// MaxSourceBytes is the largest SCAD source a request may carry
+	MaxSourceBytes int64
+	// MaxAssetBytes is the largest asset a request may carry
+	MaxAssetBytes int64
+	// MaxAssets is the number of assets a request may carry
+	MaxAssets int
+}
+
+// DefaultRequestLimits are the limits of handlers not given any
+var DefaultRequestLimits = RequestLimits{
+	MaxSourceBytes: 10 << 20,
+	MaxAssetBytes:  services.DefaultMaxAssetBytes,
+	MaxAssets:      services.DefaultMaxAssets,
+}
+
+// maxBody returns the largest request body of the given content type: the
+// source alone for raw SCAD bodies, and room for the source and every asset
+// otherwise, with JSON bodies allowing for base64 encoding
+func (l RequestLimits) maxBody(contentType string) int64 {
+	switch contentType {
+	case MIMEOpenSCAD:
+		return l.MaxSourceBytes
+	case binding.MIMEMultipartPOSTForm:
+		return l.MaxSourceBytes + int64(l.MaxAssets)*l.MaxAssetBytes + multipartOverhead
+	default:
+		return (l.MaxSourceBytes+int64(l.MaxAssets)*l.MaxAssetBytes)/3*4 + multipartOverhead
+	}
+}
+
+// bindErrorStatus maps errors from bindRenderRequest to status codes
+func bindErrorStatus(err error) int {
+	if errors.Is(err, errRequestTooLarge) || errors.Is(err, services.ErrAssetTooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// tooLarge wraps errors from reading a body cut off by http.MaxBytesReader as
+// errRequestTooLarge
+func tooLarge(err error) error {
+	var maxBytes *http.MaxBytesError
+	if errors.As(err, &maxBytes) {
+		return fmt.Errorf("%w: bodies are limited to %d bytes", errRequestTooLarge, maxBytes.Limit)
+	}
+	return err
+}
+
+// stringFields are the request fields that may be given as form fields or
+// query parameters
+var stringFields = []string{"scad_content", "format", "summary_type", "openscad_version", "backend", "callback_url"}
+
+// boolFields are the boolean request fields that may be given as form fields
+// or query parameters
+var boolFields = []string{"store", "analyze", "overlay"}
+
+// numberFields are the numeric request fields that may be given as form
+// fields or query parameters
+var numberFields = []string{"overhang_angle", "nozzle_width", "min_wall_thickness"}
+
+// optionTypes maps each options object and field to its schema type, e.g.
+// optionTypes["png"]["width"] is "integer"
+var optionTypes = func() map[string]map[string]string {
+	types := make(map[string]map[string]string)
+	for _, f := range services.ExportFormats() {
+		fields := make(map[string]string, len(f.Options))
+		for _, o := range f.Options {
+			fields[o.Name] = o.Type
+		}
+		types[f.OptionsKey] = fields
+	}
+	return types
+}()
+
+// bindRenderRequest reads a render request in one of three forms and
+// validates it:
+//
+//   - a JSON body
+//   - multipart/form-data, either with the JSON request in a "request" field
+//     or with the SCAD source as a "file" part (or "scad_content" field) and
+//     the other request fields as form fields; "assets" file parts are
+//     appended to assets under their file names
+//   - a raw application/x-openscad body holding the SCAD source, with the
+//     other request fields as query parameters
+//
+// Outside JSON, features may be repeated or comma-separated, parameters are a
+// JSON object, and options are given either as an "options" JSON object or
+// per field, e.g. png.width=800. Bodies larger than limits allow are refused
+// with errRequestTooLarge, and assets over the limits with
+// services.ErrAssetTooLarge, before they are read.
+func bindRenderRequest(c *gin.Context, limits RequestLimits, req any, assets *[]models.Asset) error {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limits.maxBody(c.ContentType()))
+
+	switch c.ContentType() {
+	case binding.MIMEMultipartPOSTForm:
+		if err := bindMultipart(c, limits, req, assets); err != nil {
+			return tooLarge(err)
+		}
+	case MIMEOpenSCAD:
+		source, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read SCAD source: %w", tooLarge(err))
+		}
+		values := c.Request.URL.Query()
+		values.Set("scad_content", string(source))
+		if err := bindValues(values, req); err != nil {
+			return err
+		}
+	default:
+		return tooLarge(c.ShouldBindJSON(req))
+	}
+	return binding.Validator.ValidateStruct(req)
+}
+
+// bindMultipart decodes a multipart form into req and assets, refusing asset
+// parts over limits before reading them
+func bindMultipart(c *gin.Context, limits RequestLimits, req any, assets *[]models.Asset) error {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return err
+	}
+
+	if values, ok := form.Value[requestField]; ok {
+		if len(values) != 1 {
+			return fmt.Errorf("multipart requests need exactly one %q field holding the JSON request", requestField)
+		}
+		if err := json.Unmarshal([]byte(values[0]), req); err != nil {
+			return err
+		}
+	} else {
+		values := url.Values(form.Value)
+		if files := form.File[sourceField]; len(files) > 0 {
+			if len(files) > 1 || values.Has("scad_content") {
+				return fmt.Errorf("send the SCAD source once, as a single %q part or the scad_content field", sourceField)
+			}
+			if files[0].Size > limits.MaxSourceBytes {
+				return fmt.Errorf("%w: SCAD sources are limited to %d bytes", errRequestTooLarge, limits.MaxSourceBytes)
+			}
+			source, err := readFormFile(files[0])
+			if err != nil {
+				return err
+			}
+			values.Set("scad_content", string(source))
+		}
+		if err := bindValues(values, req); err != nil {
+			return err
+		}
+	}
+
+	files := form.File[assetsField]
+	if len(files) > limits.MaxAssets {
+		return fmt.Errorf("%w: %d assets sent, at most %d allowed", services.ErrAssetTooLarge, len(files), limits.MaxAssets)
+	}
+	for _, fh := range files {
+		if fh.Size > limits.MaxAssetBytes {
+			return fmt.Errorf("%w: %s is %d bytes, at most %d allowed", services.ErrAssetTooLarge, fh.Filename, fh.Size, limits.MaxAssetBytes)
+		}
+		data, err := readFormFile(fh)
+		if err != nil {
+			return err
+		}
+		*assets = append(*assets, models.Asset{Name: fh.Filename, Data: data})
+	}
+	return nil
+}
+
+// bindValues decodes form fields or query parameters into req by building
+// the equivalent JSON request, so every request form ends up in the same
+// model. Unknown parameters are ignored.
+func bindValues(values url.Values, req any) error {
+	fields := make(map[string]any)
+	for _, name := range stringFields {
+		if v := values.Get(name); v != "" {
+			fields[name] = v
+		}
+	}
+
+	if list, ok := values["features"]; ok {
+		features := []string{}
+		for _, v := range list {
+			for _, f := range strings.Split(v, ",") {
+				if f = strings.TrimSpace(f); f != "" {
+					features = append(features, f)
+				}
+			}
+		}
+		fields["features"] = features
+	}
+
+	for _, name := range boolFields {
+		if v := values.Get(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%s must be a boolean, got %q", name, v)
+			}
+			fields[name] = b
+		}
+	}
+
+	for _, name := range numberFields {
+		if v := values.Get(name); v != "" {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("%s must be a number, got %q", name, v)
+			}
+			fields[name] = n
+		}
+	}
+
+	if v := values.Get("build_volume"); v != "" {
+		volume := []float64{}
+		for _, part := range strings.Split(v, ",") {
+			n, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return fmt.Errorf("build_volume must be comma separated numbers, got %q", v)
+			}
+			volume = append(volume, n)
+		}
+		fields["build_volume"] = volume
+	}
+
+	if raw := values.Get("parameters"); raw != "" {
+		if !json.Valid([]byte(raw)) {
+			return errors.New("parameters must be a JSON object")
+		}
+		fields["parameters"] = json.RawMessage(raw)
+	}
+
+	options, err := optionValues(values)
+	if err != nil {
+		return err
+	}
+	if options != nil {
+		fields["options"] = options
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, req)
+}
+
+// optionValues reads export options from an "options" JSON parameter or from
+// per-field parameters such as png.width=800, converting each to the type of
+// its field. It returns nil when no options are given.
+func optionValues(values url.Values) (any, error) {
+	perField := make(map[string]map[string]any)
+	for key, list := range values {
+		group, field, ok := strings.Cut(key, ".")
+		if !ok {
+			continue
+		}
+		typ, known := optionTypes[group][field]
+		if !known {
+			return nil, fmt.Errorf("unknown option %q", key)
+		}
+		v, err := parseOptionValue(typ, list[len(list)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid option %q: %w", key, err)
+		}
+		if perField[group] == nil {
+			perField[group] = make(map[string]any)
+		}
+		perField[group][field] = v
+	}
+
+	raw := values.Get("options")
+	switch {
+	case raw != "" && len(perField) > 0:
+		return nil, errors.New("give options either as an options JSON object or as per-field parameters, not both")
+	case raw != "":
+		if !json.Valid([]byte(raw)) {
+			return nil, errors.New("options must be a JSON object")
+		}
+		return json.RawMessage(raw), nil
+	case len(perField) > 0:
+		return perField, nil
+	default:
+		return nil, nil
+	}
+}
+
+// parseOptionValue converts a parameter to the JSON type of its option field
+func parseOptionValue(typ, v string) (any, error) {
+	switch typ {
+	case "integer":
+		return strconv.Atoi(v)
+	case "number":
+		return strconv.ParseFloat(v, 64)
+	case "boolean":
+		return strconv.ParseBool(v)
+	default:
+		return v, nil
+	}
+}
+
+// readFormFile reads an uploaded multipart file
+func readFormFile(fh *multipart.FileHeader) ([]byte, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", fh.Filename, err)
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// renderErrorStatus maps errors from the OpenSCAD service to status codes
+func renderErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrUnknownVersion),
+		errors.Is(err, services.ErrUnsupportedFeature),
+		errors.Is(err, services.ErrMissingFont),
+		errors.Is(err, services.ErrInvalidAsset),
+		errors.Is(err, services.ErrInvalidParameter),
+		errors.Is(err, services.ErrUnknownSummaryType):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrAssetTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, services.ErrShuttingDown):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// exportErrorStatus maps export errors to status codes, treating an
+// unsupported format as a bad request
+func exportErrorStatus(err error, format string) int {
+	if err.Error() == "unsupported format: "+format {
+		return http.StatusBadRequest
+	}
+	return renderErrorStatus(err)
+}
@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/services"
+)
+
+// assetRecorder returns a mock exporter that stores the assets it receives
+func assetRecorder(got *[]models.Asset) *MockOpenSCADExporter {
+	return &MockOpenSCADExporter{
+		ExportFunc: func(req *models.ExportRequest) ([]byte, string, error) {
+			*got = req.Assets
+			return []byte("ok"), "application/octet-stream", nil
+		},
+		SummaryFunc: func(req *models.SummaryRequest) (*models.SummaryResponse, error) {
+			*got = req.Assets
+			return &models.SummaryResponse{}, nil
+		},
+	}
+}
+
+func TestExport_JSONAssets(t *testing.T) {
+	var got []models.Asset
+	router := setupRouterWithMock(assetRecorder(&got))
+
+	// "c29saWQ=" is base64 for "solid"
+	body := `{"scad_content":"import(\"a.stl\");","format":"stl_binary","assets":[{"name":"a.stl","data":"c29saWQ="}]}`
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/openscad/v1/export", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(got) != 1 || got[0].Name != "a.stl" || string(got[0].Data) != "solid" {
+		t.Errorf("Expected decoded asset a.stl, got %+v", got)
+	}
+}
+
+// multipartRenderRequest builds a multipart request with the JSON request in
+// the request field and the given files as asset parts
+func multipartRenderRequest(t *testing.T, path, request string, files map[string]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	if request != "" {
+		w.WriteField("request", request)
+	}
+	for name, content := range files {
+		part, err := w.CreateFormFile("assets", name)
+		if err != nil {
+			t.Fatalf("Failed to create form file: %v", err)
+		}
+		part.Write([]byte(content))
+	}
+	w.Close()
+
+	req := httptest.NewRequest("POST", path, &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+func TestRenderEndpoints_MultipartAssets(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		request    string
+		files      map[string]string
+		wantStatus int
+		wantAssets int
+	}{
+		{"Export with assets", "/openscad/v1/export", `{"scad_content":"surface(\"h.png\");","format":"stl_binary"}`, map[string]string{"h.png": "png", "logo.svg": "<svg/>"}, http.StatusOK, 2},
+		{"Summary with asset", "/openscad/v1/summary", `{"scad_content":"import(\"a.stl\");"}`, map[string]string{"a.stl": "solid"}, http.StatusOK, 1},
+		{"Missing request field", "/openscad/v1/export", "", map[string]string{"a.stl": "solid"}, http.StatusBadRequest, 0},
+		{"Invalid request JSON", "/openscad/v1/export", `{"format":`, nil, http.StatusBadRequest, 0},
+		{"Missing required field", "/openscad/v1/export", `{"scad_content":"cube(1);"}`, nil, http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []models.Asset
+			router := setupRouterWithMock(assetRecorder(&got))
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, multipartRenderRequest(t, tt.path, tt.request, tt.files))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if len(got) != tt.wantAssets {
+				t.Errorf("Expected %d assets, got %d", tt.wantAssets, len(got))
+			}
+		})
+	}
+}
+
+func TestExport_RequestLimits(t *testing.T) {
+	limits := RequestLimits{MaxSourceBytes: 64, MaxAssetBytes: 8, MaxAssets: 2}
+	request := `{"scad_content":"import(\"a.stl\");","format":"stl_binary"}`
+	tests := []struct {
+		name       string
+		request    string
+		files      map[string]string
+		wantStatus int
+	}{
+		{"Within limits", request, map[string]string{"a.stl": "solid", "b.stl": "solid"}, http.StatusOK},
+		{"Too many assets", request, map[string]string{"a.stl": "solid", "b.stl": "solid", "c.stl": "solid"}, http.StatusRequestEntityTooLarge},
+		{"Asset too large", request, map[string]string{"a.stl": "solid cube"}, http.StatusRequestEntityTooLarge},
+		{"Body too large", request[:len(request)-1] + `,"parameters":{"pad":"` + strings.Repeat("x", int(limits.maxBody(binding.MIMEMultipartPOSTForm))) + `"}}`, nil, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []models.Asset
+			router := gin.New()
+			router.POST("/openscad/v1/export", NewHandlerWithService(assetRecorder(&got)).WithRequestLimits(limits).Export)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, multipartRenderRequest(t, "/openscad/v1/export", tt.request, tt.files))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK && got != nil {
+				t.Errorf("Expected the request to be refused before rendering, got %d assets", len(got))
+			}
+		})
+	}
+
+	// JSON bodies are capped too, allowing for base64
+	router := gin.New()
+	router.POST("/openscad/v1/export", NewHandlerWithService(&MockOpenSCADExporter{}).WithRequestLimits(limits).Export)
+	body := `{"scad_content":"` + strings.Repeat("x", int(limits.maxBody(binding.MIMEJSON))) + `","format":"stl_binary"}`
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/openscad/v1/export", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413 for an oversized JSON body, got %d", w.Code)
+	}
+
+	// Raw SCAD bodies are limited to the source size
+	for _, tt := range []struct {
+		size       int
+		wantStatus int
+	}{
+		{size: 64, wantStatus: http.StatusOK},
+		{size: 65, wantStatus: http.StatusRequestEntityTooLarge},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/openscad/v1/export?format=stl_binary", strings.NewReader(strings.Repeat(" ", tt.size)))
+		req.Header.Set("Content-Type", MIMEOpenSCAD)
+		router.ServeHTTP(w, req)
+		if w.Code != tt.wantStatus {
+			t.Errorf("Expected status %d for a %d byte source, got %d: %s", tt.wantStatus, tt.size, w.Code, w.Body.String())
+		}
+	}
+
+	// As are SCAD sources sent as a multipart file part
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	mw.WriteField("format", "stl_binary")
+	part, _ := mw.CreateFormFile(sourceField, "model.scad")
+	part.Write([]byte(strings.Repeat(" ", 65)))
+	mw.Close()
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/openscad/v1/export", &form)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413 for an oversized source part, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestExport_AssetErrors(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantStatus int
+	}{
+		{fmt.Errorf("%w: \"../a.stl\"", services.ErrInvalidAsset), http.StatusBadRequest},
+		{fmt.Errorf("%w: a.stl is 20 bytes, limit is 10", services.ErrAssetTooLarge), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			router := setupRouterWithMock(&MockOpenSCADExporter{
+				ExportFunc: func(req *models.ExportRequest) ([]byte, string, error) {
+					return nil, "", tt.err
+				},
+			})
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/openscad/v1/export", bytes.NewBufferString(`{"scad_content":"cube(1);","format":"png"}`))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+		})
+	}
+}
+
+// requestRecorder returns a mock exporter that stores the requests it receives
+func requestRecorder(export *models.ExportRequest, summary *models.SummaryRequest) *MockOpenSCADExporter {
+	return &MockOpenSCADExporter{
+		ExportFunc: func(req *models.ExportRequest) ([]byte, string, error) {
+			*export = *req
+			return []byte("ok"), "application/octet-stream", nil
+		},
+		SummaryFunc: func(req *models.SummaryRequest) (*models.SummaryResponse, error) {
+			*summary = *req
+			return &models.SummaryResponse{}, nil
+		},
+	}
+}
+
+func TestExport_RawBody(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		check      func(t *testing.T, req models.ExportRequest)
+	}{
+		{
+			name:       "Per-field options",
+			query:      "format=png&png.width=1024&png.height=768&features=roof,lazy-union&backend=manifold",
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, req models.ExportRequest) {
+				if req.Format != "png" || req.Backend != "manifold" {
+					t.Errorf("Expected png with manifold, got %s %s", req.Format, req.Backend)
+				}
+				if req.Options.PNG == nil || *req.Options.PNG.Width != 1024 || *req.Options.PNG.Height != 768 {
+					t.Errorf("Expected 1024x768 PNG options, got %+v", req.Options.PNG)
+				}
+				if len(req.Features) != 2 || req.Features[1] != "lazy-union" {
+					t.Errorf("Expected [roof lazy-union], got %v", req.Features)
+				}
+			},
+		},
+		{
+			name:       "Options JSON",
+			query:      `format=svg&options={"svg":{"fill":true,"stroke_width":0.5}}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, req models.ExportRequest) {
+				if req.Options.SVG == nil || !*req.Options.SVG.Fill || *req.Options.SVG.StrokeWidth != 0.5 {
+					t.Errorf("Expected SVG options, got %+v", req.Options.SVG)
+				}
+			},
+		},
+		{
+			name:       "Repeated features",
+			query:      "format=stl_binary&stl.decimal_precision=8&features=roof&features=textmetrics&openscad_version=nightly",
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, req models.ExportRequest) {
+				if len(req.Features) != 2 || req.OpenSCADVersion != "nightly" || *req.Options.STL.DecimalPrecision != 8 {
+					t.Errorf("Expected features, version and STL precision, got %+v", req)
+				}
+			},
+		},
+		{"Missing format", "png.width=10", http.StatusBadRequest, nil},
+		{"Unknown option", "format=png&png.depth=3", http.StatusBadRequest, nil},
+		{"Wrong option type", "format=png&png.width=wide", http.StatusBadRequest, nil},
+		{"Both option forms", `format=png&png.width=10&options={"png":{}}`, http.StatusBadRequest, nil},
+		{"Invalid options JSON", "format=png&options={", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var export models.ExportRequest
+			router := setupRouterWithMock(requestRecorder(&export, nil))
+
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("Failed to parse query: %v", err)
+			}
+			u := url.URL{Path: "/openscad/v1/export", RawQuery: query.Encode()}
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", u.String(), bytes.NewBufferString("cube([10,10,10]);"))
+			req.Header.Set("Content-Type", "application/x-openscad")
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.check != nil {
+				if export.ScadContent != "cube([10,10,10]);" {
+					t.Errorf("Expected the body as SCAD source, got %q", export.ScadContent)
+				}
+				tt.check(t, export)
+			}
+		})
+	}
+}
+
+func TestRenderEndpoints_MultipartFields(t *testing.T) {
+	var export models.ExportRequest
+	var summary models.SummaryRequest
+	router := setupRouterWithMock(requestRecorder(&export, &summary))
+
+	build := func(path string, fields map[string]string, source string) *http.Request {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		for k, v := range fields {
+			w.WriteField(k, v)
+		}
+		if source != "" {
+			part, _ := w.CreateFormFile("file", "model.scad")
+			part.Write([]byte(source))
+		}
+		part, _ := w.CreateFormFile("assets", "logo.svg")
+		part.Write([]byte("<svg/>"))
+		w.Close()
+
+		req := httptest.NewRequest("POST", path, &body)
+		req.Header.Set("Content-Type", w.FormDataContentType())
+		return req
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, build("/openscad/v1/export", map[string]string{"format": "3mf", "3mf.unit": "inch", "3mf.add_metadata": "false"}, `import("logo.svg");`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if export.ScadContent != `import("logo.svg");` || export.Format != "3mf" {
+		t.Errorf("Expected source from the file part, got %+v", export)
+	}
+	if export.Options.ThreeMF == nil || *export.Options.ThreeMF.Unit != "inch" || *export.Options.ThreeMF.AddMetadata {
+		t.Errorf("Expected 3MF options, got %+v", export.Options.ThreeMF)
+	}
+	if len(export.Assets) != 1 || export.Assets[0].Name != "logo.svg" {
+		t.Errorf("Expected logo.svg asset, got %+v", export.Assets)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, build("/openscad/v1/summary", map[string]string{"scad_content": "cube(1);", "summary_type": "geometry"}, ""))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if summary.ScadContent != "cube(1);" || summary.SummaryType != "geometry" {
+		t.Errorf("Expected summary fields, got %+v", summary)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, build("/openscad/v1/export", map[string]string{"format": "png", "scad_content": "cube(1);"}, "sphere(1);"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for two sources, got %d", w.Code)
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/logging"
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/webhooks"
+)
+
+// errCallbacksDisabled is returned for callback requests when no callback
+// hosts are allowed
+var errCallbacksDisabled = errors.New("callbacks are disabled")
+
+// WithCallbacks lets export and summary requests with a callback_url render
+// in the background and deliver their result through callbacks
+func (h *Handler) WithCallbacks(callbacks *webhooks.Dispatcher) *Handler {
+	h.callbacks = callbacks
+	return h
+}
+
+// dispatch starts run in the background, delivering its result to
+// callbackURL, and responds with 202 Accepted
+func (h *Handler) dispatch(c *gin.Context, callbackURL string, run func(ctx context.Context, id string) any) {
+	if h.callbacks == nil {
+		respondError(c, http.StatusNotImplemented, "callback rejected", errCallbacksDisabled)
+		return
+	}
+
+	id, err := h.callbacks.Dispatch(c.Request.Context(), callbackURL, run)
+	switch {
+	case errors.Is(err, webhooks.ErrInvalidURL) || errors.Is(err, webhooks.ErrHostNotAllowed):
+		respondError(c, http.StatusBadRequest, "invalid request", err)
+		return
+	case errors.Is(err, webhooks.ErrClosed) || errors.Is(err, webhooks.ErrQueueFull):
+		respondError(c, http.StatusServiceUnavailable, "callback rejected", err)
+		return
+	case err != nil:
+		respondError(c, http.StatusInternalServerError, "callback rejected", err)
+		return
+	}
+	logging.FromContext(c.Request.Context()).Info("callback accepted", "callback_id", id)
+	c.JSON(http.StatusAccepted, models.CallbackAccepted{CallbackID: id, Status: "accepted"})
+}
+
+// exportCallback renders req for a callback. With artifact storage the
+// result is stored and linked with a download link starting with base;
+// otherwise it is sent inline.
+func (h *Handler) exportCallback(ctx context.Context, id, base string, req *models.ExportRequest) models.CallbackPayload {
+	payload := newCallbackPayload(ctx, id, "export")
+	payload.Format = req.Format
+
+	start := time.Now()
+	result, err := h.openscadService.Export(ctx, req)
+	payload.RenderSeconds = time.Since(start).Seconds()
+	if err != nil {
+		logging.FromContext(ctx).Error("export failed", "format", req.Format, "error", err)
+		return callbackFailure(payload, exportErrorStatus(err, req.Format), "export failed", err)
+	}
+	data, contentType := result.Data, result.ContentType
+	extras, err := extrasOf(req, result)
+	if err != nil {
+		logging.FromContext(ctx).Error("mesh analysis failed", "format", req.Format, "error", err)
+		return callbackFailure(payload, http.StatusInternalServerError, "mesh analysis failed", err)
+	}
+	if s := extras.summary; s != nil {
+		payload.Summary, payload.SummaryRaw = &s.Summary, s.Raw
+	}
+	payload.Analysis = extras.analysis
+
+	if h.artifacts.enabled() {
+		stored, err := h.artifacts.save(ctx, base, req, data, contentType, time.Since(start))
+		if err != nil {
+			return callbackFailure(payload, artifactErrorStatus(err), "artifact storage failed", err)
+		}
+		payload.Artifact = stored
+	} else {
+		payload.ContentType = contentType
+		payload.Data = data
+	}
+	return callbackSuccess(payload)
+}
+
+// summaryCallback generates the summary of req for a callback
+func (h *Handler) summaryCallback(ctx context.Context, id string, req *models.SummaryRequest) models.CallbackPayload {
+	payload := newCallbackPayload(ctx, id, "summary")
+
+	start := time.Now()
+	response, err := h.openscadService.Summary(ctx, req)
+	payload.RenderSeconds = time.Since(start).Seconds()
+	if err != nil {
+		logging.FromContext(ctx).Error("summary generation failed", "error", err)
+		return callbackFailure(payload, renderErrorStatus(err), "summary generation failed", err)
+	}
+
+	payload.Summary, payload.SummaryRaw = &response.Summary, response.Raw
+	return callbackSuccess(payload)
+}
+
+func newCallbackPayload(ctx context.Context, id, operation string) models.CallbackPayload {
+	return models.CallbackPayload{
+		CallbackID: id,
+		RequestID:  logging.RequestID(ctx),
+		Operation:  operation,
+	}
+}
+
+func callbackSuccess(payload models.CallbackPayload) models.CallbackPayload {
+	payload.Status = "succeeded"
+	payload.CompletedAt = time.Now().UTC()
+	return payload
+}
+
+// callbackFailure records the error and the status code the request would
+// have failed with without a callback
+func callbackFailure(payload models.CallbackPayload, status int, message string, err error) models.CallbackPayload {
+	payload.Status = "failed"
+	payload.CompletedAt = time.Now().UTC()
+	payload.StatusCode = status
+	payload.Error = message
+	payload.Diagnostics = err.Error()
+	return payload
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/artifacts"
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/webhooks"
+)
+
+// setupCallbackRouter returns a router whose callbacks may go to 127.0.0.1,
+// with artifact storage when stored is set, and a channel receiving every
+// delivered payload
+func setupCallbackRouter(t *testing.T, exporter *MockOpenSCADExporter, stored bool) (*gin.Engine, string, <-chan models.CallbackPayload) {
+	t.Helper()
+	payloads := make(chan models.CallbackPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(webhooks.HeaderSignature) != webhooks.Sign([]byte("secret"), r.Header.Get(webhooks.HeaderTimestamp), body) {
+			t.Errorf("Expected a valid callback signature")
+		}
+		var payload models.CallbackPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("Failed to parse callback: %v", err)
+		}
+		payloads <- payload
+	}))
+	t.Cleanup(server.Close)
+
+	callbacks, err := webhooks.New(webhooks.Config{AllowedHosts: []string{"127.0.0.1"}, Secret: []byte("secret")})
+	if err != nil {
+		t.Fatalf("Failed to create dispatcher: %v", err)
+	}
+	t.Cleanup(func() { callbacks.Close(context.Background()) })
+
+	var store *artifacts.Store
+	if stored {
+		store, err = artifacts.New(context.Background(), artifacts.Config{Dir: t.TempDir(), SigningKey: []byte("key")})
+		if err != nil {
+			t.Fatalf("Failed to create store: %v", err)
+		}
+	}
+
+	h := NewHandlerWithService(exporter).
+		WithArtifacts(NewArtifactHandler(exporter, store, "")).
+		WithCallbacks(callbacks)
+	router := gin.New()
+	router.POST("/openscad/v1/export", h.Export)
+	router.POST("/openscad/v1/summary", h.Summary)
+	return router, server.URL + "/hooks", payloads
+}
+
+func postJSON(router *gin.Engine, path string, body any) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func receive(t *testing.T, payloads <-chan models.CallbackPayload) models.CallbackPayload {
+	t.Helper()
+	select {
+	case payload := <-payloads:
+		return payload
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a callback")
+		return models.CallbackPayload{}
+	}
+}
+
+func acceptedID(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var accepted models.CallbackAccepted
+	if err := json.Unmarshal(w.Body.Bytes(), &accepted); err != nil || accepted.CallbackID == "" {
+		t.Fatalf("Expected a callback ID, got %s", w.Body.String())
+	}
+	return accepted.CallbackID
+}
+
+func TestExport_Callback(t *testing.T) {
+	tests := []struct {
+		name         string
+		stored       bool
+		exportErr    error
+		wantStatus   string
+		wantInline   bool
+		wantArtifact bool
+		wantCode     int
+	}{
+		{name: "Inline", wantStatus: "succeeded", wantInline: true},
+		{name: "Stored", stored: true, wantStatus: "succeeded", wantArtifact: true},
+		{name: "Render failure", exportErr: errors.New("openscad command failed: exit status 1, output: ERROR: Parser error"), wantStatus: "failed", wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := &MockOpenSCADExporter{
+				ExportFunc: func(req *models.ExportRequest) ([]byte, string, error) {
+					if tt.exportErr != nil {
+						return nil, "", tt.exportErr
+					}
+					return []byte("solid cube"), "application/octet-stream", nil
+				},
+			}
+			router, callbackURL, payloads := setupCallbackRouter(t, exporter, tt.stored)
+
+			w := postJSON(router, "/openscad/v1/export", map[string]string{
+				"scad_content": "cube(1);",
+				"format":       "stl_binary",
+				"callback_url": callbackURL,
+			})
+			id := acceptedID(t, w)
+
+			payload := receive(t, payloads)
+			if payload.CallbackID != id || payload.Operation != "export" || payload.Format != "stl_binary" || payload.Status != tt.wantStatus {
+				t.Errorf("Expected %s export callback %s, got %+v", tt.wantStatus, id, payload)
+			}
+			if tt.wantInline != (string(payload.Data) == "solid cube") {
+				t.Errorf("Expected inline data %v, got %q", tt.wantInline, payload.Data)
+			}
+			if tt.wantArtifact != (payload.Artifact != nil && strings.Contains(payload.Artifact.URL, "/openscad/v1/artifacts/")) {
+				t.Errorf("Expected artifact %v, got %+v", tt.wantArtifact, payload.Artifact)
+			}
+			if payload.StatusCode != tt.wantCode {
+				t.Errorf("Expected status code %d, got %d", tt.wantCode, payload.StatusCode)
+			}
+			if tt.exportErr != nil && !strings.Contains(payload.Diagnostics, "Parser error") {
+				t.Errorf("Expected OpenSCAD output in diagnostics, got %q", payload.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestExport_CallbackWithSummary(t *testing.T) {
+	router, callbackURL, payloads := setupCallbackRouter(t, &MockOpenSCADExporter{}, false)
+
+	w := postJSON(router, "/openscad/v1/export", map[string]string{
+		"scad_content": "cube(1);",
+		"format":       "stl_binary",
+		"summary_type": "geometry",
+		"callback_url": callbackURL,
+	})
+	acceptedID(t, w)
+
+	payload := receive(t, payloads)
+	if payload.Status != "succeeded" || len(payload.Data) == 0 {
+		t.Errorf("Expected a succeeded export with data, got %+v", payload)
+	}
+	if payload.Summary == nil || payload.Summary.Geometry == nil || len(payload.SummaryRaw) == 0 {
+		t.Errorf("Expected the summary with the export, got %v", payload.Summary)
+	}
+}
+
+func TestSummary_Callback(t *testing.T) {
+	router, callbackURL, payloads := setupCallbackRouter(t, &MockOpenSCADExporter{}, false)
+
+	w := postJSON(router, "/openscad/v1/summary", map[string]string{
+		"scad_content": "cube(1);",
+		"callback_url": callbackURL,
+	})
+	id := acceptedID(t, w)
+
+	payload := receive(t, payloads)
+	if payload.CallbackID != id || payload.Operation != "summary" || payload.Status != "succeeded" {
+		t.Errorf("Expected succeeded summary callback %s, got %+v", id, payload)
+	}
+	if payload.Summary == nil || payload.Summary.Geometry == nil || *payload.Summary.Geometry.Facets != 6 || len(payload.SummaryRaw) == 0 {
+		t.Errorf("Expected the summary, got %v", payload.Summary)
+	}
+}
+
+func TestCallback_Rejected(t *testing.T) {
+	router, _, _ := setupCallbackRouter(t, &MockOpenSCADExporter{}, false)
+	disabled := gin.New()
+	disabled.POST("/openscad/v1/export", NewHandlerWithService(&MockOpenSCADExporter{}).Export)
+
+	tests := []struct {
+		name        string
+		router      *gin.Engine
+		callbackURL string
+		wantStatus  int
+	}{
+		{"Host not allowed", router, "https://evil.example.com/hooks", http.StatusBadRequest},
+		{"Not a URL", router, "hooks", http.StatusBadRequest},
+		{"Callbacks disabled", disabled, "http://127.0.0.1/hooks", http.StatusNotImplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postJSON(tt.router, "/openscad/v1/export", map[string]string{
+				"scad_content": "cube(1);",
+				"format":       "stl_binary",
+				"callback_url": tt.callbackURL,
+			})
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
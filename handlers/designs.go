@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/designs"
+	"github.com/stevexciv/scad-server/logging"
+	"github.com/stevexciv/scad-server/models"
+)
+
+// errDesignsDisabled is returned when no design database is configured
+var errDesignsDisabled = errors.New("saved designs are disabled")
+
+// DesignHandler stores designs with immutable versions and renders them
+type DesignHandler struct {
+	store   *designs.Store
+	exports *Handler
+}
+
+// NewDesignHandler creates a new design handler that renders through
+// exports. A nil store disables the endpoints.
+func NewDesignHandler(store *designs.Store, exports *Handler) *DesignHandler {
+	return &DesignHandler{
+		store:   store,
+		exports: exports,
+	}
+}
+
+// Create handles the design creation endpoint
+// @Summary Create a design
+// @Description Saves a named design holding SCAD files, assets, default parameters and default export options as its version 1. The main file, "main.scad" unless named otherwise or the design has a single file, must be at the top level; the other files are available to include and use under their names.
+// @Description Requires an admin API key in X-API-Key. The files besides the main one and the assets together may number at most the assets a render accepts.
+// @Tags designs
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "Admin API key"
+// @Param request body models.DesignRequest true "Design"
+// @Success 201 {object} models.Design "Created design with its first version"
+// @Failure 400 {object} models.ErrorResponse "Bad Request"
+// @Failure 403 {object} models.ErrorResponse "Not an admin"
+// @Failure 413 {object} models.ErrorResponse "Design Too Large"
+// @Failure 429 {object} models.ErrorResponse "Quota Exceeded"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 501 {object} models.ErrorResponse "Designs disabled"
+// @Router /openscad/v1/designs [post]
+func (h *DesignHandler) Create(c *gin.Context) {
+	if h.store == nil {
+		respondError(c, http.StatusNotImplemented, "design creation failed", errDesignsDisabled)
+		return
+	}
+
+	var req models.DesignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+	design, err := h.store.Create(c.Request.Context(), &req)
+	if err != nil {
+		respondError(c, designErrorStatus(err), "design creation failed", err)
+		return
+	}
+	logging.FromContext(c.Request.Context()).Info("design created", "design_id", design.ID)
+	c.JSON(http.StatusCreated, design)
+}
+
+// List handles the design listing endpoint
+// @Summary List designs
+// @Description Lists the saved designs, most recently updated first, without their versions
+// @Tags designs
+// @Produce json
+// @Success 200 {object} models.DesignList "Designs"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 501 {object} models.ErrorResponse "Designs disabled"
+// @Router /openscad/v1/designs [get]
+func (h *DesignHandler) List(c *gin.Context) {
+	if h.store == nil {
+		respondError(c, http.StatusNotImplemented, "design listing failed", errDesignsDisabled)
+		return
+	}
+
+	list, err := h.store.List(c.Request.Context())
+	if err != nil {
+		respondError(c, designErrorStatus(err), "design listing failed", err)
+		return
+	}
+	c.JSON(http.StatusOK, models.DesignList{Designs: list})
+}
+
+// Get handles the design endpoint
+// @Summary Get a design
+// @Description Returns a design with its latest version
+// @Tags designs
+// @Produce json
+// @Param id path string true "Design ID"
+// @Success 200 {object} models.Design "Design"
+// @Failure 404 {object} models.ErrorResponse "Not Found"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 501 {object} models.ErrorResponse "Designs disabled"
+// @Router /openscad/v1/designs/{id} [get]
+func (h *DesignHandler) Get(c *gin.Context) {
+	if h.store == nil {
+		respondError(c, http.StatusNotImplemented, "design lookup failed", errDesignsDisabled)
+		return
+	}
+
+	design, err := h.store.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, designErrorStatus(err), "design lookup failed", err)
+		return
+	}
+	c.JSON(http.StatusOK, design)
+}
+
+// Update handles the design update endpoint
+// @Summary Update a design
+// @Description Saves the request as a new version of the design and replaces its name and description. Earlier versions stay unchanged. Requires an admin API key in X-API-Key
+// @Tags designs
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "Admin API key"
+// @Param id path string true "Design ID"
+// @Param request body models.DesignRequest true "Design"
+// @Success 200 {object} models.Design "Design with its new version"
+// @Failure 400 {object} models.ErrorResponse "Bad Request"
+// @Failure 403 {object} models.ErrorResponse "Not an admin"
+// @Failure 404 {object} models.ErrorResponse "Not Found"
+// @Failure 413 {object} models.ErrorResponse "Design Too Large"
+// @Failure 429 {object} models.ErrorResponse "Quota Exceeded"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 501 {object} models.ErrorResponse "Designs disabled"
+// @Router /openscad/v1/designs/{id} [put]
+func (h *DesignHandler) Update(c *gin.Context) {
+	if h.store == nil {
+		respondError(c, http.StatusNotImplemented, "design update failed", errDesignsDisabled)
+		return
+	}
+
+	var req models.DesignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+	design, err := h.store.Update(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		respondError(c, designErrorStatus(err), "design update failed", err)
+		return
+	}
+	logging.FromContext(c.Request.Context()).Info("design updated", "design_id", design.ID, "version", design.LatestVersion)
+	c.JSON(http.StatusOK, design)
+}
+
+// Delete handles the design deletion endpoint
+// @Summary Delete a design
+// @Description Deletes a design and all its versions. Requires an admin API key in X-API-Key
+// @Tags designs
+// @Param X-API-Key header string true "Admin API key"
+// @Param id path string true "Design ID"
+// @Success 204 "Deleted"
+// @Failure 403 {object} models.ErrorResponse "Not an admin"
+// @Failure 404 {object} models.ErrorResponse "Not Found"
+// @Failure 429 {object} models.ErrorResponse "Quota Exceeded"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 501 {object} models.ErrorResponse "Designs disabled"
+// @Router /openscad/v1/designs/{id} [delete]
+func (h *DesignHandler) Delete(c *gin.Context) {
+	if h.store == nil {
+		respondError(c, http.StatusNotImplemented, "design deletion failed", errDesignsDisabled)
+		return
+	}
+
+	if err := h.store.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		respondError(c, designErrorStatus(err), "design deletion failed", err)
+		return
+	}
+	logging.FromContext(c.Request.Context()).Info("design deleted", "design_id", c.Param("id"))
+	c.Status(http.StatusNoContent)
+}
+
+// Versions handles the design versions endpoint
+// @Summary List design versions
+// @Description Lists the versions of a design, newest first, without their files and assets
+// @Tags designs
+// @Produce json
+// @Param id path string true "Design ID"
+// @Success 200 {object} models.DesignVersionList "Versions"
+// @Failure 404 {object} models.ErrorResponse "Not Found"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 501 {object} models.ErrorResponse "Designs disabled"
+// @Router /openscad/v1/designs/{id}/versions [get]
+func (h *DesignHandler) Versions(c *gin.Context) {
+	if h.store == nil {
+		respondError(c, http.StatusNotImplemented, "design lookup failed", errDesignsDisabled)
+		return
+	}
+
+	versions, err := h.store.Versions(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, designErrorStatus(err), "design lookup failed", err)
+		return
+	}
+	c.JSON(http.StatusOK, models.DesignVersionList{Versions: versions})
+}
+
+// Version handles the design version endpoint
+// @Summary Get a design version
+// @Description Returns a version of a design with its files and assets
+// @Tags designs
+// @Produce json
+// @Param id path string true "Design ID"
+// @Param version path int true "Version"
+// @Success 200 {object} models.DesignVersion "Version"
+// @Failure 400 {object} models.ErrorResponse "Bad Request"
+// @Failure 404 {object} models.ErrorResponse "Not Found"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 501 {object} models.ErrorResponse "Designs disabled"
+// @Router /openscad/v1/designs/{id}/versions/{version} [get]
+func (h *DesignHandler) Version(c *gin.Context) {
+	version, ok := h.version(c, "design lookup failed")
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, version)
+}
+
+// Export handles the design export endpoint
+// @Summary Export a design version
+// @Description Renders a version of a design like the export endpoint. The format and options default to the version's, and parameters are merged over its default parameters, so the same request always renders the same model.
+// @Description "store" and "callback_url" work as for the export endpoint.
+// @Tags designs
+// @Accept json
+// @Produce octet-stream
+// @Param id path string true "Design ID"
+// @Param version path int true "Version"
+// @Param request body models.DesignExportRequest false "Overrides"
+// @Success 200 {file} binary "Exported file"
+// @Success 202 {object} models.CallbackAccepted "Render started, result goes to callback_url"
+// @Header 200 {string} X-Artifact-ID "ID of the stored artifact, when store is set"
+// @Header 200 {string} X-Artifact-URL "Signed download link of the stored artifact, when store is set"
+// @Failure 400 {object} models.ErrorResponse "Bad Request"
+// @Failure 404 {object} models.ErrorResponse "Not Found"
+// @Failure 413 {object} models.ErrorResponse "Asset Too Large"
+// @Failure 429 {object} models.ErrorResponse "Quota Exceeded"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 501 {object} models.ErrorResponse "Designs, artifact storage or callbacks disabled"
+// @Failure 503 {object} models.ErrorResponse "Shutting Down"
+// @Failure 507 {object} models.ErrorResponse "Storage Full"
+// @Router /openscad/v1/designs/{id}/versions/{version}/export [post]
+func (h *DesignHandler) Export(c *gin.Context) {
+	var req models.DesignExportRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, "invalid request", err)
+			return
+		}
+	}
+
+	version, ok := h.version(c, "export failed")
+	if !ok {
+		return
+	}
+	export := designs.ExportRequest(version, &req)
+	if export.Format == "" {
+		respondError(c, http.StatusBadRequest, "invalid request", errors.New("format is required when the design has no default format"))
+		return
+	}
+	h.exports.export(c, export)
+}
+
+// version looks up the version addressed by the request path, responding
+// with errMsg and returning false if that fails
+func (h *DesignHandler) version(c *gin.Context, errMsg string) (*models.DesignVersion, bool) {
+	if h.store == nil {
+		respondError(c, http.StatusNotImplemented, errMsg, errDesignsDisabled)
+		return nil, false
+	}
+
+	number, err := strconv.Atoi(c.Param("version"))
+	if err != nil || number < 1 {
+		respondError(c, http.StatusBadRequest, "invalid request", fmt.Errorf("invalid version %q", c.Param("version")))
+		return nil, false
+	}
+	version, err := h.store.Version(c.Request.Context(), c.Param("id"), number)
+	if err != nil {
+		respondError(c, designErrorStatus(err), errMsg, err)
+		return nil, false
+	}
+	return version, true
+}
+
+// designErrorStatus maps design store errors to status codes
+func designErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, designs.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, designs.ErrInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, designs.ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/designs"
+	"github.com/stevexciv/scad-server/models"
+)
+
+func setupDesignRouter(t *testing.T, exporter *MockOpenSCADExporter) *gin.Engine {
+	t.Helper()
+	store, err := designs.Open(context.Background(), designs.Config{Path: filepath.Join(t.TempDir(), "designs.db")})
+	if err != nil {
+		t.Fatalf("Failed to open designs: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	h := NewDesignHandler(store, NewHandlerWithService(exporter))
+	router := gin.New()
+	router.POST("/openscad/v1/designs", h.Create)
+	router.GET("/openscad/v1/designs", h.List)
+	router.GET("/openscad/v1/designs/:id", h.Get)
+	router.PUT("/openscad/v1/designs/:id", h.Update)
+	router.DELETE("/openscad/v1/designs/:id", h.Delete)
+	router.GET("/openscad/v1/designs/:id/versions", h.Versions)
+	router.GET("/openscad/v1/designs/:id/versions/:version", h.Version)
+	router.POST("/openscad/v1/designs/:id/versions/:version/export", h.Export)
+	return router
+}
+
+func sendJSON(router *gin.Engine, method, path string, body any) *httptest.ResponseRecorder {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func designRequest(size int) models.DesignRequest {
+	return models.DesignRequest{
+		Name:       "Box",
+		Files:      []models.DesignFile{{Name: "main.scad", Content: "include <lib/box.scad>\nbox(size);"}, {Name: "lib/box.scad", Content: "module box(s) { cube(s); }"}},
+		Parameters: map[string]any{"size": size},
+		Format:     "stl_binary",
+	}
+}
+
+func TestDesigns_Lifecycle(t *testing.T) {
+	var rendered []*models.ExportRequest
+	router := setupDesignRouter(t, &MockOpenSCADExporter{
+		ExportFunc: func(req *models.ExportRequest) ([]byte, string, error) {
+			rendered = append(rendered, req)
+			return []byte("solid box"), "application/octet-stream", nil
+		},
+	})
+
+	w := sendJSON(router, "POST", "/openscad/v1/designs", designRequest(10))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var design models.Design
+	json.Unmarshal(w.Body.Bytes(), &design)
+	base := "/openscad/v1/designs/" + design.ID
+
+	if w := sendJSON(router, "PUT", base, designRequest(20)); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = sendJSON(router, "GET", base+"/versions", nil)
+	var versions models.DesignVersionList
+	json.Unmarshal(w.Body.Bytes(), &versions)
+	if w.Code != http.StatusOK || len(versions.Versions) != 2 {
+		t.Errorf("Expected 2 versions, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Version 1 keeps rendering with its own defaults
+	for _, tt := range []struct {
+		version    string
+		body       any
+		wantSize   float64
+		wantFormat string
+	}{
+		{"1", nil, 10, "stl_binary"},
+		{"2", nil, 20, "stl_binary"},
+		{"1", models.DesignExportRequest{Format: "3mf", Parameters: map[string]any{"size": 5}}, 5, "3mf"},
+	} {
+		w := sendJSON(router, "POST", base+"/versions/"+tt.version+"/export", tt.body)
+		if w.Code != http.StatusOK || w.Body.String() != "solid box" {
+			t.Fatalf("Expected the render, got %d: %s", w.Code, w.Body.String())
+		}
+		req := rendered[len(rendered)-1]
+		if req.Parameters["size"] != tt.wantSize || req.Format != tt.wantFormat {
+			t.Errorf("Expected version %s to render %s with size %v, got %s %v", tt.version, tt.wantFormat, tt.wantSize, req.Format, req.Parameters)
+		}
+		if len(req.Libraries) != 1 || req.Libraries[0].Name != "lib/box.scad" {
+			t.Errorf("Expected the library, got %+v", req.Libraries)
+		}
+	}
+
+	if w := sendJSON(router, "DELETE", base, nil); w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", w.Code)
+	}
+	if w := sendJSON(router, "GET", base, nil); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 after deletion, got %d", w.Code)
+	}
+}
+
+func TestDesigns_Errors(t *testing.T) {
+	router := setupDesignRouter(t, &MockOpenSCADExporter{})
+	w := sendJSON(router, "POST", "/openscad/v1/designs", designRequest(10))
+	var design models.Design
+	json.Unmarshal(w.Body.Bytes(), &design)
+
+	noFormat := designRequest(10)
+	noFormat.Format = ""
+	w = sendJSON(router, "POST", "/openscad/v1/designs", noFormat)
+	var unformatted models.Design
+	json.Unmarshal(w.Body.Bytes(), &unformatted)
+
+	disabled := gin.New()
+	disabled.GET("/openscad/v1/designs", NewDesignHandler(nil, NewHandlerWithService(&MockOpenSCADExporter{})).List)
+
+	invalid := designRequest(10)
+	invalid.Main = "missing.scad"
+
+	tests := []struct {
+		name       string
+		router     *gin.Engine
+		method     string
+		path       string
+		body       any
+		wantStatus int
+	}{
+		{"Invalid design", router, "POST", "/openscad/v1/designs", invalid, http.StatusBadRequest},
+		{"Missing files", router, "POST", "/openscad/v1/designs", map[string]string{"name": "Box"}, http.StatusBadRequest},
+		{"Unknown design", router, "PUT", "/openscad/v1/designs/nope", designRequest(10), http.StatusNotFound},
+		{"Unknown version", router, "GET", "/openscad/v1/designs/" + design.ID + "/versions/7", nil, http.StatusNotFound},
+		{"Bad version", router, "GET", "/openscad/v1/designs/" + design.ID + "/versions/latest", nil, http.StatusBadRequest},
+		{"No format", router, "POST", "/openscad/v1/designs/" + unformatted.ID + "/versions/1/export", nil, http.StatusBadRequest},
+		{"Store disabled", router, "POST", "/openscad/v1/designs/" + design.ID + "/versions/1/export", map[string]bool{"store": true}, http.StatusNotImplemented},
+		{"Disabled", disabled, "GET", "/openscad/v1/designs", nil, http.StatusNotImplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := sendJSON(tt.router, tt.method, tt.path, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
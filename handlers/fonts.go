@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/logging"
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/services"
+)
+
+// multipartOverhead is the room left for multipart headers and boundaries on
+// top of the largest accepted font
+const multipartOverhead = 64 << 10
+
+// FontHandler manages the fonts available to OpenSCAD
+type FontHandler struct {
+	fonts    services.FontManager
+	maxBytes int64
+}
+
+// NewFontHandler creates a new font handler accepting uploads of up to
+// maxBytes
+func NewFontHandler(fonts services.FontManager, maxBytes int64) *FontHandler {
+	return &FontHandler{
+		fonts:    fonts,
+		maxBytes: maxBytes,
+	}
+}
+
+// List handles the font list endpoint
+// @Summary List uploaded fonts
+// @Description Lists the fonts uploaded to the managed font directory and the families each provides
+// @Tags fonts
+// @Produce json
+// @Success 200 {object} models.FontsResponse "Uploaded fonts"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 501 {object} models.ErrorResponse "Font management disabled"
+// @Router /openscad/v1/fonts [get]
+func (h *FontHandler) List(c *gin.Context) {
+	fonts, err := h.fonts.ListFonts(c.Request.Context())
+	if err != nil {
+		h.fontError(c, "font listing failed", err)
+		return
+	}
+	c.JSON(http.StatusOK, models.FontsResponse{Fonts: fonts})
+}
+
+// Upload handles the font upload endpoint
+// @Summary Upload a font
+// @Description Stores a TrueType or OpenType font in the managed font directory, where OpenSCAD's text() can use it by family name
+// @Description Requires an admin API key in X-API-Key. Uploaded fonts are limited in total size as well as per file
+// @Tags fonts
+// @Accept multipart/form-data
+// @Produce json
+// @Param X-API-Key header string true "Admin API key"
+// @Param file formData file true "TTF or OTF font file"
+// @Success 201 {object} models.FontFile "Stored font"
+// @Failure 400 {object} models.ErrorResponse "Not a valid font"
+// @Failure 403 {object} models.ErrorResponse "Not an admin"
+// @Failure 409 {object} models.ErrorResponse "Font already exists"
+// @Failure 413 {object} models.ErrorResponse "Font too large or font quota exceeded"
+// @Failure 429 {object} models.ErrorResponse "Quota Exceeded"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 501 {object} models.ErrorResponse "Font management disabled"
+// @Router /openscad/v1/fonts [post]
+func (h *FontHandler) Upload(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxBytes+multipartOverhead)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondError(c, http.StatusRequestEntityTooLarge, "font too large", fmt.Errorf("fonts are limited to %d bytes", h.maxBytes))
+			return
+		}
+		respondError(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+	if header.Size > h.maxBytes {
+		respondError(c, http.StatusRequestEntityTooLarge, "font too large", fmt.Errorf("fonts are limited to %d bytes", h.maxBytes))
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+
+	font, err := h.fonts.AddFont(c.Request.Context(), header.Filename, data)
+	if err != nil {
+		h.fontError(c, "font upload failed", err)
+		return
+	}
+	logging.FromContext(c.Request.Context()).Info("font uploaded", "name", font.Name, "families", font.Families)
+	c.JSON(http.StatusCreated, font)
+}
+
+// Delete handles the font delete endpoint
+// @Summary Delete an uploaded font
+// @Description Removes a font from the managed font directory. Requires an admin API key in X-API-Key
+// @Tags fonts
+// @Param X-API-Key header string true "Admin API key"
+// @Param name path string true "Font file name"
+// @Success 204 "Deleted"
+// @Failure 403 {object} models.ErrorResponse "Not an admin"
+// @Failure 404 {object} models.ErrorResponse "Font not found"
+// @Failure 429 {object} models.ErrorResponse "Quota Exceeded"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 501 {object} models.ErrorResponse "Font management disabled"
+// @Router /openscad/v1/fonts/{name} [delete]
+func (h *FontHandler) Delete(c *gin.Context) {
+	name := c.Param("name")
+	if err := h.fonts.RemoveFont(name); err != nil {
+		h.fontError(c, "font deletion failed", err)
+		return
+	}
+	logging.FromContext(c.Request.Context()).Info("font deleted", "name", name)
+	c.Status(http.StatusNoContent)
+}
+
+// Families handles the font families endpoint
+// @Summary List font families
+// @Description Lists every font family and face OpenSCAD can use, including uploaded fonts. Face names are the values to pass to text(font=...).
+// @Tags fonts
+// @Produce json
+// @Success 200 {object} models.FontFamiliesResponse "Available fonts"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Router /openscad/v1/fonts/families [get]
+func (h *FontHandler) Families(c *gin.Context) {
+	faces, err := h.fonts.FontFaces(c.Request.Context())
+	if err != nil {
+		h.fontError(c, "font listing failed", err)
+		return
+	}
+
+	families := make([]string, 0, len(faces))
+	for _, f := range faces {
+		families = append(families, f.Family)
+	}
+	slices.Sort(families)
+	c.JSON(http.StatusOK, models.FontFamiliesResponse{Families: slices.Compact(families), Fonts: faces})
+}
+
+// fontError maps font management errors to status codes
+func (h *FontHandler) fontError(c *gin.Context, errMsg string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrFontsDisabled):
+		status = http.StatusNotImplemented
+	case errors.Is(err, services.ErrInvalidFont):
+		status = http.StatusBadRequest
+	case errors.Is(err, services.ErrFontExists):
+		status = http.StatusConflict
+	case errors.Is(err, services.ErrFontNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrFontQuotaExceeded):
+		status = http.StatusRequestEntityTooLarge
+	default:
+		logging.FromContext(c.Request.Context()).Error(errMsg, "error", err)
+	}
+	respondError(c, status, errMsg, err)
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/services"
+)
+
+// mockFontManager is an in-memory implementation of services.FontManager
+type mockFontManager struct {
+	fonts    map[string][]byte
+	maxTotal int
+}
+
+func (m *mockFontManager) ListFonts(ctx context.Context) ([]models.FontFile, error) {
+	if m.fonts == nil {
+		return nil, services.ErrFontsDisabled
+	}
+	var fonts []models.FontFile
+	for name, data := range m.fonts {
+		fonts = append(fonts, models.FontFile{Name: name, Size: int64(len(data))})
+	}
+	return fonts, nil
+}
+
+func (m *mockFontManager) AddFont(ctx context.Context, name string, data []byte) (*models.FontFile, error) {
+	if m.fonts == nil {
+		return nil, services.ErrFontsDisabled
+	}
+	if _, ok := m.fonts[name]; ok {
+		return nil, fmt.Errorf("%w: %s", services.ErrFontExists, name)
+	}
+	if !bytes.HasPrefix(data, []byte("OTTO")) {
+		return nil, fmt.Errorf("%w: %s", services.ErrInvalidFont, name)
+	}
+	total := len(data)
+	for _, font := range m.fonts {
+		total += len(font)
+	}
+	if m.maxTotal > 0 && total > m.maxTotal {
+		return nil, fmt.Errorf("%w: %s", services.ErrFontQuotaExceeded, name)
+	}
+	m.fonts[name] = data
+	return &models.FontFile{Name: name, Size: int64(len(data)), Families: []string{"Acme"}}, nil
+}
+
+func (m *mockFontManager) RemoveFont(name string) error {
+	if _, ok := m.fonts[name]; !ok {
+		return fmt.Errorf("%w: %s", services.ErrFontNotFound, name)
+	}
+	delete(m.fonts, name)
+	return nil
+}
+
+func (m *mockFontManager) FontFaces(ctx context.Context) ([]models.FontFace, error) {
+	return []models.FontFace{
+		{Name: "Acme:style=Bold", Family: "Acme", Style: "Bold", Managed: true},
+		{Name: "Acme:style=Regular", Family: "Acme", Style: "Regular", Managed: true},
+		{Name: "Liberation Sans:style=Regular", Family: "Liberation Sans", Style: "Regular"},
+	}, nil
+}
+
+func setupFontRouter(fonts services.FontManager, maxBytes int64) *gin.Engine {
+	h := NewFontHandler(fonts, maxBytes)
+	router := gin.New()
+	router.GET("/openscad/v1/fonts", h.List)
+	router.GET("/openscad/v1/fonts/families", h.Families)
+	router.POST("/openscad/v1/fonts", h.Upload)
+	router.DELETE("/openscad/v1/fonts/:name", h.Delete)
+	return router
+}
+
+// fontUpload builds a multipart upload request for a font file
+func fontUpload(t *testing.T, name string, data []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write(data)
+	w.Close()
+
+	req := httptest.NewRequest("POST", "/openscad/v1/fonts", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+func TestFontUpload(t *testing.T) {
+	router := setupFontRouter(&mockFontManager{fonts: map[string][]byte{"Taken.otf": []byte("OTTO")}, maxTotal: 40}, 64)
+
+	tests := []struct {
+		name       string
+		file       string
+		data       []byte
+		wantStatus int
+	}{
+		{"Valid font", "Acme.otf", []byte("OTTO font"), http.StatusCreated},
+		{"Existing font", "Taken.otf", []byte("OTTO font"), http.StatusConflict},
+		{"Not a font", "Acme.ttf", []byte("hello"), http.StatusBadRequest},
+		{"Too large", "Big.otf", bytes.Repeat([]byte("x"), 100), http.StatusRequestEntityTooLarge},
+		{"Over the quota", "Full.otf", append([]byte("OTTO"), make([]byte, 36)...), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, fontUpload(t, tt.file, tt.data))
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/openscad/v1/fonts", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without a file, got %d", w.Code)
+	}
+}
+
+func TestFontListAndDelete(t *testing.T) {
+	router := setupFontRouter(&mockFontManager{fonts: map[string][]byte{"Acme.otf": []byte("OTTO")}}, 1<<20)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/openscad/v1/fonts", nil))
+	var list models.FontsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if w.Code != http.StatusOK || len(list.Fonts) != 1 {
+		t.Errorf("Expected one font, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/openscad/v1/fonts/Acme.otf", nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/openscad/v1/fonts/Acme.otf", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestFontFamilies(t *testing.T) {
+	router := setupFontRouter(&mockFontManager{}, 1<<20)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/openscad/v1/fonts/families", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var resp models.FontFamiliesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(resp.Families) != 2 || resp.Families[0] != "Acme" {
+		t.Errorf("Expected [Acme Liberation Sans], got %v", resp.Families)
+	}
+	if len(resp.Fonts) != 3 {
+		t.Errorf("Expected 3 faces, got %d", len(resp.Fonts))
+	}
+}
+
+func TestFonts_Disabled(t *testing.T) {
+	router := setupFontRouter(&mockFontManager{}, 1<<20)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/openscad/v1/fonts", nil),
+		fontUpload(t, "Acme.otf", []byte("OTTO")),
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusNotImplemented {
+			t.Errorf("%s %s: expected status 501, got %d", req.Method, req.URL.Path, w.Code)
+		}
+	}
+}
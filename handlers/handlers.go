@@ -1,24 +1,39 @@
 package handlers
 
 import (
-	"log"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/logging"
+	"github.com/stevexciv/scad-server/mesh"
 	"github.com/stevexciv/scad-server/models"
 	"github.com/stevexciv/scad-server/services"
 	"github.com/stevexciv/scad-server/version"
+	"github.com/stevexciv/scad-server/webhooks"
 )
 
 // Handler provides HTTP handlers
 type Handler struct {
 	openscadService services.OpenSCADExporter
+	artifacts       *ArtifactHandler
+	callbacks       *webhooks.Dispatcher
+	printOptions    mesh.PrintOptions
+	limits          RequestLimits
 }
 
 // NewHandler creates a new handler with the default OpenSCAD service
 func NewHandler() *Handler {
 	return &Handler{
 		openscadService: services.NewOpenSCADService(),
+		printOptions:    mesh.DefaultPrintOptions,
+		limits:          DefaultRequestLimits,
 	}
 }
 
@@ -26,76 +41,277 @@ func NewHandler() *Handler {
 func NewHandlerWithService(exporter services.OpenSCADExporter) *Handler {
 	return &Handler{
 		openscadService: exporter,
+		printOptions:    mesh.DefaultPrintOptions,
+		limits:          DefaultRequestLimits,
 	}
 }
 
+// WithRequestLimits refuses render requests uploading more than limits allow
+func (h *Handler) WithRequestLimits(limits RequestLimits) *Handler {
+	h.limits = limits
+	return h
+}
+
+// WithArtifacts lets export requests with "store" set keep their result in
+// the artifact store
+func (h *Handler) WithArtifacts(artifacts *ArtifactHandler) *Handler {
+	h.artifacts = artifacts
+	return h
+}
+
 // Export handles the export endpoint
 // @Summary Export SCAD to various formats
 // @Description Exports OpenSCAD content to PNG, STL (binary/ASCII), SVG, PDF, 3MF, WebP, or AVIF format
+// @Description Assets for import() and surface() are sent base64-encoded in "assets", or as multipart/form-data with the JSON request in the "request" field and each asset as an "assets" file part
+// @Description Multipart requests may instead send the SCAD source as a "file" part with the other fields as form fields, and application/x-openscad requests send the raw source as the body with the other fields as query parameters; options then go in an "options" JSON parameter or per field, e.g. png.width=800
+// @Description With "store" set the result is also kept in the artifact store, and the X-Artifact-ID and X-Artifact-URL headers identify it and link to its download
+// @Description With "callback_url" set the request is answered with 202 Accepted and the result is POSTed to the callback as a models.CallbackPayload once the render finishes, stored as an artifact when artifact storage is enabled and inline otherwise
+// @Description With "summary_type" set OpenSCAD also writes its summary in the same run. The summary is returned in the X-Summary header, or with the file as a models.ExportEnvelope when Accept prefers application/json, or as multipart/mixed with a "summary" part holding a models.SummaryResponse and a "file" part when Accept prefers multipart/mixed; callbacks carry it in "summary" and "summary_raw"
+// @Description Accept is matched by q-value, with ties going to the file itself; the X-Summary and X-Mesh-Analysis headers are left out when larger than 4 KiB
+// @Description With "analyze" set the exported STL or 3MF is analyzed as by the analyze endpoint and the models.MeshAnalysis is returned the same way: in the X-Mesh-Analysis header, in the envelope's "analysis", as an "analysis" part before the file, or in the callback's "analysis"
 // @Tags export
-// @Accept json
-// @Produce octet-stream
+// @Accept json,mpfd,application/x-openscad
+// @Produce octet-stream,json,multipart/mixed
 // @Param request body models.ExportRequest true "Export request"
 // @Success 200 {file} binary "Exported file"
+// @Success 202 {object} models.CallbackAccepted "Render started, result goes to callback_url"
+// @Header 200 {string} X-Artifact-ID "ID of the stored artifact, when store is set"
+// @Header 200 {string} X-Artifact-URL "Signed download link of the stored artifact, when store is set"
+// @Header 200 {string} X-Summary "Typed summary as JSON (models.Summary), when summary_type is set, the file is returned on its own and it fits in 4 KiB"
+// @Header 200 {string} X-Mesh-Analysis "Mesh analysis as JSON (models.MeshAnalysis), when analyze is set, the file is returned on its own and it fits in 4 KiB"
 // @Failure 400 {object} models.ErrorResponse "Bad Request"
+// @Failure 413 {object} models.ErrorResponse "Asset Too Large"
+// @Failure 429 {object} models.ErrorResponse "Quota Exceeded"
 // @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 501 {object} models.ErrorResponse "Artifact storage or callbacks disabled"
+// @Failure 503 {object} models.ErrorResponse "Shutting Down"
+// @Failure 507 {object} models.ErrorResponse "Storage Full"
 // @Router /openscad/v1/export [post]
 func (h *Handler) Export(c *gin.Context) {
 	var req models.ExportRequest
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "invalid request",
-			Message: err.Error(),
-		})
+	if err := bindRenderRequest(c, h.limits, &req, &req.Assets); err != nil {
+		respondError(c, bindErrorStatus(err), "invalid request", err)
 		return
 	}
+	h.export(c, &req)
+}
 
-	data, contentType, err := h.openscadService.Export(&req)
-	if err != nil {
-		statusCode := http.StatusInternalServerError
-		if err.Error() == "unsupported format: "+req.Format {
-			statusCode = http.StatusBadRequest
+// export renders req and responds with the file, storing it or handing it to
+// a callback as the request asks
+func (h *Handler) export(c *gin.Context, req *models.ExportRequest) {
+	if req.Store && !h.artifacts.enabled() {
+		respondError(c, http.StatusNotImplemented, "export failed", errArtifactsDisabled)
+		return
+	}
+	if req.Analyze {
+		if err := mesh.ValidateFormat(req.Format); err != nil {
+			respondError(c, http.StatusBadRequest, "export failed", err)
+			return
 		}
-		log.Printf("OpenSCAD export error: %v", err)
-		c.JSON(statusCode, models.ErrorResponse{
-			Error:   "export failed",
-			Message: err.Error(),
+	}
+	if req.CallbackURL != "" {
+		var base string
+		if h.artifacts.enabled() {
+			base = h.artifacts.linkBase(c)
+		}
+		h.dispatch(c, req.CallbackURL, func(ctx context.Context, id string) any {
+			return h.exportCallback(ctx, id, base, req)
 		})
 		return
 	}
 
+	start := time.Now()
+	result, err := h.openscadService.Export(c.Request.Context(), req)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("export failed", "format", req.Format, "error", err)
+		respondError(c, exportErrorStatus(err, req.Format), "export failed", err)
+		return
+	}
+	data, contentType := result.Data, result.ContentType
+	extras, err := extrasOf(req, result)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("mesh analysis failed", "format", req.Format, "error", err)
+		respondError(c, http.StatusInternalServerError, "mesh analysis failed", err)
+		return
+	}
+
+	if req.Store {
+		stored, err := h.artifacts.save(c.Request.Context(), h.artifacts.linkBase(c), req, data, contentType, time.Since(start))
+		if err != nil {
+			respondError(c, artifactErrorStatus(err), "artifact storage failed", err)
+			return
+		}
+		c.Header("X-Artifact-ID", stored.ID)
+		c.Header("X-Artifact-URL", stored.URL)
+	}
+	if extras.summary != nil || extras.analysis != nil {
+		respondWithExtras(c, req.Format, contentType, data, extras)
+		return
+	}
 	c.Data(http.StatusOK, contentType, data)
 }
 
+// exportExtras holds what an export returns besides the file: the summary
+// OpenSCAD wrote in the same run and the analysis of the mesh
+type exportExtras struct {
+	summary  *models.SummaryResponse
+	analysis *models.MeshAnalysis
+}
+
+// extrasOf returns what the export of req returned besides the file,
+// analyzing the mesh when req asks for it
+func extrasOf(req *models.ExportRequest, result *services.ExportResult) (*exportExtras, error) {
+	extras := &exportExtras{summary: result.Summary}
+	if !req.Analyze {
+		return extras, nil
+	}
+	m, err := mesh.Parse(req.Format, result.Data)
+	if err != nil {
+		return nil, err
+	}
+	analysis := mesh.Analyze(m)
+	extras.analysis = &analysis
+	return extras, nil
+}
+
+// maxExtrasHeaderBytes caps the X-Summary and X-Mesh-Analysis headers, as
+// proxies commonly reject responses with headers much larger than this
+const maxExtrasHeaderBytes = 4 << 10
+
+// respondWithExtras responds with an exported file, its summary and its
+// analysis: as a JSON envelope for clients preferring application/json, as
+// multipart/mixed with a part each for clients preferring that, and otherwise
+// as the file with the others in the X-Summary and X-Mesh-Analysis headers
+func respondWithExtras(c *gin.Context, format, contentType string, data []byte, extras *exportExtras) {
+	logger := logging.FromContext(c.Request.Context())
+	switch preferredType(c.GetHeader("Accept"), contentType, "application/json", "multipart/mixed") {
+	case "application/json":
+		envelope := models.ExportEnvelope{ContentType: contentType, Data: data, Analysis: extras.analysis}
+		if s := extras.summary; s != nil {
+			envelope.Summary, envelope.SummaryRaw = &s.Summary, s.Raw
+		}
+		c.JSON(http.StatusOK, envelope)
+	case "multipart/mixed":
+		body, boundary, err := multipartExtras(format, contentType, data, extras)
+		if err != nil {
+			logger.Error("failed to write multipart response", "format", format, "error", err)
+			respondError(c, http.StatusInternalServerError, "export failed", err)
+			return
+		}
+		c.Data(http.StatusOK, "multipart/mixed; boundary="+boundary, body)
+	default:
+		if extras.summary != nil {
+			setExtrasHeader(c, "X-Summary", extras.summary.Summary)
+		}
+		if extras.analysis != nil {
+			setExtrasHeader(c, "X-Mesh-Analysis", extras.analysis)
+		}
+		c.Data(http.StatusOK, contentType, data)
+	}
+}
+
+// multipartExtras encodes the summary and analysis parts followed by the
+// file part, returning the body and its boundary
+func multipartExtras(format, contentType string, data []byte, extras *exportExtras) ([]byte, string, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if extras.summary != nil {
+		if err := writeJSONPart(mw, "summary", extras.summary); err != nil {
+			return nil, "", err
+		}
+	}
+	if extras.analysis != nil {
+		if err := writeJSONPart(mw, "analysis", extras.analysis); err != nil {
+			return nil, "", err
+		}
+	}
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":        {contentType},
+		"Content-Disposition": {fmt.Sprintf(`attachment; name="file"; filename="output.%s"`, services.FileExtension(format))},
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to add file part: %w", err)
+	}
+	if _, err := part.Write(data); err != nil {
+		return nil, "", fmt.Errorf("failed to write file part: %w", err)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to finish multipart body: %w", err)
+	}
+	return body.Bytes(), mw.Boundary(), nil
+}
+
+// writeJSONPart adds a named inline JSON part to mw
+func writeJSONPart(mw *multipart.Writer, name string, v any) error {
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":        {"application/json"},
+		"Content-Disposition": {fmt.Sprintf(`inline; name=%q`, name)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add %s part: %w", name, err)
+	}
+	if err := json.NewEncoder(part).Encode(v); err != nil {
+		return fmt.Errorf("failed to write %s part: %w", name, err)
+	}
+	return nil
+}
+
+// setExtrasHeader sets header to v as JSON, leaving it out when it would be
+// larger than maxExtrasHeaderBytes; clients get those in full by accepting
+// application/json or multipart/mixed
+func setExtrasHeader(c *gin.Context, header string, v any) {
+	logger := logging.FromContext(c.Request.Context())
+	value, err := json.Marshal(v)
+	if err != nil {
+		logger.Error("failed to encode header", "header", header, "error", err)
+		return
+	}
+	if len(value) > maxExtrasHeaderBytes {
+		logger.Warn("header too large, leaving it out", "header", header, "bytes", len(value), "limit", maxExtrasHeaderBytes)
+		return
+	}
+	c.Header(header, string(value))
+}
+
 // Summary handles the summary endpoint
 // @Summary Generate summary information
 // @Description Generates summary information for OpenSCAD content
+// @Description "summary" holds the typed sections of the requested summary type and "raw" the summary exactly as OpenSCAD wrote it; unknown summary types are rejected before OpenSCAD runs
+// @Description Accepts assets, form fields and raw application/x-openscad bodies the same way as the export endpoint
+// @Description With "callback_url" set the request is answered with 202 Accepted and the summary is POSTed to the callback as a models.CallbackPayload
 // @Tags summary
-// @Accept json
+// @Accept json,mpfd,application/x-openscad
 // @Produce json
 // @Param request body models.SummaryRequest true "Summary request"
 // @Success 200 {object} models.SummaryResponse "Summary information"
+// @Success 202 {object} models.CallbackAccepted "Summary started, result goes to callback_url"
 // @Failure 400 {object} models.ErrorResponse "Bad Request"
+// @Failure 413 {object} models.ErrorResponse "Asset Too Large"
+// @Failure 429 {object} models.ErrorResponse "Quota Exceeded"
 // @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 501 {object} models.ErrorResponse "Callbacks disabled"
+// @Failure 503 {object} models.ErrorResponse "Shutting Down"
 // @Router /openscad/v1/summary [post]
 func (h *Handler) Summary(c *gin.Context) {
 	var req models.SummaryRequest
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "invalid request",
-			Message: err.Error(),
+	if err := bindRenderRequest(c, h.limits, &req, &req.Assets); err != nil {
+		respondError(c, bindErrorStatus(err), "invalid request", err)
+		return
+	}
+	if req.CallbackURL != "" {
+		h.dispatch(c, req.CallbackURL, func(ctx context.Context, id string) any {
+			return h.summaryCallback(ctx, id, &req)
 		})
 		return
 	}
 
-	response, err := h.openscadService.Summary(&req)
+	response, err := h.openscadService.Summary(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "summary generation failed",
-			Message: err.Error(),
-		})
+		statusCode := renderErrorStatus(err)
+		logging.FromContext(c.Request.Context()).Error("summary generation failed", "error", err)
+		respondError(c, statusCode, "summary generation failed", err)
 		return
 	}
 
@@ -104,16 +320,30 @@ func (h *Handler) Summary(c *gin.Context) {
 
 // HealthCheck handles the health check endpoint
 // @Summary Health check
-// @Description Checks if the API is running
+// @Description Checks if the API is running; reports "draining" with 503 during shutdown
 // @Tags health
 // @Produce json
 // @Success 200 {object} map[string]string
+// @Failure 503 {object} map[string]string
 // @Router /health [get]
 func (h *Handler) HealthCheck(c *gin.Context) {
 	info := version.GetInfo()
-	c.JSON(http.StatusOK, gin.H{
-		"status": "ok",
+	status, code := "ok", http.StatusOK
+	if d, ok := h.openscadService.(services.Drainer); ok && d.Draining() {
+		status, code = "draining", http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{
+		"status": status,
 		"commit": info.Commit,
 		"tag":    info.Tag,
 	})
 }
+
+// respondError writes an ErrorResponse tagged with the request's correlation ID
+func respondError(c *gin.Context, status int, errMsg string, err error) {
+	c.JSON(status, models.ErrorResponse{
+		Error:     errMsg,
+		Message:   err.Error(),
+		RequestID: logging.RequestID(c.Request.Context()),
+	})
+}
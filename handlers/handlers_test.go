@@ -2,14 +2,20 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/middleware"
 	"github.com/stevexciv/scad-server/models"
 	"github.com/stevexciv/scad-server/services"
 )
@@ -24,23 +30,33 @@ type MockOpenSCADExporter struct {
 	SummaryFunc func(req *models.SummaryRequest) (*models.SummaryResponse, error)
 }
 
-func (m *MockOpenSCADExporter) Export(req *models.ExportRequest) ([]byte, string, error) {
+func (m *MockOpenSCADExporter) Export(ctx context.Context, req *models.ExportRequest) (*services.ExportResult, error) {
+	data, contentType, err := []byte("mock export data"), "application/octet-stream", error(nil)
 	if m.ExportFunc != nil {
-		return m.ExportFunc(req)
+		data, contentType, err = m.ExportFunc(req)
 	}
-	// Default behavior: return mock data
-	return []byte("mock export data"), "application/octet-stream", nil
+	if err != nil {
+		return nil, err
+	}
+	result := &services.ExportResult{Data: data, ContentType: contentType}
+	// Like the service, return the summary of the same run when requested
+	if req.SummaryType != "" {
+		if result.Summary, err = m.Summary(ctx, &models.SummaryRequest{ScadContent: req.ScadContent, SummaryType: req.SummaryType}); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
 }
 
-func (m *MockOpenSCADExporter) Summary(req *models.SummaryRequest) (*models.SummaryResponse, error) {
+func (m *MockOpenSCADExporter) Summary(ctx context.Context, req *models.SummaryRequest) (*models.SummaryResponse, error) {
 	if m.SummaryFunc != nil {
 		return m.SummaryFunc(req)
 	}
 	// Default behavior: return mock summary
+	facets := 6
 	return &models.SummaryResponse{
-		Summary: map[string]interface{}{
-			"facets": 6,
-		},
+		Summary: models.Summary{Geometry: &models.SummaryGeometry{Dimensions: 3, Facets: &facets}},
+		Raw:     json.RawMessage(`{"geometry":{"dimensions":3,"facets":6}}`),
 	}, nil
 }
 
@@ -203,10 +219,10 @@ func TestSummaryEndpoint_MissingRequiredFields(t *testing.T) {
 func TestSummaryEndpoint_ValidRequest(t *testing.T) {
 	mock := &MockOpenSCADExporter{
 		SummaryFunc: func(req *models.SummaryRequest) (*models.SummaryResponse, error) {
+			facets := 6
 			return &models.SummaryResponse{
-				Summary: map[string]interface{}{
-					"facets": 6,
-				},
+				Summary: models.Summary{Geometry: &models.SummaryGeometry{Dimensions: 3, Facets: &facets}},
+				Raw:     json.RawMessage(`{"geometry":{"dimensions":3,"facets":6}}`),
 			}, nil
 		},
 	}
@@ -238,8 +254,11 @@ func TestSummaryEndpoint_ValidRequest(t *testing.T) {
 		t.Errorf("Failed to parse response: %v", err)
 	}
 
-	if response.Summary == nil {
-		t.Errorf("Expected summary data, got nil")
+	if response.Summary.Geometry == nil || *response.Summary.Geometry.Facets != 6 {
+		t.Errorf("Expected 6 facets, got %+v", response.Summary.Geometry)
+	}
+	if string(response.Raw) != `{"geometry":{"dimensions":3,"facets":6}}` {
+		t.Errorf("Expected the raw summary, got %s", response.Raw)
 	}
 }
 
@@ -390,6 +409,148 @@ func TestExportEndpoint_ValidFormats(t *testing.T) {
 	}
 }
 
+func TestExportEndpoint_WithSummary(t *testing.T) {
+	router := setupRouterWithMock(&MockOpenSCADExporter{})
+	body := `{"scad_content": "cube(1);", "format": "stl_binary", "summary_type": "geometry"}`
+
+	inHeader := func(t *testing.T, w *httptest.ResponseRecorder) {
+		var summary models.Summary
+		if err := json.Unmarshal([]byte(w.Header().Get("X-Summary")), &summary); err != nil || summary.Geometry == nil || *summary.Geometry.Facets != 6 {
+			t.Errorf("Expected the summary in X-Summary, got %q", w.Header().Get("X-Summary"))
+		}
+		if w.Body.String() != "mock export data" {
+			t.Errorf("Expected the exported file, got %q", w.Body.String())
+		}
+	}
+	inEnvelope := func(t *testing.T, w *httptest.ResponseRecorder) {
+		var envelope models.ExportEnvelope
+		if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+			t.Fatalf("Expected an export envelope, got %v", err)
+		}
+		if string(envelope.Data) != "mock export data" || envelope.ContentType != "application/octet-stream" {
+			t.Errorf("Expected the exported file in the envelope, got %+v", envelope)
+		}
+		if envelope.Summary.Geometry == nil || len(envelope.SummaryRaw) == 0 {
+			t.Errorf("Expected the summary in the envelope, got %+v", envelope)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		accept string
+		check  func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "Header",
+			check: inHeader,
+		},
+		{
+			name:   "JSON envelope",
+			accept: "application/json",
+			check:  inEnvelope,
+		},
+		{
+			name:   "JSON preferred by q-value",
+			accept: "multipart/mixed;q=0.5, application/json",
+			check:  inEnvelope,
+		},
+		{
+			name:   "File preferred by q-value",
+			accept: "application/json;q=0.2, */*",
+			check:  inHeader,
+		},
+		{
+			name:   "JSON refused",
+			accept: "application/json;q=0, */*;q=0.1",
+			check:  inHeader,
+		},
+		{
+			name:   "Multipart",
+			accept: "multipart/mixed",
+			check: func(t *testing.T, w *httptest.ResponseRecorder) {
+				mediaType, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+				if err != nil || mediaType != "multipart/mixed" {
+					t.Fatalf("Expected a multipart/mixed response, got %q", w.Header().Get("Content-Type"))
+				}
+				mr := multipart.NewReader(w.Body, params["boundary"])
+
+				part, err := mr.NextPart()
+				if err != nil || partName(part) != "summary" {
+					t.Fatalf("Expected the summary part first, got %v", err)
+				}
+				var summary models.SummaryResponse
+				if err := json.NewDecoder(part).Decode(&summary); err != nil || summary.Summary.Geometry == nil {
+					t.Errorf("Expected the summary, got %v", err)
+				}
+
+				part, err = mr.NextPart()
+				if err != nil || partName(part) != "file" || part.FileName() != "output.stl" {
+					t.Fatalf("Expected the file part second, got %v", err)
+				}
+				if data, _ := io.ReadAll(part); string(data) != "mock export data" {
+					t.Errorf("Expected the exported file, got %q", data)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/openscad/v1/export", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			tt.check(t, w)
+		})
+	}
+
+	// Without summary_type the Accept header doesn't change the response
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/openscad/v1/export", bytes.NewBufferString(`{"scad_content": "cube(1);", "format": "stl_binary"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	router.ServeHTTP(w, req)
+	if w.Body.String() != "mock export data" || w.Header().Get("X-Summary") != "" {
+		t.Errorf("Expected only the exported file, got %q %v", w.Body.String(), w.Header())
+	}
+}
+
+func TestSetExtrasHeader_DropsLargeValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{name: "Small", value: "ok", want: true},
+		{name: "Too large", value: strings.Repeat("a", maxExtrasHeaderBytes), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/openscad/v1/export", nil)
+			setExtrasHeader(c, "X-Summary", tt.value)
+			if got := w.Header().Get("X-Summary") != ""; got != tt.want {
+				t.Errorf("Expected header set %v, got %q", tt.want, w.Header().Get("X-Summary"))
+			}
+		})
+	}
+}
+
+// partName returns the name in the Content-Disposition of a multipart/mixed
+// part, which Part.FormName only reads for form-data parts
+func partName(part *multipart.Part) string {
+	_, params, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+	return params["name"]
+}
+
 func TestExportEndpoint_ServiceError(t *testing.T) {
 	mock := &MockOpenSCADExporter{
 		ExportFunc: func(req *models.ExportRequest) ([]byte, string, error) {
@@ -515,3 +676,150 @@ func setupRouterWithMock(exporter services.OpenSCADExporter) *gin.Engine {
 
 	return router
 }
+
+func TestExportEndpoint_ErrorIncludesRequestID(t *testing.T) {
+	mock := &MockOpenSCADExporter{
+		ExportFunc: func(req *models.ExportRequest) ([]byte, string, error) {
+			return nil, "", errors.New("render failed")
+		},
+	}
+	router := gin.New()
+	router.Use(middleware.RequestID())
+	router.POST("/openscad/v1/export", NewHandlerWithService(mock).Export)
+
+	body := `{"scad_content":"cube(1);","format":"png"}`
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("POST", "/openscad/v1/export", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.RequestIDHeader, "req-123")
+	router.ServeHTTP(w, req)
+
+	var errResp models.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &errResp); err != nil {
+		t.Fatalf("Failed to parse error response: %v", err)
+	}
+
+	if errResp.RequestID != "req-123" {
+		t.Errorf("Expected request_id 'req-123', got '%s'", errResp.RequestID)
+	}
+}
+
+// drainingExporter is a mock exporter that reports a shutdown in progress
+type drainingExporter struct {
+	MockOpenSCADExporter
+}
+
+func (d *drainingExporter) Draining() bool {
+	return true
+}
+
+func TestHealthCheck_Draining(t *testing.T) {
+	router := setupRouterWithMock(&drainingExporter{})
+
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/health", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+
+	var response map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response["status"] != "draining" {
+		t.Errorf("Expected status 'draining', got '%s'", response["status"])
+	}
+}
+
+func TestEndpoints_ShuttingDown(t *testing.T) {
+	mock := &MockOpenSCADExporter{
+		ExportFunc: func(req *models.ExportRequest) ([]byte, string, error) {
+			return nil, "", services.ErrShuttingDown
+		},
+		SummaryFunc: func(req *models.SummaryRequest) (*models.SummaryResponse, error) {
+			return nil, fmt.Errorf("openscad command aborted: %w", services.ErrShuttingDown)
+		},
+	}
+	router := setupRouterWithMock(mock)
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/openscad/v1/export", `{"scad_content":"cube(1);","format":"png"}`},
+		{"/openscad/v1/summary", `{"scad_content":"cube(1);"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest("POST", tt.path, bytes.NewBufferString(tt.body))
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusServiceUnavailable {
+				t.Errorf("Expected status 503, got %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestEndpoints_InvalidRenderSelection(t *testing.T) {
+	mock := &MockOpenSCADExporter{
+		ExportFunc: func(req *models.ExportRequest) ([]byte, string, error) {
+			if req.Backend != "" {
+				return nil, "", fmt.Errorf("%w: backend %q", services.ErrUnsupportedFeature, req.Backend)
+			}
+			return nil, "", fmt.Errorf("%w: %s", services.ErrUnknownVersion, req.OpenSCADVersion)
+		},
+		SummaryFunc: func(req *models.SummaryRequest) (*models.SummaryResponse, error) {
+			if len(req.Features) > 0 {
+				return nil, fmt.Errorf("%w: feature %q", services.ErrUnsupportedFeature, req.Features[0])
+			}
+			if req.SummaryType == "volume" {
+				return nil, fmt.Errorf("%w: %s", services.ErrUnknownSummaryType, req.SummaryType)
+			}
+			return nil, fmt.Errorf("%w: %s", services.ErrUnknownVersion, req.OpenSCADVersion)
+		},
+	}
+	router := setupRouterWithMock(mock)
+
+	tests := []struct {
+		name string
+		path string
+		body string
+	}{
+		{"Export unknown version", "/openscad/v1/export", `{"scad_content":"cube(1);","format":"png","openscad_version":"nightly"}`},
+		{"Summary unknown version", "/openscad/v1/summary", `{"scad_content":"cube(1);","openscad_version":"nightly"}`},
+		{"Export unavailable backend", "/openscad/v1/export", `{"scad_content":"cube(1);","format":"png","backend":"manifold"}`},
+		{"Summary unavailable feature", "/openscad/v1/summary", `{"scad_content":"cube(1);","features":["roof"]}`},
+		{"Summary unknown type", "/openscad/v1/summary", `{"scad_content":"cube(1);","summary_type":"volume"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest("POST", tt.path, bytes.NewBufferString(tt.body))
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d", w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/history"
+	"github.com/stevexciv/scad-server/logging"
+	"github.com/stevexciv/scad-server/middleware"
+	"github.com/stevexciv/scad-server/models"
+)
+
+var (
+	// errHistoryDisabled is returned when no history database is configured
+	errHistoryDisabled = errors.New("render history is disabled")
+	// errOtherClient is returned when a caller without an admin key asks for
+	// another client's history
+	errOtherClient = errors.New("the history of other clients requires an admin API key")
+)
+
+// HistoryHandler lists recorded export and summary requests
+type HistoryHandler struct {
+	store *history.Store
+}
+
+// NewHistoryHandler creates a new history handler. A nil store disables the
+// endpoint.
+func NewHistoryHandler(store *history.Store) *HistoryHandler {
+	return &HistoryHandler{
+		store: store,
+	}
+}
+
+// List handles the history endpoint
+// @Summary List render history
+// @Description Returns recorded export and summary requests, newest first, with their request hash, format, options, client, duration, OpenSCAD exit code, output size and diagnostics. Pass next_cursor as cursor to fetch the next page.
+// @Description Callers see only their own requests unless they send an admin API key, which lists every client's and allows filtering by client.
+// @Tags history
+// @Produce json
+// @Param X-API-Key header string false "API key; admin keys see every client"
+// @Param operation query string false "Operation" Enums(export, summary)
+// @Param format query string false "Export format, or summary type for summaries"
+// @Param client query string false "Client identity, e.g. ip:10.0.0.1 or key:<hash>; other clients need an admin key"
+// @Param status query string false "Outcome" Enums(succeeded, failed)
+// @Param request_hash query string false "Request hash"
+// @Param since query string false "Only entries created at or after this RFC 3339 time"
+// @Param until query string false "Only entries created before this RFC 3339 time"
+// @Param limit query int false "Page size (1-500, default 50)"
+// @Param cursor query string false "Cursor from a previous page"
+// @Success 200 {object} models.HistoryResponse "History entries"
+// @Failure 400 {object} models.ErrorResponse "Bad Request"
+// @Failure 403 {object} models.ErrorResponse "Other client without admin key"
+// @Failure 429 {object} models.ErrorResponse "Quota Exceeded"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 501 {object} models.ErrorResponse "History disabled"
+// @Router /openscad/v1/history [get]
+func (h *HistoryHandler) List(c *gin.Context) {
+	if h.store == nil {
+		respondError(c, http.StatusNotImplemented, "history unavailable", errHistoryDisabled)
+		return
+	}
+
+	filter, err := historyFilter(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+	if !middleware.IsAdmin(c) {
+		client := middleware.ClientKey(c)
+		if filter.Client != "" && filter.Client != client {
+			respondError(c, http.StatusForbidden, "forbidden", errOtherClient)
+			return
+		}
+		filter.Client = client
+	}
+
+	entries, next, err := h.store.List(c.Request.Context(), filter)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("history query failed", "error", err)
+		respondError(c, http.StatusInternalServerError, "history query failed", err)
+		return
+	}
+	c.JSON(http.StatusOK, models.HistoryResponse{Entries: entries, NextCursor: next})
+}
+
+// historyFilter reads the filter of a history request from its query
+func historyFilter(c *gin.Context) (history.Filter, error) {
+	filter := history.Filter{
+		Operation:   c.Query("operation"),
+		Format:      c.Query("format"),
+		Client:      c.Query("client"),
+		Status:      c.Query("status"),
+		RequestHash: c.Query("request_hash"),
+	}
+	switch filter.Operation {
+	case "", "export", "summary":
+	default:
+		return filter, fmt.Errorf("invalid operation %q, must be export or summary", filter.Operation)
+	}
+	switch filter.Status {
+	case "", "succeeded", "failed":
+	default:
+		return filter, fmt.Errorf("invalid status %q, must be succeeded or failed", filter.Status)
+	}
+
+	for name, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := c.Query(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s %q, must be an RFC 3339 time", name, value)
+			}
+			*t = parsed
+		}
+	}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > history.MaxLimit {
+			return filter, fmt.Errorf("invalid limit %q, must be between 1 and %d", value, history.MaxLimit)
+		}
+		filter.Limit = limit
+	}
+	if value := c.Query("cursor"); value != "" {
+		cursor, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || cursor < 1 {
+			return filter, fmt.Errorf("invalid cursor %q", value)
+		}
+		filter.Cursor = cursor
+	}
+	return filter, nil
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/history"
+	"github.com/stevexciv/scad-server/middleware"
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/services"
+)
+
+func setupHistoryRouter(t *testing.T) (*gin.Engine, *history.Store) {
+	t.Helper()
+	store, err := history.Open(context.Background(), history.Config{Path: filepath.Join(t.TempDir(), "history.db")})
+	if err != nil {
+		t.Fatalf("Failed to open history: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	router := gin.New()
+	router.GET("/openscad/v1/history", middleware.Identify(nil), middleware.Admin([]string{"root"}), NewHistoryHandler(store).List)
+	return router, store
+}
+
+func TestHealthCheck_DrainingWithHistory(t *testing.T) {
+	_, store := setupHistoryRouter(t)
+	service := services.NewOpenSCADService()
+	router := gin.New()
+	router.GET("/health", NewHandlerWithService(history.NewRecorder(service, store)).HealthCheck)
+
+	health := func() int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+		return w.Code
+	}
+	if code := health(); code != http.StatusOK {
+		t.Fatalf("Expected status 200 before the drain, got %d", code)
+	}
+	if err := service.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if code := health(); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 while draining through the recorder, got %d", code)
+	}
+}
+
+func TestHistory_List(t *testing.T) {
+	router, store := setupHistoryRouter(t)
+	exporter := history.NewRecorder(&MockOpenSCADExporter{}, store)
+	// httptest requests come from 192.0.2.1
+	ctx := history.WithClient(context.Background(), "ip:192.0.2.1")
+	for _, format := range []string{"stl_binary", "3mf", "stl_binary"} {
+		if _, err := exporter.Export(ctx, &models.ExportRequest{ScadContent: "cube(1);", Format: format}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if _, err := exporter.Summary(ctx, &models.SummaryRequest{ScadContent: "cube(1);"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	other := history.WithClient(context.Background(), "ip:10.0.0.2")
+	if _, err := exporter.Export(other, &models.ExportRequest{ScadContent: "cube(2);", Format: "stl_binary"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		admin      bool
+		wantCount  int
+		wantCursor bool
+	}{
+		{"Own entries", "", false, 4, false},
+		{"Operation", "?operation=summary", false, 1, false},
+		{"Format", "?format=stl_binary", false, 2, false},
+		{"Own client", "?client=ip:192.0.2.1&status=succeeded", false, 4, false},
+		{"Paged", "?limit=3", false, 3, true},
+		{"Since", "?since=2000-01-01T00:00:00Z&until=2000-01-02T00:00:00Z", false, 0, false},
+		{"Admin sees every client", "", true, 5, false},
+		{"Admin filters by client", "?client=ip:10.0.0.2", true, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/openscad/v1/history"+tt.query, nil)
+			if tt.admin {
+				req.Header.Set(middleware.APIKeyHeader, "root")
+			}
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			var response models.HistoryResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			if len(response.Entries) != tt.wantCount {
+				t.Errorf("Expected %d entries, got %d", tt.wantCount, len(response.Entries))
+			}
+			if tt.wantCursor != (response.NextCursor != "") {
+				t.Errorf("Expected next cursor %v, got %q", tt.wantCursor, response.NextCursor)
+			}
+		})
+	}
+}
+
+func TestHistory_Errors(t *testing.T) {
+	router, _ := setupHistoryRouter(t)
+	disabled := gin.New()
+	disabled.GET("/openscad/v1/history", NewHistoryHandler(nil).List)
+
+	tests := []struct {
+		name       string
+		router     *gin.Engine
+		query      string
+		wantStatus int
+	}{
+		{"Unknown operation", router, "?operation=render", http.StatusBadRequest},
+		{"Unknown status", router, "?status=pending", http.StatusBadRequest},
+		{"Bad since", router, "?since=yesterday", http.StatusBadRequest},
+		{"Limit too large", router, "?limit=501", http.StatusBadRequest},
+		{"Bad cursor", router, "?cursor=abc", http.StatusBadRequest},
+		{"Other client without admin key", router, "?client=ip:10.0.0.2", http.StatusForbidden},
+		{"Disabled", disabled, "", http.StatusNotImplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.router.ServeHTTP(w, httptest.NewRequest("GET", "/openscad/v1/history"+tt.query, nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/logging"
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/ratelimit"
+	"github.com/stevexciv/scad-server/services"
+	"github.com/stevexciv/scad-server/version"
+)
+
+// InfoHandler reports server and OpenSCAD capabilities
+type InfoHandler struct {
+	provider services.InfoProvider
+	limiter  *ratelimit.Limiter
+}
+
+// NewInfoHandler creates a new info handler. limiter may be nil when rate
+// limiting is not in use.
+func NewInfoHandler(provider services.InfoProvider, limiter *ratelimit.Limiter) *InfoHandler {
+	return &InfoHandler{
+		provider: provider,
+		limiter:  limiter,
+	}
+}
+
+// Info handles the info endpoint
+// @Summary Server and OpenSCAD capabilities
+// @Description Reports the server build, the selected OpenSCAD installation's version with its experimental features, color schemes, fonts and libraries, the supported export formats with their option schemas, and the limits currently in force
+// @Tags info
+// @Produce json
+// @Param openscad_version query string false "OpenSCAD installation to describe (default: the server default)"
+// @Success 200 {object} models.InfoResponse "Capabilities"
+// @Failure 400 {object} models.ErrorResponse "Unknown OpenSCAD version"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Router /openscad/v1/info [get]
+func (h *InfoHandler) Info(c *gin.Context) {
+	openscad, err := h.provider.Info(c.Request.Context(), c.Query("openscad_version"))
+	if err != nil {
+		h.inspectionFailed(c, err)
+		return
+	}
+
+	limits := h.provider.Limits()
+	if h.limiter != nil {
+		cfg := h.limiter.Config()
+		limits.RequestsPerMinute = cfg.RequestsPerMinute
+		limits.RenderBudgetSeconds = cfg.RenderSeconds
+		limits.RenderBudgetPeriodSeconds = cfg.RenderPeriod.Seconds()
+	}
+
+	build := version.GetInfo()
+	c.JSON(http.StatusOK, models.InfoResponse{
+		Server:   models.ServerInfo{Commit: build.Commit, Tag: build.Tag},
+		OpenSCAD: *openscad,
+		Formats:  services.ExportFormats(),
+		Limits:   limits,
+	})
+}
+
+// Versions handles the versions endpoint
+// @Summary List OpenSCAD installations
+// @Description Lists the named OpenSCAD installations that requests can select with openscad_version, and which one is the default
+// @Tags info
+// @Produce json
+// @Success 200 {object} models.VersionsResponse "Installations"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Router /openscad/v1/versions [get]
+func (h *InfoHandler) Versions(c *gin.Context) {
+	versions, err := h.provider.Versions(c.Request.Context())
+	if err != nil {
+		h.inspectionFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.VersionsResponse{Versions: versions})
+}
+
+// inspectionFailed reports an error from the info provider
+func (h *InfoHandler) inspectionFailed(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrUnknownVersion) {
+		respondError(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+	logging.FromContext(c.Request.Context()).Error("openscad inspection failed", "error", err)
+	respondError(c, http.StatusInternalServerError, "openscad inspection failed", err)
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/ratelimit"
+	"github.com/stevexciv/scad-server/services"
+)
+
+// mockInfoProvider is a mock implementation of services.InfoProvider
+type mockInfoProvider struct {
+	info *models.OpenSCADInfo
+	err  error
+}
+
+func (m *mockInfoProvider) Info(ctx context.Context, version string) (*models.OpenSCADInfo, error) {
+	if version != "" && version != m.info.Name {
+		return nil, fmt.Errorf("%w: %s", services.ErrUnknownVersion, version)
+	}
+	return m.info, m.err
+}
+
+func (m *mockInfoProvider) Versions(ctx context.Context) ([]models.OpenSCADVersion, error) {
+	return []models.OpenSCADVersion{{Name: m.info.Name, Version: m.info.Version, Default: true}}, m.err
+}
+
+func (m *mockInfoProvider) Limits() models.LimitsInfo {
+	return models.LimitsInfo{RenderTimeoutSeconds: 300, MaxConcurrentRenders: 4}
+}
+
+func TestInfoEndpoint(t *testing.T) {
+	provider := &mockInfoProvider{info: &models.OpenSCADInfo{
+		Name:         "stable",
+		Version:      "2021.01",
+		ColorSchemes: []string{"Cornfield", "Metallic"},
+	}}
+	limiter := ratelimit.New(ratelimit.Config{RequestsPerMinute: 60, RenderSeconds: 600, RenderPeriod: time.Hour})
+
+	router := gin.New()
+	router.GET("/openscad/v1/info", NewInfoHandler(provider, limiter).Info)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/openscad/v1/info", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var resp models.InfoResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if resp.OpenSCAD.Version != "2021.01" {
+		t.Errorf("Expected version 2021.01, got %s", resp.OpenSCAD.Version)
+	}
+	if len(resp.Formats) == 0 {
+		t.Errorf("Expected formats to be listed")
+	}
+	if resp.Limits.MaxConcurrentRenders != 4 || resp.Limits.RenderTimeoutSeconds != 300 {
+		t.Errorf("Expected service limits, got %+v", resp.Limits)
+	}
+	if resp.Limits.RequestsPerMinute != 60 || resp.Limits.RenderBudgetPeriodSeconds != 3600 {
+		t.Errorf("Expected rate limits, got %+v", resp.Limits)
+	}
+	if resp.Server.Commit == "" {
+		t.Errorf("Expected server commit")
+	}
+}
+
+func TestInfoEndpoint_InspectionError(t *testing.T) {
+	provider := &mockInfoProvider{
+		info: &models.OpenSCADInfo{Name: "stable"},
+		err:  errors.New("openscad --version failed: exit status 127"),
+	}
+
+	router := gin.New()
+	router.GET("/openscad/v1/info", NewInfoHandler(provider, nil).Info)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/openscad/v1/info", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", w.Code)
+	}
+}
+
+func TestInfoEndpoint_UnknownVersion(t *testing.T) {
+	provider := &mockInfoProvider{info: &models.OpenSCADInfo{Name: "stable"}}
+
+	router := gin.New()
+	router.GET("/openscad/v1/info", NewInfoHandler(provider, nil).Info)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/openscad/v1/info?openscad_version=nightly", nil))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestVersionsEndpoint(t *testing.T) {
+	provider := &mockInfoProvider{info: &models.OpenSCADInfo{Name: "stable", Version: "2021.01"}}
+
+	router := gin.New()
+	router.GET("/openscad/v1/versions", NewInfoHandler(provider, nil).Versions)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/openscad/v1/versions", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var resp models.VersionsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(resp.Versions) != 1 || resp.Versions[0].Name != "stable" || !resp.Versions[0].Default {
+		t.Errorf("Expected default stable version, got %+v", resp.Versions)
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// mediaRange is one entry of an Accept header
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// preferredType returns the offer the Accept header ranks highest, each
+// offer taking the q-value of the most specific range matching it. Ties go
+// to the earlier offer, and a missing header accepts anything. It returns ""
+// when accept rules out every offer.
+func preferredType(accept string, offers ...string) string {
+	ranges := parseAccept(accept)
+	if strings.TrimSpace(accept) == "" {
+		ranges = []mediaRange{{typ: "*", subtype: "*", q: 1}}
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := quality(ranges, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// parseAccept parses the media ranges of an Accept header, skipping
+// malformed ones
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, field := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(field))
+		if err != nil {
+			continue
+		}
+		typ, subtype, ok := strings.Cut(mediaType, "/")
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{typ: typ, subtype: subtype, q: q})
+	}
+	return ranges
+}
+
+// quality returns the q-value of the most specific range matching offer, or
+// 0 when none does
+func quality(ranges []mediaRange, offer string) float64 {
+	typ, subtype, _ := strings.Cut(offer, "/")
+	q, specificity := 0.0, -1
+	for _, r := range ranges {
+		var s int
+		switch {
+		case r.typ == typ && r.subtype == subtype:
+			s = 2
+		case r.typ == typ && r.subtype == "*":
+			s = 1
+		case r.typ == "*" && r.subtype == "*":
+			s = 0
+		default:
+			continue
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
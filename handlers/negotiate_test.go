@@ -0,0 +1,31 @@
+package handlers
+
+import "testing"
+
+func TestPreferredType(t *testing.T) {
+	offers := []string{"model/stl", "application/json", "multipart/mixed"}
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{name: "Missing header", accept: "", want: "model/stl"},
+		{name: "Anything", accept: "*/*", want: "model/stl"},
+		{name: "Exact match", accept: "application/json", want: "application/json"},
+		{name: "Higher q wins", accept: "application/json;q=0.4, multipart/mixed;q=0.8", want: "multipart/mixed"},
+		{name: "Specific range overrides wildcard", accept: "*/*;q=0.9, model/stl;q=0.1", want: "application/json"},
+		{name: "Subtype wildcard", accept: "multipart/*, */*;q=0.5", want: "multipart/mixed"},
+		{name: "Zero q rules out", accept: "application/json;q=0", want: ""},
+		{name: "Substring doesn't match", accept: "application/jsonx", want: ""},
+		{name: "Case insensitive with spaces", accept: " Application/JSON ; q=0.7 , text/html", want: "application/json"},
+		{name: "Malformed q skipped", accept: "application/json;q=high, multipart/mixed", want: "multipart/mixed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := preferredType(tt.accept, offers...); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
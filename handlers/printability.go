@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/logging"
+	"github.com/stevexciv/scad-server/mesh"
+	"github.com/stevexciv/scad-server/models"
+)
+
+// overlaySize is the width and height in pixels of each view of the
+// printability overlay
+const overlaySize = 512
+
+// WithPrintOptions sets the printer settings printability requests are
+// checked against unless they override them
+func (h *Handler) WithPrintOptions(opts mesh.PrintOptions) *Handler {
+	h.printOptions = opts
+	return h
+}
+
+// Printability handles the printability endpoint
+// @Summary Check the exported mesh for printability
+// @Description Exports OpenSCAD content as a mesh and checks it before printing: overhang area steeper than "overhang_angle" from vertical, minimum wall thickness, features thinner than "nozzle_width", floating islands, fit within "build_volume" and first layer contact area
+// @Description Each failed check is returned as a warning with the location of the region it refers to; warnings of severity "error" make the part unprintable. Printer settings left out use the server's defaults
+// @Description With "overlay" set the report also carries a base64 PNG of the part from above and below with overhangs red, thin walls yellow, thin features orange and floating islands magenta
+// @Description Accepts assets, form fields and raw application/x-openscad bodies the same way as the export endpoint
+// @Tags analyze
+// @Accept json,mpfd,application/x-openscad
+// @Produce json
+// @Param request body models.PrintabilityRequest true "Printability request"
+// @Success 200 {object} models.PrintabilityReport "Printability report"
+// @Failure 400 {object} models.ErrorResponse "Bad Request"
+// @Failure 413 {object} models.ErrorResponse "Asset Too Large"
+// @Failure 429 {object} models.ErrorResponse "Quota Exceeded"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 503 {object} models.ErrorResponse "Shutting Down"
+// @Router /openscad/v1/printability [post]
+func (h *Handler) Printability(c *gin.Context) {
+	var req models.PrintabilityRequest
+
+	if err := bindRenderRequest(c, h.limits, &req, &req.Assets); err != nil {
+		respondError(c, bindErrorStatus(err), "invalid request", err)
+		return
+	}
+	opts, err := h.requestPrintOptions(&req)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+
+	m, ok := h.exportMesh(c, &req.AnalyzeRequest)
+	if !ok {
+		return
+	}
+	report, issues := mesh.Check(m, opts)
+	if req.Overlay {
+		if report.Overlay, err = mesh.Overlay(m, issues, overlaySize); err != nil {
+			logging.FromContext(c.Request.Context()).Error("overlay failed", "error", err)
+			respondError(c, http.StatusInternalServerError, "overlay failed", err)
+			return
+		}
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// requestPrintOptions returns the handler's print options with the
+// overrides of req applied
+func (h *Handler) requestPrintOptions(req *models.PrintabilityRequest) (mesh.PrintOptions, error) {
+	opts := h.printOptions
+	if req.OverhangAngle != nil {
+		opts.OverhangAngle = *req.OverhangAngle
+	}
+	if req.NozzleWidth != nil {
+		opts.NozzleWidth = *req.NozzleWidth
+	}
+	if req.MinWallThickness != nil {
+		opts.MinWallThickness = *req.MinWallThickness
+	}
+	if req.BuildVolume != nil {
+		if len(req.BuildVolume) != 3 {
+			return opts, fmt.Errorf("build_volume must have 3 values, got %d", len(req.BuildVolume))
+		}
+		opts.BuildVolume = mesh.Vec(req.BuildVolume)
+	}
+	return opts, opts.Validate()
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/mesh"
+	"github.com/stevexciv/scad-server/models"
+)
+
+// largeTetrahedronSTL is tetrahedronSTL scaled to 30 mm, a part that passes
+// every check with the default printer settings
+var largeTetrahedronSTL = strings.NewReplacer(" 1", " 30").Replace(tetrahedronSTL)
+
+func setupPrintabilityRouter(h *Handler) *gin.Engine {
+	router := gin.New()
+	router.POST("/openscad/v1/printability", h.Printability)
+	return router
+}
+
+func exportSTL(stl string) *MockOpenSCADExporter {
+	return &MockOpenSCADExporter{
+		ExportFunc: func(req *models.ExportRequest) ([]byte, string, error) {
+			return []byte(stl), "model/stl", nil
+		},
+	}
+}
+
+func decodeReport(t *testing.T, w *httptest.ResponseRecorder) models.PrintabilityReport {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var report models.PrintabilityReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Expected a printability report, got %v", err)
+	}
+	return report
+}
+
+func TestPrintability(t *testing.T) {
+	router := setupPrintabilityRouter(NewHandlerWithService(exportSTL(largeTetrahedronSTL)))
+
+	report := decodeReport(t, sendJSON(router, "POST", "/openscad/v1/printability", map[string]string{"scad_content": "polyhedron(...);", "format": "stl_ascii"}))
+	if !report.Printable || len(report.Warnings) != 0 || !report.FitsBuildVolume {
+		t.Errorf("Expected a printable part without warnings, got %+v", report)
+	}
+	if math.Abs(report.FirstLayerArea-450) > 1e-9 {
+		t.Errorf("Expected a first layer of 450 mm², got %g", report.FirstLayerArea)
+	}
+	if report.OverhangAngle != mesh.DefaultPrintOptions.OverhangAngle || report.Overlay != nil {
+		t.Errorf("Expected the default overhang angle and no overlay, got %g and %d bytes", report.OverhangAngle, len(report.Overlay))
+	}
+
+	report = decodeReport(t, sendJSON(router, "POST", "/openscad/v1/printability", map[string]any{
+		"scad_content": "polyhedron(...);",
+		"format":       "stl_ascii",
+		"build_volume": []float64{20, 20, 20},
+		"overlay":      true,
+	}))
+	if report.Printable || report.FitsBuildVolume || len(report.Warnings) != 1 || report.Warnings[0].Check != "build_volume" {
+		t.Errorf("Expected the part not to fit, got %+v", report.Warnings)
+	}
+	img, err := png.Decode(bytes.NewReader(report.Overlay))
+	if err != nil {
+		t.Fatalf("Expected a PNG overlay, got %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 2*overlaySize || b.Dy() != overlaySize {
+		t.Errorf("Expected two %d pixel views, got %v", overlaySize, b)
+	}
+}
+
+func TestPrintability_ThinPart(t *testing.T) {
+	router := setupPrintabilityRouter(NewHandlerWithService(exportSTL(tetrahedronSTL)))
+
+	report := decodeReport(t, sendJSON(router, "POST", "/openscad/v1/printability", map[string]string{"scad_content": "polyhedron(...);", "format": "stl_ascii"}))
+	if report.Printable || report.MinWallThickness == nil || *report.MinWallThickness >= 0.4 {
+		t.Errorf("Expected a part thinner than the nozzle, got %+v", report)
+	}
+	for _, w := range report.Warnings {
+		if w.Check == "thin_feature" && (w.Severity != "error" || w.Location == nil) {
+			t.Errorf("Expected a located error, got %+v", w)
+		}
+	}
+}
+
+func TestPrintability_Defaults(t *testing.T) {
+	opts := mesh.DefaultPrintOptions
+	opts.BuildVolume = mesh.Vec{20, 20, 20}
+	router := setupPrintabilityRouter(NewHandlerWithService(exportSTL(largeTetrahedronSTL)).WithPrintOptions(opts))
+
+	report := decodeReport(t, sendJSON(router, "POST", "/openscad/v1/printability", map[string]string{"scad_content": "polyhedron(...);", "format": "stl_ascii"}))
+	if report.FitsBuildVolume || report.BuildVolume[0] != 20 {
+		t.Errorf("Expected the server's build volume to apply, got %v", report.BuildVolume)
+	}
+}
+
+func TestPrintability_RawBody(t *testing.T) {
+	router := setupPrintabilityRouter(NewHandlerWithService(exportSTL(largeTetrahedronSTL)))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/openscad/v1/printability?format=stl_ascii&overhang_angle=30&build_volume=100,100,50", bytes.NewBufferString("polyhedron(...);"))
+	req.Header.Set("Content-Type", "application/x-openscad")
+	router.ServeHTTP(w, req)
+
+	report := decodeReport(t, w)
+	if report.OverhangAngle != 30 || len(report.BuildVolume) != 3 || report.BuildVolume[2] != 50 {
+		t.Errorf("Expected the settings from the query, got %g and %v", report.OverhangAngle, report.BuildVolume)
+	}
+}
+
+func TestPrintability_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		body map[string]any
+	}{
+		{"Missing source", map[string]any{"format": "stl_ascii"}},
+		{"Not a mesh format", map[string]any{"scad_content": "cube(1);", "format": "png"}},
+		{"Flat overhang angle", map[string]any{"scad_content": "cube(1);", "overhang_angle": 90}},
+		{"Negative nozzle width", map[string]any{"scad_content": "cube(1);", "nozzle_width": -0.4}},
+		{"Two dimensional build volume", map[string]any{"scad_content": "cube(1);", "build_volume": []float64{220, 220}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupPrintabilityRouter(NewHandlerWithService(exportSTL(largeTetrahedronSTL)))
+			if w := sendJSON(router, "POST", "/openscad/v1/printability", tt.body); w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
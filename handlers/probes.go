@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/health"
+)
+
+// ProbeHandler serves the liveness and readiness probes
+type ProbeHandler struct {
+	checker *health.Checker
+}
+
+// NewProbeHandler creates a new probe handler running the given readiness checks
+func NewProbeHandler(checker *health.Checker) *ProbeHandler {
+	return &ProbeHandler{
+		checker: checker,
+	}
+}
+
+// Livez handles the liveness endpoint
+// @Summary Liveness probe
+// @Description Reports that the process is up and serving HTTP. It does not check OpenSCAD, so a failing render environment does not cause restarts.
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Router /livez [get]
+func (h *ProbeHandler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz handles the readiness endpoint
+// @Summary Readiness probe
+// @Description Runs the readiness checks (shutdown state, OpenSCAD binary, temp directory space, render queue and, if enabled, a cached cube() render) and reports each result
+// @Tags health
+// @Produce json
+// @Success 200 {object} models.ReadinessResponse "Ready"
+// @Failure 503 {object} models.ReadinessResponse "Not ready"
+// @Router /readyz [get]
+func (h *ProbeHandler) Readyz(c *gin.Context) {
+	resp := h.checker.Run(c.Request.Context())
+
+	status := http.StatusOK
+	if resp.Status != health.StatusReady {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, resp)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/health"
+	"github.com/stevexciv/scad-server/models"
+)
+
+func setupProbeRouter(checker *health.Checker) *gin.Engine {
+	router := gin.New()
+	h := NewProbeHandler(checker)
+	router.GET("/livez", h.Livez)
+	router.GET("/readyz", h.Readyz)
+	return router
+}
+
+func TestLivez(t *testing.T) {
+	router := setupProbeRouter(health.NewChecker(time.Second))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/livez", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		name       string
+		diskErr    error
+		wantCode   int
+		wantStatus string
+	}{
+		{"Ready", nil, http.StatusOK, health.StatusReady},
+		{"Not ready", errors.New("temp directory not writable"), http.StatusServiceUnavailable, health.StatusNotReady},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := health.NewChecker(time.Second)
+			checker.Add("openscad", func(ctx context.Context) (health.Details, error) {
+				return health.Details{"version": "OpenSCAD version 2021.01"}, nil
+			})
+			checker.Add("temp_dir", func(ctx context.Context) (health.Details, error) {
+				return nil, tt.diskErr
+			})
+			router := setupProbeRouter(checker)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+
+			var resp models.ReadinessResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			if resp.Status != tt.wantStatus {
+				t.Errorf("Expected status %s, got %s", tt.wantStatus, resp.Status)
+			}
+			if resp.Checks["openscad"].Details["version"] != "OpenSCAD version 2021.01" {
+				t.Errorf("Expected openscad details, got %v", resp.Checks["openscad"].Details)
+			}
+			if tt.diskErr != nil && resp.Checks["temp_dir"].Error != tt.diskErr.Error() {
+				t.Errorf("Expected temp_dir error %q, got %q", tt.diskErr, resp.Checks["temp_dir"].Error)
+			}
+		})
+	}
+}
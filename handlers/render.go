@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/logging"
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/services"
+	"github.com/stevexciv/scad-server/version"
+)
+
+const (
+	// maxURLSourceBytes bounds the decompressed SCAD source of a render URL
+	maxURLSourceBytes = 1 << 20
+	// maxImageSize bounds each dimension of a render URL image
+	maxImageSize = 4096
+	// parameterPrefix marks render URL query parameters that set a customizer
+	// parameter, e.g. param.width=20
+	parameterPrefix = "param."
+	// signatureParam is the render URL query parameter holding the signature
+	signatureParam = "sig"
+)
+
+// renderFormats lists the formats served by render URLs
+var renderFormats = []string{"png", "webp", "avif", "svg"}
+
+// errBadSignature is returned when a render URL is unsigned or its signature
+// doesn't match
+var errBadSignature = errors.New("render URL signature missing or invalid")
+
+// RenderHandler serves renders addressed entirely by their URL, so they can be
+// embedded with a plain <img src>
+type RenderHandler struct {
+	exporter services.OpenSCADExporter
+	identity services.RenderIdentifier
+	secret   []byte
+	maxAge   time.Duration
+}
+
+// NewRenderHandler creates a new render URL handler. When secret is set every
+// URL must carry a matching HMAC-SHA256 signature. Responses may be cached for
+// maxAge.
+func NewRenderHandler(exporter services.OpenSCADExporter, secret string, maxAge time.Duration) *RenderHandler {
+	return &RenderHandler{
+		exporter: exporter,
+		secret:   []byte(secret),
+		maxAge:   maxAge,
+	}
+}
+
+// WithRenderIdentity folds the installation version and server defaults
+// reported by identity into ETags, so they change when a reload or upgrade
+// changes what a URL renders
+func (h *RenderHandler) WithRenderIdentity(identity services.RenderIdentifier) *RenderHandler {
+	h.identity = identity
+	return h
+}
+
+// Render handles the render URL endpoint
+// @Summary Render an image from a URL
+// @Description Renders SCAD source carried in the URL as raw DEFLATE compressed, base64url encoded "source", for embedding renders with <img src>
+// @Description Customizer parameters are set with param.<name>=<value>, where values that parse as JSON are used as such and anything else is a string
+// @Description Responses carry a strong ETag derived from the request, the OpenSCAD version and the server's render defaults and may be cached; when the server has a render URL secret, the URL must end with sig=<base64url HMAC-SHA256 of the path and query before it>
+// @Tags export
+// @Produce png,image/webp,image/avif,image/svg+xml
+// @Param format path string true "Image format" Enums(png, webp, avif, svg)
+// @Param source query string true "SCAD source, raw DEFLATE compressed and base64url encoded"
+// @Param size query string false "Image size as WIDTHxHEIGHT" example(800x600)
+// @Param camera query string false "Camera as translate_x,y,z,rot_x,y,z,distance or eye_x,y,z,center_x,y,z"
+// @Param openscad_version query string false "OpenSCAD installation"
+// @Param sig query string false "URL signature, required when the server has a render URL secret"
+// @Param If-None-Match header string false "ETag of a cached render"
+// @Success 200 {file} binary "Rendered image"
+// @Success 304 "Not Modified"
+// @Failure 400 {object} models.ErrorResponse "Bad Request"
+// @Failure 403 {object} models.ErrorResponse "Bad Signature"
+// @Failure 429 {object} models.ErrorResponse "Quota Exceeded"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 503 {object} models.ErrorResponse "Shutting Down"
+// @Router /openscad/v1/render/{format} [get]
+func (h *RenderHandler) Render(c *gin.Context) {
+	if err := h.verify(c.Request.URL); err != nil {
+		respondError(c, http.StatusForbidden, "forbidden", err)
+		return
+	}
+
+	req, err := renderURLRequest(c.Param("format"), c.Request.URL.Query())
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+
+	var identity string
+	if h.identity != nil {
+		identity, err = h.identity.RenderIdentity(c.Request.Context(), req.OpenSCADVersion)
+		if err != nil {
+			respondError(c, renderErrorStatus(err), "render failed", err)
+			return
+		}
+	}
+	etag, err := renderETag(req, identity)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "render failed", err)
+		return
+	}
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.maxAge.Seconds())))
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	result, err := h.exporter.Export(c.Request.Context(), req)
+	if err != nil {
+		c.Header("ETag", "")
+		c.Header("Cache-Control", "no-store")
+		logging.FromContext(c.Request.Context()).Error("render failed", "format", req.Format, "error", err)
+		respondError(c, renderErrorStatus(err), "render failed", err)
+		return
+	}
+	c.Data(http.StatusOK, result.ContentType, result.Data)
+}
+
+// verify checks the signature of a render URL when a secret is configured.
+// The signature is the last query parameter and covers the escaped path and
+// the query string before it.
+func (h *RenderHandler) verify(u *url.URL) error {
+	if len(h.secret) == 0 {
+		return nil
+	}
+
+	query := u.RawQuery
+	var signed, sig string
+	if i := strings.LastIndex(query, "&"+signatureParam+"="); i >= 0 {
+		signed, sig = query[:i], query[i+len(signatureParam)+2:]
+	} else if strings.HasPrefix(query, signatureParam+"=") {
+		sig = query[len(signatureParam)+1:]
+	} else {
+		return errBadSignature
+	}
+
+	got, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(sig, "="))
+	if err != nil {
+		return errBadSignature
+	}
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write([]byte(u.EscapedPath() + "?" + signed))
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return errBadSignature
+	}
+	return nil
+}
+
+// renderURLRequest builds the export request described by a render URL
+func renderURLRequest(format string, query url.Values) (*models.ExportRequest, error) {
+	if !slices.Contains(renderFormats, format) {
+		return nil, fmt.Errorf("unsupported format: %s, expected one of %s", format, strings.Join(renderFormats, ", "))
+	}
+
+	req := &models.ExportRequest{Format: format}
+	for key, values := range query {
+		value := values[len(values)-1]
+		switch {
+		case key == "source":
+			source, err := decodeSource(value)
+			if err != nil {
+				return nil, err
+			}
+			req.ScadContent = source
+		case key == "size":
+			width, height, err := parseSize(value)
+			if err != nil {
+				return nil, err
+			}
+			req.Options.PNG = pngOptions(req.Options.PNG)
+			req.Options.PNG.Width, req.Options.PNG.Height = &width, &height
+		case key == "camera":
+			req.Options.PNG = pngOptions(req.Options.PNG)
+			req.Options.PNG.Camera = &value
+		case key == "openscad_version":
+			req.OpenSCADVersion = value
+		case strings.HasPrefix(key, parameterPrefix):
+			if req.Parameters == nil {
+				req.Parameters = make(map[string]any)
+			}
+			req.Parameters[strings.TrimPrefix(key, parameterPrefix)] = parameterValue(value)
+		case key == signatureParam:
+			// Checked by verify
+		default:
+			return nil, fmt.Errorf("unknown query parameter %q", key)
+		}
+	}
+
+	if req.ScadContent == "" {
+		return nil, errors.New("source is required")
+	}
+	return req, nil
+}
+
+// decodeSource reverses the base64url and raw DEFLATE encoding of a render
+// URL source, refusing sources that inflate beyond maxURLSourceBytes
+func decodeSource(encoded string) (string, error) {
+	compressed, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return "", fmt.Errorf("source is not base64url encoded: %w", err)
+	}
+	r := flate.NewReader(bytes.NewReader(compressed))
+	defer r.Close()
+
+	source, err := io.ReadAll(io.LimitReader(r, maxURLSourceBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("source is not DEFLATE compressed: %w", err)
+	}
+	if len(source) > maxURLSourceBytes {
+		return "", fmt.Errorf("source exceeds %d bytes", maxURLSourceBytes)
+	}
+	return string(source), nil
+}
+
+// parseSize reads an image size given as WIDTHxHEIGHT
+func parseSize(v string) (width, height int, err error) {
+	w, h, ok := strings.Cut(v, "x")
+	if ok {
+		width, err = strconv.Atoi(w)
+	}
+	if ok && err == nil {
+		height, err = strconv.Atoi(h)
+	}
+	if !ok || err != nil || width < 1 || height < 1 || width > maxImageSize || height > maxImageSize {
+		return 0, 0, fmt.Errorf("size must be WIDTHxHEIGHT with each between 1 and %d, got %q", maxImageSize, v)
+	}
+	return width, height, nil
+}
+
+// parameterValue interprets a parameter as JSON, so numbers, booleans and
+// vectors keep their type, falling back to the plain string
+func parameterValue(v string) any {
+	var value any
+	if err := json.Unmarshal([]byte(v), &value); err != nil {
+		return v
+	}
+	return value
+}
+
+// pngOptions returns opts, allocating it if needed
+func pngOptions(opts *models.PNGOptions) *models.PNGOptions {
+	if opts == nil {
+		return &models.PNGOptions{}
+	}
+	return opts
+}
+
+// renderETag derives a strong ETag from the request, the server build and the
+// render identity, so a new deployment, OpenSCAD upgrade or reload doesn't
+// serve renders cached from before it
+func renderETag(req *models.ExportRequest, identity string) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	info := version.GetInfo()
+	sum := sha256.New()
+	sum.Write([]byte(info.Commit + "\x00" + info.Tag + "\x00" + identity + "\x00"))
+	sum.Write(data)
+	return `"` + hex.EncodeToString(sum.Sum(nil)[:16]) + `"`, nil
+}
+
+// etagMatches reports whether an If-None-Match header lists etag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
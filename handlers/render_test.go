@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/services"
+)
+
+// encodeSource compresses and encodes SCAD source the way render URLs expect
+func encodeSource(t *testing.T, source string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		t.Fatalf("Failed to create compressor: %v", err)
+	}
+	w.Write([]byte(source))
+	w.Close()
+	return base64.RawURLEncoding.EncodeToString(buf.Bytes())
+}
+
+// sign appends the render URL signature for secret to pathAndQuery
+func sign(pathAndQuery, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(pathAndQuery))
+	return pathAndQuery + "&sig=" + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func setupRenderRouter(exporter services.OpenSCADExporter, secret string) *gin.Engine {
+	router := gin.New()
+	h := NewRenderHandler(exporter, secret, time.Hour)
+	router.GET("/openscad/v1/render/:format", h.Render)
+	return router
+}
+
+func TestRender(t *testing.T) {
+	var got models.ExportRequest
+	exporter := &MockOpenSCADExporter{
+		ExportFunc: func(req *models.ExportRequest) ([]byte, string, error) {
+			got = *req
+			return []byte("png data"), "image/png", nil
+		},
+	}
+	router := setupRenderRouter(exporter, "")
+
+	src := encodeSource(t, "cube([w, 10, 10]);")
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/openscad/v1/render/png?source="+src+"&size=400x300&camera=0,0,0,55,0,25,140&param.w=25&param.label=Hi&param.v=[1,2]&param.s=%2220%22", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got.ScadContent != "cube([w, 10, 10]);" || got.Format != "png" {
+		t.Errorf("Expected decoded source and png format, got %+v", got)
+	}
+	if png := got.Options.PNG; png == nil || *png.Width != 400 || *png.Height != 300 || *png.Camera != "0,0,0,55,0,25,140" {
+		t.Errorf("Expected 400x300 with camera, got %+v", got.Options.PNG)
+	}
+	if got.Parameters["w"] != 25.0 || got.Parameters["label"] != "Hi" || got.Parameters["s"] != "20" {
+		t.Errorf("Expected typed parameters, got %v", got.Parameters)
+	}
+	if v, ok := got.Parameters["v"].([]any); !ok || len(v) != 2 {
+		t.Errorf("Expected vector parameter, got %v", got.Parameters["v"])
+	}
+
+	etag := w.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `"`) {
+		t.Errorf("Expected strong ETag, got %q", etag)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=3600" {
+		t.Errorf("Expected cacheable response, got %q", cc)
+	}
+
+	// Revalidation is answered without rendering
+	got = models.ExportRequest{}
+	w = httptest.NewRecorder()
+	req.Header.Set("If-None-Match", `"other", `+etag)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304, got %d", w.Code)
+	}
+	if got.ScadContent != "" {
+		t.Error("Expected no render for a matching ETag")
+	}
+
+	// A different request has a different ETag
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/openscad/v1/render/png?source="+src+"&size=400x301", nil))
+	if w.Header().Get("ETag") == etag {
+		t.Error("Expected a different ETag for a different size")
+	}
+}
+
+// fakeIdentity is a RenderIdentifier returning a settable identity
+type fakeIdentity struct {
+	identity string
+}
+
+func (f *fakeIdentity) RenderIdentity(ctx context.Context, version string) (string, error) {
+	if version == "missing" {
+		return "", services.ErrUnknownVersion
+	}
+	return f.identity, nil
+}
+
+func TestRender_IdentityChangesETag(t *testing.T) {
+	exporter := &MockOpenSCADExporter{
+		ExportFunc: func(req *models.ExportRequest) ([]byte, string, error) {
+			return []byte("png data"), "image/png", nil
+		},
+	}
+	identity := &fakeIdentity{identity: "2021.01"}
+	router := gin.New()
+	router.GET("/openscad/v1/render/:format", NewRenderHandler(exporter, "", time.Hour).WithRenderIdentity(identity).Render)
+	target := "/openscad/v1/render/png?source=" + encodeSource(t, "cube(1);")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+	etag := w.Header().Get("ETag")
+
+	identity.identity = "2025.03.15"
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", target, nil)
+	req.Header.Set("If-None-Match", etag)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected a fresh render after the identity changed, got %d", w.Code)
+	}
+	if w.Header().Get("ETag") == etag {
+		t.Error("Expected a different ETag for a different identity")
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", target+"&openscad_version=missing", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown installation, got %d", w.Code)
+	}
+}
+
+func TestRender_InvalidRequests(t *testing.T) {
+	src := encodeSource(t, "cube(1);")
+	huge := encodeSource(t, strings.Repeat(" ", maxURLSourceBytes+1))
+
+	tests := []struct {
+		name   string
+		target string
+	}{
+		{"Unsupported format", "/openscad/v1/render/stl_binary?source=" + src},
+		{"Missing source", "/openscad/v1/render/png?size=10x10"},
+		{"Not base64", "/openscad/v1/render/png?source=***"},
+		{"Not compressed", "/openscad/v1/render/png?source=" + base64.RawURLEncoding.EncodeToString([]byte("cube(1);"))},
+		{"Source too large", "/openscad/v1/render/png?source=" + huge},
+		{"Bad size", "/openscad/v1/render/png?source=" + src + "&size=10"},
+		{"Size too large", "/openscad/v1/render/png?source=" + src + "&size=10000x10"},
+		{"Unknown parameter", "/openscad/v1/render/png?source=" + src + "&width=10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRenderRouter(&MockOpenSCADExporter{}, "")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", tt.target, nil))
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRender_Signature(t *testing.T) {
+	const secret = "s3cret"
+	path := "/openscad/v1/render/png?source=" + encodeSource(t, "cube(1);") + "&size=64x64"
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+	}{
+		{"Signed", sign(path, secret), http.StatusOK},
+		{"Padded signature", sign(path, secret) + "=", http.StatusOK},
+		{"Unsigned", path, http.StatusForbidden},
+		{"Wrong secret", sign(path, "other"), http.StatusForbidden},
+		{"Tampered query", strings.Replace(sign(path, secret), "64x64", "65x64", 1), http.StatusForbidden},
+		{"Parameter after signature", sign(path, secret) + "&size=128x128", http.StatusForbidden},
+		{"Different path", strings.Replace(sign(path, secret), "/png?", "/webp?", 1), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRenderRouter(&MockOpenSCADExporter{}, secret)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", tt.target, nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRender_ErrorsAreNotCached(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"Invalid parameter", services.ErrInvalidParameter, http.StatusBadRequest},
+		{"Render failure", errors.New("openscad command failed"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := &MockOpenSCADExporter{
+				ExportFunc: func(req *models.ExportRequest) ([]byte, string, error) {
+					return nil, "", tt.err
+				},
+			}
+			router := setupRenderRouter(exporter, "")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/openscad/v1/render/png?source="+encodeSource(t, "cube(1);"), nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if cc := w.Header().Get("Cache-Control"); cc != "no-store" {
+				t.Errorf("Expected Cache-Control no-store, got %q", cc)
+			}
+			if etag := w.Header().Get("ETag"); etag != "" {
+				t.Errorf("Expected no ETag, got %q", etag)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/logging"
+	"github.com/stevexciv/scad-server/middleware"
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/ratelimit"
+	"github.com/stevexciv/scad-server/services"
+	"github.com/stevexciv/scad-server/sweep"
+)
+
+// SweepHandler renders a model for every parameter set of a sweep
+type SweepHandler struct {
+	exporter    services.OpenSCADExporter
+	maxVariants int
+	workers     int
+	limiter     *ratelimit.Limiter
+	limits      RequestLimits
+}
+
+// NewSweepHandler creates a new sweep handler that renders up to maxVariants
+// variants per request, workers at a time
+func NewSweepHandler(exporter services.OpenSCADExporter, maxVariants, workers int) *SweepHandler {
+	return &SweepHandler{
+		exporter:    exporter,
+		maxVariants: maxVariants,
+		workers:     workers,
+		limits:      DefaultRequestLimits,
+	}
+}
+
+// WithRequestLimits refuses sweeps uploading more than limits allow
+func (h *SweepHandler) WithRequestLimits(limits RequestLimits) *SweepHandler {
+	h.limits = limits
+	return h
+}
+
+// WithRateLimit charges every variant of a sweep to the caller's request
+// quota, not just the request itself
+func (h *SweepHandler) WithRateLimit(limiter *ratelimit.Limiter) *SweepHandler {
+	h.limiter = limiter
+	return h
+}
+
+// Sweep handles the parameter sweep endpoint
+// @Summary Render a parameter sweep
+// @Description Renders a model once for every combination of "grid" (parameter name to list of values) or once for every parameter set in "sets", each over the shared "parameters"
+// @Description Responds with a zip archive of the outputs, named by the "filename" template ({index} and {parameter} placeholders, the format's extension is appended), and manifest.json and manifest.csv listing every variant's parameters, status, error and, with "summary_type" set, its summary
+// @Description Variants that fail are listed in the manifest; the request fails only when every variant fails
+// @Description Every variant counts as a request against the caller's quota; sweeps of more variants than the per-minute allowance are rejected
+// @Description The archive is streamed as variants finish, so the failure count is sent as a trailer
+// @Description Multipart requests send the JSON request in the "request" field and each asset as an "assets" file part
+// @Tags sweep
+// @Accept json,mpfd
+// @Produce application/zip
+// @Param request body models.SweepRequest true "Sweep request"
+// @Success 200 {file} binary "Zip archive of the outputs and manifests"
+// @Header 200 {integer} X-Sweep-Variants "Number of variants"
+// @Header 200 {integer} X-Sweep-Failed "Number of failed variants, sent as a trailer"
+// @Failure 400 {object} models.ErrorResponse "Bad Request"
+// @Failure 413 {object} models.ErrorResponse "Asset Too Large"
+// @Failure 429 {object} models.ErrorResponse "Quota Exceeded"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 503 {object} models.ErrorResponse "Shutting Down"
+// @Router /openscad/v1/sweep [post]
+func (h *SweepHandler) Sweep(c *gin.Context) {
+	var req models.SweepRequest
+	if err := bindRenderRequest(c, h.limits, &req, &req.Assets); err != nil {
+		respondError(c, bindErrorStatus(err), "invalid request", err)
+		return
+	}
+	variants, err := sweep.Plan(&req, h.maxVariants)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+
+	if h.limiter != nil {
+		if limit := h.limiter.Config().RequestsPerMinute; limit > 0 && len(variants) > limit {
+			respondError(c, http.StatusBadRequest, "invalid request", fmt.Errorf("%w: %d variants exceed the quota of %d requests per minute", sweep.ErrInvalid, len(variants), limit))
+			return
+		}
+		// The route's rate limit already counted the first variant
+		if !middleware.ChargeRequests(c, h.limiter, len(variants)-1) {
+			return
+		}
+	}
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	logger := logging.FromContext(ctx)
+
+	// Failures are held until the first success, so that a sweep where every
+	// variant fails can still respond with an error
+	var (
+		failed   []sweep.Result
+		archive  *sweep.Archive
+		writeErr error
+	)
+	sweep.Stream(ctx, h.exporter, &req, variants, h.workers, func(result sweep.Result) {
+		if writeErr != nil {
+			return
+		}
+		if archive == nil {
+			if result.Err != nil {
+				failed = append(failed, result)
+				return
+			}
+			c.Header("Content-Type", "application/zip")
+			c.Header("Content-Disposition", `attachment; filename="sweep.zip"`)
+			c.Header("X-Sweep-Variants", strconv.Itoa(len(variants)))
+			c.Header("Trailer", "X-Sweep-Failed")
+			c.Status(http.StatusOK)
+			archive = sweep.NewArchive(c.Writer, req.Format)
+			for _, f := range failed {
+				archive.Add(f)
+			}
+		}
+		if writeErr = archive.Add(result); writeErr != nil {
+			cancel()
+		}
+	})
+
+	if archive == nil {
+		err := failed[0].Err
+		logger.Error("sweep failed", "format", req.Format, "variants", len(variants), "error", err)
+		respondError(c, exportErrorStatus(err, req.Format), "sweep failed", fmt.Errorf("all %d variants failed, first: %w", len(variants), err))
+		return
+	}
+	if writeErr != nil {
+		// The response is already under way, so it can only be cut short
+		logger.Error("sweep archive failed", "format", req.Format, "error", writeErr)
+		return
+	}
+	manifest, err := archive.Close()
+	if err != nil {
+		logger.Error("sweep archive failed", "format", req.Format, "error", err)
+		return
+	}
+	c.Writer.Header().Set("X-Sweep-Failed", strconv.Itoa(manifest.Failed))
+	logger.Info("sweep rendered", "format", req.Format, "variants", manifest.Variants, "failed", manifest.Failed)
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/middleware"
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/ratelimit"
+	"github.com/stevexciv/scad-server/sweep"
+)
+
+func setupSweepRouter(exporter *MockOpenSCADExporter) *gin.Engine {
+	h := NewSweepHandler(exporter, 4, 2)
+	router := gin.New()
+	router.POST("/openscad/v1/sweep", h.Sweep)
+	return router
+}
+
+func TestSweep(t *testing.T) {
+	router := setupSweepRouter(&MockOpenSCADExporter{
+		ExportFunc: func(req *models.ExportRequest) ([]byte, string, error) {
+			if req.Parameters["size"] == 3.0 {
+				return nil, "", errors.New("render failed")
+			}
+			return []byte("solid"), "application/octet-stream", nil
+		},
+	})
+
+	w := sendJSON(router, "POST", "/openscad/v1/sweep", models.SweepRequest{
+		ScadContent: "cube(size);",
+		Format:      "stl_binary",
+		Grid:        map[string][]any{"size": {1, 2, 3}},
+		Filename:    "cube_{size}",
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "application/zip" || w.Header().Get("X-Sweep-Variants") != "3" || w.Result().Trailer.Get("X-Sweep-Failed") != "1" {
+		t.Errorf("Expected a zip of 3 variants with 1 failure, got headers %v and trailers %v", w.Header(), w.Result().Trailer)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("Expected a zip archive, got %v", err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	want := []string{"cube_1.stl", "cube_2.stl", sweep.ManifestJSON, sweep.ManifestCSV}
+	if len(names) != len(want) {
+		t.Fatalf("Expected entries %v, got %v", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("Expected entry %d to be %s, got %s", i, want[i], names[i])
+		}
+	}
+}
+
+func TestSweep_ChargesEveryVariant(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Config{RequestsPerMinute: 4})
+	h := NewSweepHandler(&MockOpenSCADExporter{}, 10, 2).WithRateLimit(limiter)
+	router := gin.New()
+	router.POST("/openscad/v1/sweep", middleware.RateLimit(limiter), h.Sweep)
+
+	sweepOf := func(n int) models.SweepRequest {
+		sets := make([]map[string]any, n)
+		for i := range sets {
+			sets[i] = map[string]any{"size": i + 1}
+		}
+		return models.SweepRequest{ScadContent: "cube(size);", Format: "stl_binary", Sets: sets}
+	}
+
+	if w := sendJSON(router, "POST", "/openscad/v1/sweep", sweepOf(5)); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a sweep over the per-minute quota to be rejected with 400, got %d", w.Code)
+	}
+	w := sendJSON(router, "POST", "/openscad/v1/sweep", sweepOf(2))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("X-RateLimit-Remaining"); got != "1" {
+		t.Errorf("Expected both variants to be charged, got %s requests remaining", got)
+	}
+	w = sendJSON(router, "POST", "/openscad/v1/sweep", sweepOf(2))
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected status 429 with Retry-After, got %d", w.Code)
+	}
+	if usage := limiter.Usage("ip:192.0.2.1"); usage.RequestsUsed != 4 {
+		t.Errorf("Expected the rejected sweep to count only its request, got %d used", usage.RequestsUsed)
+	}
+}
+
+func TestSweep_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       any
+		exporter   *MockOpenSCADExporter
+		wantStatus int
+	}{
+		{
+			name:       "missing format",
+			body:       map[string]any{"scad_content": "cube(size);", "grid": map[string]any{"size": []any{1}}},
+			exporter:   &MockOpenSCADExporter{},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "no grid or sets",
+			body:       models.SweepRequest{ScadContent: "cube(size);", Format: "stl_binary"},
+			exporter:   &MockOpenSCADExporter{},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "too many variants",
+			body:       models.SweepRequest{ScadContent: "cube(size);", Format: "stl_binary", Grid: map[string][]any{"size": {1, 2, 3, 4, 5}}},
+			exporter:   &MockOpenSCADExporter{},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "every variant fails",
+			body: models.SweepRequest{ScadContent: "cube(size);", Format: "stl_binary", Sets: []map[string]any{{"size": 1}, {"size": 2}}},
+			exporter: &MockOpenSCADExporter{
+				ExportFunc: func(req *models.ExportRequest) ([]byte, string, error) {
+					return nil, "", errors.New("render failed")
+				},
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "unsupported format",
+			body: models.SweepRequest{ScadContent: "cube(size);", Format: "obj", Sets: []map[string]any{{"size": 1}}},
+			exporter: &MockOpenSCADExporter{
+				ExportFunc: func(req *models.ExportRequest) ([]byte, string, error) {
+					return nil, "", errors.New("unsupported format: obj")
+				},
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := sendJSON(setupSweepRouter(tt.exporter), "POST", "/openscad/v1/sweep", tt.body)
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
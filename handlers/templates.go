@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/templates"
+)
+
+// errTemplatesDisabled is returned when no template directory is configured
+var errTemplatesDisabled = errors.New("templates are disabled")
+
+// TemplateHandler lists parametric templates and renders them from
+// parameter values alone
+type TemplateHandler struct {
+	catalog *templates.Catalog
+	exports *Handler
+}
+
+// NewTemplateHandler creates a new template handler that renders through
+// exports. A nil catalog disables the endpoints.
+func NewTemplateHandler(catalog *templates.Catalog, exports *Handler) *TemplateHandler {
+	return &TemplateHandler{
+		catalog: catalog,
+		exports: exports,
+	}
+}
+
+// List handles the template listing endpoint
+// @Summary List templates
+// @Description Lists the templates sorted by name, each with the parameter schema extracted from its customizer annotations
+// @Tags templates
+// @Produce json
+// @Success 200 {object} models.TemplateList "Templates"
+// @Failure 501 {object} models.ErrorResponse "Templates disabled"
+// @Router /openscad/v1/templates [get]
+func (h *TemplateHandler) List(c *gin.Context) {
+	if h.catalog == nil {
+		respondError(c, http.StatusNotImplemented, "template listing failed", errTemplatesDisabled)
+		return
+	}
+	c.JSON(http.StatusOK, models.TemplateList{Templates: h.catalog.List()})
+}
+
+// Get handles the template endpoint
+// @Summary Get a template
+// @Description Returns a template with its parameter schema
+// @Tags templates
+// @Produce json
+// @Param name path string true "Template name"
+// @Success 200 {object} models.Template "Template"
+// @Failure 404 {object} models.ErrorResponse "Not Found"
+// @Failure 501 {object} models.ErrorResponse "Templates disabled"
+// @Router /openscad/v1/templates/{name} [get]
+func (h *TemplateHandler) Get(c *gin.Context) {
+	tmpl, ok := h.template(c, "template lookup failed")
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, tmpl.Template)
+}
+
+// Export handles the template export endpoint
+// @Summary Export a template
+// @Description Renders a template like the export endpoint, without SCAD code. Parameters not given keep their defaults; values are checked against the template's parameter schema.
+// @Description "store" and "callback_url" work as for the export endpoint.
+// @Tags templates
+// @Accept json
+// @Produce octet-stream
+// @Param name path string true "Template name"
+// @Param request body models.TemplateExportRequest true "Format and parameter values"
+// @Success 200 {file} binary "Exported file"
+// @Success 202 {object} models.CallbackAccepted "Render started, result goes to callback_url"
+// @Header 200 {string} X-Artifact-ID "ID of the stored artifact, when store is set"
+// @Header 200 {string} X-Artifact-URL "Signed download link of the stored artifact, when store is set"
+// @Failure 400 {object} models.ErrorResponse "Bad Request"
+// @Failure 404 {object} models.ErrorResponse "Not Found"
+// @Failure 429 {object} models.ErrorResponse "Quota Exceeded"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 501 {object} models.ErrorResponse "Templates, artifact storage or callbacks disabled"
+// @Failure 503 {object} models.ErrorResponse "Shutting Down"
+// @Failure 507 {object} models.ErrorResponse "Storage Full"
+// @Router /openscad/v1/templates/{name}/export [post]
+func (h *TemplateHandler) Export(c *gin.Context) {
+	tmpl, ok := h.template(c, "export failed")
+	if !ok {
+		return
+	}
+
+	var req models.TemplateExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+	if err := tmpl.Validate(req.Parameters); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+
+	h.exports.export(c, &models.ExportRequest{
+		ScadContent:     tmpl.Source(),
+		Format:          req.Format,
+		Parameters:      req.Parameters,
+		Options:         req.Options,
+		OpenSCADVersion: req.OpenSCADVersion,
+		Backend:         req.Backend,
+		Features:        req.Features,
+		Store:           req.Store,
+		CallbackURL:     req.CallbackURL,
+	})
+}
+
+// template looks up the template named by the request path, responding with
+// errMsg and returning false if that fails
+func (h *TemplateHandler) template(c *gin.Context, errMsg string) (*templates.Template, bool) {
+	if h.catalog == nil {
+		respondError(c, http.StatusNotImplemented, errMsg, errTemplatesDisabled)
+		return nil, false
+	}
+
+	tmpl, err := h.catalog.Get(c.Param("name"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, errMsg, err)
+		return nil, false
+	}
+	return tmpl, true
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/templates"
+)
+
+const standoffTemplate = `// Threaded standoff
+
+height = 10; // [2:50]
+hole = "M3"; // [M2, M3, M4]
+cylinder(h = height);
+`
+
+func setupTemplateRouter(t *testing.T, exporter *MockOpenSCADExporter) *gin.Engine {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "standoff.scad"), []byte(standoffTemplate), 0o644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	catalog, err := templates.Load(dir)
+	if err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+
+	h := NewTemplateHandler(catalog, NewHandlerWithService(exporter))
+	router := gin.New()
+	router.GET("/openscad/v1/templates", h.List)
+	router.GET("/openscad/v1/templates/:name", h.Get)
+	router.POST("/openscad/v1/templates/:name/export", h.Export)
+	return router
+}
+
+func TestTemplates_List(t *testing.T) {
+	router := setupTemplateRouter(t, &MockOpenSCADExporter{})
+
+	w := sendJSON(router, "GET", "/openscad/v1/templates", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var list models.TemplateList
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list.Templates) != 1 || list.Templates[0].Name != "standoff" || len(list.Templates[0].Parameters) != 2 {
+		t.Fatalf("Expected standoff with 2 parameters, got %+v", list)
+	}
+	if hole := list.Templates[0].Parameters[1]; hole.Name != "hole" || len(hole.Options) != 3 {
+		t.Errorf("Expected hole with 3 options, got %+v", hole)
+	}
+
+	if w := sendJSON(router, "GET", "/openscad/v1/templates/standoff", nil); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
+
+func TestTemplates_Export(t *testing.T) {
+	var rendered *models.ExportRequest
+	router := setupTemplateRouter(t, &MockOpenSCADExporter{
+		ExportFunc: func(req *models.ExportRequest) ([]byte, string, error) {
+			rendered = req
+			return []byte("solid standoff"), "application/octet-stream", nil
+		},
+	})
+
+	w := sendJSON(router, "POST", "/openscad/v1/templates/standoff/export", models.TemplateExportRequest{
+		Format:     "stl_binary",
+		Parameters: map[string]any{"height": 20, "hole": "M4"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Body.String() != "solid standoff" {
+		t.Errorf("Expected the exported file, got %q", w.Body.String())
+	}
+	if rendered.ScadContent != standoffTemplate || rendered.Format != "stl_binary" || rendered.Parameters["hole"] != "M4" {
+		t.Errorf("Expected the template source with the parameters, got %+v", rendered)
+	}
+}
+
+func TestTemplates_Errors(t *testing.T) {
+	router := setupTemplateRouter(t, &MockOpenSCADExporter{})
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       any
+		wantStatus int
+	}{
+		{"unknown template", "GET", "/openscad/v1/templates/missing", nil, http.StatusNotFound},
+		{"export unknown template", "POST", "/openscad/v1/templates/missing/export", models.TemplateExportRequest{Format: "stl_binary"}, http.StatusNotFound},
+		{"missing format", "POST", "/openscad/v1/templates/standoff/export", map[string]any{"parameters": map[string]any{"height": 5}}, http.StatusBadRequest},
+		{"unknown parameter", "POST", "/openscad/v1/templates/standoff/export", models.TemplateExportRequest{Format: "stl_binary", Parameters: map[string]any{"width": 5}}, http.StatusBadRequest},
+		{"out of range", "POST", "/openscad/v1/templates/standoff/export", models.TemplateExportRequest{Format: "stl_binary", Parameters: map[string]any{"height": 100}}, http.StatusBadRequest},
+		{"not an option", "POST", "/openscad/v1/templates/standoff/export", models.TemplateExportRequest{Format: "stl_binary", Parameters: map[string]any{"hole": "M5"}}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := sendJSON(router, tt.method, tt.path, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestTemplates_Disabled(t *testing.T) {
+	h := NewTemplateHandler(nil, NewHandlerWithService(&MockOpenSCADExporter{}))
+	router := gin.New()
+	router.GET("/openscad/v1/templates", h.List)
+	router.POST("/openscad/v1/templates/:name/export", h.Export)
+
+	if w := sendJSON(router, "GET", "/openscad/v1/templates", nil); w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status 501, got %d", w.Code)
+	}
+	if w := sendJSON(router, "POST", "/openscad/v1/templates/standoff/export", nil); w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status 501, got %d", w.Code)
+	}
+}
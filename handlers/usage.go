@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/middleware"
+	"github.com/stevexciv/scad-server/ratelimit"
+)
+
+// UsageHandler reports rate limit and render quota consumption
+type UsageHandler struct {
+	limiter *ratelimit.Limiter
+}
+
+// NewUsageHandler creates a new usage handler backed by the given limiter
+func NewUsageHandler(limiter *ratelimit.Limiter) *UsageHandler {
+	return &UsageHandler{
+		limiter: limiter,
+	}
+}
+
+// Usage handles the usage endpoint
+// @Summary Current quota usage
+// @Description Returns the calling client's request count and render-seconds consumption for the current windows. Clients are identified by a configured X-API-Key, or by IP address otherwise. A limit of 0 means unlimited.
+// @Tags usage
+// @Produce json
+// @Param X-API-Key header string false "Client API key"
+// @Success 200 {object} ratelimit.Usage "Current usage"
+// @Router /openscad/v1/usage [get]
+func (h *UsageHandler) Usage(c *gin.Context) {
+	c.JSON(http.StatusOK, h.limiter.Usage(middleware.ClientKey(c)))
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/middleware"
+	"github.com/stevexciv/scad-server/ratelimit"
+)
+
+func TestUsageEndpoint(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Config{RequestsPerMinute: 10, RenderSeconds: 60})
+	h := NewUsageHandler(limiter)
+
+	router := gin.New()
+	router.GET("/openscad/v1/usage", h.Usage)
+	router.POST("/openscad/v1/export", middleware.RateLimit(limiter), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/openscad/v1/export", nil)
+		router.ServeHTTP(w, req)
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/openscad/v1/usage", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var usage ratelimit.Usage
+	if err := json.Unmarshal(w.Body.Bytes(), &usage); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	if usage.RequestsUsed != 3 {
+		t.Errorf("Expected 3 requests used, got %d", usage.RequestsUsed)
+	}
+	if usage.RequestsRemaining != 7 {
+		t.Errorf("Expected 7 requests remaining, got %d", usage.RequestsRemaining)
+	}
+	if usage.RenderSecondsLimit != 60 {
+		t.Errorf("Expected render seconds limit 60, got %v", usage.RenderSecondsLimit)
+	}
+}
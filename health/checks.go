@@ -0,0 +1,75 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/stevexciv/scad-server/services"
+)
+
+// Shutdown fails once the service has started draining for shutdown
+func Shutdown(service services.Drainer) CheckFunc {
+	return func(ctx context.Context) (Details, error) {
+		if service.Draining() {
+			return nil, errors.New("server is draining for shutdown")
+		}
+		return nil, nil
+	}
+}
+
+// Binary fails if any configured OpenSCAD installation can no longer be
+// executed; details map each installation to its version line
+func Binary(service *services.OpenSCADService) CheckFunc {
+	return func(ctx context.Context) (Details, error) {
+		versions, err := service.CheckBinaries(ctx)
+		details := make(Details, len(versions))
+		for name, version := range versions {
+			details[name] = version
+		}
+		return details, err
+	}
+}
+
+// TempDir fails if the render temp directory is not writable or has less than
+// minFree bytes available
+func TempDir(service *services.OpenSCADService, minFree uint64) CheckFunc {
+	return func(ctx context.Context) (Details, error) {
+		status, err := service.CheckTempDir()
+		details := Details{"dir": status.Dir, "min_free_bytes": minFree}
+		if status.FreeKnown {
+			details["free_bytes"] = status.FreeBytes
+		}
+		if err != nil {
+			return details, err
+		}
+		if status.FreeKnown && status.FreeBytes < minFree {
+			return details, fmt.Errorf("only %d bytes free, need %d", status.FreeBytes, minFree)
+		}
+		return details, nil
+	}
+}
+
+// Queue fails when more than maxQueued renders are waiting for a slot
+func Queue(service *services.OpenSCADService, maxQueued int) CheckFunc {
+	return func(ctx context.Context) (Details, error) {
+		q := service.QueueStatus()
+		details := Details{
+			"queued":     q.Queued,
+			"in_flight":  q.InFlight,
+			"capacity":   q.Capacity,
+			"max_queued": maxQueued,
+		}
+		if q.Queued > maxQueued {
+			return details, fmt.Errorf("render queue saturated: %d waiting, limit %d", q.Queued, maxQueued)
+		}
+		return details, nil
+	}
+}
+
+// SelfTest fails if rendering a small cube does not succeed
+func SelfTest(service *services.OpenSCADService) CheckFunc {
+	return func(ctx context.Context) (Details, error) {
+		return nil, service.SelfTest(ctx)
+	}
+}
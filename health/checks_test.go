@@ -0,0 +1,60 @@
+package health
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stevexciv/scad-server/services"
+)
+
+func newTestService(t *testing.T) *services.OpenSCADService {
+	t.Helper()
+	cfg := services.DefaultConfig()
+	cfg.TempDir = t.TempDir()
+	cfg.MaxConcurrentRenders = 2
+	return services.NewOpenSCADServiceWithConfig(cfg)
+}
+
+func TestTempDir(t *testing.T) {
+	service := newTestService(t)
+
+	details, err := TempDir(service, 1)(context.Background())
+	if err != nil {
+		t.Fatalf("Expected writable temp dir, got %v", err)
+	}
+	if details["dir"] == "" {
+		t.Errorf("Expected dir in details")
+	}
+
+	if _, err := TempDir(service, math.MaxUint64)(context.Background()); err == nil {
+		t.Errorf("Expected failure when free space is below threshold")
+	}
+}
+
+func TestQueue(t *testing.T) {
+	service := newTestService(t)
+
+	details, err := Queue(service, 0)(context.Background())
+	if err != nil {
+		t.Fatalf("Expected idle queue to pass, got %v", err)
+	}
+	if details["capacity"] != 2 {
+		t.Errorf("Expected capacity 2, got %v", details["capacity"])
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	service := newTestService(t)
+
+	if _, err := Shutdown(service)(context.Background()); err != nil {
+		t.Errorf("Expected running service to pass, got %v", err)
+	}
+
+	if err := service.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Failed to shut down: %v", err)
+	}
+	if _, err := Shutdown(service)(context.Background()); err == nil {
+		t.Errorf("Expected draining service to fail")
+	}
+}
@@ -0,0 +1,146 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+// Check statuses reported in models.CheckResult
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Overall statuses reported in models.ReadinessResponse
+const (
+	StatusReady    = "ready"
+	StatusNotReady = "not_ready"
+)
+
+// Details carries check-specific values such as free bytes or queue depth
+type Details map[string]interface{}
+
+// CheckFunc performs one readiness check. A non-nil error marks the check as
+// failed; details are reported either way.
+type CheckFunc func(ctx context.Context) (Details, error)
+
+type namedCheck struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker runs a fixed set of readiness checks concurrently
+type Checker struct {
+	timeout time.Duration
+	checks  []namedCheck
+}
+
+// NewChecker creates a checker that gives each check at most timeout to finish
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers a check under name; checks are reported in the order added
+func (c *Checker) Add(name string, fn CheckFunc) {
+	c.checks = append(c.checks, namedCheck{name: name, fn: fn})
+}
+
+// Run executes every check and reports the server ready only if all pass
+func (c *Checker) Run(ctx context.Context) models.ReadinessResponse {
+	results := make([]models.CheckResult, len(c.checks))
+
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	resp := models.ReadinessResponse{
+		Status: StatusReady,
+		Checks: make(map[string]models.CheckResult, len(results)),
+	}
+	for i, result := range results {
+		if result.Status != StatusOK {
+			resp.Status = StatusNotReady
+		}
+		resp.Checks[c.checks[i].name] = result
+	}
+	return resp
+}
+
+// run executes one check, converting a timeout into a failure
+func (c *Checker) run(ctx context.Context, check namedCheck) models.CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	type outcome struct {
+		details Details
+		err     error
+	}
+	done := make(chan outcome, 1)
+
+	start := time.Now()
+	go func() {
+		details, err := check.fn(ctx)
+		done <- outcome{details, err}
+	}()
+
+	var out outcome
+	select {
+	case out = <-done:
+	case <-ctx.Done():
+		out.err = fmt.Errorf("check timed out after %s", c.timeout)
+	}
+
+	result := models.CheckResult{
+		Status:     StatusOK,
+		DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+		Details:    out.details,
+	}
+	if out.err != nil {
+		result.Status = StatusFail
+		result.Error = out.err.Error()
+	}
+	return result
+}
+
+// Cached wraps fn so that it runs at most once per ttl; callers in between
+// receive the previous outcome with "cached" and "checked_at" details added.
+// Concurrent callers wait for a single run rather than starting their own.
+func Cached(ttl time.Duration, fn CheckFunc) CheckFunc {
+	var (
+		mu        sync.Mutex
+		checkedAt time.Time
+		details   Details
+		lastErr   error
+	)
+
+	return func(ctx context.Context) (Details, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		cached := !checkedAt.IsZero() && time.Since(checkedAt) < ttl
+		if !cached {
+			d, err := fn(ctx)
+			if ctx.Err() != nil {
+				// Runs cut short by the caller are not cached
+				return d, err
+			}
+			details, lastErr, checkedAt = d, err, time.Now()
+		}
+
+		out := Details{"cached": cached, "checked_at": checkedAt.UTC().Format(time.RFC3339)}
+		for k, v := range details {
+			out[k] = v
+		}
+		return out, lastErr
+	}
+}
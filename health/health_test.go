@@ -0,0 +1,117 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChecker_Run(t *testing.T) {
+	tests := []struct {
+		name       string
+		checks     map[string]CheckFunc
+		wantStatus string
+		wantFailed []string
+	}{
+		{
+			name: "All passing",
+			checks: map[string]CheckFunc{
+				"a": func(ctx context.Context) (Details, error) { return nil, nil },
+				"b": func(ctx context.Context) (Details, error) { return Details{"n": 1}, nil },
+			},
+			wantStatus: StatusReady,
+		},
+		{
+			name: "One failing",
+			checks: map[string]CheckFunc{
+				"a": func(ctx context.Context) (Details, error) { return nil, nil },
+				"b": func(ctx context.Context) (Details, error) { return nil, errors.New("broken") },
+			},
+			wantStatus: StatusNotReady,
+			wantFailed: []string{"b"},
+		},
+		{
+			name: "Timed out",
+			checks: map[string]CheckFunc{
+				"slow": func(ctx context.Context) (Details, error) {
+					time.Sleep(time.Second)
+					return nil, nil
+				},
+			},
+			wantStatus: StatusNotReady,
+			wantFailed: []string{"slow"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker(50 * time.Millisecond)
+			for name, fn := range tt.checks {
+				c.Add(name, fn)
+			}
+
+			resp := c.Run(context.Background())
+			if resp.Status != tt.wantStatus {
+				t.Errorf("Expected status %s, got %s", tt.wantStatus, resp.Status)
+			}
+			if len(resp.Checks) != len(tt.checks) {
+				t.Errorf("Expected %d check results, got %d", len(tt.checks), len(resp.Checks))
+			}
+			for _, name := range tt.wantFailed {
+				result := resp.Checks[name]
+				if result.Status != StatusFail {
+					t.Errorf("Expected check %s to fail, got %s", name, result.Status)
+				}
+				if result.Error == "" {
+					t.Errorf("Expected check %s to report an error", name)
+				}
+			}
+		})
+	}
+}
+
+func TestCached(t *testing.T) {
+	calls := 0
+	check := Cached(time.Hour, func(ctx context.Context) (Details, error) {
+		calls++
+		return Details{"calls": calls}, errors.New("render failed")
+	})
+
+	for i := 0; i < 3; i++ {
+		details, err := check(context.Background())
+		if err == nil || err.Error() != "render failed" {
+			t.Errorf("Expected cached error, got %v", err)
+		}
+		if details["calls"] != 1 {
+			t.Errorf("Expected details from first run, got %v", details["calls"])
+		}
+		if details["cached"] != (i > 0) {
+			t.Errorf("Expected cached=%v on call %d, got %v", i > 0, i+1, details["cached"])
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %d", calls)
+	}
+}
+
+func TestCached_SkipsInterruptedRuns(t *testing.T) {
+	calls := 0
+	check := Cached(time.Hour, func(ctx context.Context) (Details, error) {
+		calls++
+		return nil, ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := check(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context canceled, got %v", err)
+	}
+
+	if _, err := check(context.Background()); err != nil {
+		t.Errorf("Expected fresh run to pass, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 calls, got %d", calls)
+	}
+}
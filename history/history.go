@@ -0,0 +1,279 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/sqlitedb"
+)
+
+const (
+	// DefaultLimit is the page size when a query doesn't set one
+	DefaultLimit = 50
+	// MaxLimit is the largest page size
+	MaxLimit = 500
+	// schemaVersion is the version of the current schema
+	schemaVersion = 1
+)
+
+// schema creates the tables of schemaVersion
+const schema = `
+CREATE TABLE IF NOT EXISTS renders (
+	id               INTEGER PRIMARY KEY AUTOINCREMENT,
+	created_at       INTEGER NOT NULL,
+	request_id       TEXT    NOT NULL DEFAULT '',
+	client           TEXT    NOT NULL DEFAULT '',
+	operation        TEXT    NOT NULL,
+	format           TEXT    NOT NULL DEFAULT '',
+	request_hash     TEXT    NOT NULL,
+	openscad_version TEXT    NOT NULL DEFAULT '',
+	options          TEXT,
+	parameters       TEXT,
+	status           TEXT    NOT NULL,
+	duration_seconds REAL    NOT NULL,
+	exit_code        INTEGER,
+	output_bytes     INTEGER NOT NULL DEFAULT 0,
+	error            TEXT    NOT NULL DEFAULT '',
+	diagnostics      TEXT    NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS renders_created_at ON renders (created_at);
+CREATE INDEX IF NOT EXISTS renders_client ON renders (client, id);
+`
+
+// insertColumns are the renders columns written by Add and columns those read
+// by scanEntry, in order
+const (
+	insertColumns = `created_at, request_id, client, operation, format, request_hash, openscad_version,
+	options, parameters, status, duration_seconds, exit_code, output_bytes, error, diagnostics`
+	columns = "id, " + insertColumns
+)
+
+// Config contains history store settings
+type Config struct {
+	// Path is the SQLite database file
+	Path string
+	// Retention is how long entries are kept (0 keeps them forever)
+	Retention time.Duration
+	// MaxEntries caps the number of entries kept (0 for unlimited)
+	MaxEntries int
+}
+
+// Filter selects history entries. Zero fields don't filter.
+type Filter struct {
+	Operation   string
+	Format      string
+	Client      string
+	Status      string
+	RequestHash string
+	Since       time.Time
+	Until       time.Time
+	// Cursor continues a listing after the entry with this ID
+	Cursor int64
+	// Limit is the page size, DefaultLimit when 0
+	Limit int
+}
+
+// Store keeps the render history in an SQLite database
+type Store struct {
+	db         *sql.DB
+	retention  time.Duration
+	maxEntries int
+	now        func() time.Time
+}
+
+// Open opens or creates the database at cfg.Path
+func Open(ctx context.Context, cfg Config) (*Store, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("history database path must not be empty")
+	}
+	db, err := sqlitedb.Open(cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+	if err := sqlitedb.Migrate(ctx, db, "history", schemaVersion, schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+	return &Store{db: db, retention: cfg.Retention, maxEntries: cfg.MaxEntries, now: time.Now}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Add records e, setting its ID and, when unset, its creation time
+func (s *Store) Add(ctx context.Context, e *models.HistoryEntry) error {
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = s.now().UTC()
+	}
+	options, err := sqlitedb.NullJSON(e.Options, e.Options == nil)
+	if err != nil {
+		return err
+	}
+	parameters, err := sqlitedb.NullJSON(e.Parameters, len(e.Parameters) == 0)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.ExecContext(ctx, `INSERT INTO renders (`+insertColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.CreatedAt.UnixMilli(), e.RequestID, e.Client, e.Operation, e.Format, e.RequestHash, e.OpenSCADVersion,
+		options, parameters, e.Status, e.DurationSeconds, e.ExitCode, e.OutputBytes, e.Error, e.Diagnostics)
+	if err != nil {
+		return fmt.Errorf("failed to record history: %w", err)
+	}
+	e.ID, err = result.LastInsertId()
+	return err
+}
+
+// List returns the entries matching f, newest first, and the cursor of the
+// next page ("" on the last page)
+func (s *Store) List(ctx context.Context, f Filter) ([]models.HistoryEntry, string, error) {
+	var where []string
+	var args []any
+	add := func(cond string, arg any) {
+		where = append(where, cond)
+		args = append(args, arg)
+	}
+	for column, value := range map[string]string{
+		"operation":    f.Operation,
+		"format":       f.Format,
+		"client":       f.Client,
+		"status":       f.Status,
+		"request_hash": f.RequestHash,
+	} {
+		if value != "" {
+			add(column+" = ?", value)
+		}
+	}
+	if !f.Since.IsZero() {
+		add("created_at >= ?", f.Since.UnixMilli())
+	}
+	if !f.Until.IsZero() {
+		add("created_at < ?", f.Until.UnixMilli())
+	}
+	if f.Cursor > 0 {
+		add("id < ?", f.Cursor)
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	limit = min(limit, MaxLimit)
+
+	query := "SELECT " + columns + " FROM renders"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	// One extra row tells whether there is another page
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit+1)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query history: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.HistoryEntry{}
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read history: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to read history: %w", err)
+	}
+
+	var next string
+	if len(entries) > limit {
+		entries = entries[:limit]
+		next = fmt.Sprint(entries[limit-1].ID)
+	}
+	return entries, next, nil
+}
+
+// Prune deletes entries older than the retention period and beyond the
+// entry cap, returning how many it deleted
+func (s *Store) Prune(ctx context.Context) (int64, error) {
+	var removed int64
+	if s.retention > 0 {
+		result, err := s.db.ExecContext(ctx, "DELETE FROM renders WHERE created_at < ?", s.now().Add(-s.retention).UnixMilli())
+		if err != nil {
+			return 0, fmt.Errorf("failed to prune history: %w", err)
+		}
+		n, _ := result.RowsAffected()
+		removed += n
+	}
+	if s.maxEntries > 0 {
+		result, err := s.db.ExecContext(ctx,
+			"DELETE FROM renders WHERE id <= (SELECT id FROM renders ORDER BY id DESC LIMIT 1 OFFSET ?)", s.maxEntries)
+		if err != nil {
+			return removed, fmt.Errorf("failed to prune history: %w", err)
+		}
+		n, _ := result.RowsAffected()
+		removed += n
+	}
+	return removed, nil
+}
+
+// RunRetention calls Prune every interval until ctx is done
+func (s *Store) RunRetention(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			removed, err := s.Prune(ctx)
+			if err != nil {
+				slog.Error("History pruning failed", "error", err)
+			} else if removed > 0 {
+				slog.Info("Pruned render history", "count", removed)
+			}
+		}
+	}
+}
+
+// scanEntry reads a row selected with columns
+func scanEntry(rows *sql.Rows) (models.HistoryEntry, error) {
+	var e models.HistoryEntry
+	var createdAt int64
+	var options, parameters sql.NullString
+	var exitCode sql.NullInt64
+	err := rows.Scan(&e.ID, &createdAt, &e.RequestID, &e.Client, &e.Operation, &e.Format, &e.RequestHash,
+		&e.OpenSCADVersion, &options, &parameters, &e.Status, &e.DurationSeconds, &exitCode, &e.OutputBytes,
+		&e.Error, &e.Diagnostics)
+	if err != nil {
+		return e, err
+	}
+
+	e.CreatedAt = time.UnixMilli(createdAt).UTC()
+	if options.Valid {
+		if err := json.Unmarshal([]byte(options.String), &e.Options); err != nil {
+			return e, err
+		}
+	}
+	if parameters.Valid {
+		if err := json.Unmarshal([]byte(parameters.String), &e.Parameters); err != nil {
+			return e, err
+		}
+	}
+	if exitCode.Valid {
+		code := int(exitCode.Int64)
+		e.ExitCode = &code
+	}
+	return e, nil
+}
@@ -0,0 +1,239 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+func openTestStore(t *testing.T, cfg Config) *Store {
+	t.Helper()
+	cfg.Path = filepath.Join(t.TempDir(), "history.db")
+	store, err := Open(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// add records an entry created at the given time
+func add(t *testing.T, store *Store, createdAt time.Time, e models.HistoryEntry) models.HistoryEntry {
+	t.Helper()
+	e.CreatedAt = createdAt
+	if e.Status == "" {
+		e.Status = "succeeded"
+	}
+	if err := store.Add(context.Background(), &e); err != nil {
+		t.Fatalf("Failed to add entry: %v", err)
+	}
+	return e
+}
+
+func TestAdd_RoundTrip(t *testing.T) {
+	store := openTestStore(t, Config{})
+	exitCode, precision := 1, 8
+	want := add(t, store, time.Date(2026, 1, 2, 3, 4, 5, 6e6, time.UTC), models.HistoryEntry{
+		RequestID:       "req-1",
+		Client:          "ip:10.0.0.1",
+		Operation:       "export",
+		Format:          "stl_binary",
+		RequestHash:     "sha256:abc",
+		OpenSCADVersion: "nightly",
+		Options:         &models.ExportOptions{STL: &models.STLOptions{DecimalPrecision: &precision}},
+		Parameters:      map[string]any{"size": 10.0},
+		Status:          "failed",
+		DurationSeconds: 1.5,
+		ExitCode:        &exitCode,
+		Error:           "openscad command failed",
+		Diagnostics:     "ERROR: Parser error",
+	})
+	if want.ID == 0 {
+		t.Fatalf("Expected Add to set the ID")
+	}
+
+	entries, next, err := store.List(context.Background(), Filter{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(entries) != 1 || next != "" {
+		t.Fatalf("Expected 1 entry and no next page, got %d and %q", len(entries), next)
+	}
+	got := entries[0]
+	if got.ID != want.ID || !got.CreatedAt.Equal(want.CreatedAt) || got.Client != want.Client || got.RequestHash != want.RequestHash {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+	if got.Options == nil || got.Options.STL == nil || *got.Options.STL.DecimalPrecision != 8 || got.Parameters["size"] != 10.0 {
+		t.Errorf("Expected options and parameters to round-trip, got %+v %v", got.Options, got.Parameters)
+	}
+	if got.ExitCode == nil || *got.ExitCode != 1 || got.Diagnostics != want.Diagnostics || got.DurationSeconds != 1.5 {
+		t.Errorf("Expected exit code 1 with diagnostics, got %+v", got)
+	}
+}
+
+func TestList_Filters(t *testing.T) {
+	store := openTestStore(t, Config{})
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	add(t, store, base, models.HistoryEntry{Operation: "export", Format: "stl_binary", Client: "ip:a", RequestHash: "h1"})
+	add(t, store, base.Add(time.Hour), models.HistoryEntry{Operation: "export", Format: "3mf", Client: "ip:b", RequestHash: "h2", Status: "failed"})
+	add(t, store, base.Add(2*time.Hour), models.HistoryEntry{Operation: "summary", Format: "all", Client: "ip:a", RequestHash: "h3"})
+
+	tests := []struct {
+		name      string
+		filter    Filter
+		wantCount int
+	}{
+		{"All", Filter{}, 3},
+		{"Operation", Filter{Operation: "export"}, 2},
+		{"Format", Filter{Format: "3mf"}, 1},
+		{"Client", Filter{Client: "ip:a"}, 2},
+		{"Status", Filter{Status: "failed"}, 1},
+		{"Request hash", Filter{RequestHash: "h3"}, 1},
+		{"Since", Filter{Since: base.Add(time.Hour)}, 2},
+		{"Until", Filter{Until: base.Add(time.Hour)}, 1},
+		{"Combined", Filter{Client: "ip:a", Operation: "summary"}, 1},
+		{"No match", Filter{Client: "ip:c"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, _, err := store.List(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(entries) != tt.wantCount {
+				t.Errorf("Expected %d entries, got %d", tt.wantCount, len(entries))
+			}
+		})
+	}
+}
+
+func TestList_Pagination(t *testing.T) {
+	store := openTestStore(t, Config{})
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		add(t, store, base.Add(time.Duration(i)*time.Minute), models.HistoryEntry{Operation: "export", RequestHash: "h"})
+	}
+
+	var ids []int64
+	var cursor int64
+	for pages := 1; ; pages++ {
+		entries, next, err := store.List(context.Background(), Filter{Limit: 2, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		for _, e := range entries {
+			ids = append(ids, e.ID)
+		}
+		if next == "" {
+			if pages != 3 {
+				t.Errorf("Expected 3 pages, got %d", pages)
+			}
+			break
+		}
+		if _, err := fmt.Sscan(next, &cursor); err != nil {
+			t.Fatalf("Expected a numeric cursor, got %q", next)
+		}
+	}
+
+	if len(ids) != 5 {
+		t.Fatalf("Expected 5 entries, got %v", ids)
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] >= ids[i-1] {
+			t.Errorf("Expected newest first without repeats, got %v", ids)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		cfg         Config
+		wantRemoved int64
+		wantLeft    int
+	}{
+		{"Retention", Config{Retention: 24 * time.Hour}, 2, 2},
+		{"Max entries", Config{MaxEntries: 3}, 1, 3},
+		{"Both", Config{Retention: 24 * time.Hour, MaxEntries: 1}, 3, 1},
+		{"Keep everything", Config{}, 0, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := openTestStore(t, tt.cfg)
+			store.now = func() time.Time { return now }
+			for _, age := range []time.Duration{72 * time.Hour, 48 * time.Hour, time.Hour, 0} {
+				add(t, store, now.Add(-age), models.HistoryEntry{Operation: "export", RequestHash: "h"})
+			}
+
+			removed, err := store.Prune(context.Background())
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			entries, _, _ := store.List(context.Background(), Filter{})
+			if removed != tt.wantRemoved || len(entries) != tt.wantLeft {
+				t.Errorf("Expected %d removed and %d left, got %d and %d", tt.wantRemoved, tt.wantLeft, removed, len(entries))
+			}
+			for _, e := range entries {
+				if now.Sub(e.CreatedAt) > 24*time.Hour && tt.cfg.Retention > 0 {
+					t.Errorf("Expected entries older than the retention period to be deleted, got %s", e.CreatedAt)
+				}
+			}
+		})
+	}
+}
+
+func TestOpen_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	store, err := Open(context.Background(), Config{Path: path})
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	add(t, store, time.Now(), models.HistoryEntry{Operation: "export", RequestHash: "h"})
+	store.Close()
+
+	store, err = Open(context.Background(), Config{Path: path})
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer store.Close()
+	entries, _, err := store.List(context.Background(), Filter{})
+	if err != nil || len(entries) != 1 {
+		t.Errorf("Expected the entry to survive reopening, got %d entries, %v", len(entries), err)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	ascii := strings.Repeat("a", maxTextBytes)
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"Short", "error", "error"},
+		{"At the limit", ascii, ascii},
+		{"Over the limit", ascii + "b", ascii + "... (truncated)"},
+		// "é" is two bytes, so the limit falls inside the last one
+		{"Split rune", strings.Repeat("a", maxTextBytes-1) + "éé", strings.Repeat("a", maxTextBytes-1) + "... (truncated)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncate(tt.in)
+			if got != tt.want {
+				t.Errorf("Expected %d bytes ending %q, got %d bytes ending %q", len(tt.want), tt.want[max(len(tt.want)-20, 0):], len(got), got[max(len(got)-20, 0):])
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("Expected valid UTF-8, got %q", got[max(len(got)-20, 0):])
+			}
+		})
+	}
+}
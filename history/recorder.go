@@ -0,0 +1,150 @@
+package history
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stevexciv/scad-server/logging"
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/services"
+)
+
+// maxTextBytes bounds the error and diagnostics kept per entry
+const maxTextBytes = 16 << 10
+
+type clientKey struct{}
+
+// WithClient returns a context identifying the client a request is made for
+func WithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// Client returns the client identity stored in ctx, or ""
+func Client(ctx context.Context) string {
+	client, _ := ctx.Value(clientKey{}).(string)
+	return client
+}
+
+// Recorder is an OpenSCADExporter that records every call in a Store
+type Recorder struct {
+	next  services.OpenSCADExporter
+	store *Store
+}
+
+// NewRecorder returns an exporter that passes calls on to next and records
+// them in store
+func NewRecorder(next services.OpenSCADExporter, store *Store) *Recorder {
+	return &Recorder{next: next, store: store}
+}
+
+// Export exports through the wrapped exporter and records the call
+func (r *Recorder) Export(ctx context.Context, req *models.ExportRequest) (*services.ExportResult, error) {
+	options := req.Options
+	entry := models.HistoryEntry{
+		Operation:       "export",
+		Format:          req.Format,
+		OpenSCADVersion: req.OpenSCADVersion,
+		Options:         &options,
+		Parameters:      req.Parameters,
+	}
+
+	var result *services.ExportResult
+	err := r.record(ctx, &entry, req, func(ctx context.Context) (int, error) {
+		var err error
+		result, err = r.next.Export(ctx, req)
+		if err != nil {
+			return 0, err
+		}
+		return len(result.Data), nil
+	})
+	return result, err
+}
+
+// Draining reports whether the wrapped exporter is shutting down, so that
+// health checks still see a drain through the recorder
+func (r *Recorder) Draining() bool {
+	d, ok := r.next.(services.Drainer)
+	return ok && d.Draining()
+}
+
+// Summary generates a summary through the wrapped exporter and records the
+// call. The entry's format is the summary type.
+func (r *Recorder) Summary(ctx context.Context, req *models.SummaryRequest) (*models.SummaryResponse, error) {
+	summaryType := req.SummaryType
+	if summaryType == "" {
+		summaryType = "all"
+	}
+	entry := models.HistoryEntry{
+		Operation:       "summary",
+		Format:          summaryType,
+		OpenSCADVersion: req.OpenSCADVersion,
+		Parameters:      req.Parameters,
+	}
+
+	var response *models.SummaryResponse
+	err := r.record(ctx, &entry, req, func(ctx context.Context) (int, error) {
+		var err error
+		response, err = r.next.Summary(ctx, req)
+		if err != nil {
+			return 0, err
+		}
+		return len(response.Raw), nil
+	})
+	return response, err
+}
+
+// record runs call, which returns the output size, and adds entry describing
+// it. Failures to record are logged but don't fail the call.
+func (r *Recorder) record(ctx context.Context, entry *models.HistoryEntry, req any, call func(ctx context.Context) (int, error)) error {
+	var last *services.RenderResult
+	ctx = services.WithRenderResultRecorder(ctx, func(result services.RenderResult) {
+		last = &result
+	})
+
+	start := time.Now()
+	size, err := call(ctx)
+	entry.DurationSeconds = time.Since(start).Seconds()
+
+	entry.RequestID = logging.RequestID(ctx)
+	entry.Client = Client(ctx)
+	entry.RequestHash = requestHash(req)
+	entry.OutputBytes = int64(size)
+	entry.Status = "succeeded"
+	if err != nil {
+		entry.Status = "failed"
+		entry.Error = truncate(err.Error())
+	}
+	if last != nil {
+		entry.ExitCode = &last.ExitCode
+		entry.Diagnostics = truncate(last.Output)
+	}
+
+	// Record even when the request was cancelled
+	if addErr := r.store.Add(context.WithoutCancel(ctx), entry); addErr != nil {
+		logging.FromContext(ctx).Error("failed to record render history", "error", addErr)
+	}
+	return err
+}
+
+// requestHash identifies a request by the SHA-256 of its JSON encoding
+func requestHash(req any) string {
+	data, _ := json.Marshal(req)
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// truncate bounds s to maxTextBytes, cutting at a rune boundary
+func truncate(s string) string {
+	if len(s) <= maxTextBytes {
+		return s
+	}
+	end := maxTextBytes
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end] + "... (truncated)"
+}
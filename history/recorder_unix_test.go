@@ -0,0 +1,111 @@
+//go:build unix
+
+package history
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stevexciv/scad-server/logging"
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/services"
+)
+
+// newFakeService returns a service whose OpenSCAD binary is a shell script
+// with the given body
+func newFakeService(t *testing.T, script string) *services.OpenSCADService {
+	t.Helper()
+	binary := filepath.Join(t.TempDir(), "openscad")
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatalf("Failed to write fake openscad: %v", err)
+	}
+	cfg := services.DefaultConfig()
+	cfg.Binary = binary
+	cfg.TempDir = t.TempDir()
+	return services.NewOpenSCADServiceWithConfig(cfg)
+}
+
+func TestRecorder_Export(t *testing.T) {
+	tests := []struct {
+		name         string
+		script       string
+		wantStatus   string
+		wantExitCode int
+		wantOutput   string
+	}{
+		{
+			name:         "Succeeded",
+			script:       `echo "WARNING: Object may not be a valid 2-manifold"; printf 'solid cube' > "$2"`,
+			wantStatus:   "succeeded",
+			wantExitCode: 0,
+			wantOutput:   "WARNING: Object may not be a valid 2-manifold",
+		},
+		{
+			name:         "Failed",
+			script:       `echo "ERROR: Parser error in file"; exit 1`,
+			wantStatus:   "failed",
+			wantExitCode: 1,
+			wantOutput:   "ERROR: Parser error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := openTestStore(t, Config{})
+			recorder := NewRecorder(newFakeService(t, tt.script), store)
+
+			ctx := WithClient(logging.WithRequestID(context.Background(), "req-1"), "ip:10.0.0.1")
+			req := &models.ExportRequest{ScadContent: "cube(1);", Format: "stl_ascii"}
+			result, err := recorder.Export(ctx, req)
+			if (err != nil) != (tt.wantStatus == "failed") {
+				t.Fatalf("Expected status %s, got error %v", tt.wantStatus, err)
+			}
+
+			entries, _, err := store.List(context.Background(), Filter{})
+			if err != nil || len(entries) != 1 {
+				t.Fatalf("Expected 1 entry, got %d: %v", len(entries), err)
+			}
+			e := entries[0]
+			if e.Operation != "export" || e.Format != "stl_ascii" || e.Status != tt.wantStatus {
+				t.Errorf("Expected %s stl_ascii export, got %+v", tt.wantStatus, e)
+			}
+			if e.Client != "ip:10.0.0.1" || e.RequestID != "req-1" || e.RequestHash != requestHash(req) {
+				t.Errorf("Expected client, request ID and hash to be recorded, got %+v", e)
+			}
+			if e.ExitCode == nil || *e.ExitCode != tt.wantExitCode {
+				t.Errorf("Expected exit code %d, got %v", tt.wantExitCode, e.ExitCode)
+			}
+			if !strings.Contains(e.Diagnostics, tt.wantOutput) {
+				t.Errorf("Expected diagnostics to contain %q, got %q", tt.wantOutput, e.Diagnostics)
+			}
+			var size int64
+			if result != nil {
+				size = int64(len(result.Data))
+			}
+			if e.OutputBytes != size {
+				t.Errorf("Expected output size %d, got %d", size, e.OutputBytes)
+			}
+		})
+	}
+}
+
+func TestRecorder_NotRendered(t *testing.T) {
+	store := openTestStore(t, Config{})
+	recorder := NewRecorder(newFakeService(t, "exit 0"), store)
+
+	_, err := recorder.Summary(context.Background(), &models.SummaryRequest{ScadContent: "cube(1);", SummaryType: "all", OpenSCADVersion: "missing"})
+	if err == nil {
+		t.Fatalf("Expected an unknown version to fail")
+	}
+
+	entries, _, _ := store.List(context.Background(), Filter{})
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(entries))
+	}
+	if e := entries[0]; e.Operation != "summary" || e.Status != "failed" || e.ExitCode != nil || e.Error == "" {
+		t.Errorf("Expected a failed summary without exit code, got %+v", e)
+	}
+}
@@ -0,0 +1,18 @@
+// Package ids generates the random identifiers of artifacts, callbacks and
+// designs
+package ids
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// New returns 128 random bits as 32 lowercase hex digits
+func New() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
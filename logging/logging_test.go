@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"Empty defaults to info", "", slog.LevelInfo, false},
+		{"Debug", "debug", slog.LevelDebug, false},
+		{"Upper case", "WARN", slog.LevelWarn, false},
+		{"Error", "error", slog.LevelError, false},
+		{"Invalid", "verbose", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLevel(tt.level)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLevel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseLevel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetup_JSONWithRequestID(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	var buf bytes.Buffer
+	if err := Setup(&buf, "json", "info"); err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+
+	ctx := WithRequestID(context.Background(), "abc123")
+	FromContext(ctx).Info("render finished", "format", "png")
+	FromContext(ctx).Debug("suppressed")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 log line, got %d: %s", len(lines), buf.String())
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Failed to parse log line: %v", err)
+	}
+	if entry["request_id"] != "abc123" {
+		t.Errorf("Expected request_id 'abc123', got %v", entry["request_id"])
+	}
+	if entry["format"] != "png" {
+		t.Errorf("Expected format 'png', got %v", entry["format"])
+	}
+}
+
+func TestSetLevel_ChangesExistingLogger(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	var buf bytes.Buffer
+	if err := Setup(&buf, "text", "info"); err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+
+	slog.Debug("hidden")
+	if err := SetLevel("debug"); err != nil {
+		t.Fatalf("SetLevel() error = %v", err)
+	}
+	slog.Debug("visible")
+
+	if strings.Contains(buf.String(), "hidden") {
+		t.Errorf("Expected debug line before SetLevel to be suppressed")
+	}
+	if !strings.Contains(buf.String(), "visible") {
+		t.Errorf("Expected debug line after SetLevel to be logged")
+	}
+}
+
+func TestSetup_InvalidFormat(t *testing.T) {
+	if err := Setup(&bytes.Buffer{}, "xml", "info"); err == nil {
+		t.Errorf("Expected error for unsupported format")
+	}
+}
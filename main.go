@@ -1,18 +1,49 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
+	"net/http"
 	"os"
-	"os/exec"
+	"os/signal"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/artifacts"
+	"github.com/stevexciv/scad-server/config"
+	"github.com/stevexciv/scad-server/designs"
 	_ "github.com/stevexciv/scad-server/docs"
 	"github.com/stevexciv/scad-server/handlers"
+	"github.com/stevexciv/scad-server/health"
+	"github.com/stevexciv/scad-server/history"
+	"github.com/stevexciv/scad-server/logging"
+	"github.com/stevexciv/scad-server/metrics"
+	"github.com/stevexciv/scad-server/middleware"
+	"github.com/stevexciv/scad-server/ratelimit"
+	"github.com/stevexciv/scad-server/services"
+	"github.com/stevexciv/scad-server/templates"
+	"github.com/stevexciv/scad-server/tracing"
 	"github.com/stevexciv/scad-server/version"
+	"github.com/stevexciv/scad-server/webhooks"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+const (
+	// readHeaderTimeout bounds how long a client may take to send request headers
+	readHeaderTimeout = 10 * time.Second
+	// responseFlushTimeout is how long in-flight responses get to complete
+	// once renders have drained or been killed
+	responseFlushTimeout = 5 * time.Second
 )
 
 // @title OpenSCAD HTTP API
@@ -28,65 +59,326 @@ import (
 //
 // @BasePath /
 func main() {
+	// Resolve configuration from flags, environment and config file
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		config.Usage(os.Stderr)
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n\nFlags:\n", err)
+		config.Usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	// Configure structured logging
+	if err := logging.Setup(os.Stderr, cfg.Logging.Format, cfg.Logging.Level); err != nil {
+		fatal("Invalid logging configuration", "error", err)
+	}
+
+	// Configure tracing; spans are only exported when an OTLP endpoint is set
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.OTLPEndpoint)
+	if err != nil {
+		fatal("Invalid tracing configuration", "error", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Warn("Failed to flush traces", "error", err)
+		}
+	}()
+
 	// Log version information
 	info := version.GetInfo()
-	log.Printf("Starting scad-server (commit: %s, tag: %s)", info.Commit, info.Tag)
+	slog.Info("Starting scad-server", "commit", info.Commit, "tag", info.Tag)
 
-	// Check if OpenSCAD is available
-	if err := checkOpenSCAD(); err != nil {
-		log.Fatalf("OpenSCAD not available: %v", err)
-	}
+	gin.SetMode(cfg.Server.GinMode)
 
-	// Set Gin mode from environment variable
-	mode := os.Getenv("SCADSRV_GIN_MODE")
-	if mode == "" {
-		mode = gin.ReleaseMode
+	router := gin.New()
+	// Client IPs come from X-Forwarded-For only when a trusted proxy sent it
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		fatal("Invalid trusted proxies", "error", err)
 	}
-	gin.SetMode(mode)
+	router.Use(
+		otelgin.Middleware(tracing.ServiceName),
+		middleware.RequestID(),
+		middleware.Logger(),
+		gin.Recovery(),
+		middleware.Metrics(),
+	)
 
-	router := gin.Default()
+	// Background cleanup of expired artifacts and history entries
+	janitorCtx, stopJanitor := context.WithCancel(context.Background())
+	defer stopJanitor()
 
 	// Create handler
-	h := handlers.NewHandler()
+	service := services.NewOpenSCADServiceWithConfig(cfg.Service())
+
+	// Render history; every export and summary goes through the recorder
+	var exporter services.OpenSCADExporter = service
+	var renders *history.Store
+	if historyCfg, ok := cfg.HistoryStore(); ok {
+		renders, err = history.Open(context.Background(), historyCfg)
+		if err != nil {
+			fatal("History database not usable", "path", historyCfg.Path, "error", err)
+		}
+		defer renders.Close()
+		go renders.RunRetention(janitorCtx, cfg.History.PruneInterval)
+		exporter = history.NewRecorder(service, renders)
+	}
+	renderHistory := handlers.NewHistoryHandler(renders)
+
+	// Render requests are refused before they are read when they upload more
+	// than the source and asset limits allow
+	limits := handlers.RequestLimits{
+		MaxSourceBytes: int64(cfg.Assets.MaxSourceMB) << 20,
+		MaxAssetBytes:  int64(cfg.Assets.MaxSizeMB) << 20,
+		MaxAssets:      cfg.Assets.MaxCount,
+	}
+	h := handlers.NewHandlerWithService(exporter).WithPrintOptions(cfg.PrintOptions()).WithRequestLimits(limits)
+	renderURLs := handlers.NewRenderHandler(exporter, cfg.RenderURL.Secret, cfg.RenderURL.MaxAge).WithRenderIdentity(service)
+
+	// Stored renders with signed download links
+	var store *artifacts.Store
+	if storeCfg, ok := cfg.ArtifactStore(); ok {
+		if len(storeCfg.SigningKey) == 0 {
+			storeCfg.SigningKey = make([]byte, 32)
+			if _, err := rand.Read(storeCfg.SigningKey); err != nil {
+				fatal("Generating artifact signing key failed", "error", err)
+			}
+			slog.Warn("No artifact signing key configured; download links stop working on restart")
+		}
+		store, err = artifacts.New(context.Background(), storeCfg)
+		if err != nil {
+			fatal("Artifact storage not usable", "backend", storeCfg.Backend, "error", err)
+		}
+		go store.RunJanitor(janitorCtx, cfg.Artifacts.JanitorInterval)
+	}
+	stored := handlers.NewArtifactHandler(exporter, store, cfg.Artifacts.BaseURL).WithRequestLimits(limits)
+	h.WithArtifacts(stored)
+
+	// Render completion callbacks
+	var callbacks *webhooks.Dispatcher
+	if callbackCfg, ok := cfg.Callbacks(); ok {
+		callbacks, err = webhooks.New(callbackCfg)
+		if err != nil {
+			fatal("Callbacks not usable", "error", err)
+		}
+		h.WithCallbacks(callbacks)
+	}
+
+	// Saved designs with immutable versions
+	var designStore *designs.Store
+	if designCfg, ok := cfg.DesignStore(); ok {
+		designStore, err = designs.Open(context.Background(), designCfg)
+		if err != nil {
+			fatal("Design database not usable", "path", designCfg.Path, "error", err)
+		}
+		defer designStore.Close()
+	}
+	savedDesigns := handlers.NewDesignHandler(designStore, h)
+
+	// Parametric templates rendered from parameter values alone
+	var catalog *templates.Catalog
+	if cfg.Templates.Dir != "" {
+		catalog, err = templates.Load(cfg.Templates.Dir)
+		if err != nil {
+			fatal("Template directory not usable", "dir", cfg.Templates.Dir, "error", err)
+		}
+		slog.Info("Templates loaded", "dir", cfg.Templates.Dir, "count", len(catalog.List()))
+	}
+	catalogHandler := handlers.NewTemplateHandler(catalog, h)
+
+	// Check that every OpenSCAD installation is available
+	versions, err := service.CheckBinaries(context.Background())
+	if err != nil {
+		fatal("OpenSCAD not available", "error", err)
+	}
+	for _, name := range service.VersionNames() {
+		slog.Info("OpenSCAD installation", "name", name, "version", versions[name], "default", name == service.DefaultVersion())
+		if err := service.CheckRenderDefaults(context.Background(), name); err != nil {
+			slog.Warn("Default backend or features unavailable; requests using them will be rejected", "name", name, "error", err)
+		}
+	}
+
+	// Make uploaded fonts visible to OpenSCAD
+	if err := service.PrepareFontDir(); err != nil {
+		fatal("Font directory not usable", "dir", cfg.Fonts.Dir, "error", err)
+	}
+	fonts := handlers.NewFontHandler(service, int64(cfg.Fonts.MaxUploadMB)<<20)
+
+	// Per-client rate limiting and render-time quotas
+	limiter := ratelimit.New(cfg.Limits())
+	usage := handlers.NewUsageHandler(limiter)
+	capabilities := handlers.NewInfoHandler(service, limiter)
+
+	// Parameter sweeps render their variants on as many workers as there are
+	// render slots, each counted against the caller's quota
+	sweeps := handlers.NewSweepHandler(exporter, cfg.Sweep.MaxVariants, cfg.OpenSCAD.MaxConcurrentRenders).WithRateLimit(limiter).WithRequestLimits(limits)
+
+	// Readiness checks
+	checker := health.NewChecker(cfg.Health.CheckTimeout)
+	checker.Add("shutdown", health.Shutdown(service))
+	checker.Add("openscad", health.Binary(service))
+	checker.Add("temp_dir", health.TempDir(service, uint64(cfg.Health.MinFreeDiskMB)<<20))
+	checker.Add("queue", health.Queue(service, cfg.MaxQueuedRenders()))
+	if cfg.Health.SelfTest {
+		checker.Add("self_test", health.Cached(cfg.Health.SelfTestInterval, health.SelfTest(service)))
+	}
+	probes := handlers.NewProbeHandler(checker)
+
+	// Re-read the configuration on SIGHUP and apply the settings that are
+	// safe to change at runtime
+	go reloadOnSignal(cfg, service, limiter)
 
 	// Health check endpoint
 	router.GET("/health", h.HealthCheck)
+	router.GET("/livez", probes.Livez)
+	router.GET("/readyz", probes.Readyz)
 
 	// API v1 routes
-	v1 := router.Group("/openscad/v1")
+	v1 := router.Group("/openscad/v1",
+		middleware.Identify(append(slices.Clone(cfg.Auth.APIKeys), cfg.Auth.AdminKeys...)),
+		middleware.Admin(cfg.Auth.AdminKeys),
+	)
 	{
-		v1.POST("/export", h.Export)
-		v1.POST("/summary", h.Summary)
+		v1.GET("/info", capabilities.Info)
+		v1.GET("/versions", capabilities.Versions)
+		v1.GET("/usage", usage.Usage)
+		v1.GET("/fonts", fonts.List)
+		v1.GET("/fonts/families", fonts.Families)
+		v1.GET("/artifacts/:id", stored.Download)
+		v1.GET("/artifacts/:id/metadata", stored.Metadata)
+		v1.GET("/designs", savedDesigns.List)
+		v1.GET("/designs/:id", savedDesigns.Get)
+		v1.GET("/designs/:id/versions", savedDesigns.Versions)
+		v1.GET("/designs/:id/versions/:version", savedDesigns.Version)
+		v1.GET("/templates", catalogHandler.List)
+		v1.GET("/templates/:name", catalogHandler.Get)
+
+		render := v1.Group("", middleware.RateLimit(limiter))
+		render.POST("/export", h.Export)
+		render.POST("/summary", h.Summary)
+		render.POST("/analyze", h.Analyze)
+		render.POST("/printability", h.Printability)
+		render.POST("/sweep", sweeps.Sweep)
+		render.GET("/render/:format", renderURLs.Render)
+		render.POST("/artifacts", stored.Create)
+		render.POST("/designs/:id/versions/:version/export", savedDesigns.Export)
+		render.POST("/templates/:name/export", catalogHandler.Export)
+		render.GET("/history", renderHistory.List)
+
+		// Changes to state every client depends on
+		admin := v1.Group("", middleware.RequireAdmin(), middleware.RateLimit(limiter))
+		admin.POST("/fonts", fonts.Upload)
+		admin.DELETE("/fonts/:name", fonts.Delete)
+		admin.POST("/designs", savedDesigns.Create)
+		admin.PUT("/designs/:id", savedDesigns.Update)
+		admin.DELETE("/designs/:id", savedDesigns.Delete)
 	}
 
+	// Prometheus metrics
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
 	// Swagger documentation
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	// Get port from environment variable
-	port := os.Getenv("SCADSRV_PORT")
-	if port == "" {
-		port = "8000"
-	}
-
 	// Check if port is available
-	addr := ":" + port
+	addr := ":" + strconv.Itoa(cfg.Server.Port)
 	if err := checkPortAvailable(addr); err != nil {
-		log.Fatalf("Port %s is not available: %v", port, err)
+		fatal("Port is not available", "port", cfg.Server.Port, "error", err)
+	}
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           router,
+		ReadHeaderTimeout: readHeaderTimeout,
 	}
 
-	log.Printf("Starting server on port %s", port)
-	if err := router.Run(addr); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("Starting server", "port", cfg.Server.Port)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+
+	select {
+	case err := <-serveErr:
+		fatal("Failed to start server", "error", err)
+	case sig := <-stop:
+		// A second signal falls back to the default behaviour and exits at once
+		signal.Stop(stop)
+		slog.Info("Shutting down", "signal", sig.String(), "grace_period", cfg.Server.ShutdownGracePeriod)
+	}
+
+	shutdown(srv, service, callbacks, cfg.Server.ShutdownGracePeriod)
+}
+
+// shutdown waits up to grace for in-flight renders, killing any that remain,
+// and for pending callbacks, dead-lettering any that remain, then stops the
+// HTTP server. The listener stays open while renders drain so health checks
+// report the server as draining and new renders get a 503.
+func shutdown(srv *http.Server, service *services.OpenSCADService, callbacks *webhooks.Dispatcher, grace time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	if err := service.Shutdown(ctx); err != nil {
+		slog.Warn("Grace period expired, killed remaining renders", "error", err)
+	}
+	if callbacks != nil {
+		if err := callbacks.Close(ctx); err != nil {
+			slog.Warn("Grace period expired, dead-lettered pending callbacks", "error", err)
+		}
 	}
+
+	// Give handlers of aborted renders a moment to write their responses
+	ctx, cancel = context.WithTimeout(context.Background(), responseFlushTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Warn("Closing remaining connections", "error", err)
+		srv.Close()
+	}
+	slog.Info("Server stopped")
 }
 
-// checkOpenSCAD verifies that the openscad binary is available
-func checkOpenSCAD() error {
-	cmd := exec.Command("openscad", "--version")
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("openscad binary not found or not executable: %w", err)
+// reloadOnSignal reloads the configuration whenever the process receives
+// SIGHUP. Invalid configurations are rejected as a whole; settings that need a
+// restart are reported by the reload that changes them and otherwise ignored.
+func reloadOnSignal(current *config.Config, service *services.OpenSCADService, limiter *ratelimit.Limiter) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	reloader := config.NewReloader(current)
+
+	for range hup {
+		next, err := config.Load(os.Args[1:], os.Getenv)
+		if err != nil {
+			slog.Error("Config reload failed, keeping current configuration", "error", err)
+			continue
+		}
+
+		if err := logging.SetLevel(next.Logging.Level); err != nil {
+			slog.Error("Config reload failed, keeping current configuration", "error", err)
+			continue
+		}
+		service.UpdateConfig(next.Service())
+		limiter.SetConfig(next.Limits())
+
+		if changed := reloader.Reload(next); len(changed) > 0 {
+			slog.Warn("Some settings changed but require a restart", "settings", changed)
+		}
+		slog.Info("Configuration reloaded",
+			"render_timeout", next.OpenSCAD.Timeout,
+			"log_level", next.Logging.Level,
+			"rate_limit_rpm", next.RateLimit.RequestsPerMinute,
+			"render_budget_seconds", next.RateLimit.RenderBudgetSeconds,
+		)
 	}
-	return nil
+}
+
+// fatal logs msg at error level and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
 
 // checkPortAvailable checks if the specified port is available for binding
@@ -0,0 +1,230 @@
+package mesh
+
+import (
+	"math"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+// degenerateRatio is the ratio of twice a triangle's area to the square of
+// its longest edge below which it counts as degenerate: a sliver whose
+// corners are collinear up to rounding
+const degenerateRatio = 1e-9
+
+// edge is an undirected edge between two vertex indices, lower index first
+type edge [2]int
+
+func newEdge(a, b int) edge {
+	if a > b {
+		a, b = b, a
+	}
+	return edge{a, b}
+}
+
+// Analyze measures m. Volume, centroid and inertia treat the mesh as a solid
+// of unit density bounded by its triangles, which is only meaningful for
+// closed meshes; open meshes fall back to the area centroid.
+func Analyze(m *Mesh) models.MeshAnalysis {
+	analysis := models.MeshAnalysis{
+		Triangles: len(m.Triangles),
+		Vertices:  len(m.Vertices),
+	}
+
+	// Only vertices used by triangles count toward the bounds
+	lo := Vec{math.Inf(1), math.Inf(1), math.Inf(1)}
+	hi := Vec{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for _, t := range m.Triangles {
+		for _, i := range t {
+			for axis, x := range m.Vertices[i] {
+				lo[axis], hi[axis] = min(lo[axis], x), max(hi[axis], x)
+			}
+		}
+	}
+	if len(m.Triangles) == 0 {
+		lo, hi = Vec{}, Vec{}
+	}
+	analysis.BoundingBox = models.SummaryBoundingBox{
+		Min:  lo[:],
+		Max:  hi[:],
+		Size: []float64{hi[0] - lo[0], hi[1] - lo[1], hi[2] - lo[2]},
+	}
+
+	var (
+		volume, area       float64
+		moment, areaMoment Vec
+		covariance         [3][3]float64
+	)
+	edges := make(map[edge]int)
+	for _, t := range m.Triangles {
+		a, b, c := m.Vertices[t[0]], m.Vertices[t[1]], m.Vertices[t[2]]
+		normal := cross(sub(b, a), sub(c, a))
+		doubleArea := length(normal)
+		area += doubleArea / 2
+		for axis := range 3 {
+			areaMoment[axis] += doubleArea / 2 * (a[axis] + b[axis] + c[axis]) / 3
+		}
+		longest := max(length(sub(b, a)), length(sub(c, b)), length(sub(a, c)))
+		if t[0] == t[1] || t[1] == t[2] || t[2] == t[0] || doubleArea <= degenerateRatio*longest*longest {
+			analysis.DegenerateTriangles++
+		}
+
+		// Signed tetrahedron from the origin to the triangle
+		det := dot(a, cross(b, c))
+		volume += det / 6
+		for axis := range 3 {
+			moment[axis] += det / 24 * (a[axis] + b[axis] + c[axis])
+		}
+		for i := range 3 {
+			for j := range 3 {
+				covariance[i][j] += det / 120 * (2*(a[i]*a[j]+b[i]*b[j]+c[i]*c[j]) +
+					a[i]*b[j] + a[j]*b[i] + a[i]*c[j] + a[j]*c[i] + b[i]*c[j] + b[j]*c[i])
+			}
+		}
+
+		for k := range 3 {
+			if t[k] == t[(k+1)%3] {
+				continue
+			}
+			edges[newEdge(t[k], t[(k+1)%3])]++
+		}
+	}
+
+	for _, count := range edges {
+		switch {
+		case count == 1:
+			analysis.OpenEdges++
+		case count > 2:
+			analysis.NonManifoldEdges++
+		}
+	}
+	analysis.Shells = len(groups(m, nil))
+	analysis.Manifold = len(m.Triangles) > 0 && analysis.OpenEdges == 0 && analysis.NonManifoldEdges == 0
+	analysis.SurfaceArea = area
+
+	// Inward facing meshes have negative volume; flip them so the solid is
+	// measured either way
+	if volume < 0 {
+		volume = -volume
+		for axis := range 3 {
+			moment[axis] = -moment[axis]
+		}
+		for i := range 3 {
+			for j := range 3 {
+				covariance[i][j] = -covariance[i][j]
+			}
+		}
+	}
+	analysis.Volume = volume
+
+	var centroid Vec
+	switch {
+	case volume > 0:
+		for axis := range 3 {
+			centroid[axis] = moment[axis] / volume
+		}
+		// Move the covariance to the centroid, then I = trace(C)·E - C
+		for i := range 3 {
+			for j := range 3 {
+				covariance[i][j] -= volume * centroid[i] * centroid[j]
+			}
+		}
+		trace := covariance[0][0] + covariance[1][1] + covariance[2][2]
+		analysis.Inertia = make([][]float64, 3)
+		for i := range 3 {
+			analysis.Inertia[i] = make([]float64, 3)
+			for j := range 3 {
+				analysis.Inertia[i][j] = -covariance[i][j]
+				if i == j {
+					analysis.Inertia[i][j] += trace
+				}
+			}
+		}
+	case area > 0:
+		for axis := range 3 {
+			centroid[axis] = areaMoment[axis] / area
+		}
+	}
+	if len(m.Triangles) > 0 {
+		analysis.Centroid = centroid[:]
+	}
+	return analysis
+}
+
+// groups returns the triangles of m for which member is true (all of them
+// for a nil member), grouped into sets connected through shared edges
+func groups(m *Mesh, member []bool) [][]int {
+	sets := newUnionFind(len(m.Triangles))
+	owner := make(map[edge]int)
+	for n, t := range m.Triangles {
+		if member != nil && !member[n] {
+			continue
+		}
+		for k := range 3 {
+			if t[k] == t[(k+1)%3] {
+				continue
+			}
+			e := newEdge(t[k], t[(k+1)%3])
+			if first, ok := owner[e]; ok {
+				sets.union(first, n)
+			} else {
+				owner[e] = n
+			}
+		}
+	}
+
+	index := make(map[int]int)
+	var result [][]int
+	for n := range m.Triangles {
+		if member != nil && !member[n] {
+			continue
+		}
+		root := sets.find(n)
+		i, ok := index[root]
+		if !ok {
+			i = len(result)
+			index[root] = i
+			result = append(result, nil)
+		}
+		result[i] = append(result[i], n)
+	}
+	return result
+}
+
+// unionFind groups connected triangles
+type unionFind []int
+
+func newUnionFind(n int) unionFind {
+	parent := make(unionFind, n)
+	for i := range parent {
+		parent[i] = i
+	}
+	return parent
+}
+
+func (u unionFind) find(i int) int {
+	for u[i] != i {
+		u[i] = u[u[i]]
+		i = u[i]
+	}
+	return i
+}
+
+func (u unionFind) union(a, b int) {
+	u[u.find(a)] = u.find(b)
+}
+
+func sub(a, b Vec) Vec {
+	return Vec{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+func dot(a, b Vec) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+func cross(a, b Vec) Vec {
+	return Vec{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
+
+func length(a Vec) float64 {
+	return math.Sqrt(dot(a, a))
+}
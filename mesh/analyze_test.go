@@ -0,0 +1,132 @@
+package mesh
+
+import (
+	"math"
+	"testing"
+)
+
+// build merges triangles into a mesh like the STL parsers do
+func build(triangles [][3]Vec) *Mesh {
+	b := newBuilder()
+	for _, t := range triangles {
+		b.triangle(t[0], t[1], t[2])
+	}
+	return &b.mesh
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-6*max(1, math.Abs(b))
+}
+
+func TestAnalyze_Cube(t *testing.T) {
+	a := Analyze(build(cube(Vec{}, 10)))
+
+	if a.Triangles != 12 || a.Vertices != 8 || a.Shells != 1 {
+		t.Errorf("Expected 12 triangles, 8 vertices and 1 shell, got %+v", a)
+	}
+	if !near(a.Volume, 1000) || !near(a.SurfaceArea, 600) {
+		t.Errorf("Expected volume 1000 and area 600, got %g and %g", a.Volume, a.SurfaceArea)
+	}
+	if !a.Manifold || a.OpenEdges != 0 || a.NonManifoldEdges != 0 || a.DegenerateTriangles != 0 {
+		t.Errorf("Expected a clean manifold mesh, got %+v", a)
+	}
+	for axis := range 3 {
+		if !near(a.Centroid[axis], 5) || !near(a.BoundingBox.Size[axis], 10) {
+			t.Errorf("Expected centroid 5 and size 10 on axis %d, got %v and %v", axis, a.Centroid, a.BoundingBox.Size)
+		}
+	}
+
+	// A solid cube of mass m = 1000 has I = m·s²/6 about its centroid
+	for i := range 3 {
+		for j := range 3 {
+			want := 0.0
+			if i == j {
+				want = 1000 * 100 / 6.0
+			}
+			if math.Abs(a.Inertia[i][j]-want) > 1e-6 {
+				t.Errorf("Expected inertia[%d][%d] = %g, got %g", i, j, want, a.Inertia[i][j])
+			}
+		}
+	}
+}
+
+func TestAnalyze_Defects(t *testing.T) {
+	inverted := cube(Vec{}, 2)
+	for i := range inverted {
+		inverted[i][1], inverted[i][2] = inverted[i][2], inverted[i][1]
+	}
+
+	tests := []struct {
+		name           string
+		triangles      [][3]Vec
+		wantShells     int
+		wantOpen       int
+		wantNonMan     int
+		wantDegenerate int
+		wantManifold   bool
+		wantVolume     float64
+	}{
+		{
+			name:         "Two cubes",
+			triangles:    append(cube(Vec{}, 1), cube(Vec{5, 0, 0}, 1)...),
+			wantShells:   2,
+			wantManifold: true,
+			wantVolume:   2,
+		},
+		{
+			// The missing triangle lies in a plane through the origin, so it
+			// adds no volume
+			name:       "Missing triangle",
+			triangles:  cube(Vec{}, 1)[1:],
+			wantShells: 1,
+			wantOpen:   3,
+			wantVolume: 1,
+		},
+		{
+			name:       "Cubes sharing an edge",
+			triangles:  append(cube(Vec{}, 1), cube(Vec{1, 1, 0}, 1)...),
+			wantShells: 1,
+			wantNonMan: 1,
+			wantVolume: 2,
+		},
+		{
+			name:           "Degenerate triangle",
+			triangles:      append(cube(Vec{}, 1), [3]Vec{{0, 0, 0}, {0.5, 0, 0}, {1, 0, 0}}),
+			wantShells:     1,
+			wantOpen:       2,
+			wantNonMan:     1,
+			wantDegenerate: 1,
+			wantVolume:     1,
+		},
+		{
+			name:         "Inside out",
+			triangles:    inverted,
+			wantShells:   1,
+			wantManifold: true,
+			wantVolume:   8,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Analyze(build(tt.triangles))
+			if a.Shells != tt.wantShells || a.OpenEdges != tt.wantOpen || a.NonManifoldEdges != tt.wantNonMan || a.DegenerateTriangles != tt.wantDegenerate {
+				t.Errorf("Expected %d shells, %d open, %d non-manifold edges and %d degenerate triangles, got %d, %d, %d and %d",
+					tt.wantShells, tt.wantOpen, tt.wantNonMan, tt.wantDegenerate, a.Shells, a.OpenEdges, a.NonManifoldEdges, a.DegenerateTriangles)
+			}
+			if a.Manifold != tt.wantManifold {
+				t.Errorf("Expected manifold %v, got %v", tt.wantManifold, a.Manifold)
+			}
+			if !near(a.Volume, tt.wantVolume) {
+				t.Errorf("Expected volume %g, got %g", tt.wantVolume, a.Volume)
+			}
+		})
+	}
+}
+
+func TestAnalyze_Empty(t *testing.T) {
+	a := Analyze(&Mesh{})
+	if a.Triangles != 0 || a.Volume != 0 || a.Manifold || a.Centroid != nil || a.Inertia != nil {
+		t.Errorf("Expected an empty analysis, got %+v", a)
+	}
+}
@@ -0,0 +1,272 @@
+// Package mesh reads the triangle meshes OpenSCAD exports as STL and 3MF and
+// measures them
+package mesh
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Formats lists the export formats that produce meshes
+var Formats = []string{"stl_binary", "stl_ascii", "3mf"}
+
+var (
+	// ErrUnsupportedFormat is returned for export formats without a mesh
+	ErrUnsupportedFormat = errors.New("format has no mesh")
+	// ErrInvalid is returned for files that don't parse as their format
+	ErrInvalid = errors.New("invalid mesh file")
+)
+
+// Vec is a point or direction in millimeters
+type Vec [3]float64
+
+// Mesh is an indexed triangle mesh
+type Mesh struct {
+	Vertices  []Vec
+	Triangles [][3]int
+}
+
+// ValidateFormat checks that format is one of Formats
+func ValidateFormat(format string) error {
+	if !slices.Contains(Formats, format) {
+		return fmt.Errorf("%w: %s, expected one of %s", ErrUnsupportedFormat, format, strings.Join(Formats, ", "))
+	}
+	return nil
+}
+
+// Parse reads a file exported as format
+func Parse(format string, data []byte) (*Mesh, error) {
+	if err := ValidateFormat(format); err != nil {
+		return nil, err
+	}
+	switch format {
+	case "stl_binary":
+		return parseBinarySTL(data)
+	case "stl_ascii":
+		return parseASCIISTL(data)
+	default:
+		return parse3MF(data)
+	}
+}
+
+// builder assembles a mesh from triangle corners, merging corners at the same
+// position into one vertex as STL files repeat them for every triangle
+type builder struct {
+	mesh  Mesh
+	index map[Vec]int
+}
+
+func newBuilder() *builder {
+	return &builder{index: make(map[Vec]int)}
+}
+
+// vertex returns the index of the vertex at v, adding it if it's new
+func (b *builder) vertex(v Vec) int {
+	if i, ok := b.index[v]; ok {
+		return i
+	}
+	i := len(b.mesh.Vertices)
+	b.mesh.Vertices = append(b.mesh.Vertices, v)
+	b.index[v] = i
+	return i
+}
+
+func (b *builder) triangle(a, c, d Vec) {
+	b.mesh.Triangles = append(b.mesh.Triangles, [3]int{b.vertex(a), b.vertex(c), b.vertex(d)})
+}
+
+// parseBinarySTL reads an 80 byte header, a triangle count and 50 bytes per
+// triangle: the normal, the three corners and an attribute word
+func parseBinarySTL(data []byte) (*Mesh, error) {
+	const header, record = 84, 50
+	if len(data) < header {
+		return nil, fmt.Errorf("%w: binary STL shorter than its header", ErrInvalid)
+	}
+	count := int(binary.LittleEndian.Uint32(data[80:header]))
+	if len(data) != header+count*record {
+		return nil, fmt.Errorf("%w: binary STL of %d bytes doesn't hold %d triangles", ErrInvalid, len(data), count)
+	}
+
+	b := newBuilder()
+	for i := range count {
+		r := data[header+i*record:]
+		var corners [3]Vec
+		for c := range corners {
+			for axis := range 3 {
+				offset := 12 + c*12 + axis*4
+				corners[c][axis] = float64(math.Float32frombits(binary.LittleEndian.Uint32(r[offset:])))
+			}
+		}
+		b.triangle(corners[0], corners[1], corners[2])
+	}
+	return &b.mesh, nil
+}
+
+// parseASCIISTL reads the "vertex x y z" lines of an ASCII STL, three per
+// facet
+func parseASCIISTL(data []byte) (*Mesh, error) {
+	b := newBuilder()
+	var corners []Vec
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "vertex" {
+			continue
+		}
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("%w: line %d: vertex needs 3 coordinates", ErrInvalid, line)
+		}
+		var v Vec
+		for axis := range 3 {
+			f, err := strconv.ParseFloat(fields[axis+1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %v", ErrInvalid, line, err)
+			}
+			v[axis] = f
+		}
+		if corners = append(corners, v); len(corners) == 3 {
+			b.triangle(corners[0], corners[1], corners[2])
+			corners = corners[:0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if len(corners) != 0 {
+		return nil, fmt.Errorf("%w: ASCII STL ends inside a facet", ErrInvalid)
+	}
+	return &b.mesh, nil
+}
+
+// unitScale converts the 3MF model units to millimeters
+var unitScale = map[string]float64{
+	"":           1,
+	"micron":     0.001,
+	"millimeter": 1,
+	"centimeter": 10,
+	"inch":       25.4,
+	"foot":       304.8,
+	"meter":      1000,
+}
+
+// model3MF is the part of a 3MF model file that holds meshes
+type model3MF struct {
+	Unit    string      `xml:"unit,attr"`
+	Objects []object3MF `xml:"resources>object"`
+	Items   []struct {
+		ObjectID  string `xml:"objectid,attr"`
+		Transform string `xml:"transform,attr"`
+	} `xml:"build>item"`
+}
+
+// object3MF is a mesh object of a 3MF model
+type object3MF struct {
+	ID       string `xml:"id,attr"`
+	Vertices []struct {
+		X float64 `xml:"x,attr"`
+		Y float64 `xml:"y,attr"`
+		Z float64 `xml:"z,attr"`
+	} `xml:"mesh>vertices>vertex"`
+	Triangles []struct {
+		V1 int `xml:"v1,attr"`
+		V2 int `xml:"v2,attr"`
+		V3 int `xml:"v3,attr"`
+	} `xml:"mesh>triangles>triangle"`
+}
+
+// parse3MF reads the mesh objects of the model part of a 3MF package, placed
+// by the build items' transforms, or all of them when the build lists none
+func parse3MF(data []byte) (*Mesh, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: 3MF isn't a zip package: %v", ErrInvalid, err)
+	}
+	var part io.ReadCloser
+	for _, f := range zr.File {
+		if strings.HasPrefix(f.Name, "3D/") && strings.HasSuffix(f.Name, ".model") {
+			if part, err = f.Open(); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+			}
+			break
+		}
+	}
+	if part == nil {
+		return nil, fmt.Errorf("%w: 3MF package has no 3D model", ErrInvalid)
+	}
+	defer part.Close()
+
+	var model model3MF
+	if err := xml.NewDecoder(part).Decode(&model); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	scale, ok := unitScale[model.Unit]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown 3MF unit %q", ErrInvalid, model.Unit)
+	}
+
+	type placement struct {
+		object    string
+		transform [12]float64
+	}
+	identity := [12]float64{1, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0}
+	var placements []placement
+	for _, item := range model.Items {
+		transform := identity
+		if item.Transform != "" {
+			fields := strings.Fields(item.Transform)
+			if len(fields) != 12 {
+				return nil, fmt.Errorf("%w: transform %q needs 12 values", ErrInvalid, item.Transform)
+			}
+			for i, field := range fields {
+				if transform[i], err = strconv.ParseFloat(field, 64); err != nil {
+					return nil, fmt.Errorf("%w: transform %q: %v", ErrInvalid, item.Transform, err)
+				}
+			}
+		}
+		placements = append(placements, placement{item.ObjectID, transform})
+	}
+	if len(placements) == 0 {
+		for _, object := range model.Objects {
+			placements = append(placements, placement{object.ID, identity})
+		}
+	}
+
+	var mesh Mesh
+	for _, p := range placements {
+		i := slices.IndexFunc(model.Objects, func(o object3MF) bool { return o.ID == p.object })
+		if i < 0 {
+			return nil, fmt.Errorf("%w: build item refers to unknown object %q", ErrInvalid, p.object)
+		}
+		object := model.Objects[i]
+
+		// 3MF transforms are row vectors times a 4x3 matrix
+		base := len(mesh.Vertices)
+		m := p.transform
+		for _, v := range object.Vertices {
+			mesh.Vertices = append(mesh.Vertices, Vec{
+				scale * (v.X*m[0] + v.Y*m[3] + v.Z*m[6] + m[9]),
+				scale * (v.X*m[1] + v.Y*m[4] + v.Z*m[7] + m[10]),
+				scale * (v.X*m[2] + v.Y*m[5] + v.Z*m[8] + m[11]),
+			})
+		}
+		for _, t := range object.Triangles {
+			for _, v := range []int{t.V1, t.V2, t.V3} {
+				if v < 0 || v >= len(object.Vertices) {
+					return nil, fmt.Errorf("%w: object %q has a triangle with unknown vertex %d", ErrInvalid, object.ID, v)
+				}
+			}
+			mesh.Triangles = append(mesh.Triangles, [3]int{base + t.V1, base + t.V2, base + t.V3})
+		}
+	}
+	return &mesh, nil
+}
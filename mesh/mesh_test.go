@@ -0,0 +1,172 @@
+package mesh
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// cube returns the outward facing triangles of a cube of side s with its
+// lowest corner at origin
+func cube(origin Vec, s float64) [][3]Vec {
+	return box(origin, Vec{s, s, s})
+}
+
+// box returns the outward facing triangles of a box of the given size with
+// its lowest corner at origin
+func box(origin, size Vec) [][3]Vec {
+	p := func(x, y, z float64) Vec {
+		return Vec{origin[0] + x*size[0], origin[1] + y*size[1], origin[2] + z*size[2]}
+	}
+	return [][3]Vec{
+		{p(0, 0, 0), p(0, 1, 0), p(1, 1, 0)}, {p(0, 0, 0), p(1, 1, 0), p(1, 0, 0)},
+		{p(0, 0, 1), p(1, 0, 1), p(1, 1, 1)}, {p(0, 0, 1), p(1, 1, 1), p(0, 1, 1)},
+		{p(0, 0, 0), p(1, 0, 0), p(1, 0, 1)}, {p(0, 0, 0), p(1, 0, 1), p(0, 0, 1)},
+		{p(0, 1, 0), p(0, 1, 1), p(1, 1, 1)}, {p(0, 1, 0), p(1, 1, 1), p(1, 1, 0)},
+		{p(0, 0, 0), p(0, 0, 1), p(0, 1, 1)}, {p(0, 0, 0), p(0, 1, 1), p(0, 1, 0)},
+		{p(1, 0, 0), p(1, 1, 0), p(1, 1, 1)}, {p(1, 0, 0), p(1, 1, 1), p(1, 0, 1)},
+	}
+}
+
+func binarySTL(triangles [][3]Vec) []byte {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, 80))
+	binary.Write(&buf, binary.LittleEndian, uint32(len(triangles)))
+	for _, t := range triangles {
+		binary.Write(&buf, binary.LittleEndian, [3]float32{})
+		for _, v := range t {
+			binary.Write(&buf, binary.LittleEndian, [3]float32{float32(v[0]), float32(v[1]), float32(v[2])})
+		}
+		binary.Write(&buf, binary.LittleEndian, uint16(0))
+	}
+	return buf.Bytes()
+}
+
+func asciiSTL(triangles [][3]Vec) []byte {
+	var b strings.Builder
+	b.WriteString("solid OpenSCAD_Model\n")
+	for _, t := range triangles {
+		b.WriteString("  facet normal 0 0 0\n    outer loop\n")
+		for _, v := range t {
+			fmt.Fprintf(&b, "      vertex %g %g %g\n", v[0], v[1], v[2])
+		}
+		b.WriteString("    endloop\n  endfacet\n")
+	}
+	b.WriteString("endsolid OpenSCAD_Model\n")
+	return []byte(b.String())
+}
+
+// threeMF packages model as a 3MF file
+func threeMF(t *testing.T, model string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, err := zw.Create("3D/3dmodel.model")
+	if err != nil {
+		t.Fatalf("Failed to create 3MF: %v", err)
+	}
+	f.Write([]byte(model))
+	zw.Close()
+	return buf.Bytes()
+}
+
+// unitCube3MF is a 3MF model of a unit cube with unit and transform filled
+// in
+const unitCube3MF = `<?xml version="1.0" encoding="UTF-8"?>
+<model unit="%s" xmlns="http://schemas.microsoft.com/3dmanufacturing/core/2015/02">
+ <resources>
+  <object id="1" type="model">
+   <mesh>
+    <vertices>
+     <vertex x="0" y="0" z="0"/><vertex x="1" y="0" z="0"/><vertex x="1" y="1" z="0"/><vertex x="0" y="1" z="0"/>
+     <vertex x="0" y="0" z="1"/><vertex x="1" y="0" z="1"/><vertex x="1" y="1" z="1"/><vertex x="0" y="1" z="1"/>
+    </vertices>
+    <triangles>
+     <triangle v1="0" v2="3" v3="2"/><triangle v1="0" v2="2" v3="1"/>
+     <triangle v1="4" v2="5" v3="6"/><triangle v1="4" v2="6" v3="7"/>
+     <triangle v1="0" v2="1" v3="5"/><triangle v1="0" v2="5" v3="4"/>
+     <triangle v1="3" v2="7" v3="6"/><triangle v1="3" v2="6" v3="2"/>
+     <triangle v1="0" v2="4" v3="7"/><triangle v1="0" v2="7" v3="3"/>
+     <triangle v1="1" v2="2" v3="6"/><triangle v1="1" v2="6" v3="5"/>
+    </triangles>
+   </mesh>
+  </object>
+ </resources>
+ <build>%s</build>
+</model>`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		data    []byte
+		wantMin Vec
+		wantMax Vec
+	}{
+		{"Binary STL", "stl_binary", binarySTL(cube(Vec{}, 10)), Vec{0, 0, 0}, Vec{10, 10, 10}},
+		{"ASCII STL", "stl_ascii", asciiSTL(cube(Vec{1, 2, 3}, 2)), Vec{1, 2, 3}, Vec{3, 4, 5}},
+		{"3MF", "3mf", threeMF(t, fmt.Sprintf(unitCube3MF, "millimeter", `<item objectid="1"/>`)), Vec{0, 0, 0}, Vec{1, 1, 1}},
+		{"3MF in inches", "3mf", threeMF(t, fmt.Sprintf(unitCube3MF, "inch", "")), Vec{0, 0, 0}, Vec{25.4, 25.4, 25.4}},
+		{"3MF with transform", "3mf", threeMF(t, fmt.Sprintf(unitCube3MF, "", `<item objectid="1" transform="2 0 0 0 2 0 0 0 2 10 0 0"/>`)), Vec{10, 0, 0}, Vec{12, 2, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Parse(tt.format, tt.data)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(m.Vertices) != 8 || len(m.Triangles) != 12 {
+				t.Errorf("Expected 8 vertices and 12 triangles, got %d and %d", len(m.Vertices), len(m.Triangles))
+			}
+			lo, hi := m.Vertices[0], m.Vertices[0]
+			for _, v := range m.Vertices {
+				for axis := range 3 {
+					lo[axis], hi[axis] = min(lo[axis], v[axis]), max(hi[axis], v[axis])
+				}
+			}
+			for axis := range 3 {
+				if math.Abs(lo[axis]-tt.wantMin[axis]) > 1e-9 || math.Abs(hi[axis]-tt.wantMax[axis]) > 1e-9 {
+					t.Errorf("Expected bounds %v to %v, got %v to %v", tt.wantMin, tt.wantMax, lo, hi)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		data    []byte
+		wantErr error
+	}{
+		{"Not a mesh format", "png", nil, ErrUnsupportedFormat},
+		{"Short binary STL", "stl_binary", make([]byte, 40), ErrInvalid},
+		{"Truncated binary STL", "stl_binary", binarySTL(cube(Vec{}, 1))[:500], ErrInvalid},
+		{"ASCII STL ending inside a facet", "stl_ascii", []byte("solid x\nvertex 0 0 0\nvertex 1 0 0\n"), ErrInvalid},
+		{"ASCII STL with a bad number", "stl_ascii", []byte("vertex 0 zero 0\n"), ErrInvalid},
+		{"3MF that isn't a zip", "3mf", []byte("<model/>"), ErrInvalid},
+		{"3MF without a model", "3mf", func() []byte {
+			var buf bytes.Buffer
+			zip.NewWriter(&buf).Close()
+			return buf.Bytes()
+		}(), ErrInvalid},
+		{"3MF with an unknown unit", "3mf", threeMF(t, fmt.Sprintf(unitCube3MF, "furlong", "")), ErrInvalid},
+		{"3MF with an unknown object", "3mf", threeMF(t, fmt.Sprintf(unitCube3MF, "", `<item objectid="2"/>`)), ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.format, tt.data); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
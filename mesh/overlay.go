@@ -0,0 +1,121 @@
+package mesh
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+)
+
+// issueColors are the overlay colors of the issues; triangles without one
+// are gray
+var issueColors = map[Issue]color.NRGBA{
+	IssueNone:        {R: 180, G: 180, B: 180, A: 255},
+	IssueOverhang:    {R: 220, G: 40, B: 40, A: 255},
+	IssueThinWall:    {R: 240, G: 200, B: 30, A: 255},
+	IssueThinFeature: {R: 240, G: 120, B: 20, A: 255},
+	IssueFloating:    {R: 200, G: 40, B: 200, A: 255},
+}
+
+// overlayViews are the directions the overlay looks at the part from: front
+// right above, where walls and islands show, and front right below, where
+// overhangs and the first layer show
+var overlayViews = []Vec{{-1, 1, -1}, {-1, 1, 1}}
+
+// Overlay renders m as a PNG of two size × size views side by side, from
+// above and from below, with every triangle colored by its issue: overhangs
+// red, thin walls yellow, thin features orange and floating islands magenta
+func Overlay(m *Mesh, issues []Issue, size int) ([]byte, error) {
+	img := image.NewNRGBA(image.Rect(0, 0, size*len(overlayViews), size))
+	for i, view := range overlayViews {
+		render(img, image.Rect(i*size, 0, (i+1)*size, size), m, issues, view)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// render draws m into the rect of img as seen along the view direction, with
+// an orthographic projection fitted to the rect and flat shading
+func render(img *image.NRGBA, rect image.Rectangle, m *Mesh, issues []Issue, view Vec) {
+	l := length(view)
+	forward := Vec{view[0] / l, view[1] / l, view[2] / l}
+	right := cross(forward, Vec{0, 0, 1})
+	l = length(right)
+	right = Vec{right[0] / l, right[1] / l, right[2] / l}
+	up := cross(right, forward)
+
+	// Screen coordinates of every vertex and the scale fitting them in rect
+	screen := make([]Vec, len(m.Vertices))
+	lo := Vec{math.Inf(1), math.Inf(1)}
+	hi := Vec{math.Inf(-1), math.Inf(-1)}
+	for i, v := range m.Vertices {
+		screen[i] = Vec{dot(v, right), dot(v, up), dot(v, forward)}
+		for axis := range 2 {
+			lo[axis], hi[axis] = min(lo[axis], screen[i][axis]), max(hi[axis], screen[i][axis])
+		}
+	}
+	const margin = 0.05
+	extent := max(hi[0]-lo[0], hi[1]-lo[1])
+	if extent <= 0 || math.IsInf(extent, 0) {
+		return
+	}
+	width, height := float64(rect.Dx()), float64(rect.Dy())
+	scale := min(width, height) * (1 - 2*margin) / extent
+	offsetX := float64(rect.Min.X) + (width-scale*(hi[0]-lo[0]))/2
+	offsetY := float64(rect.Min.Y) + (height-scale*(hi[1]-lo[1]))/2
+	pixel := func(v Vec) (float64, float64) {
+		return offsetX + (v[0]-lo[0])*scale, offsetY + (hi[1]-v[1])*scale
+	}
+
+	depth := make([]float64, rect.Dx()*rect.Dy())
+	for i := range depth {
+		depth[i] = math.Inf(1)
+	}
+	for n, t := range m.Triangles {
+		a, b, c := m.Vertices[t[0]], m.Vertices[t[1]], m.Vertices[t[2]]
+		normal := cross(sub(b, a), sub(c, a))
+		nl := length(normal)
+		if nl == 0 {
+			continue
+		}
+		shade := 0.35 + 0.65*math.Abs(dot(normal, forward))/nl
+		base := issueColors[IssueNone]
+		if n < len(issues) {
+			base = issueColors[issues[n]]
+		}
+		fill := color.NRGBA{R: uint8(float64(base.R) * shade), G: uint8(float64(base.G) * shade), B: uint8(float64(base.B) * shade), A: 255}
+
+		x0, y0 := pixel(screen[t[0]])
+		x1, y1 := pixel(screen[t[1]])
+		x2, y2 := pixel(screen[t[2]])
+		area := (x1-x0)*(y2-y0) - (x2-x0)*(y1-y0)
+		if area == 0 {
+			continue
+		}
+		minX := max(rect.Min.X, int(math.Floor(min(x0, x1, x2))))
+		maxX := min(rect.Max.X-1, int(math.Ceil(max(x0, x1, x2))))
+		minY := max(rect.Min.Y, int(math.Floor(min(y0, y1, y2))))
+		maxY := min(rect.Max.Y-1, int(math.Ceil(max(y0, y1, y2))))
+		for py := minY; py <= maxY; py++ {
+			for px := minX; px <= maxX; px++ {
+				x, y := float64(px)+0.5, float64(py)+0.5
+				w0 := ((x1-x)*(y2-y) - (x2-x)*(y1-y)) / area
+				w1 := ((x2-x)*(y0-y) - (x0-x)*(y2-y)) / area
+				w2 := 1 - w0 - w1
+				if w0 < 0 || w1 < 0 || w2 < 0 {
+					continue
+				}
+				z := w0*screen[t[0]][2] + w1*screen[t[1]][2] + w2*screen[t[2]][2]
+				i := (py-rect.Min.Y)*rect.Dx() + px - rect.Min.X
+				if z < depth[i] {
+					depth[i] = z
+					img.SetNRGBA(px, py, fill)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,379 @@
+package mesh
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+const (
+	// bedTolerance is how far above the lowest point of a part a triangle
+	// still rests on the build plate
+	bedTolerance = 0.01
+	// minContactRatio is the share of its footprint below which a part's
+	// contact with the build plate is reported as small
+	minContactRatio = 0.1
+	// maxWarnings caps the warnings of each check; the worst regions are kept
+	maxWarnings = 20
+)
+
+// ErrInvalidOptions is returned for print options out of range
+var ErrInvalidOptions = errors.New("invalid print options")
+
+// PrintOptions are the printer settings a mesh is checked against
+type PrintOptions struct {
+	// OverhangAngle is the steepest overhang, in degrees from vertical,
+	// that prints without support
+	OverhangAngle float64
+	// NozzleWidth is the narrowest feature the printer can lay down
+	NozzleWidth float64
+	// MinWallThickness is the thinnest wall considered sturdy
+	MinWallThickness float64
+	// BuildVolume is the printable size along x, y and z
+	BuildVolume Vec
+}
+
+// DefaultPrintOptions describe a common desktop FDM printer
+var DefaultPrintOptions = PrintOptions{
+	OverhangAngle:    45,
+	NozzleWidth:      0.4,
+	MinWallThickness: 0.8,
+	BuildVolume:      Vec{220, 220, 250},
+}
+
+// Validate checks that the options are within range
+func (o PrintOptions) Validate() error {
+	var errs []error
+	if o.OverhangAngle <= 0 || o.OverhangAngle >= 90 {
+		errs = append(errs, fmt.Errorf("overhang_angle must be between 0 and 90 degrees, got %g", o.OverhangAngle))
+	}
+	if o.NozzleWidth <= 0 {
+		errs = append(errs, fmt.Errorf("nozzle_width must be positive, got %g", o.NozzleWidth))
+	}
+	if o.MinWallThickness < 0 {
+		errs = append(errs, fmt.Errorf("min_wall_thickness must not be negative, got %g", o.MinWallThickness))
+	}
+	if o.BuildVolume[0] <= 0 || o.BuildVolume[1] <= 0 || o.BuildVolume[2] <= 0 {
+		errs = append(errs, fmt.Errorf("build_volume must be positive, got %v", o.BuildVolume))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidOptions, err)
+	}
+	return nil
+}
+
+// Issue is the print check a triangle fails, for highlighting
+type Issue uint8
+
+// Issues in increasing precedence: a triangle failing several checks is
+// marked with the last
+const (
+	IssueNone Issue = iota
+	IssueOverhang
+	IssueThinWall
+	IssueThinFeature
+	IssueFloating
+)
+
+// Check runs the print checks on m, standing on its lowest point as exported,
+// and returns the report together with the issue of every triangle:
+//
+//   - overhangs: downward faces steeper than the overhang angle from
+//     vertical, except those resting on the build plate
+//   - wall thickness: the distance from each face straight into the part to
+//     the opposite face; thinner than the nozzle is a thin feature, thinner
+//     than the minimum wall a thin wall
+//   - floating islands: shells whose lowest point is above the build plate,
+//     except cavities
+//   - the size of the part against the build volume
+//   - the area of the downward faces resting on the build plate
+func Check(m *Mesh, opts PrintOptions) (models.PrintabilityReport, []Issue) {
+	n := len(m.Triangles)
+	issues := make([]Issue, n)
+	normals := make([]Vec, n)
+	areas := make([]float64, n)
+	centers := make([]Vec, n)
+
+	// Normals point out of the part whichever way the mesh is wound
+	orientation := 1.0
+	if signedVolume(m, nil) < 0 {
+		orientation = -1
+	}
+	for i, t := range m.Triangles {
+		a, b, c := m.Vertices[t[0]], m.Vertices[t[1]], m.Vertices[t[2]]
+		normal := cross(sub(b, a), sub(c, a))
+		if l := length(normal); l > 0 {
+			areas[i] = l / 2
+			normals[i] = Vec{orientation * normal[0] / l, orientation * normal[1] / l, orientation * normal[2] / l}
+		}
+		centers[i] = Vec{(a[0] + b[0] + c[0]) / 3, (a[1] + b[1] + c[1]) / 3, (a[2] + b[2] + c[2]) / 3}
+	}
+
+	lo, hi := bounds(m, nil)
+	size := sub(hi, lo)
+	report := models.PrintabilityReport{
+		OverhangAngle: opts.OverhangAngle,
+		NozzleWidth:   opts.NozzleWidth,
+		BoundingBox:   models.SummaryBoundingBox{Min: lo[:], Max: hi[:], Size: size[:]},
+		BuildVolume:   slices.Clone(opts.BuildVolume[:]),
+		Warnings:      []models.PrintabilityWarning{},
+	}
+	if n == 0 {
+		report.Printable, report.FitsBuildVolume = true, true
+		return report, issues
+	}
+	bed := lo[2]
+
+	// Overhangs and the first layer
+	limit := math.Sin(opts.OverhangAngle * math.Pi / 180)
+	overhang := make([]bool, n)
+	onBed := make([]bool, n)
+	for i, t := range m.Triangles {
+		if areas[i] == 0 {
+			continue
+		}
+		if max(m.Vertices[t[0]][2], m.Vertices[t[1]][2], m.Vertices[t[2]][2]) <= bed+bedTolerance {
+			if normals[i][2] < 0 {
+				onBed[i] = true
+				report.FirstLayerArea += areas[i]
+			}
+			continue
+		}
+		if -normals[i][2] > limit {
+			overhang[i] = true
+			issues[i] = IssueOverhang
+			report.OverhangArea += areas[i]
+		}
+	}
+	report.Warnings = append(report.Warnings, regionWarnings(m, "overhang", overhang, areas, centers, false,
+		func(group []int) float64 { return sum(group, areas) },
+		func(area float64) (string, string) {
+			return "warning", fmt.Sprintf("%.2f mm² overhang steeper than %g° from vertical needs support", area, opts.OverhangAngle)
+		})...)
+
+	// Wall thickness, measured straight into the part from every face
+	tree := newBVH(m)
+	thickness := make([]float64, n)
+	thinWall, thinFeature := make([]bool, n), make([]bool, n)
+	minWall := math.Inf(1)
+	for i := range m.Triangles {
+		thickness[i] = math.Inf(1)
+		if areas[i] == 0 {
+			continue
+		}
+		inward := Vec{-normals[i][0], -normals[i][1], -normals[i][2]}
+		thickness[i] = tree.nearest(centers[i], inward, i)
+		minWall = min(minWall, thickness[i])
+		switch {
+		case thickness[i] < opts.NozzleWidth:
+			thinFeature[i] = true
+			issues[i] = IssueThinFeature
+		case thickness[i] < opts.MinWallThickness:
+			thinWall[i] = true
+			issues[i] = IssueThinWall
+		}
+	}
+	if !math.IsInf(minWall, 1) {
+		report.MinWallThickness = &minWall
+	}
+	thinnest := func(group []int) float64 {
+		t := math.Inf(1)
+		for _, i := range group {
+			t = min(t, thickness[i])
+		}
+		return t
+	}
+	report.Warnings = append(report.Warnings, regionWarnings(m, "thin_feature", thinFeature, areas, centers, true, thinnest,
+		func(t float64) (string, string) {
+			return "error", fmt.Sprintf("%.2f mm thick, thinner than the %g mm nozzle", t, opts.NozzleWidth)
+		})...)
+	report.Warnings = append(report.Warnings, regionWarnings(m, "thin_wall", thinWall, areas, centers, true, thinnest,
+		func(t float64) (string, string) {
+			return "warning", fmt.Sprintf("%.2f mm thick wall, thinner than %g mm", t, opts.MinWallThickness)
+		})...)
+
+	// Floating islands
+	var islands []models.PrintabilityWarning
+	for _, shell := range groups(m, nil) {
+		if orientation*signedVolume(m, shell) < 0 {
+			continue
+		}
+		shellLo, _ := bounds(m, shell)
+		if gap := shellLo[2] - bed; gap > bedTolerance {
+			report.FloatingIslands++
+			for _, i := range shell {
+				issues[i] = IssueFloating
+			}
+			islands = append(islands, models.PrintabilityWarning{
+				Check:    "floating_island",
+				Severity: "error",
+				Message:  fmt.Sprintf("shell floats %.2f mm above the build plate", gap),
+				Value:    gap,
+				Location: location(m, shell, areas, centers),
+			})
+		}
+	}
+	slices.SortStableFunc(islands, func(a, b models.PrintabilityWarning) int { return cmp.Compare(b.Value, a.Value) })
+	report.Warnings = append(report.Warnings, islands[:min(len(islands), maxWarnings)]...)
+
+	// Build volume
+	report.FitsBuildVolume = true
+	overflow := 0.0
+	for axis := range 3 {
+		overflow = max(overflow, size[axis]-opts.BuildVolume[axis])
+	}
+	if overflow > 0 {
+		report.FitsBuildVolume = false
+		report.Warnings = append(report.Warnings, models.PrintabilityWarning{
+			Check:    "build_volume",
+			Severity: "error",
+			Message: fmt.Sprintf("part is %g x %g x %g mm, larger than the %g x %g x %g mm build volume",
+				size[0], size[1], size[2], opts.BuildVolume[0], opts.BuildVolume[1], opts.BuildVolume[2]),
+			Value:    overflow,
+			Location: location(m, nil, areas, centers),
+		})
+	}
+
+	// First layer
+	if footprint := size[0] * size[1]; report.FirstLayerArea < minContactRatio*footprint {
+		warning := models.PrintabilityWarning{
+			Check:    "first_layer",
+			Severity: "warning",
+			Message:  fmt.Sprintf("only %.2f mm² of the %.2f mm² footprint touches the build plate", report.FirstLayerArea, footprint),
+			Value:    report.FirstLayerArea,
+		}
+		if report.FirstLayerArea > 0 {
+			warning.Location = location(m, memberIndexes(onBed), areas, centers)
+		}
+		report.Warnings = append(report.Warnings, warning)
+	}
+
+	report.Printable = !slices.ContainsFunc(report.Warnings, func(w models.PrintabilityWarning) bool { return w.Severity == "error" })
+	return report, issues
+}
+
+// regionWarnings returns a warning of check for every connected region of
+// the member triangles, worst first and at most maxWarnings. value measures a
+// region and describe gives the severity and message for that value; lower
+// values are worse when ascending is set.
+func regionWarnings(m *Mesh, check string, member []bool, areas []float64, centers []Vec, ascending bool,
+	value func(group []int) float64, describe func(v float64) (severity, message string)) []models.PrintabilityWarning {
+	regions := groups(m, member)
+	values := make([]float64, len(regions))
+	order := make([]int, len(regions))
+	for i, group := range regions {
+		values[i], order[i] = value(group), i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		if ascending {
+			return cmp.Compare(values[a], values[b])
+		}
+		return cmp.Compare(values[b], values[a])
+	})
+
+	warnings := make([]models.PrintabilityWarning, 0, min(len(order), maxWarnings))
+	for _, i := range order[:min(len(order), maxWarnings)] {
+		severity, message := describe(values[i])
+		warnings = append(warnings, models.PrintabilityWarning{
+			Check:    check,
+			Severity: severity,
+			Message:  message,
+			Value:    values[i],
+			Location: location(m, regions[i], areas, centers),
+		})
+	}
+	return warnings
+}
+
+// location describes the given triangles of m, or all of them for nil
+func location(m *Mesh, triangles []int, areas []float64, centers []Vec) *models.PrintabilityLocation {
+	lo, hi := bounds(m, triangles)
+	count := len(triangles)
+	if triangles == nil {
+		count = len(m.Triangles)
+	}
+
+	// The area-weighted center, or the middle of the bounds for slivers
+	var center Vec
+	total := 0.0
+	each(m, triangles, func(i int) {
+		total += areas[i]
+		for axis := range 3 {
+			center[axis] += areas[i] * centers[i][axis]
+		}
+	})
+	for axis := range 3 {
+		if total > 0 {
+			center[axis] /= total
+		} else {
+			center[axis] = (lo[axis] + hi[axis]) / 2
+		}
+	}
+	return &models.PrintabilityLocation{Center: center[:], Min: lo[:], Max: hi[:], Triangles: count}
+}
+
+// bounds returns the corners of the box around the given triangles of m, or
+// all of them for nil
+func bounds(m *Mesh, triangles []int) (lo, hi Vec) {
+	lo = Vec{math.Inf(1), math.Inf(1), math.Inf(1)}
+	hi = Vec{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	each(m, triangles, func(i int) {
+		for _, v := range m.Triangles[i] {
+			for axis, x := range m.Vertices[v] {
+				lo[axis], hi[axis] = min(lo[axis], x), max(hi[axis], x)
+			}
+		}
+	})
+	if math.IsInf(lo[0], 1) {
+		return Vec{}, Vec{}
+	}
+	return lo, hi
+}
+
+// signedVolume returns the volume enclosed by the given triangles of m, or
+// all of them for nil, negative when they face inward
+func signedVolume(m *Mesh, triangles []int) float64 {
+	volume := 0.0
+	each(m, triangles, func(i int) {
+		t := m.Triangles[i]
+		volume += dot(m.Vertices[t[0]], cross(m.Vertices[t[1]], m.Vertices[t[2]])) / 6
+	})
+	return volume
+}
+
+// each calls fn with the given triangle indexes, or every index for nil
+func each(m *Mesh, triangles []int, fn func(i int)) {
+	if triangles == nil {
+		for i := range m.Triangles {
+			fn(i)
+		}
+		return
+	}
+	for _, i := range triangles {
+		fn(i)
+	}
+}
+
+// memberIndexes returns the indexes at which member is true
+func memberIndexes(member []bool) []int {
+	var indexes []int
+	for i, ok := range member {
+		if ok {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// sum returns the total of values at the given indexes
+func sum(indexes []int, values []float64) float64 {
+	total := 0.0
+	for _, i := range indexes {
+		total += values[i]
+	}
+	return total
+}
@@ -0,0 +1,182 @@
+package mesh
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"math"
+	"testing"
+)
+
+// funnel is an upside-down pyramid standing on its tip, with a 40 × 40 top
+// 10 above the tip, so its sides overhang at 63° from vertical
+func funnel() [][3]Vec {
+	p := Vec{0, 0, 0}
+	a, b, c, d := Vec{-20, -20, 10}, Vec{20, -20, 10}, Vec{20, 20, 10}, Vec{-20, 20, 10}
+	return [][3]Vec{{a, b, c}, {a, c, d}, {p, b, a}, {p, c, b}, {p, d, c}, {p, a, d}}
+}
+
+// checks returns the checks of the warnings in report order
+func checks(warnings []string) map[string]int {
+	counts := make(map[string]int)
+	for _, w := range warnings {
+		counts[w]++
+	}
+	return counts
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name          string
+		triangles     [][3]Vec
+		wantPrintable bool
+		wantChecks    map[string]int
+		wantOverhang  float64
+		wantIslands   int
+	}{
+		{
+			name:          "Cube",
+			triangles:     cube(Vec{}, 10),
+			wantPrintable: true,
+			wantChecks:    map[string]int{},
+		},
+		{
+			name:          "Floating cube",
+			triangles:     append(cube(Vec{}, 10), cube(Vec{20, 0, 5}, 1)...),
+			wantPrintable: false,
+			wantChecks:    map[string]int{"overhang": 1, "floating_island": 1},
+			wantOverhang:  1,
+			wantIslands:   1,
+		},
+		{
+			name:          "Thin plate",
+			triangles:     box(Vec{}, Vec{10, 10, 0.3}),
+			wantPrintable: false,
+			wantChecks:    map[string]int{"thin_feature": 2},
+		},
+		{
+			name:          "Thin wall",
+			triangles:     box(Vec{}, Vec{10, 10, 0.6}),
+			wantPrintable: true,
+			wantChecks:    map[string]int{"thin_wall": 2},
+		},
+		{
+			name:          "Too long",
+			triangles:     box(Vec{}, Vec{300, 10, 10}),
+			wantPrintable: false,
+			wantChecks:    map[string]int{"build_volume": 1},
+		},
+		{
+			name:          "Funnel",
+			triangles:     funnel(),
+			wantPrintable: true,
+			wantChecks:    map[string]int{"overhang": 1, "first_layer": 1},
+			wantOverhang:  4 * 40 * math.Sqrt(500) / 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := build(tt.triangles)
+			report, issues := Check(m, DefaultPrintOptions)
+			if len(issues) != len(m.Triangles) {
+				t.Fatalf("Expected an issue per triangle, got %d for %d", len(issues), len(m.Triangles))
+			}
+			if report.Printable != tt.wantPrintable {
+				t.Errorf("Expected printable %v, got %v: %+v", tt.wantPrintable, report.Printable, report.Warnings)
+			}
+			var got []string
+			for _, w := range report.Warnings {
+				got = append(got, w.Check)
+				if w.Location == nil && w.Check != "first_layer" {
+					t.Errorf("Expected a location for %s, got none", w.Check)
+				}
+			}
+			counts := checks(got)
+			if len(counts) != len(tt.wantChecks) {
+				t.Errorf("Expected warnings %v, got %v", tt.wantChecks, counts)
+			}
+			for check, n := range tt.wantChecks {
+				if counts[check] != n {
+					t.Errorf("Expected %d %s warnings, got %d", n, check, counts[check])
+				}
+			}
+			if !near(report.OverhangArea, tt.wantOverhang) || report.FloatingIslands != tt.wantIslands {
+				t.Errorf("Expected overhang %g and %d islands, got %g and %d", tt.wantOverhang, tt.wantIslands, report.OverhangArea, report.FloatingIslands)
+			}
+		})
+	}
+}
+
+func TestCheck_Measures(t *testing.T) {
+	report, _ := Check(build(box(Vec{}, Vec{10, 20, 3})), DefaultPrintOptions)
+	if report.MinWallThickness == nil || !near(*report.MinWallThickness, 3) {
+		t.Errorf("Expected a minimum wall of 3, got %v", report.MinWallThickness)
+	}
+	if !near(report.FirstLayerArea, 200) || !report.FitsBuildVolume {
+		t.Errorf("Expected 200 mm² on the plate within the build volume, got %g, %v", report.FirstLayerArea, report.FitsBuildVolume)
+	}
+
+	// A stricter printer flags the same part
+	opts := DefaultPrintOptions
+	opts.MinWallThickness = 4
+	opts.BuildVolume = Vec{15, 15, 15}
+	report, _ = Check(build(box(Vec{}, Vec{10, 20, 3})), opts)
+	if report.FitsBuildVolume || report.Printable {
+		t.Errorf("Expected the part not to fit, got %+v", report)
+	}
+}
+
+func TestPrintOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(o *PrintOptions)
+	}{
+		{"Flat overhang angle", func(o *PrintOptions) { o.OverhangAngle = 90 }},
+		{"No nozzle", func(o *PrintOptions) { o.NozzleWidth = 0 }},
+		{"Negative wall", func(o *PrintOptions) { o.MinWallThickness = -1 }},
+		{"Empty build volume", func(o *PrintOptions) { o.BuildVolume[2] = 0 }},
+	}
+
+	if err := DefaultPrintOptions.Validate(); err != nil {
+		t.Errorf("Expected the defaults to be valid, got %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultPrintOptions
+			tt.modify(&opts)
+			if err := opts.Validate(); !errors.Is(err, ErrInvalidOptions) {
+				t.Errorf("Expected ErrInvalidOptions, got %v", err)
+			}
+		})
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	m := build(append(cube(Vec{}, 10), cube(Vec{20, 0, 5}, 1)...))
+	_, issues := Check(m, DefaultPrintOptions)
+
+	data, err := Overlay(m, issues, 64)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Expected a PNG, got %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 128 || b.Dy() != 64 {
+		t.Errorf("Expected two 64 × 64 views, got %v", b)
+	}
+
+	var part, highlighted bool
+	for y := range 64 {
+		for x := range 128 {
+			r, g, b, a := img.At(x, y).RGBA()
+			part = part || a > 0
+			highlighted = highlighted || (a > 0 && r > g && b > g)
+		}
+	}
+	if !part || !highlighted {
+		t.Errorf("Expected the part with the floating cube highlighted, got part %v, highlighted %v", part, highlighted)
+	}
+}
@@ -0,0 +1,160 @@
+package mesh
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// leafSize is the number of triangles in a bounding volume leaf
+const leafSize = 4
+
+// bvh is a bounding volume hierarchy over the triangles of a mesh, for
+// finding the nearest triangle along a ray
+type bvh struct {
+	mesh      *Mesh
+	triangles []int
+	nodes     []bvhNode
+}
+
+// bvhNode bounds either the triangles[start:start+count] of a leaf or, when
+// count is 0, its two children at left and left+1
+type bvhNode struct {
+	lo, hi       Vec
+	left         int
+	start, count int
+}
+
+func newBVH(m *Mesh) *bvh {
+	b := &bvh{mesh: m, triangles: make([]int, len(m.Triangles))}
+	for i := range b.triangles {
+		b.triangles[i] = i
+	}
+	if len(b.triangles) > 0 {
+		b.nodes = append(b.nodes, bvhNode{})
+		b.build(0, 0, len(b.triangles))
+	}
+	return b
+}
+
+// build fills node with the triangles[start:end], splitting them at the
+// median of their centers along the longest axis until they fit a leaf
+func (b *bvh) build(node, start, end int) {
+	lo := Vec{math.Inf(1), math.Inf(1), math.Inf(1)}
+	hi := Vec{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for _, t := range b.triangles[start:end] {
+		for _, v := range b.mesh.Triangles[t] {
+			for axis, x := range b.mesh.Vertices[v] {
+				lo[axis], hi[axis] = min(lo[axis], x), max(hi[axis], x)
+			}
+		}
+	}
+	b.nodes[node].lo, b.nodes[node].hi = lo, hi
+	if end-start <= leafSize {
+		b.nodes[node].start, b.nodes[node].count = start, end-start
+		return
+	}
+
+	axis := 0
+	for a := 1; a < 3; a++ {
+		if hi[a]-lo[a] > hi[axis]-lo[axis] {
+			axis = a
+		}
+	}
+	slices.SortFunc(b.triangles[start:end], func(x, y int) int {
+		return cmp.Compare(b.center(x)[axis], b.center(y)[axis])
+	})
+
+	left := len(b.nodes)
+	b.nodes[node].left = left
+	b.nodes = append(b.nodes, bvhNode{}, bvhNode{})
+	mid := (start + end) / 2
+	b.build(left, start, mid)
+	b.build(left+1, mid, end)
+}
+
+// center returns the centroid of triangle t
+func (b *bvh) center(t int) Vec {
+	tri := b.mesh.Triangles[t]
+	a, c, d := b.mesh.Vertices[tri[0]], b.mesh.Vertices[tri[1]], b.mesh.Vertices[tri[2]]
+	return Vec{(a[0] + c[0] + d[0]) / 3, (a[1] + c[1] + d[1]) / 3, (a[2] + c[2] + d[2]) / 3}
+}
+
+// nearest returns the distance along dir from origin to the nearest triangle
+// other than skip, or +Inf if the ray hits none. dir must be a unit vector.
+func (b *bvh) nearest(origin, dir Vec, skip int) float64 {
+	best := math.Inf(1)
+	if len(b.nodes) == 0 {
+		return best
+	}
+	stack := []int{0}
+	for len(stack) > 0 {
+		n := b.nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		if !hitsBox(origin, dir, n.lo, n.hi, best) {
+			continue
+		}
+		if n.count == 0 {
+			stack = append(stack, n.left, n.left+1)
+			continue
+		}
+		for _, t := range b.triangles[n.start : n.start+n.count] {
+			if t == skip {
+				continue
+			}
+			if d := b.intersect(origin, dir, t); d < best {
+				best = d
+			}
+		}
+	}
+	return best
+}
+
+// intersect returns the distance along dir at which the ray from origin hits
+// triangle t, or +Inf if it doesn't (Möller–Trumbore)
+func (b *bvh) intersect(origin, dir Vec, t int) float64 {
+	const epsilon = 1e-9
+	tri := b.mesh.Triangles[t]
+	v0, v1, v2 := b.mesh.Vertices[tri[0]], b.mesh.Vertices[tri[1]], b.mesh.Vertices[tri[2]]
+	e1, e2 := sub(v1, v0), sub(v2, v0)
+	p := cross(dir, e2)
+	det := dot(e1, p)
+	if math.Abs(det) < epsilon {
+		return math.Inf(1)
+	}
+	s := sub(origin, v0)
+	u := dot(s, p) / det
+	if u < 0 || u > 1 {
+		return math.Inf(1)
+	}
+	q := cross(s, e1)
+	v := dot(dir, q) / det
+	if v < 0 || u+v > 1 {
+		return math.Inf(1)
+	}
+	if d := dot(e2, q) / det; d > epsilon {
+		return d
+	}
+	return math.Inf(1)
+}
+
+// hitsBox reports whether the ray from origin along dir enters the box
+// before maxDist
+func hitsBox(origin, dir, lo, hi Vec, maxDist float64) bool {
+	near, far := 0.0, maxDist
+	for axis := range 3 {
+		if dir[axis] == 0 {
+			if origin[axis] < lo[axis] || origin[axis] > hi[axis] {
+				return false
+			}
+			continue
+		}
+		t1 := (lo[axis] - origin[axis]) / dir[axis]
+		t2 := (hi[axis] - origin[axis]) / dir[axis]
+		near, far = max(near, min(t1, t2)), min(far, max(t1, t2))
+		if near > far {
+			return false
+		}
+	}
+	return true
+}
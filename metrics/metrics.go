@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "scadsrv"
+
+// byteBuckets covers outputs from a few hundred bytes up to ~256 MB
+var byteBuckets = prometheus.ExponentialBuckets(256, 4, 11)
+
+var (
+	// HTTPRequests counts handled HTTP requests per route, method and status
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Total HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	// HTTPRequestDuration observes HTTP request latency per route, method and status
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status code.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2.5, 12),
+	}, []string{"route", "method", "status"})
+
+	// RenderDuration observes OpenSCAD wall time per output format
+	RenderDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "render_duration_seconds",
+		Help:      "OpenSCAD process wall time by output format (\"summary\" for summary requests).",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 14),
+	}, []string{"format"})
+
+	// RenderExitCodes counts OpenSCAD process exits per exit code
+	RenderExitCodes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "render_exit_codes_total",
+		Help:      "OpenSCAD process exits by exit code (-1 if the process was killed or failed to start).",
+	}, []string{"exit_code"})
+
+	// RenderTimeouts counts OpenSCAD invocations killed for exceeding the timeout
+	RenderTimeouts = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "render_timeouts_total",
+		Help:      "OpenSCAD invocations killed for exceeding the render timeout.",
+	})
+
+	// TempDirFailures counts failures to create or populate render temp directories
+	TempDirFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tempdir_failures_total",
+		Help:      "Failures to create or write render temporary directories.",
+	})
+
+	// RenderQueueDepth is the number of renders waiting for a free slot
+	RenderQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "render_queue_depth",
+		Help:      "Renders waiting for a free OpenSCAD slot.",
+	})
+
+	// RendersInFlight is the number of OpenSCAD processes currently running
+	RendersInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "renders_in_flight",
+		Help:      "OpenSCAD processes currently running.",
+	})
+
+	// OutputBytes observes the size of export responses per format
+	OutputBytes = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "output_bytes",
+		Help:      "Size of exported files by format.",
+		Buckets:   byteBuckets,
+	}, []string{"format"})
+
+	// ConversionDuration observes PNG to WebP/AVIF conversion time
+	ConversionDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "conversion_duration_seconds",
+		Help:      "PNG conversion time by target format.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"format"})
+
+	// ConversionBytes observes the size of PNG conversion input and output
+	ConversionBytes = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "conversion_bytes",
+		Help:      "PNG conversion sizes by target format and direction (input or output).",
+		Buckets:   byteBuckets,
+	}, []string{"format", "direction"})
+
+	// ArtifactStorageBytes is the total size of stored artifacts
+	ArtifactStorageBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "artifact_storage_bytes",
+		Help:      "Total size of rendered artifacts kept for download.",
+	})
+
+	// WebhookDeliveries counts callback delivery attempts per result
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Callback delivery attempts by result (delivered, retried or failed).",
+	}, []string{"result"})
+)
+
+// Handler returns the HTTP handler serving metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
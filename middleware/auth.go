@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/logging"
+	"github.com/stevexciv/scad-server/models"
+)
+
+// adminKey is the gin context key Admin marks administrators with
+const adminKey = "scadsrv.admin"
+
+// Admin marks callers whose X-API-Key is one of adminKeys as administrators,
+// for RequireAdmin and for handlers that show administrators more
+func Admin(adminKeys []string) gin.HandlerFunc {
+	known := make(map[string]bool, len(adminKeys))
+	for _, key := range adminKeys {
+		known[hashKey(key)] = true
+	}
+	return func(c *gin.Context) {
+		if key := c.GetHeader(APIKeyHeader); key != "" && known[hashKey(key)] {
+			c.Set(adminKey, true)
+		}
+		c.Next()
+	}
+}
+
+// IsAdmin reports whether Admin marked the caller as an administrator
+func IsAdmin(c *gin.Context) bool {
+	return c.GetBool(adminKey)
+}
+
+// RequireAdmin rejects callers that Admin hasn't marked as administrators,
+// guarding endpoints that change state every client depends on
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !IsAdmin(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.ErrorResponse{
+				Error:     "forbidden",
+				Message:   "an admin API key is required in " + APIKeyHeader,
+				RequestID: logging.RequestID(c.Request.Context()),
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/history"
+)
+
+// Identify resolves the caller's ClientKey and attaches it to the request
+// so that rate limits, usage and the render history see the same client.
+// Callers are identified by X-API-Key only when it is one of apiKeys, since
+// any other value could be changed on every request to dodge the limits;
+// everyone else is identified by IP.
+func Identify(apiKeys []string) gin.HandlerFunc {
+	known := make(map[string]bool, len(apiKeys))
+	for _, key := range apiKeys {
+		known[hashKey(key)] = true
+	}
+	return func(c *gin.Context) {
+		client := "ip:" + c.ClientIP()
+		if key := c.GetHeader(APIKeyHeader); key != "" {
+			if hash := hashKey(key); known[hash] {
+				client = "key:" + hash[:16]
+			}
+		}
+		c.Set(clientKey, client)
+		c.Request = c.Request.WithContext(history.WithClient(c.Request.Context(), client))
+		c.Next()
+	}
+}
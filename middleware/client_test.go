@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/history"
+)
+
+func TestIdentify(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiKey     string
+		wantPrefix string
+	}{
+		{"Configured API key", "secret-key", "key:"},
+		{"Unknown API key", "made-up-key", "ip:"},
+		{"IP address", "", "ip:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen, key string
+			router := gin.New()
+			router.Use(Identify([]string{"secret-key"}))
+			router.GET("/", func(c *gin.Context) {
+				seen = history.Client(c.Request.Context())
+				key = ClientKey(c)
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.apiKey != "" {
+				req.Header.Set(APIKeyHeader, tt.apiKey)
+			}
+			router.ServeHTTP(w, req)
+
+			if !strings.HasPrefix(seen, tt.wantPrefix) {
+				t.Errorf("Expected client starting with '%s', got '%s'", tt.wantPrefix, seen)
+			}
+			if seen != key {
+				t.Errorf("Expected ClientKey to match the history client '%s', got '%s'", seen, key)
+			}
+			if strings.Contains(seen, tt.apiKey) && tt.apiKey != "" {
+				t.Errorf("Expected the API key to be hashed, got '%s'", seen)
+			}
+		})
+	}
+}
+
+func TestIdentify_UntrustedForwardedFor(t *testing.T) {
+	var seen string
+	router := gin.New()
+	router.SetTrustedProxies(nil)
+	router.Use(Identify(nil))
+	router.GET("/", func(c *gin.Context) {
+		seen = ClientKey(c)
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.0.2.10:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	router.ServeHTTP(w, req)
+
+	if seen != "ip:192.0.2.10" {
+		t.Errorf("Expected the connection's IP 'ip:192.0.2.10', got '%s'", seen)
+	}
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stevexciv/scad-server/metrics"
+)
+
+func TestMetrics_RecordsRouteAndStatus(t *testing.T) {
+	router := gin.New()
+	router.Use(Metrics())
+	router.GET("/items/:id", func(c *gin.Context) {
+		c.Status(http.StatusTeapot)
+	})
+
+	counter := metrics.HTTPRequests.WithLabelValues("/items/:id", "GET", "418")
+	before := testutil.ToFloat64(counter)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/items/42", nil)
+	router.ServeHTTP(w, req)
+
+	if got := testutil.ToFloat64(counter) - before; got != 1 {
+		t.Errorf("Expected counter to increase by 1, got %v", got)
+	}
+}
+
+func TestMetrics_GroupsUnmatchedRoutes(t *testing.T) {
+	router := gin.New()
+	router.Use(Metrics())
+
+	counter := metrics.HTTPRequests.WithLabelValues("unmatched", "GET", "404")
+	before := testutil.ToFloat64(counter)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/does/not/exist", nil)
+	router.ServeHTTP(w, req)
+
+	if got := testutil.ToFloat64(counter) - before; got != 1 {
+		t.Errorf("Expected counter to increase by 1, got %v", got)
+	}
+}
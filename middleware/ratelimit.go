@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/logging"
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/ratelimit"
+	"github.com/stevexciv/scad-server/services"
+)
+
+// APIKeyHeader identifies a client independently of its IP address
+const APIKeyHeader = "X-API-Key"
+
+// clientKey is the gin context key Identify stores the caller's ClientKey at
+const clientKey = "scadsrv.client"
+
+// ClientKey identifies the caller as resolved by Identify: by API key when it
+// sent a configured one, otherwise by IP
+func ClientKey(c *gin.Context) string {
+	if client := c.GetString(clientKey); client != "" {
+		return client
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// hashKey returns the SHA-256 of an API key, so that keys never appear in
+// usage output or logs
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// RateLimit rejects requests from clients that have exceeded their request
+// or render-seconds allowance and charges OpenSCAD wall time to the caller
+func RateLimit(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !limiter.Enabled() {
+			c.Next()
+			return
+		}
+
+		if !ChargeRequests(c, limiter, 1) {
+			return
+		}
+
+		client := ClientKey(c)
+		ctx := services.WithRenderTimeRecorder(c.Request.Context(), func(d time.Duration) {
+			limiter.AddRenderTime(client, d)
+		})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// ChargeRequests counts n requests against the caller's quota, for handlers
+// whose single request does the work of several. When the quota can't cover
+// them it responds 429 with Retry-After, aborts and returns false.
+func ChargeRequests(c *gin.Context, limiter *ratelimit.Limiter, n int) bool {
+	if n <= 0 || !limiter.Enabled() {
+		return true
+	}
+
+	client := ClientKey(c)
+	usage, ok := limiter.AllowN(client, n)
+	setQuotaHeaders(c, usage)
+	if !ok {
+		retryAfter := max(int(math.Ceil(usage.RetryAfter.Seconds())), 1)
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, models.ErrorResponse{
+			Error:     "rate limit exceeded",
+			Message:   fmt.Sprintf("quota exhausted for %s, retry after %d seconds", client, retryAfter),
+			RequestID: logging.RequestID(c.Request.Context()),
+		})
+		return false
+	}
+	return true
+}
+
+func setQuotaHeaders(c *gin.Context, usage ratelimit.Usage) {
+	if usage.RequestsLimit > 0 {
+		c.Header("X-RateLimit-Limit", strconv.Itoa(usage.RequestsLimit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(usage.RequestsRemaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(usage.RequestsReset.Unix(), 10))
+	}
+	if usage.RenderSecondsLimit > 0 {
+		c.Header("X-RenderQuota-Limit", strconv.FormatFloat(usage.RenderSecondsLimit, 'f', -1, 64))
+		c.Header("X-RenderQuota-Remaining", strconv.FormatFloat(usage.RenderSecondsRemaining, 'f', -1, 64))
+		c.Header("X-RenderQuota-Reset", strconv.FormatInt(usage.RenderReset.Unix(), 10))
+	}
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/ratelimit"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func setupRateLimitRouter(limiter *ratelimit.Limiter) *gin.Engine {
+	router := gin.New()
+	router.Use(Identify([]string{"alpha", "beta"}), RateLimit(limiter))
+	router.POST("/export", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	return router
+}
+
+func TestRateLimit_RejectsWithQuotaHeaders(t *testing.T) {
+	router := setupRateLimitRouter(ratelimit.New(ratelimit.Config{RequestsPerMinute: 1}))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/export", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("Expected X-RateLimit-Remaining 0, got '%s'", got)
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/export", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected Retry-After header")
+	}
+
+	var errResp models.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &errResp); err != nil {
+		t.Errorf("Failed to parse error response: %v", err)
+	}
+	if errResp.Error != "rate limit exceeded" {
+		t.Errorf("Expected error 'rate limit exceeded', got '%s'", errResp.Error)
+	}
+}
+
+func TestRateLimit_KeysByAPIKey(t *testing.T) {
+	router := setupRateLimitRouter(ratelimit.New(ratelimit.Config{RequestsPerMinute: 1}))
+
+	for _, key := range []string{"alpha", "beta"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/export", nil)
+		req.Header.Set(APIKeyHeader, key)
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200 for key %s, got %d", key, w.Code)
+		}
+	}
+}
+
+func TestRateLimit_UnknownKeysShareTheIPQuota(t *testing.T) {
+	router := setupRateLimitRouter(ratelimit.New(ratelimit.Config{RequestsPerMinute: 1}))
+
+	for i, key := range []string{"random-1", "random-2"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/export", nil)
+		req.Header.Set(APIKeyHeader, key)
+		router.ServeHTTP(w, req)
+
+		if want := []int{http.StatusOK, http.StatusTooManyRequests}[i]; w.Code != want {
+			t.Errorf("Expected status %d for key %s, got %d", want, key, w.Code)
+		}
+	}
+}
+
+func TestRateLimit_DisabledSetsNoHeaders(t *testing.T) {
+	router := setupRateLimitRouter(ratelimit.New(ratelimit.Config{}))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/export", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("X-RateLimit-Limit"); got != "" {
+		t.Errorf("Expected no X-RateLimit-Limit header, got '%s'", got)
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	c.Request.RemoteAddr = "192.0.2.10:1234"
+
+	if got := ClientKey(c); got != "ip:192.0.2.10" {
+		t.Errorf("Expected 'ip:192.0.2.10', got '%s'", got)
+	}
+
+	// Without Identify an API key is never trusted
+	c.Request.Header.Set(APIKeyHeader, "secret")
+	if got := ClientKey(c); got != "ip:192.0.2.10" {
+		t.Errorf("Expected 'ip:192.0.2.10', got '%s'", got)
+	}
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stevexciv/scad-server/logging"
+)
+
+func setupRequestIDRouter(seen *string) *gin.Engine {
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/", func(c *gin.Context) {
+		*seen = logging.RequestID(c.Request.Context())
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func TestRequestID_AcceptsIncoming(t *testing.T) {
+	var seen string
+	router := setupRequestIDRouter(&seen)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(RequestIDHeader, "ci-build-42")
+	router.ServeHTTP(w, req)
+
+	if seen != "ci-build-42" {
+		t.Errorf("Expected context request ID 'ci-build-42', got '%s'", seen)
+	}
+	if got := w.Header().Get(RequestIDHeader); got != "ci-build-42" {
+		t.Errorf("Expected response header 'ci-build-42', got '%s'", got)
+	}
+}
+
+func TestRequestID_GeneratesWhenMissingOrInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+	}{
+		{"Missing", ""},
+		{"Contains spaces", "bad id"},
+		{"Contains newline", "bad\nid"},
+		{"Too long", strings.Repeat("a", maxRequestIDLength+1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			router := setupRequestIDRouter(&seen)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.incoming != "" {
+				req.Header[RequestIDHeader] = []string{tt.incoming}
+			}
+			router.ServeHTTP(w, req)
+
+			if len(seen) != 32 {
+				t.Errorf("Expected generated 32 character ID, got '%s'", seen)
+			}
+			if got := w.Header().Get(RequestIDHeader); got != seen {
+				t.Errorf("Expected response header '%s', got '%s'", seen, got)
+			}
+		})
+	}
+}
@@ -1,10 +1,44 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // ExportRequest represents the request body for export endpoint
 type ExportRequest struct {
-	ScadContent string        `json:"scad_content" binding:"required" example:"cube([10,10,10]);"`
-	Format      string        `json:"format" binding:"required" example:"png"`
-	Options     ExportOptions `json:"options"`
+	ScadContent     string         `json:"scad_content" binding:"required" example:"cube([10,10,10]);"`
+	Format          string         `json:"format" binding:"required" example:"png"`
+	OpenSCADVersion string         `json:"openscad_version,omitempty" example:"nightly"`
+	Backend         string         `json:"backend,omitempty" example:"manifold" enums:"cgal,manifold"`
+	Features        []string       `json:"features,omitempty" example:"lazy-union"`
+	Parameters      map[string]any `json:"parameters,omitempty" swaggertype:"object"`
+	Assets          []Asset        `json:"assets,omitempty"`
+	Options         ExportOptions  `json:"options"`
+	SummaryType     string         `json:"summary_type,omitempty" example:"bounding-box" enums:"all,cache,time,camera,geometry,bounding-box,area"`
+	Analyze         bool           `json:"analyze,omitempty" example:"false"`
+	Store           bool           `json:"store,omitempty" example:"false"`
+	CallbackURL     string         `json:"callback_url,omitempty" example:"https://ci.example.com/hooks/scad"`
+	// Libraries are SCAD files for include and use, written next to the
+	// source like assets. Only saved designs set them; clients can't.
+	Libraries []Asset `json:"-"`
+}
+
+// ExportEnvelope carries an exported file together with the summary OpenSCAD
+// wrote in the same run and the analysis of its mesh, as requested
+type ExportEnvelope struct {
+	ContentType string          `json:"content_type" example:"application/octet-stream"`
+	Data        []byte          `json:"data" swaggertype:"string" format:"base64"`
+	Summary     *Summary        `json:"summary,omitempty"`
+	SummaryRaw  json.RawMessage `json:"summary_raw,omitempty" swaggertype:"object"`
+	Analysis    *MeshAnalysis   `json:"analysis,omitempty"`
+}
+
+// Asset is a file written next to the SCAD source so import() and surface()
+// can reference it by Name
+type Asset struct {
+	Name string `json:"name" example:"parts/bracket.stl"`
+	Data []byte `json:"data" swaggertype:"string" format:"base64" example:"c29saWQgYnJhY2tldA=="`
 }
 
 // ExportOptions contains format-specific export options
@@ -19,8 +53,9 @@ type ExportOptions struct {
 // PNGOptions contains PNG export options.
 // Also used for webp and avif formats (which render via PNG internally).
 type PNGOptions struct {
-	Width  *int `json:"width,omitempty" example:"800"`
-	Height *int `json:"height,omitempty" example:"600"`
+	Width  *int    `json:"width,omitempty" example:"800"`
+	Height *int    `json:"height,omitempty" example:"600"`
+	Camera *string `json:"camera,omitempty" example:"0,0,0,55,0,25,140"`
 }
 
 // STLOptions contains STL export options
@@ -66,17 +101,499 @@ type ThreeMFOptions struct {
 
 // SummaryRequest represents the request body for summary endpoint
 type SummaryRequest struct {
-	ScadContent string `json:"scad_content" binding:"required" example:"cube([10,10,10]);"`
-	SummaryType string `json:"summary_type,omitempty" example:"all" enums:"all,cache,time,camera,geometry,bounding-box,area"`
+	ScadContent     string         `json:"scad_content" binding:"required" example:"cube([10,10,10]);"`
+	SummaryType     string         `json:"summary_type,omitempty" example:"all" enums:"all,cache,time,camera,geometry,bounding-box,area"`
+	OpenSCADVersion string         `json:"openscad_version,omitempty" example:"nightly"`
+	Backend         string         `json:"backend,omitempty" example:"manifold" enums:"cgal,manifold"`
+	Features        []string       `json:"features,omitempty" example:"lazy-union"`
+	Parameters      map[string]any `json:"parameters,omitempty" swaggertype:"object"`
+	Assets          []Asset        `json:"assets,omitempty"`
+	CallbackURL     string         `json:"callback_url,omitempty" example:"https://ci.example.com/hooks/scad"`
 }
 
-// SummaryResponse represents the response from summary endpoint
+// SummaryResponse represents the response from summary endpoint. Summary
+// holds the sections OpenSCAD reported for the requested summary type; Raw
+// is the summary exactly as OpenSCAD wrote it, including fields this server
+// doesn't know yet.
 type SummaryResponse struct {
-	Summary map[string]interface{} `json:"summary"`
+	Summary Summary         `json:"summary"`
+	Raw     json.RawMessage `json:"raw" swaggertype:"object"`
+}
+
+// Summary is OpenSCAD's --summary output. Sections that weren't requested
+// are omitted.
+type Summary struct {
+	Cache       *SummaryCache       `json:"cache,omitempty"`
+	Time        *SummaryTime        `json:"time,omitempty"`
+	Camera      *SummaryCamera      `json:"camera,omitempty"`
+	Geometry    *SummaryGeometry    `json:"geometry,omitempty"`
+	BoundingBox *SummaryBoundingBox `json:"bounding_box,omitempty"`
+	Area        *SummaryArea        `json:"area,omitempty"`
+}
+
+// SummaryCache reports the entries and sizes of OpenSCAD's geometry caches
+type SummaryCache struct {
+	GeometryCacheEntries int64 `json:"geometry_cache_entries" example:"3"`
+	GeometryCacheSize    int64 `json:"geometry_cache_size" example:"1584"`
+	CGALCacheEntries     int64 `json:"cgal_cache_entries" example:"0"`
+	CGALCacheSize        int64 `json:"cgal_cache_size" example:"0"`
+}
+
+// SummaryTime reports how long the render took
+type SummaryTime struct {
+	Time    string  `json:"time" example:"0:00:00.042"`
+	Hours   int     `json:"hours" example:"0"`
+	Minutes int     `json:"minutes" example:"0"`
+	Seconds float64 `json:"seconds" example:"0.042"`
+}
+
+// SummaryCamera reports the camera used for image exports
+type SummaryCamera struct {
+	Translation []float64 `json:"translation" example:"0,0,0"`
+	Rotation    []float64 `json:"rotation" example:"55,0,25"`
+	Distance    float64   `json:"distance" example:"140"`
+	FOV         float64   `json:"fov" example:"22.5"`
+}
+
+// SummaryGeometry describes the rendered geometry. Vertices and facets are
+// reported for 3D geometry, contours for 2D geometry.
+type SummaryGeometry struct {
+	Dimensions  int                 `json:"dimensions" example:"3"`
+	Convex      *bool               `json:"convex,omitempty" example:"true"`
+	Vertices    *int                `json:"vertices,omitempty" example:"8"`
+	Facets      *int                `json:"facets,omitempty" example:"6"`
+	Contours    *int                `json:"contours,omitempty" example:"1"`
+	BoundingBox *SummaryBoundingBox `json:"bounding_box,omitempty"`
+}
+
+// SummaryBoundingBox is the axis-aligned bounding box of the geometry
+type SummaryBoundingBox struct {
+	Min  []float64 `json:"min" example:"0,0,0"`
+	Max  []float64 `json:"max" example:"10,10,10"`
+	Size []float64 `json:"size" example:"10,10,10"`
+}
+
+// SummaryArea reports the area of 2D geometry
+type SummaryArea struct {
+	Area float64 `json:"area" example:"100"`
+}
+
+// AnalyzeRequest represents the request body for the analyze endpoint: the
+// model is exported as Format and the resulting mesh is analyzed
+type AnalyzeRequest struct {
+	ScadContent     string         `json:"scad_content" binding:"required" example:"cube([10,10,10]);"`
+	Format          string         `json:"format,omitempty" example:"stl_binary" enums:"stl_binary,stl_ascii,3mf"`
+	OpenSCADVersion string         `json:"openscad_version,omitempty" example:"nightly"`
+	Backend         string         `json:"backend,omitempty" example:"manifold" enums:"cgal,manifold"`
+	Features        []string       `json:"features,omitempty" example:"lazy-union"`
+	Parameters      map[string]any `json:"parameters,omitempty" swaggertype:"object"`
+	Assets          []Asset        `json:"assets,omitempty"`
+	Options         ExportOptions  `json:"options"`
+}
+
+// MeshAnalysis describes an exported triangle mesh. Lengths are in
+// millimeters; volume, centroid and inertia treat the mesh as a solid of unit
+// density and are only meaningful when it is manifold.
+type MeshAnalysis struct {
+	Triangles           int                `json:"triangles" example:"12"`
+	Vertices            int                `json:"vertices" example:"8"`
+	Volume              float64            `json:"volume" example:"1000"`
+	SurfaceArea         float64            `json:"surface_area" example:"600"`
+	BoundingBox         SummaryBoundingBox `json:"bounding_box"`
+	Centroid            []float64          `json:"centroid,omitempty" example:"5,5,5"`
+	Inertia             [][]float64        `json:"inertia,omitempty" swaggertype:"array,number"`
+	Shells              int                `json:"shells" example:"1"`
+	OpenEdges           int                `json:"open_edges" example:"0"`
+	NonManifoldEdges    int                `json:"non_manifold_edges" example:"0"`
+	DegenerateTriangles int                `json:"degenerate_triangles" example:"0"`
+	Manifold            bool               `json:"manifold" example:"true"`
+}
+
+// PrintabilityRequest represents the request body for the printability
+// endpoint. Printer settings left out use the server's defaults.
+type PrintabilityRequest struct {
+	AnalyzeRequest
+	OverhangAngle    *float64  `json:"overhang_angle,omitempty" example:"45"`
+	NozzleWidth      *float64  `json:"nozzle_width,omitempty" example:"0.4"`
+	MinWallThickness *float64  `json:"min_wall_thickness,omitempty" example:"0.8"`
+	BuildVolume      []float64 `json:"build_volume,omitempty" example:"220,220,250"`
+	Overlay          bool      `json:"overlay,omitempty" example:"false"`
+}
+
+// PrintabilityReport lists the print checks of an exported mesh. Lengths are
+// in millimeters and areas in square millimeters.
+type PrintabilityReport struct {
+	Printable        bool                  `json:"printable" example:"true"`
+	OverhangAngle    float64               `json:"overhang_angle" example:"45"`
+	OverhangArea     float64               `json:"overhang_area" example:"12.5"`
+	MinWallThickness *float64              `json:"min_wall_thickness,omitempty" example:"1.2"`
+	NozzleWidth      float64               `json:"nozzle_width" example:"0.4"`
+	FloatingIslands  int                   `json:"floating_islands" example:"0"`
+	BoundingBox      SummaryBoundingBox    `json:"bounding_box"`
+	BuildVolume      []float64             `json:"build_volume" example:"220,220,250"`
+	FitsBuildVolume  bool                  `json:"fits_build_volume" example:"true"`
+	FirstLayerArea   float64               `json:"first_layer_area" example:"100"`
+	Warnings         []PrintabilityWarning `json:"warnings"`
+	Overlay          []byte                `json:"overlay,omitempty" swaggertype:"string" format:"base64"`
+}
+
+// PrintabilityWarning is a failed print check. Warnings of severity "error"
+// make the part unprintable.
+type PrintabilityWarning struct {
+	Check    string                `json:"check" example:"overhang" enums:"overhang,thin_wall,thin_feature,floating_island,build_volume,first_layer"`
+	Severity string                `json:"severity" example:"warning" enums:"warning,error"`
+	Message  string                `json:"message" example:"overhang of 12.5 mm² steeper than 45°"`
+	Value    float64               `json:"value" example:"12.5"`
+	Location *PrintabilityLocation `json:"location,omitempty"`
+}
+
+// PrintabilityLocation is the region of the mesh a warning refers to
+type PrintabilityLocation struct {
+	Center    []float64 `json:"center" example:"5,5,10"`
+	Min       []float64 `json:"min" example:"0,0,10"`
+	Max       []float64 `json:"max" example:"10,10,10"`
+	Triangles int       `json:"triangles" example:"2"`
 }
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
-	Error   string `json:"error" example:"invalid parameter"`
-	Message string `json:"message,omitempty" example:"detailed error message"`
+	Error     string `json:"error" example:"invalid parameter"`
+	Message   string `json:"message,omitempty" example:"detailed error message"`
+	RequestID string `json:"request_id,omitempty" example:"4f9c2a7e1b3d4c5a8e6f7a9b0c1d2e3f"`
+}
+
+// ReadinessResponse represents the response from the readiness endpoint
+type ReadinessResponse struct {
+	Status string                 `json:"status" example:"ready" enums:"ready,not_ready"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// CheckResult is the outcome of one readiness check
+type CheckResult struct {
+	Status     string                 `json:"status" example:"ok" enums:"ok,fail"`
+	Error      string                 `json:"error,omitempty" example:"temp directory not writable"`
+	DurationMS float64                `json:"duration_ms" example:"12.5"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+}
+
+// InfoResponse represents the response from the info endpoint
+type InfoResponse struct {
+	Server   ServerInfo   `json:"server"`
+	OpenSCAD OpenSCADInfo `json:"openscad"`
+	Formats  []FormatInfo `json:"formats"`
+	Limits   LimitsInfo   `json:"limits"`
+}
+
+// ServerInfo describes the scad-server build
+type ServerInfo struct {
+	Commit string `json:"commit" example:"a1b2c3d"`
+	Tag    string `json:"tag" example:"v1.2.0"`
+}
+
+// OpenSCADInfo describes an OpenSCAD installation used for renders
+type OpenSCADInfo struct {
+	Name         string            `json:"name" example:"nightly"`
+	Version      string            `json:"version" example:"2021.01"`
+	Default      bool              `json:"default" example:"true"`
+	Backends     []string          `json:"backends" example:"cgal,manifold"`
+	Features     []string          `json:"features" example:"manifold,textmetrics"`
+	ColorSchemes []string          `json:"color_schemes" example:"Cornfield,Metallic,Sunset"`
+	Build        map[string]string `json:"build,omitempty"`
+	LibraryPaths []string          `json:"library_paths" example:"/usr/share/openscad/libraries"`
+	Libraries    []string          `json:"libraries" example:"MCAD"`
+	Fonts        []string          `json:"fonts" example:"Liberation Sans"`
+}
+
+// OpenSCADVersion describes a named OpenSCAD installation
+type OpenSCADVersion struct {
+	Name    string `json:"name" example:"nightly"`
+	Version string `json:"version" example:"2025.03.15"`
+	Default bool   `json:"default" example:"false"`
+}
+
+// VersionsResponse represents the response from the versions endpoint
+type VersionsResponse struct {
+	Versions []OpenSCADVersion `json:"versions"`
+}
+
+// FormatInfo describes an export format and the options it accepts
+type FormatInfo struct {
+	Name        string         `json:"name" example:"png"`
+	ContentType string         `json:"content_type" example:"image/png"`
+	OptionsKey  string         `json:"options_key,omitempty" example:"png"`
+	Options     []OptionSchema `json:"options"`
+}
+
+// OptionSchema describes one field of a format's options object
+type OptionSchema struct {
+	Name    string   `json:"name" example:"width"`
+	Type    string   `json:"type" example:"integer" enums:"integer,number,boolean,string"`
+	Enum    []string `json:"enum,omitempty"`
+	Minimum *float64 `json:"minimum,omitempty"`
+	Maximum *float64 `json:"maximum,omitempty"`
+	Example string   `json:"example,omitempty" example:"800"`
+}
+
+// LimitsInfo describes the render limits currently in force. A rate limit of
+// 0 means unlimited.
+type LimitsInfo struct {
+	RenderTimeoutSeconds      float64 `json:"render_timeout_seconds" example:"300"`
+	MaxConcurrentRenders      int     `json:"max_concurrent_renders" example:"4"`
+	RequestsPerMinute         int     `json:"requests_per_minute" example:"60"`
+	RenderBudgetSeconds       float64 `json:"render_budget_seconds" example:"600"`
+	RenderBudgetPeriodSeconds float64 `json:"render_budget_period_seconds" example:"3600"`
+}
+
+// FontFile describes a font uploaded to the managed font directory
+type FontFile struct {
+	Name     string   `json:"name" example:"Acme-Bold.ttf"`
+	Size     int64    `json:"size" example:"184320"`
+	Families []string `json:"families" example:"Acme"`
+}
+
+// FontsResponse represents the response from the font list endpoint
+type FontsResponse struct {
+	Fonts []FontFile `json:"fonts"`
+}
+
+// FontFace is a font face available to OpenSCAD
+type FontFace struct {
+	Name    string `json:"name" example:"Liberation Sans:style=Bold"`
+	Family  string `json:"family" example:"Liberation Sans"`
+	Style   string `json:"style" example:"Bold"`
+	File    string `json:"file" example:"/usr/share/fonts/truetype/liberation/LiberationSans-Bold.ttf"`
+	Managed bool   `json:"managed" example:"false"`
+}
+
+// FontFamiliesResponse represents the response from the font families endpoint
+type FontFamiliesResponse struct {
+	Families []string   `json:"families" example:"Acme,Liberation Sans"`
+	Fonts    []FontFace `json:"fonts"`
+}
+
+// Artifact describes a stored render
+type Artifact struct {
+	ID              string         `json:"id" example:"3f2a9c7e1b3d4c5a8e6f7a9b0c1d2e3f"`
+	Filename        string         `json:"filename" example:"model.stl"`
+	ContentType     string         `json:"content_type" example:"application/octet-stream"`
+	Size            int64          `json:"size" example:"684"`
+	Format          string         `json:"format" example:"stl_binary"`
+	SourceHash      string         `json:"source_hash" example:"sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	OpenSCADVersion string         `json:"openscad_version,omitempty" example:"nightly"`
+	Options         ExportOptions  `json:"options"`
+	Parameters      map[string]any `json:"parameters,omitempty" swaggertype:"object"`
+	RenderSeconds   float64        `json:"render_seconds" example:"1.42"`
+	CreatedAt       time.Time      `json:"created_at"`
+	ExpiresAt       time.Time      `json:"expires_at"`
+}
+
+// ArtifactResponse describes a stored render and its signed download link
+type ArtifactResponse struct {
+	Artifact
+	URL string `json:"url" example:"https://scad.example.com/openscad/v1/artifacts/3f2a9c7e1b3d4c5a8e6f7a9b0c1d2e3f?expires=1767225600&sig=..."`
+}
+
+// CallbackAccepted is returned for requests with a callback_url, whose result
+// is delivered to the callback once the render finishes
+type CallbackAccepted struct {
+	CallbackID string `json:"callback_id" example:"8d0e6f2a4b1c3d5e7f9a0b2c4d6e8f1a"`
+	Status     string `json:"status" example:"accepted"`
+}
+
+// CallbackPayload is POSTed to a request's callback_url when its render
+// finishes
+type CallbackPayload struct {
+	CallbackID    string            `json:"callback_id" example:"8d0e6f2a4b1c3d5e7f9a0b2c4d6e8f1a"`
+	RequestID     string            `json:"request_id,omitempty" example:"4f9c2a7e1b3d4c5a8e6f7a9b0c1d2e3f"`
+	Operation     string            `json:"operation" example:"export" enums:"export,summary"`
+	Status        string            `json:"status" example:"succeeded" enums:"succeeded,failed"`
+	Format        string            `json:"format,omitempty" example:"stl_binary"`
+	RenderSeconds float64           `json:"render_seconds" example:"1.42"`
+	CompletedAt   time.Time         `json:"completed_at"`
+	StatusCode    int               `json:"status_code,omitempty" example:"500"`
+	Error         string            `json:"error,omitempty" example:"export failed"`
+	Diagnostics   string            `json:"diagnostics,omitempty" example:"openscad command failed: exit status 1, output: ERROR: Parser error"`
+	Summary       *Summary          `json:"summary,omitempty"`
+	SummaryRaw    json.RawMessage   `json:"summary_raw,omitempty" swaggertype:"object"`
+	Analysis      *MeshAnalysis     `json:"analysis,omitempty"`
+	Artifact      *ArtifactResponse `json:"artifact,omitempty"`
+	ContentType   string            `json:"content_type,omitempty" example:"application/octet-stream"`
+	Data          []byte            `json:"data,omitempty" swaggertype:"string" format:"base64"`
+}
+
+// HistoryEntry records one export or summary call
+type HistoryEntry struct {
+	ID              int64          `json:"id" example:"1042"`
+	CreatedAt       time.Time      `json:"created_at"`
+	RequestID       string         `json:"request_id,omitempty" example:"4f9c2a7e1b3d4c5a8e6f7a9b0c1d2e3f"`
+	Client          string         `json:"client" example:"ip:203.0.113.7"`
+	Operation       string         `json:"operation" example:"export" enums:"export,summary"`
+	Format          string         `json:"format" example:"stl_binary"`
+	RequestHash     string         `json:"request_hash" example:"sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"`
+	OpenSCADVersion string         `json:"openscad_version,omitempty" example:"nightly"`
+	Options         *ExportOptions `json:"options,omitempty"`
+	Parameters      map[string]any `json:"parameters,omitempty" swaggertype:"object"`
+	Status          string         `json:"status" example:"succeeded" enums:"succeeded,failed"`
+	DurationSeconds float64        `json:"duration_seconds" example:"1.42"`
+	ExitCode        *int           `json:"exit_code,omitempty" example:"0"`
+	OutputBytes     int64          `json:"output_bytes" example:"684"`
+	Error           string         `json:"error,omitempty" example:"openscad command timed out"`
+	Diagnostics     string         `json:"diagnostics,omitempty" example:"WARNING: Ignoring unknown variable 'w'"`
+}
+
+// HistoryResponse is a page of the render history, newest first
+type HistoryResponse struct {
+	Entries    []HistoryEntry `json:"entries"`
+	NextCursor string         `json:"next_cursor,omitempty" example:"1001"`
+}
+
+// DesignFile is a SCAD source file of a design
+type DesignFile struct {
+	Name    string `json:"name" example:"lib/gears.scad"`
+	Content string `json:"content" example:"module gear(teeth) { cylinder(r=teeth, h=2); }"`
+}
+
+// DesignRequest creates a design or a new version of it
+type DesignRequest struct {
+	Name        string         `json:"name" binding:"required" example:"Gearbox"`
+	Description string         `json:"description,omitempty" example:"Two-stage reduction gearbox"`
+	Main        string         `json:"main,omitempty" example:"main.scad"`
+	Files       []DesignFile   `json:"files" binding:"required"`
+	Assets      []Asset        `json:"assets,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty" swaggertype:"object"`
+	Format      string         `json:"format,omitempty" example:"stl_binary"`
+	Options     ExportOptions  `json:"options"`
+	Message     string         `json:"message,omitempty" example:"Thicker housing walls"`
+}
+
+// DesignVersion is an immutable snapshot of a design's sources and defaults.
+// Listings leave out the files and assets.
+type DesignVersion struct {
+	Version    int            `json:"version" example:"3"`
+	Message    string         `json:"message,omitempty" example:"Thicker housing walls"`
+	Main       string         `json:"main" example:"main.scad"`
+	Files      []DesignFile   `json:"files,omitempty"`
+	Assets     []Asset        `json:"assets,omitempty"`
+	Parameters map[string]any `json:"parameters,omitempty" swaggertype:"object"`
+	Format     string         `json:"format,omitempty" example:"stl_binary"`
+	Options    ExportOptions  `json:"options"`
+	SourceHash string         `json:"source_hash" example:"sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// Design is a saved design. Single designs carry their latest version;
+// listings leave it out.
+type Design struct {
+	ID            string         `json:"id" example:"5b1e0c9a7d3f4e2a8c6b4d2f0e1a3c5b"`
+	Name          string         `json:"name" example:"Gearbox"`
+	Description   string         `json:"description,omitempty" example:"Two-stage reduction gearbox"`
+	LatestVersion int            `json:"latest_version" example:"3"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	Latest        *DesignVersion `json:"latest,omitempty"`
+}
+
+// DesignList lists the saved designs, most recently updated first
+type DesignList struct {
+	Designs []Design `json:"designs"`
+}
+
+// DesignVersionList lists the versions of a design, newest first
+type DesignVersionList struct {
+	Versions []DesignVersion `json:"versions"`
+}
+
+// DesignExportRequest renders a design version. Unset fields fall back to
+// the version's defaults; parameters are merged over its default parameters.
+type DesignExportRequest struct {
+	Format          string         `json:"format,omitempty" example:"png"`
+	OpenSCADVersion string         `json:"openscad_version,omitempty" example:"nightly"`
+	Backend         string         `json:"backend,omitempty" example:"manifold" enums:"cgal,manifold"`
+	Features        []string       `json:"features,omitempty" example:"lazy-union"`
+	Parameters      map[string]any `json:"parameters,omitempty" swaggertype:"object"`
+	Options         *ExportOptions `json:"options,omitempty"`
+	Store           bool           `json:"store,omitempty" example:"false"`
+	CallbackURL     string         `json:"callback_url,omitempty" example:"https://ci.example.com/hooks/scad"`
+}
+
+// TemplateOption is an allowed value of a template parameter with a dropdown
+type TemplateOption struct {
+	Value any    `json:"value" swaggertype:"string" example:"round"`
+	Label string `json:"label,omitempty" example:"Round"`
+}
+
+// TemplateParameter describes a customizer parameter of a template
+type TemplateParameter struct {
+	Name        string           `json:"name" example:"width"`
+	Type        string           `json:"type" example:"number" enums:"number,string,boolean,vector"`
+	Default     any              `json:"default" swaggertype:"string" example:"12"`
+	Description string           `json:"description,omitempty" example:"Clip width in mm"`
+	Group       string           `json:"group,omitempty" example:"Dimensions"`
+	Min         *float64         `json:"min,omitempty" example:"5"`
+	Max         *float64         `json:"max,omitempty" example:"50"`
+	Step        *float64         `json:"step,omitempty" example:"0.5"`
+	MaxLength   *int             `json:"max_length,omitempty" example:"20"`
+	Options     []TemplateOption `json:"options,omitempty"`
+}
+
+// Template is a parametric model from the template catalog
+type Template struct {
+	Name        string              `json:"name" example:"cable_clip"`
+	Description string              `json:"description,omitempty" example:"Snap-on clip for round cables"`
+	Parameters  []TemplateParameter `json:"parameters"`
+}
+
+// TemplateList lists the template catalog by name
+type TemplateList struct {
+	Templates []Template `json:"templates"`
+}
+
+// TemplateExportRequest renders a template with parameter values
+type TemplateExportRequest struct {
+	Format          string         `json:"format" binding:"required" example:"stl_binary"`
+	OpenSCADVersion string         `json:"openscad_version,omitempty" example:"nightly"`
+	Backend         string         `json:"backend,omitempty" example:"manifold" enums:"cgal,manifold"`
+	Features        []string       `json:"features,omitempty" example:"lazy-union"`
+	Parameters      map[string]any `json:"parameters,omitempty" swaggertype:"object"`
+	Options         ExportOptions  `json:"options"`
+	Store           bool           `json:"store,omitempty" example:"false"`
+	CallbackURL     string         `json:"callback_url,omitempty" example:"https://ci.example.com/hooks/scad"`
+}
+
+// SweepRequest renders a model once for every combination of a parameter
+// grid, or once for every parameter set of an explicit list
+type SweepRequest struct {
+	ScadContent     string           `json:"scad_content" binding:"required" example:"cube([length, 20, hole]);"`
+	Format          string           `json:"format" binding:"required" example:"stl_binary"`
+	OpenSCADVersion string           `json:"openscad_version,omitempty" example:"nightly"`
+	Backend         string           `json:"backend,omitempty" example:"manifold" enums:"cgal,manifold"`
+	Features        []string         `json:"features,omitempty" example:"lazy-union"`
+	Parameters      map[string]any   `json:"parameters,omitempty" swaggertype:"object"`
+	Grid            map[string][]any `json:"grid,omitempty" swaggertype:"object"`
+	Sets            []map[string]any `json:"sets,omitempty" swaggertype:"array,object"`
+	Filename        string           `json:"filename,omitempty" example:"bracket_{length}_{hole}"`
+	SummaryType     string           `json:"summary_type,omitempty" example:"geometry" enums:"all,cache,time,camera,geometry,bounding-box,area"`
+	Assets          []Asset          `json:"assets,omitempty"`
+	Options         ExportOptions    `json:"options"`
+}
+
+// SweepVariant is the manifest entry of one rendered parameter set
+type SweepVariant struct {
+	Index           int            `json:"index" example:"1"`
+	File            string         `json:"file,omitempty" example:"bracket_40_3.stl"`
+	Parameters      map[string]any `json:"parameters" swaggertype:"object"`
+	Status          string         `json:"status" example:"succeeded" enums:"succeeded,failed"`
+	Error           string         `json:"error,omitempty"`
+	OutputBytes     int64          `json:"output_bytes" example:"684"`
+	DurationSeconds float64        `json:"duration_seconds" example:"0.42"`
+	Summary         *Summary       `json:"summary,omitempty"`
+}
+
+// SweepManifest describes the contents of a sweep archive
+type SweepManifest struct {
+	Format    string         `json:"format" example:"stl_binary"`
+	Variants  int            `json:"variants" example:"18"`
+	Succeeded int            `json:"succeeded" example:"17"`
+	Failed    int            `json:"failed" example:"1"`
+	Results   []SweepVariant `json:"results"`
 }
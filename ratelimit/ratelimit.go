@@ -0,0 +1,200 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Config contains the per-client limits. A zero limit disables that check.
+type Config struct {
+	// RequestsPerMinute is the number of requests a client may make per minute
+	RequestsPerMinute int
+	// RenderSeconds is the OpenSCAD wall time a client may consume per RenderPeriod
+	RenderSeconds float64
+	// RenderPeriod is the length of the render-seconds budget window
+	RenderPeriod time.Duration
+}
+
+// DefaultRenderPeriod is used when a render budget is set without a period
+const DefaultRenderPeriod = time.Hour
+
+const requestWindow = time.Minute
+
+// Usage describes a client's consumption of its limits
+type Usage struct {
+	Client string `json:"client" example:"ip:192.0.2.10"`
+
+	RequestsLimit     int       `json:"requests_limit" example:"60"`
+	RequestsUsed      int       `json:"requests_used" example:"12"`
+	RequestsRemaining int       `json:"requests_remaining" example:"48"`
+	RequestsReset     time.Time `json:"requests_reset"`
+
+	RenderSecondsLimit     float64   `json:"render_seconds_limit" example:"600"`
+	RenderSecondsUsed      float64   `json:"render_seconds_used" example:"42.5"`
+	RenderSecondsRemaining float64   `json:"render_seconds_remaining" example:"557.5"`
+	RenderReset            time.Time `json:"render_reset"`
+
+	// RetryAfter is set when a request was rejected
+	RetryAfter time.Duration `json:"-"`
+}
+
+type clientState struct {
+	windowStart time.Time
+	requests    int
+	periodStart time.Time
+	renderUsed  time.Duration
+}
+
+// Limiter tracks request counts and render time per client
+type Limiter struct {
+	cfg       Config
+	mu        sync.Mutex
+	clients   map[string]*clientState
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// New creates a new limiter with the given configuration
+func New(cfg Config) *Limiter {
+	if cfg.RenderPeriod <= 0 {
+		cfg.RenderPeriod = DefaultRenderPeriod
+	}
+	return &Limiter{
+		cfg:     cfg,
+		clients: make(map[string]*clientState),
+		now:     time.Now,
+	}
+}
+
+// SetConfig replaces the limits; usage already recorded is kept
+func (l *Limiter) SetConfig(cfg Config) {
+	if cfg.RenderPeriod <= 0 {
+		cfg.RenderPeriod = DefaultRenderPeriod
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cfg = cfg
+}
+
+// Config returns the limits currently in force
+func (l *Limiter) Config() Config {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.cfg
+}
+
+// Enabled reports whether any limit is configured
+func (l *Limiter) Enabled() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.cfg.RequestsPerMinute > 0 || l.cfg.RenderSeconds > 0
+}
+
+// Allow counts a request for client and reports whether it may proceed.
+// Requests are rejected when the client has exhausted either its request
+// allowance or its render-seconds budget.
+func (l *Limiter) Allow(client string) (Usage, bool) {
+	return l.AllowN(client, 1)
+}
+
+// AllowN counts n requests for client and reports whether they may proceed.
+// Either all n are counted or, when they would exceed the request allowance,
+// none are.
+func (l *Limiter) AllowN(client string, n int) (Usage, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	st := l.state(client, now)
+
+	if l.cfg.RenderSeconds > 0 && st.renderUsed.Seconds() >= l.cfg.RenderSeconds {
+		usage := l.usage(client, st)
+		usage.RetryAfter = st.periodStart.Add(l.cfg.RenderPeriod).Sub(now)
+		return usage, false
+	}
+	if l.cfg.RequestsPerMinute > 0 && st.requests+n > l.cfg.RequestsPerMinute {
+		usage := l.usage(client, st)
+		usage.RetryAfter = st.windowStart.Add(requestWindow).Sub(now)
+		return usage, false
+	}
+
+	st.requests += n
+	return l.usage(client, st), true
+}
+
+// AddRenderTime charges d of OpenSCAD wall time to client's budget
+func (l *Limiter) AddRenderTime(client string, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	st := l.state(client, l.now())
+	st.renderUsed += d
+}
+
+// Usage returns the current usage for client without counting a request
+func (l *Limiter) Usage(client string) Usage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.usage(client, l.state(client, l.now()))
+}
+
+// state returns the client's state, resetting any windows that have elapsed
+func (l *Limiter) state(client string, now time.Time) *clientState {
+	st, ok := l.clients[client]
+	if !ok {
+		st = &clientState{windowStart: now, periodStart: now}
+		l.clients[client] = st
+	}
+	if now.Sub(st.windowStart) >= requestWindow {
+		st.windowStart = now
+		st.requests = 0
+	}
+	if now.Sub(st.periodStart) >= l.cfg.RenderPeriod {
+		st.periodStart = now
+		st.renderUsed = 0
+	}
+	return st
+}
+
+// sweep drops clients whose windows have all elapsed, at most once per period
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.cfg.RenderPeriod {
+		return
+	}
+	l.lastSweep = now
+	for client, st := range l.clients {
+		if now.Sub(st.windowStart) >= requestWindow && now.Sub(st.periodStart) >= l.cfg.RenderPeriod {
+			delete(l.clients, client)
+		}
+	}
+}
+
+func (l *Limiter) usage(client string, st *clientState) Usage {
+	usage := Usage{
+		Client:             client,
+		RequestsLimit:      l.cfg.RequestsPerMinute,
+		RequestsUsed:       st.requests,
+		RequestsReset:      st.windowStart.Add(requestWindow),
+		RenderSecondsLimit: l.cfg.RenderSeconds,
+		RenderSecondsUsed:  roundSeconds(st.renderUsed.Seconds()),
+		RenderReset:        st.periodStart.Add(l.cfg.RenderPeriod),
+	}
+	if l.cfg.RequestsPerMinute > 0 {
+		usage.RequestsRemaining = max(l.cfg.RequestsPerMinute-st.requests, 0)
+	}
+	if l.cfg.RenderSeconds > 0 {
+		usage.RenderSecondsRemaining = roundSeconds(math.Max(l.cfg.RenderSeconds-st.renderUsed.Seconds(), 0))
+	}
+	return usage
+}
+
+func roundSeconds(s float64) float64 {
+	return math.Round(s*1000) / 1000
+}
@@ -0,0 +1,166 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestLimiter creates a limiter with a controllable clock
+func newTestLimiter(cfg Config) (*Limiter, *time.Time) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := New(cfg)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestAllow_RequestsPerMinute(t *testing.T) {
+	l, now := newTestLimiter(Config{RequestsPerMinute: 2})
+
+	for i := 0; i < 2; i++ {
+		if _, ok := l.Allow("ip:1"); !ok {
+			t.Fatalf("Expected request %d to be allowed", i+1)
+		}
+	}
+
+	usage, ok := l.Allow("ip:1")
+	if ok {
+		t.Fatalf("Expected third request to be rejected")
+	}
+	if usage.RequestsRemaining != 0 {
+		t.Errorf("Expected 0 requests remaining, got %d", usage.RequestsRemaining)
+	}
+	if usage.RetryAfter != time.Minute {
+		t.Errorf("Expected retry after 1m, got %v", usage.RetryAfter)
+	}
+
+	// Other clients are unaffected
+	if _, ok := l.Allow("ip:2"); !ok {
+		t.Errorf("Expected other client to be allowed")
+	}
+
+	// The window resets after a minute
+	*now = now.Add(time.Minute)
+	if _, ok := l.Allow("ip:1"); !ok {
+		t.Errorf("Expected request to be allowed after window reset")
+	}
+}
+
+func TestAllowN_AllOrNothing(t *testing.T) {
+	l, _ := newTestLimiter(Config{RequestsPerMinute: 5})
+
+	if usage, ok := l.AllowN("ip:1", 3); !ok || usage.RequestsRemaining != 2 {
+		t.Fatalf("Expected 3 requests to be allowed with 2 remaining, got %v %+v", ok, usage)
+	}
+	usage, ok := l.AllowN("ip:1", 3)
+	if ok {
+		t.Fatalf("Expected 3 more requests to be rejected")
+	}
+	if usage.RequestsUsed != 3 {
+		t.Errorf("Expected a rejected charge to count nothing, got %d used", usage.RequestsUsed)
+	}
+	if _, ok := l.AllowN("ip:1", 2); !ok {
+		t.Errorf("Expected the remaining 2 requests to be allowed")
+	}
+}
+
+func TestAllow_RenderBudget(t *testing.T) {
+	l, now := newTestLimiter(Config{RenderSeconds: 10, RenderPeriod: time.Hour})
+
+	if _, ok := l.Allow("ip:1"); !ok {
+		t.Fatalf("Expected first request to be allowed")
+	}
+	l.AddRenderTime("ip:1", 4*time.Second)
+
+	usage := l.Usage("ip:1")
+	if usage.RenderSecondsUsed != 4 {
+		t.Errorf("Expected 4 render seconds used, got %v", usage.RenderSecondsUsed)
+	}
+	if usage.RenderSecondsRemaining != 6 {
+		t.Errorf("Expected 6 render seconds remaining, got %v", usage.RenderSecondsRemaining)
+	}
+
+	l.AddRenderTime("ip:1", 7*time.Second)
+	*now = now.Add(20 * time.Minute)
+
+	usage, ok := l.Allow("ip:1")
+	if ok {
+		t.Fatalf("Expected request to be rejected once budget is exhausted")
+	}
+	if usage.RenderSecondsRemaining != 0 {
+		t.Errorf("Expected 0 render seconds remaining, got %v", usage.RenderSecondsRemaining)
+	}
+	if usage.RetryAfter != 40*time.Minute {
+		t.Errorf("Expected retry after 40m, got %v", usage.RetryAfter)
+	}
+
+	*now = now.Add(40 * time.Minute)
+	if _, ok := l.Allow("ip:1"); !ok {
+		t.Errorf("Expected request to be allowed after budget period reset")
+	}
+}
+
+func TestUsage_DoesNotCountRequest(t *testing.T) {
+	l, _ := newTestLimiter(Config{RequestsPerMinute: 5})
+
+	l.Usage("ip:1")
+	usage := l.Usage("ip:1")
+	if usage.RequestsUsed != 0 {
+		t.Errorf("Expected 0 requests used, got %d", usage.RequestsUsed)
+	}
+	if usage.RequestsRemaining != 5 {
+		t.Errorf("Expected 5 requests remaining, got %d", usage.RequestsRemaining)
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want bool
+	}{
+		{"No limits", Config{}, false},
+		{"Requests only", Config{RequestsPerMinute: 10}, true},
+		{"Render budget only", Config{RenderSeconds: 60}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New(tt.cfg).Enabled(); got != tt.want {
+				t.Errorf("Enabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSweep_DropsIdleClients(t *testing.T) {
+	l, now := newTestLimiter(Config{RequestsPerMinute: 5, RenderPeriod: time.Minute})
+
+	l.Allow("ip:1")
+	*now = now.Add(2 * time.Minute)
+	l.Allow("ip:2")
+
+	if _, exists := l.clients["ip:1"]; exists {
+		t.Errorf("Expected idle client to be swept")
+	}
+	if _, exists := l.clients["ip:2"]; !exists {
+		t.Errorf("Expected active client to be kept")
+	}
+}
+
+func TestSetConfig_KeepsUsage(t *testing.T) {
+	l, _ := newTestLimiter(Config{RequestsPerMinute: 1})
+
+	l.Allow("ip:1")
+	if _, ok := l.Allow("ip:1"); ok {
+		t.Fatalf("Expected second request to be rejected")
+	}
+
+	l.SetConfig(Config{RequestsPerMinute: 3})
+	usage, ok := l.Allow("ip:1")
+	if !ok {
+		t.Fatalf("Expected request to be allowed after raising the limit")
+	}
+	if usage.RequestsUsed != 2 {
+		t.Errorf("Expected 2 requests used, got %d", usage.RequestsUsed)
+	}
+}
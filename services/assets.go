@@ -0,0 +1,113 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+const (
+	// DefaultMaxAssetBytes is the largest asset accepted when none is configured
+	DefaultMaxAssetBytes = 10 << 20
+	// DefaultMaxAssets is the number of assets accepted per request when none
+	// is configured
+	DefaultMaxAssets = 20
+	// maxAssetDepth bounds the number of directories in an asset name
+	maxAssetDepth = 4
+)
+
+// AssetExtensions lists the file types that can be uploaded for import() and
+// surface()
+var AssetExtensions = []string{".stl", ".3mf", ".off", ".obj", ".svg", ".dxf", ".png", ".dat"}
+
+// libraryExtensions lists the file types of export request libraries
+var libraryExtensions = []string{".scad"}
+
+var (
+	// ErrInvalidAsset is returned when an asset has an unsafe name or an
+	// unsupported type
+	ErrInvalidAsset = errors.New("invalid asset")
+	// ErrAssetTooLarge is returned when a request carries an asset over the
+	// size limit or too many assets
+	ErrAssetTooLarge = errors.New("asset too large")
+)
+
+// assetSegment restricts each element of an asset name to a safe character set
+var assetSegment = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+// validateAssets checks the names, types, sizes and count of assets and
+// libraries before any work directory is created
+func (s *OpenSCADService) validateAssets(assets, libraries []models.Asset) error {
+	if n := len(assets) + len(libraries); n > s.maxAssets {
+		return fmt.Errorf("%w: %d assets sent, at most %d allowed", ErrAssetTooLarge, n, s.maxAssets)
+	}
+
+	seen := make(map[string]bool, len(assets)+len(libraries))
+	for i, a := range slices.Concat(assets, libraries) {
+		extensions := AssetExtensions
+		if i >= len(assets) {
+			extensions = libraryExtensions
+		}
+		if err := ValidateAssetName(a.Name, extensions); err != nil {
+			return err
+		}
+		key := strings.ToLower(a.Name)
+		if seen[key] {
+			return fmt.Errorf("%w: duplicate name %q", ErrInvalidAsset, a.Name)
+		}
+		seen[key] = true
+
+		if int64(len(a.Data)) > s.maxAssetBytes {
+			return fmt.Errorf("%w: %s is %d bytes, limit is %d", ErrAssetTooLarge, a.Name, len(a.Data), s.maxAssetBytes)
+		}
+	}
+	return nil
+}
+
+// ValidateAssetName accepts relative slash-separated names such as
+// "parts/bracket.stl" whose extension is one of extensions and which don't
+// collide with the files the service writes itself
+func ValidateAssetName(name string, extensions []string) error {
+	segments := strings.Split(name, "/")
+	if len(segments) > maxAssetDepth+1 {
+		return fmt.Errorf("%w: %q is nested too deeply", ErrInvalidAsset, name)
+	}
+	for _, seg := range segments {
+		if !assetSegment.MatchString(seg) {
+			return fmt.Errorf("%w: %q must be a relative path of letters, digits, '.', '_' and '-'", ErrInvalidAsset, name)
+		}
+	}
+
+	if ext := strings.ToLower(path.Ext(name)); !slices.Contains(extensions, ext) {
+		return fmt.Errorf("%w: %q must have one of the extensions %s", ErrInvalidAsset, name, strings.Join(extensions, ", "))
+	}
+
+	// The SCAD input, render output and summary placeholder live next to the
+	// assets in the work directory
+	first := strings.ToLower(segments[0])
+	if len(segments) == 1 && (strings.HasPrefix(first, "output.") || first == "dummy.stl" || first == "input.scad") {
+		return fmt.Errorf("%w: %q is reserved", ErrInvalidAsset, name)
+	}
+	return nil
+}
+
+// writeAssets stores validated assets below dir
+func writeAssets(dir string, assets []models.Asset) error {
+	for _, a := range assets {
+		p := filepath.Join(dir, filepath.FromSlash(a.Name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return fmt.Errorf("failed to write asset %s: %w", a.Name, err)
+		}
+		if err := os.WriteFile(p, a.Data, 0644); err != nil {
+			return fmt.Errorf("failed to write asset %s: %w", a.Name, err)
+		}
+	}
+	return nil
+}
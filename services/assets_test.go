@@ -0,0 +1,104 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+func TestValidateAssetName(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"bracket.stl", false},
+		{"parts/vendor/Bracket-v2.STL", false},
+		{"logo.svg", false},
+		{"heightmap.png", false},
+		{"terrain.dat", false},
+		{"../escape.stl", true},
+		{"/etc/passwd.stl", true},
+		{"parts//bracket.stl", true},
+		{".hidden.stl", true},
+		{"input.scad", true},
+		{"lib/gears.scad", true},
+		{"model.step", true},
+		{"output.png", true},
+		{"dummy.stl", true},
+		{"parts/output.png", false},
+		{"a/b/c/d/e/deep.stl", true},
+		{"with space.stl", true},
+		{"", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAssetName(tt.name, AssetExtensions)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateAssetName(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidAsset) {
+				t.Errorf("Expected ErrInvalidAsset, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateAssets(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxAssetBytes = 8
+	cfg.MaxAssets = 2
+	service := NewOpenSCADServiceWithConfig(cfg)
+
+	tests := []struct {
+		name      string
+		assets    []models.Asset
+		libraries []models.Asset
+		wantErr   error
+	}{
+		{"None", nil, nil, nil},
+		{"Within limits", []models.Asset{{Name: "a.stl", Data: []byte("solid")}, {Name: "b.svg", Data: []byte("<svg/>")}}, nil, nil},
+		{"Too large", []models.Asset{{Name: "a.stl", Data: []byte("solid too big")}}, nil, ErrAssetTooLarge},
+		{"Too many", []models.Asset{{Name: "a.stl"}, {Name: "b.stl"}, {Name: "c.stl"}}, nil, ErrAssetTooLarge},
+		{"Duplicate", []models.Asset{{Name: "a.stl"}, {Name: "A.STL"}}, nil, ErrInvalidAsset},
+		{"Bad name", []models.Asset{{Name: "../a.stl"}}, nil, ErrInvalidAsset},
+		{"SCAD asset", []models.Asset{{Name: "lib/gears.scad"}}, nil, ErrInvalidAsset},
+		{"Library", []models.Asset{{Name: "a.stl"}}, []models.Asset{{Name: "lib/gears.scad"}}, nil},
+		{"Library that isn't SCAD", nil, []models.Asset{{Name: "lib/a.stl"}}, ErrInvalidAsset},
+		{"Libraries count as assets", []models.Asset{{Name: "a.stl"}, {Name: "b.stl"}}, []models.Asset{{Name: "c.scad"}}, ErrAssetTooLarge},
+		{"Library named like an asset", []models.Asset{{Name: "a.stl"}}, []models.Asset{{Name: "input.scad"}}, ErrInvalidAsset},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.validateAssets(tt.assets, tt.libraries)
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestWriteAssets(t *testing.T) {
+	dir := t.TempDir()
+	assets := []models.Asset{
+		{Name: "logo.svg", Data: []byte("<svg/>")},
+		{Name: "parts/bracket.stl", Data: []byte("solid bracket")},
+	}
+
+	if err := writeAssets(dir, assets); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, a := range assets {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(a.Name)))
+		if err != nil || string(data) != string(a.Data) {
+			t.Errorf("Expected %s to contain %q, got %q (%v)", a.Name, a.Data, data, err)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+//go:build unix
+
+package services
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+func TestExport_Assets(t *testing.T) {
+	// The fake OpenSCAD copies the imported asset to the output file, the
+	// way import() would read it relative to the input file
+	service := newFakeService(t, `for last; do :; done
+out=""
+while [ $# -gt 0 ]; do [ "$1" = "-o" ] && out="$2"; shift; done
+cp "$(dirname "$last")/parts/bracket.stl" "$out"`)
+
+	result, err := service.Export(context.Background(), &models.ExportRequest{
+		ScadContent: `import("parts/bracket.stl");`,
+		Format:      "stl_ascii",
+		Assets:      []models.Asset{{Name: "parts/bracket.stl", Data: []byte("solid bracket")}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(result.Data) != "solid bracket" {
+		t.Errorf("Expected the asset to be readable by openscad, got %q", result.Data)
+	}
+
+	entries, err := os.ReadDir(service.tempDir)
+	if err != nil {
+		t.Fatalf("Failed to read temp dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected work directory with assets to be removed, found %d entries", len(entries))
+	}
+}
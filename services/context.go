@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"time"
+)
+
+type renderTimeRecorderKey struct{}
+
+// RenderTimeRecorder receives the wall time of every OpenSCAD invocation made
+// on behalf of a request
+type RenderTimeRecorder func(d time.Duration)
+
+// WithRenderTimeRecorder returns a context that reports OpenSCAD wall time to rec
+func WithRenderTimeRecorder(ctx context.Context, rec RenderTimeRecorder) context.Context {
+	return context.WithValue(ctx, renderTimeRecorderKey{}, rec)
+}
+
+// recordRenderTime reports d to the recorder attached to ctx, if any
+func recordRenderTime(ctx context.Context, d time.Duration) {
+	if rec, ok := ctx.Value(renderTimeRecorderKey{}).(RenderTimeRecorder); ok && rec != nil {
+		rec(d)
+	}
+}
+
+type renderResultRecorderKey struct{}
+
+// RenderResult describes one finished OpenSCAD invocation
+type RenderResult struct {
+	// ExitCode is the process exit code, -1 if it was killed or didn't start
+	ExitCode int
+	// Duration is the process wall time
+	Duration time.Duration
+	// Output is the combined stdout and stderr, holding OpenSCAD's warnings
+	// and errors
+	Output string
+}
+
+// RenderResultRecorder receives the result of every OpenSCAD invocation made
+// on behalf of a request
+type RenderResultRecorder func(r RenderResult)
+
+// WithRenderResultRecorder returns a context that reports OpenSCAD results to rec
+func WithRenderResultRecorder(ctx context.Context, rec RenderResultRecorder) context.Context {
+	return context.WithValue(ctx, renderResultRecorderKey{}, rec)
+}
+
+// recordRenderResult reports r to the recorder attached to ctx, if any
+func recordRenderResult(ctx context.Context, r RenderResult) {
+	if rec, ok := ctx.Value(renderResultRecorderKey{}).(RenderResultRecorder); ok && rec != nil {
+		rec(r)
+	}
+}
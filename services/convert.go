@@ -2,42 +2,95 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"image"
 	"image/png"
-	"log"
+	"time"
 
 	"github.com/gen2brain/avif"
 	"github.com/gen2brain/webp"
+	"github.com/stevexciv/scad-server/logging"
+	"github.com/stevexciv/scad-server/metrics"
+	"github.com/stevexciv/scad-server/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-// convertPNGToWebP takes raw PNG bytes and returns WebP-encoded bytes.
-func convertPNGToWebP(pngData []byte) ([]byte, error) {
-	img, err := png.Decode(bytes.NewReader(pngData))
+// convertPNGToWebP takes raw PNG bytes and returns WebP-encoded bytes at the
+// given quality (0-100).
+func convertPNGToWebP(ctx context.Context, pngData []byte, quality int) (out []byte, err error) {
+	ctx, span := tracing.Start(ctx, "convert.webp", attribute.Int("input.bytes", len(pngData)))
+	defer func() { tracing.End(span, err) }()
+
+	start := time.Now()
+	img, err := decodePNG(ctx, pngData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode PNG: %w", err)
+		return nil, err
 	}
 
+	_, encodeSpan := tracing.Start(ctx, "webp.encode")
 	var buf bytes.Buffer
-	if err := webp.Encode(&buf, img, webp.Options{Quality: 80}); err != nil {
-		return nil, fmt.Errorf("failed to encode WebP: %w", err)
+	if err := webp.Encode(&buf, img, webp.Options{Quality: quality}); err != nil {
+		err = fmt.Errorf("failed to encode WebP: %w", err)
+		tracing.End(encodeSpan, err)
+		return nil, err
 	}
+	tracing.End(encodeSpan, nil)
 
-	log.Printf("[Convert] PNG (%d bytes) -> WebP (%d bytes)", len(pngData), buf.Len())
+	span.SetAttributes(attribute.Int("output.bytes", buf.Len()))
+	observeConversion("webp", start, len(pngData), buf.Len())
+	logging.FromContext(ctx).Debug("converted PNG", "format", "webp", "input_bytes", len(pngData), "output_bytes", buf.Len())
 	return buf.Bytes(), nil
 }
 
-// convertPNGToAVIF takes raw PNG bytes and returns AVIF-encoded bytes.
-func convertPNGToAVIF(pngData []byte) ([]byte, error) {
-	img, err := png.Decode(bytes.NewReader(pngData))
+// convertPNGToAVIF takes raw PNG bytes and returns AVIF-encoded bytes at the
+// given quality (0-100, where 100 is lossless).
+func convertPNGToAVIF(ctx context.Context, pngData []byte, quality int) (out []byte, err error) {
+	ctx, span := tracing.Start(ctx, "convert.avif", attribute.Int("input.bytes", len(pngData)))
+	defer func() { tracing.End(span, err) }()
+
+	start := time.Now()
+	img, err := decodePNG(ctx, pngData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode PNG: %w", err)
+		return nil, err
 	}
 
+	_, encodeSpan := tracing.Start(ctx, "avif.encode")
 	var buf bytes.Buffer
-	if err := avif.Encode(&buf, img); err != nil {
-		return nil, fmt.Errorf("failed to encode AVIF: %w", err)
+	opts := avif.Options{
+		Quality:           quality,
+		QualityAlpha:      quality,
+		Speed:             avif.DefaultSpeed,
+		ChromaSubsampling: image.YCbCrSubsampleRatio420,
 	}
+	if err := avif.Encode(&buf, img, opts); err != nil {
+		err = fmt.Errorf("failed to encode AVIF: %w", err)
+		tracing.End(encodeSpan, err)
+		return nil, err
+	}
+	tracing.End(encodeSpan, nil)
 
-	log.Printf("[Convert] PNG (%d bytes) -> AVIF (%d bytes)", len(pngData), buf.Len())
+	span.SetAttributes(attribute.Int("output.bytes", buf.Len()))
+	observeConversion("avif", start, len(pngData), buf.Len())
+	logging.FromContext(ctx).Debug("converted PNG", "format", "avif", "input_bytes", len(pngData), "output_bytes", buf.Len())
 	return buf.Bytes(), nil
 }
+
+// decodePNG decodes the PNG rendered by OpenSCAD ahead of re-encoding
+func decodePNG(ctx context.Context, pngData []byte) (img image.Image, err error) {
+	_, span := tracing.Start(ctx, "png.decode")
+	defer func() { tracing.End(span, err) }()
+
+	img, err = png.Decode(bytes.NewReader(pngData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode PNG: %w", err)
+	}
+	return img, nil
+}
+
+// observeConversion records the duration and sizes of a successful conversion
+func observeConversion(format string, start time.Time, inputSize, outputSize int) {
+	metrics.ConversionDuration.WithLabelValues(format).Observe(time.Since(start).Seconds())
+	metrics.ConversionBytes.WithLabelValues(format, "input").Observe(float64(inputSize))
+	metrics.ConversionBytes.WithLabelValues(format, "output").Observe(float64(outputSize))
+}
@@ -2,10 +2,16 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"image"
 	"image/color"
 	"image/png"
 	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 // createTestPNG creates a minimal valid PNG image for testing.
@@ -30,7 +36,7 @@ func TestConvertPNGToWebP(t *testing.T) {
 			t.Fatalf("Failed to create test PNG: %v", err)
 		}
 
-		webpData, err := convertPNGToWebP(pngData)
+		webpData, err := convertPNGToWebP(context.Background(), pngData, DefaultWebPQuality)
 		if err != nil {
 			t.Fatalf("convertPNGToWebP() error = %v", err)
 		}
@@ -52,7 +58,7 @@ func TestConvertPNGToWebP(t *testing.T) {
 	})
 
 	t.Run("Invalid input", func(t *testing.T) {
-		_, err := convertPNGToWebP([]byte("not a png"))
+		_, err := convertPNGToWebP(context.Background(), []byte("not a png"), DefaultWebPQuality)
 		if err == nil {
 			t.Error("Expected error for invalid PNG input")
 		}
@@ -66,7 +72,7 @@ func TestConvertPNGToAVIF(t *testing.T) {
 			t.Fatalf("Failed to create test PNG: %v", err)
 		}
 
-		avifData, err := convertPNGToAVIF(pngData)
+		avifData, err := convertPNGToAVIF(context.Background(), pngData, DefaultAVIFQuality)
 		if err != nil {
 			t.Fatalf("convertPNGToAVIF() error = %v", err)
 		}
@@ -85,9 +91,33 @@ func TestConvertPNGToAVIF(t *testing.T) {
 	})
 
 	t.Run("Invalid input", func(t *testing.T) {
-		_, err := convertPNGToAVIF([]byte("not a png"))
+		_, err := convertPNGToAVIF(context.Background(), []byte("not a png"), DefaultAVIFQuality)
 		if err == nil {
 			t.Error("Expected error for invalid PNG input")
 		}
 	})
 }
+
+func TestConvertPNGToWebP_Spans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	pngData, err := createTestPNG(4, 4)
+	if err != nil {
+		t.Fatalf("Failed to create test PNG: %v", err)
+	}
+	if _, err := convertPNGToWebP(context.Background(), pngData, DefaultWebPQuality); err != nil {
+		t.Fatalf("convertPNGToWebP() error = %v", err)
+	}
+
+	names := map[string]bool{}
+	for _, span := range recorder.Ended() {
+		names[span.Name()] = true
+	}
+	for _, want := range []string{"convert.webp", "png.decode", "webp.encode"} {
+		if !names[want] {
+			t.Errorf("Expected span %q, got %v", want, names)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package services
+
+// diskFree is not supported on platforms where syscall has no portable
+// statfs; the free space check then reports itself as unsupported
+func diskFree(dir string) (uint64, error) {
+	return 0, errDiskFreeUnsupported
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// BackendCGAL is OpenSCAD's original geometry backend
+	BackendCGAL = "cgal"
+	// BackendManifold is the Manifold geometry backend of newer OpenSCAD builds
+	BackendManifold = "manifold"
+)
+
+// DefaultAllowedFeatures lists the experimental features requests may enable
+// when no allowlist is configured
+var DefaultAllowedFeatures = []string{"lazy-union", "roof", "textmetrics"}
+
+// ErrUnsupportedFeature is returned when a request selects a backend or
+// experimental feature that is not allowed or not available in the selected
+// OpenSCAD installation
+var ErrUnsupportedFeature = errors.New("unsupported render option")
+
+// renderArgs validates the backend and experimental features of a request,
+// falling back to the server defaults for those it leaves unset, and returns
+// the OpenSCAD arguments selecting them
+func (s *OpenSCADService) renderArgs(ctx context.Context, version, backend string, features []string) ([]string, error) {
+	defaultBackend, defaultFeatures, allowed := s.renderDefaults()
+	if backend == "" {
+		backend = defaultBackend
+	}
+	if features == nil {
+		features = defaultFeatures
+	}
+
+	if backend != "" && backend != BackendCGAL && backend != BackendManifold {
+		return nil, fmt.Errorf("%w: backend %q, expected %s or %s", ErrUnsupportedFeature, backend, BackendCGAL, BackendManifold)
+	}
+	for _, f := range features {
+		if !slices.Contains(allowed, f) {
+			return nil, fmt.Errorf("%w: feature %q is not allowed", ErrUnsupportedFeature, f)
+		}
+	}
+	if backend == "" && len(features) == 0 {
+		return nil, nil
+	}
+
+	info, err := s.inspect(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+
+	var args []string
+	if backend != "" {
+		if !slices.Contains(info.Backends, backend) {
+			return nil, fmt.Errorf("%w: backend %q is not available in openscad %s", ErrUnsupportedFeature, backend, info.Version)
+		}
+		// Builds without --backend only have CGAL, which needs no flag
+		if len(info.Backends) > 1 {
+			args = append(args, "--backend="+backend)
+		}
+	}
+	for _, f := range features {
+		if !slices.Contains(info.Features, f) {
+			return nil, fmt.Errorf("%w: feature %q is not available in openscad %s", ErrUnsupportedFeature, f, info.Version)
+		}
+		args = append(args, "--enable="+f)
+	}
+	return args, nil
+}
+
+// renderDefaults returns a consistent snapshot of the server-wide backend,
+// features and feature allowlist
+func (s *OpenSCADService) renderDefaults() (backend string, features, allowed []string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.backend, s.features, s.allowedFeatures
+}
+
+// CheckRenderDefaults verifies that the default backend and features are
+// available in the named installation ("" for the default)
+func (s *OpenSCADService) CheckRenderDefaults(ctx context.Context, version string) error {
+	_, err := s.renderArgs(ctx, version, "", nil)
+	return err
+}
+
+// parseBackends reports the geometry backends of a build from its --help
+// output. Builds that predate --backend only have CGAL.
+func parseBackends(help string) []string {
+	for _, line := range strings.Split(help, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "--backend ") {
+			return []string{BackendCGAL, BackendManifold}
+		}
+	}
+	return []string{BackendCGAL}
+}
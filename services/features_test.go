@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+func TestParseBackends(t *testing.T) {
+	tests := []struct {
+		name string
+		help string
+		want []string
+	}{
+		{
+			name: "Nightly with --backend",
+			help: "  --enable arg   enable experimental features (specify 'all' for enabling all experimental features): roof | textmetrics\n  --backend arg  3D rendering backend to use: 'CGAL' (old/slow) [default] or 'Manifold' (new/fast)\n",
+			want: []string{BackendCGAL, BackendManifold},
+		},
+		{
+			name: "Release without --backend",
+			help: "  --enable arg   enable experimental features: roof | lazy-union\n",
+			want: []string{BackendCGAL},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseBackends(tt.help); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// newFeatureService returns a service whose default installation is already
+// inspected, so renderArgs never runs OpenSCAD
+func newFeatureService(cfg Config, info *models.OpenSCADInfo) *OpenSCADService {
+	service := NewOpenSCADServiceWithConfig(cfg)
+	service.info[DefaultVersionName] = info
+	return service
+}
+
+func TestRenderArgs(t *testing.T) {
+	nightly := &models.OpenSCADInfo{
+		Version:  "2025.03.15",
+		Backends: []string{BackendCGAL, BackendManifold},
+		Features: []string{"lazy-union", "roof", "textmetrics"},
+	}
+	release := &models.OpenSCADInfo{
+		Version:  "2021.01",
+		Backends: []string{BackendCGAL},
+		Features: []string{"lazy-union", "roof"},
+	}
+
+	withDefaults := DefaultConfig()
+	withDefaults.Backend = BackendManifold
+	withDefaults.Features = []string{"lazy-union"}
+
+	tests := []struct {
+		name     string
+		cfg      Config
+		info     *models.OpenSCADInfo
+		backend  string
+		features []string
+		want     []string
+		wantErr  bool
+	}{
+		{"Nothing requested", DefaultConfig(), nightly, "", nil, nil, false},
+		{"Manifold and features", DefaultConfig(), nightly, BackendManifold, []string{"roof", "textmetrics"}, []string{"--backend=manifold", "--enable=roof", "--enable=textmetrics"}, false},
+		{"Server defaults", withDefaults, nightly, "", nil, []string{"--backend=manifold", "--enable=lazy-union"}, false},
+		{"Empty list disables default features", withDefaults, nightly, BackendCGAL, []string{}, []string{"--backend=cgal"}, false},
+		{"CGAL without --backend", DefaultConfig(), release, BackendCGAL, nil, nil, false},
+		{"Manifold unavailable", DefaultConfig(), release, BackendManifold, nil, nil, true},
+		{"Feature unavailable", DefaultConfig(), release, "", []string{"textmetrics"}, nil, true},
+		{"Feature not allowed", DefaultConfig(), nightly, "", []string{"manifold"}, nil, true},
+		{"Unknown backend", DefaultConfig(), nightly, "opencsg", nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newFeatureService(tt.cfg, tt.info)
+			got, err := service.renderArgs(context.Background(), "", tt.backend, tt.features)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("renderArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnsupportedFeature) {
+					t.Errorf("Expected ErrUnsupportedFeature, got %v", err)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCheckRenderDefaults(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Backend = BackendManifold
+	service := newFeatureService(cfg, &models.OpenSCADInfo{Backends: []string{BackendCGAL}})
+
+	if err := service.CheckRenderDefaults(context.Background(), ""); !errors.Is(err, ErrUnsupportedFeature) {
+		t.Errorf("Expected ErrUnsupportedFeature, got %v", err)
+	}
+
+	cfg.Backend = ""
+	service.UpdateConfig(cfg)
+	if err := service.CheckRenderDefaults(context.Background(), ""); err != nil {
+		t.Errorf("Expected no error after reload, got %v", err)
+	}
+}
@@ -0,0 +1,391 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+// fontConfigName is the fontconfig file written into the font directory
+const fontConfigName = "fonts.conf"
+
+// systemFontConfig is included by the generated fontconfig file so system
+// fonts stay visible when FONTCONFIG_FILE is not already set
+const systemFontConfig = "/etc/fonts/fonts.conf"
+
+var (
+	// ErrFontsDisabled is returned by font management when no font directory
+	// is configured
+	ErrFontsDisabled = errors.New("font management is disabled")
+	// ErrInvalidFont is returned when an upload is not a TrueType or OpenType font
+	ErrInvalidFont = errors.New("invalid font")
+	// ErrFontExists is returned when uploading a font whose file name is taken
+	ErrFontExists = errors.New("font already exists")
+	// ErrFontNotFound is returned when deleting a font that was not uploaded
+	ErrFontNotFound = errors.New("font not found")
+	// ErrFontQuotaExceeded is returned when an upload would take the managed
+	// fonts past their total size limit
+	ErrFontQuotaExceeded = errors.New("font quota exceeded")
+	// ErrMissingFont is returned in strict font mode when the SCAD source
+	// asks for a font family fontconfig doesn't know
+	ErrMissingFont = errors.New("font not available")
+)
+
+var (
+	// fontFileName restricts uploaded font names to a safe character set
+	fontFileName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*\.(?i:ttf|otf)$`)
+	// fontArgPattern finds string literal font arguments such as
+	// text("Hi", font="Liberation Sans:style=Bold")
+	fontArgPattern = regexp.MustCompile(`\bfont\s*=\s*"((?:[^"\\]|\\.)*)"`)
+)
+
+// FontManager manages the fonts available to OpenSCAD
+type FontManager interface {
+	ListFonts(ctx context.Context) ([]models.FontFile, error)
+	AddFont(ctx context.Context, name string, data []byte) (*models.FontFile, error)
+	RemoveFont(name string) error
+	FontFaces(ctx context.Context) ([]models.FontFace, error)
+}
+
+// PrepareFontDir writes the fontconfig file that adds the managed font
+// directory to the fonts OpenSCAD and fc-list see. It does nothing when no
+// font directory is configured.
+func (s *OpenSCADService) PrepareFontDir() error {
+	if s.fontDir == "" {
+		return nil
+	}
+
+	base := os.Getenv("FONTCONFIG_FILE")
+	if base == "" {
+		base = systemFontConfig
+	}
+	conf := fmt.Sprintf(`<?xml version="1.0"?>
+<!DOCTYPE fontconfig SYSTEM "fonts.dtd">
+<fontconfig>
+  <include ignore_missing="yes">%s</include>
+  <dir>%s</dir>
+  <cachedir>%s</cachedir>
+</fontconfig>
+`, xmlEscape(base), xmlEscape(s.fontDir), xmlEscape(filepath.Join(s.fontDir, ".cache")))
+
+	if err := os.WriteFile(filepath.Join(s.fontDir, fontConfigName), []byte(conf), 0644); err != nil {
+		return fmt.Errorf("failed to write fontconfig file: %w", err)
+	}
+	return nil
+}
+
+// commandEnv returns the environment for OpenSCAD and fontconfig commands,
+// or nil to inherit the server's own
+func (s *OpenSCADService) commandEnv() []string {
+	if s.fontDir == "" {
+		return nil
+	}
+	return append(os.Environ(), "FONTCONFIG_FILE="+filepath.Join(s.fontDir, fontConfigName))
+}
+
+// ListFonts describes the fonts uploaded to the managed font directory
+func (s *OpenSCADService) ListFonts(ctx context.Context) ([]models.FontFile, error) {
+	if s.fontDir == "" {
+		return nil, ErrFontsDisabled
+	}
+
+	entries, err := os.ReadDir(s.fontDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read font directory: %w", err)
+	}
+
+	faces, _ := s.FontFaces(ctx)
+	fonts := []models.FontFile{}
+	for _, e := range entries {
+		if e.IsDir() || !fontFileName.MatchString(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		fonts = append(fonts, models.FontFile{
+			Name:     e.Name(),
+			Size:     info.Size(),
+			Families: familiesInFile(faces, filepath.Join(s.fontDir, e.Name())),
+		})
+	}
+	return fonts, nil
+}
+
+// AddFont stores a TrueType or OpenType font in the managed font directory
+func (s *OpenSCADService) AddFont(ctx context.Context, name string, data []byte) (*models.FontFile, error) {
+	if s.fontDir == "" {
+		return nil, ErrFontsDisabled
+	}
+	if !fontFileName.MatchString(name) {
+		return nil, fmt.Errorf("%w: file name %q must end in .ttf or .otf and contain only letters, digits, '.', '_' and '-'", ErrInvalidFont, name)
+	}
+	if !isFontData(data) {
+		return nil, fmt.Errorf("%w: %s is not a TrueType or OpenType file", ErrInvalidFont, name)
+	}
+
+	s.fontMu.Lock()
+	defer s.fontMu.Unlock()
+
+	if s.maxFontBytes > 0 {
+		used, err := s.fontBytes()
+		if err != nil {
+			return nil, err
+		}
+		if used+int64(len(data)) > s.maxFontBytes {
+			return nil, fmt.Errorf("%w: uploaded fonts are limited to %d bytes in total, %d are in use", ErrFontQuotaExceeded, s.maxFontBytes, used)
+		}
+	}
+
+	path := filepath.Join(s.fontDir, name)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("%w: %s", ErrFontExists, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to store font: %w", err)
+	}
+	_, writeErr := f.Write(data)
+	if err := errors.Join(writeErr, f.Close()); err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to store font: %w", err)
+	}
+	s.faces = nil
+
+	families, err := scanFontFamilies(ctx, path)
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidFont, name, err)
+	}
+	return &models.FontFile{Name: name, Size: int64(len(data)), Families: families}, nil
+}
+
+// fontBytes returns the total size of the uploaded fonts
+func (s *OpenSCADService) fontBytes() (int64, error) {
+	entries, err := os.ReadDir(s.fontDir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read font directory: %w", err)
+	}
+	var total int64
+	for _, e := range entries {
+		if e.IsDir() || !fontFileName.MatchString(e.Name()) {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			total += info.Size()
+		}
+	}
+	return total, nil
+}
+
+// fontInventory lists the uploaded fonts with their sizes and modification
+// times, or returns "" when no font directory is configured
+func (s *OpenSCADService) fontInventory() (string, error) {
+	if s.fontDir == "" {
+		return "", nil
+	}
+	entries, err := os.ReadDir(s.fontDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read font directory: %w", err)
+	}
+	var b strings.Builder
+	for _, e := range entries {
+		if e.IsDir() || !fontFileName.MatchString(e.Name()) {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			fmt.Fprintf(&b, "%s:%d:%d;", e.Name(), info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return b.String(), nil
+}
+
+// RemoveFont deletes a font from the managed font directory
+func (s *OpenSCADService) RemoveFont(name string) error {
+	if s.fontDir == "" {
+		return ErrFontsDisabled
+	}
+	if !fontFileName.MatchString(name) {
+		return fmt.Errorf("%w: %s", ErrFontNotFound, name)
+	}
+
+	s.fontMu.Lock()
+	defer s.fontMu.Unlock()
+
+	err := os.Remove(filepath.Join(s.fontDir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrFontNotFound, name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete font: %w", err)
+	}
+	s.faces = nil
+	return nil
+}
+
+// FontFaces lists every font face fontconfig makes available to OpenSCAD,
+// with the name to pass to text(font=...). Results are cached until the
+// managed fonts change.
+func (s *OpenSCADService) FontFaces(ctx context.Context) ([]models.FontFace, error) {
+	s.fontMu.Lock()
+	defer s.fontMu.Unlock()
+
+	if s.faces != nil {
+		return s.faces, nil
+	}
+
+	cmd := exec.CommandContext(ctx, "fc-list", "--format", "%{family[0]}\t%{style[0]}\t%{file}\n")
+	cmd.Env = s.commandEnv()
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("fc-list failed: %w", err)
+	}
+	s.faces = parseFontList(string(out), s.fontDir)
+	return s.faces, nil
+}
+
+// fontFamilies returns the distinct font family names, or nil if fontconfig
+// is unavailable
+func (s *OpenSCADService) fontFamilies(ctx context.Context) []string {
+	faces, err := s.FontFaces(ctx)
+	if err != nil {
+		return nil
+	}
+	families := make([]string, 0, len(faces))
+	for _, f := range faces {
+		families = append(families, f.Family)
+	}
+	slices.Sort(families)
+	return slices.Compact(families)
+}
+
+// checkFonts fails with ErrMissingFont, in strict font mode, when the source
+// names a font family in a string literal that fontconfig doesn't know. Fonts
+// chosen through variables cannot be checked.
+func (s *OpenSCADService) checkFonts(ctx context.Context, source string) error {
+	s.mu.RLock()
+	strict := s.strictFonts
+	s.mu.RUnlock()
+	if !strict {
+		return nil
+	}
+
+	requested := requestedFonts(source)
+	if len(requested) == 0 {
+		return nil
+	}
+	faces, err := s.FontFaces(ctx)
+	if err != nil {
+		return err
+	}
+	for _, font := range requested {
+		family, _, _ := strings.Cut(font, ":")
+		family = strings.TrimSpace(family)
+		if !slices.ContainsFunc(faces, func(f models.FontFace) bool { return strings.EqualFold(f.Family, family) }) {
+			return fmt.Errorf("%w: %q", ErrMissingFont, font)
+		}
+	}
+	return nil
+}
+
+// requestedFonts returns the non-empty font string literals in source
+func requestedFonts(source string) []string {
+	var fonts []string
+	for _, m := range fontArgPattern.FindAllStringSubmatch(source, -1) {
+		if m[1] != "" {
+			fonts = append(fonts, m[1])
+		}
+	}
+	return fonts
+}
+
+// parseFontList parses fc-list output in "family\tstyle\tfile" lines, marking
+// faces loaded from managedDir
+func parseFontList(out, managedDir string) []models.FontFace {
+	faces := []models.FontFace{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 || fields[0] == "" {
+			continue
+		}
+		face := models.FontFace{
+			Name:   fields[0],
+			Family: fields[0],
+			Style:  fields[1],
+			File:   fields[2],
+		}
+		if face.Style != "" {
+			face.Name += ":style=" + face.Style
+		}
+		face.Managed = managedDir != "" && filepath.Dir(face.File) == filepath.Clean(managedDir)
+		faces = append(faces, face)
+	}
+	slices.SortFunc(faces, func(a, b models.FontFace) int { return strings.Compare(a.Name, b.Name) })
+	return slices.CompactFunc(faces, func(a, b models.FontFace) bool { return a.Name == b.Name })
+}
+
+// familiesInFile returns the families of the faces loaded from path
+func familiesInFile(faces []models.FontFace, path string) []string {
+	families := []string{}
+	for _, f := range faces {
+		if f.File == path && !slices.Contains(families, f.Family) {
+			families = append(families, f.Family)
+		}
+	}
+	return families
+}
+
+// scanFontFamilies asks fontconfig which families a font file provides. A
+// missing fc-scan is not an error since fontconfig may not be installed.
+func scanFontFamilies(ctx context.Context, path string) ([]string, error) {
+	out, err := exec.CommandContext(ctx, "fc-scan", "--format", "%{family[0]}\n", path).Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fontconfig cannot read the font")
+	}
+
+	families := []string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if f := strings.TrimSpace(line); f != "" && !slices.Contains(families, f) {
+			families = append(families, f)
+		}
+	}
+	if len(families) == 0 {
+		return nil, fmt.Errorf("font declares no family")
+	}
+	return families, nil
+}
+
+// isFontData reports whether data starts with a TrueType or OpenType signature
+func isFontData(data []byte) bool {
+	if len(data) < 4 {
+		return false
+	}
+	switch {
+	case bytes.Equal(data[:4], []byte{0x00, 0x01, 0x00, 0x00}):
+		return true
+	case bytes.Equal(data[:4], []byte("OTTO")), bytes.Equal(data[:4], []byte("true")):
+		return true
+	}
+	return false
+}
+
+// xmlEscape escapes s for use as XML character data
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+func TestParseFontList(t *testing.T) {
+	out := "Liberation Sans\tBold\t/usr/share/fonts/LiberationSans-Bold.ttf\n" +
+		"Acme\tRegular\t/srv/fonts/Acme.ttf\n" +
+		"Liberation Sans\tBold\t/usr/share/fonts/LiberationSans-Bold.ttf\n" +
+		"broken line\n"
+
+	faces := parseFontList(out, "/srv/fonts/")
+	want := []models.FontFace{
+		{Name: "Acme:style=Regular", Family: "Acme", Style: "Regular", File: "/srv/fonts/Acme.ttf", Managed: true},
+		{Name: "Liberation Sans:style=Bold", Family: "Liberation Sans", Style: "Bold", File: "/usr/share/fonts/LiberationSans-Bold.ttf"},
+	}
+	if !reflect.DeepEqual(faces, want) {
+		t.Errorf("Expected %+v, got %+v", want, faces)
+	}
+}
+
+func TestRequestedFonts(t *testing.T) {
+	source := `text("A", font = "Acme:style=Bold");
+text("B", size=4, font="Liberation Sans");
+text("C", font="");
+text("D", font=myfont);`
+
+	want := []string{"Acme:style=Bold", "Liberation Sans"}
+	if got := requestedFonts(source); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestIsFontData(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"TrueType", []byte{0x00, 0x01, 0x00, 0x00, 0x00}, true},
+		{"OpenType", []byte("OTTO\x00\x0a"), true},
+		{"Apple TrueType", []byte("true\x00\x0a"), true},
+		{"PNG", []byte("\x89PNG\r\n"), false},
+		{"Too short", []byte("OT"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isFontData(tt.data); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCheckFonts(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.StrictFonts = true
+	service := NewOpenSCADServiceWithConfig(cfg)
+	service.faces = []models.FontFace{{Name: "Acme:style=Bold", Family: "Acme", Style: "Bold"}}
+
+	if err := service.checkFonts(context.Background(), `text("x", font="acme:style=Bold");`); err != nil {
+		t.Errorf("Expected installed font to pass, got %v", err)
+	}
+	if err := service.checkFonts(context.Background(), `text("x", font="Missing Sans");`); !errors.Is(err, ErrMissingFont) {
+		t.Errorf("Expected ErrMissingFont, got %v", err)
+	}
+
+	cfg.StrictFonts = false
+	service.UpdateConfig(cfg)
+	if err := service.checkFonts(context.Background(), `text("x", font="Missing Sans");`); err != nil {
+		t.Errorf("Expected no check outside strict mode, got %v", err)
+	}
+}
+
+func TestFonts_Disabled(t *testing.T) {
+	service := NewOpenSCADService()
+
+	if _, err := service.ListFonts(context.Background()); !errors.Is(err, ErrFontsDisabled) {
+		t.Errorf("Expected ErrFontsDisabled, got %v", err)
+	}
+	if _, err := service.AddFont(context.Background(), "Acme.ttf", []byte("OTTO")); !errors.Is(err, ErrFontsDisabled) {
+		t.Errorf("Expected ErrFontsDisabled, got %v", err)
+	}
+	if err := service.RemoveFont("Acme.ttf"); !errors.Is(err, ErrFontsDisabled) {
+		t.Errorf("Expected ErrFontsDisabled, got %v", err)
+	}
+	if env := service.commandEnv(); env != nil {
+		t.Errorf("Expected inherited environment, got %d variables", len(env))
+	}
+}
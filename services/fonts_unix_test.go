@@ -0,0 +1,136 @@
+//go:build unix
+
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeFontconfig puts fc-scan and fc-list scripts on PATH. fc-scan reports
+// the family "Acme" for files starting with "OTTO"; fc-list lists the .otf
+// files in dir as Acme faces.
+func fakeFontconfig(t *testing.T, dir string) {
+	t.Helper()
+	bin := t.TempDir()
+	scripts := map[string]string{
+		"fc-scan": `head -c 4 "$3" | grep -q OTTO || exit 1
+echo Acme`,
+		"fc-list": `for f in ` + dir + `/*.otf; do [ -e "$f" ] && printf 'Acme\tRegular\t%s\n' "$f"; done
+printf 'Liberation Sans\tRegular\t/usr/share/fonts/LiberationSans-Regular.ttf\n'`,
+	}
+	for name, script := range scripts {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+			t.Fatalf("Failed to write fake %s: %v", name, err)
+		}
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestFontLifecycle(t *testing.T) {
+	dir := t.TempDir()
+	fakeFontconfig(t, dir)
+
+	cfg := DefaultConfig()
+	cfg.FontDir = dir
+	service := NewOpenSCADServiceWithConfig(cfg)
+	ctx := context.Background()
+
+	if err := service.PrepareFontDir(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	conf, err := os.ReadFile(filepath.Join(dir, fontConfigName))
+	if err != nil || !strings.Contains(string(conf), "<dir>"+dir+"</dir>") {
+		t.Errorf("Expected fontconfig file adding %s, got %q (%v)", dir, conf, err)
+	}
+
+	// Prime the face cache so the upload has to invalidate it
+	if families := service.fontFamilies(ctx); len(families) != 1 {
+		t.Errorf("Expected only the system family, got %v", families)
+	}
+
+	font, err := service.AddFont(ctx, "Acme.otf", []byte("OTTO font data"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(font.Families) != 1 || font.Families[0] != "Acme" {
+		t.Errorf("Expected family Acme, got %v", font.Families)
+	}
+
+	fonts, err := service.ListFonts(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(fonts) != 1 || fonts[0].Name != "Acme.otf" || len(fonts[0].Families) != 1 {
+		t.Errorf("Expected Acme.otf with its family, got %+v", fonts)
+	}
+	if families := service.fontFamilies(ctx); len(families) != 2 {
+		t.Errorf("Expected uploaded family to be visible, got %v", families)
+	}
+
+	errTests := []struct {
+		name    string
+		file    string
+		data    string
+		wantErr error
+	}{
+		{"Duplicate", "Acme.otf", "OTTO", ErrFontExists},
+		{"Path traversal", "../Acme.otf", "OTTO", ErrInvalidFont},
+		{"Wrong extension", "Acme.woff", "OTTO", ErrInvalidFont},
+		{"Not a font", "Fake.ttf", "<svg/>", ErrInvalidFont},
+		{"Rejected by fontconfig", "Broken.ttf", "\x00\x01\x00\x00", ErrInvalidFont},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.AddFont(ctx, tt.file, []byte(tt.data)); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Broken.ttf")); !os.IsNotExist(err) {
+		t.Errorf("Expected rejected font to be removed")
+	}
+
+	if err := service.RemoveFont("Acme.otf"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := service.RemoveFont("Acme.otf"); !errors.Is(err, ErrFontNotFound) {
+		t.Errorf("Expected ErrFontNotFound, got %v", err)
+	}
+	if families := service.fontFamilies(ctx); len(families) != 1 {
+		t.Errorf("Expected deleted family to disappear, got %v", families)
+	}
+}
+
+func TestAddFont_Quota(t *testing.T) {
+	dir := t.TempDir()
+	fakeFontconfig(t, dir)
+
+	cfg := DefaultConfig()
+	cfg.FontDir = dir
+	cfg.MaxFontBytes = 20
+	service := NewOpenSCADServiceWithConfig(cfg)
+	ctx := context.Background()
+
+	if _, err := service.AddFont(ctx, "Acme.otf", []byte("OTTO font data")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := service.AddFont(ctx, "Other.otf", []byte("OTTO font data")); !errors.Is(err, ErrFontQuotaExceeded) {
+		t.Errorf("Expected ErrFontQuotaExceeded, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Other.otf")); !os.IsNotExist(err) {
+		t.Errorf("Expected the rejected font not to be stored")
+	}
+
+	// Deleting a font frees its share of the quota
+	if err := service.RemoveFont("Acme.otf"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := service.AddFont(ctx, "Other.otf", []byte("OTTO font data")); err != nil {
+		t.Errorf("Expected the upload to fit after deleting, got %v", err)
+	}
+}
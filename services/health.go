@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+// selfTestSource is the model rendered by SelfTest
+const selfTestSource = "cube(1);"
+
+// errDiskFreeUnsupported is returned by diskFree on platforms without statfs
+var errDiskFreeUnsupported = errors.New("free space check not supported on this platform")
+
+// QueueStatus describes render slot usage
+type QueueStatus struct {
+	// Queued is the number of renders waiting for a slot
+	Queued int
+	// InFlight is the number of OpenSCAD processes running
+	InFlight int
+	// Capacity is the number of OpenSCAD processes allowed to run at once
+	Capacity int
+}
+
+// CheckBinary runs the named installation ("" for the default) with
+// --version and returns the version line it prints
+func (s *OpenSCADService) CheckBinary(ctx context.Context, version string) (string, error) {
+	_, binary, err := s.binaryFor(version)
+	if err != nil {
+		return "", err
+	}
+	out, err := exec.CommandContext(ctx, binary, "--version").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("openscad --version failed: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// TempDirStatus describes the render temp directory
+type TempDirStatus struct {
+	// Dir is the directory renders are created in
+	Dir string
+	// FreeBytes is the space available to unprivileged users
+	FreeBytes uint64
+	// FreeKnown is false on platforms that cannot report free space
+	FreeKnown bool
+}
+
+// CheckTempDir verifies that a file can be created in the render temp
+// directory and reports the space left on its filesystem
+func (s *OpenSCADService) CheckTempDir() (TempDirStatus, error) {
+	status := TempDirStatus{Dir: s.tempDir}
+	if status.Dir == "" {
+		status.Dir = os.TempDir()
+	}
+
+	f, err := os.CreateTemp(status.Dir, "scad-health-*")
+	if err != nil {
+		return status, fmt.Errorf("temp directory not writable: %w", err)
+	}
+	_, writeErr := f.Write([]byte("ok"))
+	closeErr := f.Close()
+	removeErr := os.Remove(f.Name())
+	if err := errors.Join(writeErr, closeErr, removeErr); err != nil {
+		return status, fmt.Errorf("temp directory not writable: %w", err)
+	}
+
+	free, err := diskFree(status.Dir)
+	if errors.Is(err, errDiskFreeUnsupported) {
+		return status, nil
+	}
+	if err != nil {
+		return status, fmt.Errorf("failed to read free space: %w", err)
+	}
+	status.FreeBytes, status.FreeKnown = free, true
+	return status, nil
+}
+
+// QueueStatus returns the current render slot usage
+func (s *OpenSCADService) QueueStatus() QueueStatus {
+	return QueueStatus{
+		Queued:   int(s.queued.Load()),
+		InFlight: len(s.slots),
+		Capacity: cap(s.slots),
+	}
+}
+
+// SelfTest renders a small cube to ASCII STL with the default installation to
+// verify OpenSCAD works end to end
+func (s *OpenSCADService) SelfTest(ctx context.Context) error {
+	result, err := s.Export(ctx, &models.ExportRequest{ScadContent: selfTestSource, Format: "stl_ascii"})
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(strings.TrimSpace(string(result.Data)), "solid") {
+		return errors.New("self-test render produced unexpected output")
+	}
+	return nil
+}
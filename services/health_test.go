@@ -0,0 +1,68 @@
+//go:build unix
+
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCheckTempDir(t *testing.T) {
+	service := newFakeService(t, "exit 0")
+
+	status, err := service.CheckTempDir()
+	if err != nil {
+		t.Fatalf("Expected writable temp dir, got %v", err)
+	}
+	freeSupported := runtime.GOOS == "linux" || runtime.GOOS == "darwin"
+	if freeSupported && (!status.FreeKnown || status.FreeBytes == 0) {
+		t.Errorf("Expected free space to be reported, got %+v", status)
+	}
+
+	service.tempDir = filepath.Join(t.TempDir(), "missing")
+	if _, err := service.CheckTempDir(); err == nil {
+		t.Errorf("Expected error for missing temp dir")
+	}
+}
+
+func TestCheckBinary(t *testing.T) {
+	service := newFakeService(t, "echo 'OpenSCAD version 2021.01'")
+
+	version, err := service.CheckBinary(context.Background(), "")
+	if err != nil {
+		t.Fatalf("Expected binary check to pass, got %v", err)
+	}
+	if version != "OpenSCAD version 2021.01" {
+		t.Errorf("Expected version line, got %q", version)
+	}
+
+	service.binaries[DefaultVersionName] = filepath.Join(t.TempDir(), "missing")
+	if _, err := service.CheckBinary(context.Background(), ""); err == nil {
+		t.Errorf("Expected error for missing binary")
+	}
+}
+
+func TestSelfTest(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		wantErr bool
+	}{
+		// The output path follows -o, which is the first argument
+		{"Valid STL", `printf 'solid OpenSCAD_Model\nendsolid\n' > "$2"`, false},
+		{"Unexpected output", `echo garbage > "$2"`, true},
+		{"Render failure", "exit 1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newFakeService(t, tt.script)
+			err := service.SelfTest(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SelfTest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+var versionPattern = regexp.MustCompile(`(?i)OpenSCAD version:?\s+(\S+)`)
+
+// helpWrapWidth is the length at which openscad --help breaks lines that have
+// no space to wrap at
+const helpWrapWidth = 79
+
+// InfoProvider reports the capabilities and limits of the OpenSCAD installations
+type InfoProvider interface {
+	Info(ctx context.Context, version string) (*models.OpenSCADInfo, error)
+	Versions(ctx context.Context) ([]models.OpenSCADVersion, error)
+	Limits() models.LimitsInfo
+}
+
+// RenderIdentifier describes the server state a render depends on besides the
+// request itself
+type RenderIdentifier interface {
+	RenderIdentity(ctx context.Context, version string) (string, error)
+}
+
+// Info inspects the named installation ("" for the default) with --version,
+// --info and --help and lists the fonts and libraries it can use. Results are
+// computed once per installation and cached; failed inspections are retried
+// on the next call. Fonts are listed on every call since they can be uploaded
+// at runtime.
+func (s *OpenSCADService) Info(ctx context.Context, version string) (*models.OpenSCADInfo, error) {
+	info, err := s.inspect(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+
+	withFonts := *info
+	withFonts.Fonts = s.fontFamilies(ctx)
+	return &withFonts, nil
+}
+
+// inspect returns the cached inspection of the named installation, running
+// it first if needed
+func (s *OpenSCADService) inspect(ctx context.Context, version string) (*models.OpenSCADInfo, error) {
+	name, binary, err := s.binaryFor(version)
+	if err != nil {
+		return nil, err
+	}
+
+	s.infoMu.Lock()
+	defer s.infoMu.Unlock()
+
+	if info, ok := s.info[name]; ok {
+		return info, nil
+	}
+
+	versionOut, err := runInfoCommand(ctx, binary, "--version")
+	if err != nil {
+		return nil, err
+	}
+	info := &models.OpenSCADInfo{
+		Name:    name,
+		Version: parseVersion(versionOut),
+		Default: name == s.defaultVersion,
+	}
+
+	// --info and --help are best effort: older builds lack some sections and
+	// --info may complain about a missing display after printing what we need
+	infoOut, _ := runInfoCommand(ctx, binary, "--info")
+	info.Build, info.LibraryPaths = parseBuildInfo(infoOut)
+
+	helpOut, _ := runInfoCommand(ctx, binary, "--help")
+	info.Backends = parseBackends(helpOut)
+	info.Features = parseHelpOption(helpOut, "--enable")
+	info.ColorSchemes = parseHelpOption(helpOut, "--colorscheme")
+
+	info.Libraries = listLibraries(info.LibraryPaths)
+
+	s.info[name] = info
+	return info, nil
+}
+
+// Limits returns the render limits currently in force
+func (s *OpenSCADService) Limits() models.LimitsInfo {
+	timeout, _, _ := s.settings()
+	return models.LimitsInfo{
+		RenderTimeoutSeconds: timeout.Seconds(),
+		MaxConcurrentRenders: cap(s.slots),
+	}
+}
+
+// RenderIdentity returns a string that changes whenever the same request to
+// the named installation ("" for the default) could render differently: the
+// installation and its reported version, the default backend and features,
+// the image qualities and the uploaded fonts
+func (s *OpenSCADService) RenderIdentity(ctx context.Context, version string) (string, error) {
+	info, err := s.inspect(ctx, version)
+	if err != nil {
+		return "", err
+	}
+	backend, features, _ := s.renderDefaults()
+	_, webpQuality, avifQuality := s.settings()
+	fonts, err := s.fontInventory()
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{
+		info.Name,
+		info.Version,
+		backend,
+		strings.Join(features, ","),
+		strconv.Itoa(webpQuality),
+		strconv.Itoa(avifQuality),
+		fonts,
+	}, "\x00"), nil
+}
+
+// runInfoCommand runs an OpenSCAD binary with a single informational flag
+// and returns its combined output
+func runInfoCommand(ctx context.Context, binary, flag string) (string, error) {
+	out, err := exec.CommandContext(ctx, binary, flag).CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("openscad %s failed: %w", flag, err)
+	}
+	return string(out), nil
+}
+
+// parseVersion extracts the version number from openscad --version output
+func parseVersion(out string) string {
+	if m := versionPattern.FindStringSubmatch(out); m != nil {
+		return m[1]
+	}
+	return strings.TrimSpace(out)
+}
+
+// parseBuildInfo reads the "Key: value" lines of openscad --info and the
+// indented list that follows "OpenSCAD library path:"
+func parseBuildInfo(out string) (build map[string]string, libraryPaths []string) {
+	build = make(map[string]string)
+	inLibraryPaths := false
+
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			if inLibraryPaths {
+				if p := strings.TrimSpace(line); p != "" {
+					libraryPaths = append(libraryPaths, p)
+				}
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			inLibraryPaths = false
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		inLibraryPaths = key == "OpenSCAD library path"
+		if value != "" {
+			build[key] = value
+		}
+	}
+	return build, libraryPaths
+}
+
+// parseHelpOption returns the values listed in the openscad --help entry for
+// option, such as the color schemes after "--colorscheme arg =Cornfield|..." or
+// the features after "--enable arg ... features): roof | lazy-union"
+func parseHelpOption(help, option string) []string {
+	var entry strings.Builder
+	found, hardWrapped := false, false
+	scanner := bufio.NewScanner(strings.NewReader(help))
+	for scanner.Scan() {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		if !found {
+			if strings.HasPrefix(line, option+" ") {
+				found = true
+				entry.WriteString(line)
+				hardWrapped = len(raw) >= helpWrapWidth
+			}
+			continue
+		}
+		// The entry continues on wrapped lines until the next option. Lines
+		// filled to the wrap width were broken mid-word, others at a space.
+		if line == "" || strings.HasPrefix(line, "-") {
+			break
+		}
+		if !hardWrapped {
+			entry.WriteByte(' ')
+		}
+		entry.WriteString(line)
+		hardWrapped = len(raw) >= helpWrapWidth
+	}
+	if !found {
+		return nil
+	}
+
+	text := entry.String()
+	idx := strings.LastIndexAny(text, "=:")
+	if idx < 0 {
+		return nil
+	}
+
+	var values []string
+	for _, v := range strings.Split(text[idx+1:], "|") {
+		if v = strings.TrimSpace(v); v != "" && v != "all" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// listLibraries returns the names of the libraries (directories and .scad
+// files) found directly inside the OpenSCAD library paths
+func listLibraries(paths []string) []string {
+	var libs []string
+	for _, dir := range paths {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if strings.HasPrefix(name, ".") {
+				continue
+			}
+			if e.IsDir() || filepath.Ext(name) == ".scad" {
+				libs = append(libs, name)
+			}
+		}
+	}
+	slices.Sort(libs)
+	return slices.Compact(libs)
+}
+
+// ExportFormats describes every supported export format and the schema of
+// its options object, derived from the models option structs
+func ExportFormats() []models.FormatInfo {
+	s := &OpenSCADService{}
+	formats := make([]models.FormatInfo, 0, len(supportedFormats))
+	for _, name := range supportedFormats {
+		key := optionsKey(name)
+		formats = append(formats, models.FormatInfo{
+			Name:        name,
+			ContentType: s.getContentType(name),
+			OptionsKey:  key,
+			Options:     optionSchema(key),
+		})
+	}
+	return formats
+}
+
+// optionsKey returns the field of models.ExportOptions read for format
+func optionsKey(format string) string {
+	switch format {
+	case "png", "webp", "avif":
+		return "png"
+	case "stl_binary", "stl_ascii":
+		return "stl"
+	default:
+		return format
+	}
+}
+
+// optionSchema reflects over the models.ExportOptions field named key
+func optionSchema(key string) []models.OptionSchema {
+	optsType := reflect.TypeOf(models.ExportOptions{})
+	for i := 0; i < optsType.NumField(); i++ {
+		field := optsType.Field(i)
+		if jsonName(field) != key {
+			continue
+		}
+
+		structType := field.Type.Elem()
+		schema := make([]models.OptionSchema, 0, structType.NumField())
+		for j := 0; j < structType.NumField(); j++ {
+			schema = append(schema, fieldSchema(structType.Field(j)))
+		}
+		return schema
+	}
+	return []models.OptionSchema{}
+}
+
+// fieldSchema describes one option field from its type and struct tags
+func fieldSchema(field reflect.StructField) models.OptionSchema {
+	schema := models.OptionSchema{
+		Name:    jsonName(field),
+		Example: field.Tag.Get("example"),
+	}
+
+	t := field.Type
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int:
+		schema.Type = "integer"
+	case reflect.Float64:
+		schema.Type = "number"
+	case reflect.Bool:
+		schema.Type = "boolean"
+	default:
+		schema.Type = "string"
+	}
+
+	if enums := field.Tag.Get("enums"); enums != "" {
+		schema.Enum = strings.Split(enums, ",")
+	}
+	if v, err := strconv.ParseFloat(field.Tag.Get("minimum"), 64); err == nil {
+		schema.Minimum = &v
+	}
+	if v, err := strconv.ParseFloat(field.Tag.Get("maximum"), 64); err == nil {
+		schema.Maximum = &v
+	}
+	return schema
+}
+
+// jsonName returns the JSON name of a struct field
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	return name
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const sampleInfo = `OpenSCAD Version: 2021.01
+System information: Linux 6.1.0 #1 SMP x86_64 Debian GNU/Linux 12 (bookworm) 8 CPUs 15.52 GB RAM
+Compiler: GCC "12.2.0" 64bit
+CGAL version, kernels: 5.5.1, Cartesian<Gmpq>, Extended_cartesian<Gmpq>, Epeck
+OPENSCADPATH:
+OpenSCAD library path:
+  /root/.local/share/OpenSCAD/libraries
+  /usr/share/openscad/libraries
+
+OPENSCAD_FONT_PATH:
+OpenSCAD font path:
+  /usr/share/fonts
+`
+
+const sampleHelp = `Usage: openscad [options] file.scad
+Allowed options:
+  --export-format arg               overrides format of exported scad file
+  --colorscheme arg                 =Cornfield|Metallic|Sunset|Starnight|Before
+                                    Dawn|Nature|DeepOcean|Solarized|Tomorrow|To
+                                    morrow Night|Monotone
+  --enable arg                      enable experimental features (specify
+                                    'all' for enabling all available
+                                    features): roof | lazy-union |
+                                    textmetrics | all
+  -h [ --help ]                     print this help message and exit
+`
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		out  string
+		want string
+	}{
+		{"OpenSCAD version 2021.01\n", "2021.01"},
+		{"OpenSCAD version 2025.03.15.ai24187\n", "2025.03.15.ai24187"},
+		{"something else", "something else"},
+	}
+
+	for _, tt := range tests {
+		if got := parseVersion(tt.out); got != tt.want {
+			t.Errorf("parseVersion(%q) = %q, want %q", tt.out, got, tt.want)
+		}
+	}
+}
+
+func TestParseBuildInfo(t *testing.T) {
+	build, paths := parseBuildInfo(sampleInfo)
+
+	if build["OpenSCAD Version"] != "2021.01" {
+		t.Errorf("Expected OpenSCAD Version 2021.01, got %q", build["OpenSCAD Version"])
+	}
+	if build["Compiler"] != `GCC "12.2.0" 64bit` {
+		t.Errorf("Expected compiler entry, got %q", build["Compiler"])
+	}
+	if _, ok := build["OPENSCADPATH"]; ok {
+		t.Errorf("Expected empty values to be omitted")
+	}
+
+	want := []string{"/root/.local/share/OpenSCAD/libraries", "/usr/share/openscad/libraries"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("Expected library paths %v, got %v", want, paths)
+	}
+}
+
+func TestParseHelpOption(t *testing.T) {
+	tests := []struct {
+		option string
+		want   []string
+	}{
+		{"--enable", []string{"roof", "lazy-union", "textmetrics"}},
+		{"--backend", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.option, func(t *testing.T) {
+			got := parseHelpOption(sampleHelp, tt.option)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	// The color schemes are broken mid-word at the wrap width
+	schemes := parseHelpOption(sampleHelp, "--colorscheme")
+	want := []string{"Cornfield", "Metallic", "Sunset", "Starnight", "BeforeDawn", "Nature",
+		"DeepOcean", "Solarized", "Tomorrow", "Tomorrow Night", "Monotone"}
+	if !reflect.DeepEqual(schemes, want) {
+		t.Errorf("Expected %v, got %v", want, schemes)
+	}
+}
+
+func TestListLibraries(t *testing.T) {
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	for _, d := range []string{filepath.Join(dir1, "MCAD"), filepath.Join(dir2, "BOSL2"), filepath.Join(dir2, ".git")} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+	}
+	for _, f := range []string{filepath.Join(dir2, "gears.scad"), filepath.Join(dir2, "README.md")} {
+		if err := os.WriteFile(f, nil, 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	got := listLibraries([]string{dir1, dir2, filepath.Join(dir1, "missing")})
+	want := []string{"BOSL2", "MCAD", "gears.scad"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestExportFormats(t *testing.T) {
+	formats := ExportFormats()
+	if len(formats) != len(supportedFormats) {
+		t.Fatalf("Expected %d formats, got %d", len(supportedFormats), len(formats))
+	}
+
+	byName := make(map[string]int)
+	for i, f := range formats {
+		byName[f.Name] = i
+	}
+
+	webp := formats[byName["webp"]]
+	if webp.ContentType != "image/webp" || webp.OptionsKey != "png" {
+		t.Errorf("Expected webp to use png options with image/webp, got %+v", webp)
+	}
+	if len(webp.Options) != 3 || webp.Options[0].Name != "width" || webp.Options[0].Type != "integer" || webp.Options[2].Name != "camera" {
+		t.Errorf("Expected width/height integer and camera options, got %+v", webp.Options)
+	}
+
+	stl := formats[byName["stl_ascii"]]
+	precision := stl.Options[0]
+	if precision.Minimum == nil || *precision.Minimum != 1 || precision.Maximum == nil || *precision.Maximum != 16 {
+		t.Errorf("Expected decimal_precision bounds 1-16, got %+v", precision)
+	}
+
+	pdf := formats[byName["pdf"]]
+	if pdf.Options[0].Name != "paper_size" || len(pdf.Options[0].Enum) != 7 {
+		t.Errorf("Expected paper_size with 7 values, got %+v", pdf.Options[0])
+	}
+}
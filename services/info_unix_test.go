@@ -0,0 +1,111 @@
+//go:build unix
+
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+func TestInfo_Cached(t *testing.T) {
+	counter := filepath.Join(t.TempDir(), "calls")
+	service := newFakeService(t, `echo x >> `+counter+`
+case "$1" in
+--version) echo "OpenSCAD version 2021.01" ;;
+--help) echo "  --colorscheme arg  =Cornfield|Metallic" ;;
+esac`)
+
+	for i := 0; i < 2; i++ {
+		info, err := service.Info(context.Background(), "")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if info.Version != "2021.01" {
+			t.Errorf("Expected version 2021.01, got %s", info.Version)
+		}
+		if len(info.ColorSchemes) != 2 {
+			t.Errorf("Expected 2 color schemes, got %v", info.ColorSchemes)
+		}
+	}
+
+	data, err := os.ReadFile(counter)
+	if err != nil {
+		t.Fatalf("Failed to read call counter: %v", err)
+	}
+	// --version, --info and --help, once each
+	if calls := len(data) / 2; calls != 3 {
+		t.Errorf("Expected 3 openscad invocations, got %d", calls)
+	}
+}
+
+func TestRenderIdentity(t *testing.T) {
+	service := newFakeService(t, `[ "$1" = --version ] && echo "OpenSCAD version 2021.01"`)
+	ctx := context.Background()
+
+	before, err := service.RenderIdentity(ctx, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if again, _ := service.RenderIdentity(ctx, ""); again != before {
+		t.Errorf("Expected a stable identity, got %q and %q", before, again)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Backend = "manifold"
+	service.UpdateConfig(cfg)
+	if after, _ := service.RenderIdentity(ctx, ""); after == before {
+		t.Errorf("Expected a new default backend to change the identity")
+	}
+
+	if _, err := service.RenderIdentity(ctx, "missing"); err == nil {
+		t.Errorf("Expected error for unknown installation")
+	}
+}
+
+func TestVersions(t *testing.T) {
+	dir := t.TempDir()
+	binaries := map[string]string{}
+	for name, version := range map[string]string{"stable": "2021.01", "nightly": "2025.03.15"} {
+		binary := filepath.Join(dir, name)
+		script := "#!/bin/sh\necho 'OpenSCAD version " + version + "'\n"
+		if err := os.WriteFile(binary, []byte(script), 0755); err != nil {
+			t.Fatalf("Failed to write fake openscad: %v", err)
+		}
+		binaries[name] = binary
+	}
+
+	cfg := DefaultConfig()
+	cfg.Versions = binaries
+	cfg.DefaultVersion = "stable"
+	service := NewOpenSCADServiceWithConfig(cfg)
+
+	versions, err := service.Versions(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := []models.OpenSCADVersion{
+		{Name: "nightly", Version: "2025.03.15"},
+		{Name: "stable", Version: "2021.01", Default: true},
+	}
+	if !reflect.DeepEqual(versions, want) {
+		t.Errorf("Expected %+v, got %+v", want, versions)
+	}
+
+	lines, err := service.CheckBinaries(context.Background())
+	if err != nil {
+		t.Fatalf("Expected all binaries to run, got %v", err)
+	}
+	if lines["nightly"] != "OpenSCAD version 2025.03.15" {
+		t.Errorf("Expected nightly version line, got %q", lines["nightly"])
+	}
+
+	service.binaries["broken"] = filepath.Join(dir, "missing")
+	if _, err := service.CheckBinaries(context.Background()); err == nil {
+		t.Errorf("Expected error for missing binary")
+	}
+}
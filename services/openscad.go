@@ -3,150 +3,370 @@ package services
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/stevexciv/scad-server/logging"
+	"github.com/stevexciv/scad-server/metrics"
 	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
 	defaultTimeout = 5 * time.Minute
-	openscadCmd    = "openscad"
+	defaultBinary  = "openscad"
+
+	// DefaultWebPQuality is the WebP encoder quality used when none is configured
+	DefaultWebPQuality = 80
+	// DefaultAVIFQuality is the AVIF encoder quality used when none is configured
+	DefaultAVIFQuality = 60
 )
 
+// Config contains OpenSCADService settings
+type Config struct {
+	// Binary is the OpenSCAD executable name or path, used when Versions is empty
+	Binary string
+	// Versions maps installation names to OpenSCAD executables
+	Versions map[string]string
+	// DefaultVersion names the installation used when a request doesn't choose one
+	DefaultVersion string
+	// Timeout bounds each OpenSCAD invocation
+	Timeout time.Duration
+	// TempDir is where render working directories are created ("" for the OS default)
+	TempDir string
+	// MaxConcurrentRenders is the number of OpenSCAD processes allowed to run at once
+	MaxConcurrentRenders int
+	// WebPQuality is the WebP encoder quality (0-100)
+	WebPQuality int
+	// AVIFQuality is the AVIF encoder quality (0-100)
+	AVIFQuality int
+	// Backend is the geometry backend used when a request doesn't choose one
+	// ("" for OpenSCAD's own default)
+	Backend string
+	// Features are the experimental features enabled when a request doesn't
+	// choose any
+	Features []string
+	// AllowedFeatures are the experimental features requests may enable
+	AllowedFeatures []string
+	// FontDir is the managed font directory made visible to OpenSCAD through
+	// fontconfig ("" disables font management)
+	FontDir string
+	// StrictFonts fails renders whose source names a font that isn't installed
+	StrictFonts bool
+	// MaxFontBytes is the total size the uploaded fonts may take (0 for
+	// unlimited)
+	MaxFontBytes int64
+	// MaxAssetBytes is the largest asset a request may carry
+	MaxAssetBytes int64
+	// MaxAssets is the number of assets a request may carry
+	MaxAssets int
+}
+
+// DefaultConfig returns the settings used by NewOpenSCADService
+func DefaultConfig() Config {
+	return Config{
+		Binary:               defaultBinary,
+		Timeout:              defaultTimeout,
+		MaxConcurrentRenders: runtime.NumCPU(),
+		WebPQuality:          DefaultWebPQuality,
+		AVIFQuality:          DefaultAVIFQuality,
+		AllowedFeatures:      DefaultAllowedFeatures,
+		MaxAssetBytes:        DefaultMaxAssetBytes,
+		MaxAssets:            DefaultMaxAssets,
+	}
+}
+
+// supportedFormats lists the export formats in the order they are reported
+var supportedFormats = []string{"png", "stl_binary", "stl_ascii", "svg", "pdf", "3mf", "webp", "avif"}
+
+// ExportResult is the output of an export
+type ExportResult struct {
+	Data        []byte
+	ContentType string
+	// Summary is the summary OpenSCAD wrote in the same run, set when the
+	// request has a summary type
+	Summary *models.SummaryResponse
+}
+
 // OpenSCADExporter defines the interface for OpenSCAD operations
 type OpenSCADExporter interface {
-	Export(req *models.ExportRequest) ([]byte, string, error)
-	Summary(req *models.SummaryRequest) (*models.SummaryResponse, error)
+	Export(ctx context.Context, req *models.ExportRequest) (*ExportResult, error)
+	Summary(ctx context.Context, req *models.SummaryRequest) (*models.SummaryResponse, error)
 }
 
 // OpenSCADService provides OpenSCAD operations
 type OpenSCADService struct {
-	timeout time.Duration
+	binaries       map[string]string
+	defaultVersion string
+	tempDir        string
+	fontDir        string
+	maxFontBytes   int64
+	maxAssetBytes  int64
+	maxAssets      int
+	slots          chan struct{}
+	queued         atomic.Int64
+
+	// mu guards the settings that UpdateConfig may change at runtime
+	mu              sync.RWMutex
+	timeout         time.Duration
+	webpQuality     int
+	avifQuality     int
+	backend         string
+	features        []string
+	allowedFeatures []string
+	strictFonts     bool
+
+	// fontMu guards changes to the font directory and the cached font faces
+	fontMu sync.Mutex
+	faces  []models.FontFace
+
+	// infoMu guards the cached results of Info per installation
+	infoMu sync.Mutex
+	info   map[string]*models.OpenSCADInfo
+
+	// workMu guards the drain state used by Shutdown
+	workMu    sync.Mutex
+	draining  bool
+	inflight  sync.WaitGroup
+	workDirs  map[string]struct{}
+	procs     map[*exec.Cmd]struct{}
+	abort     chan struct{}
+	abortOnce sync.Once
 }
 
-// NewOpenSCADService creates a new OpenSCAD service
+// NewOpenSCADService creates a new OpenSCAD service with the default
+// configuration, which runs at most one OpenSCAD process per CPU at a time
 func NewOpenSCADService() *OpenSCADService {
+	return NewOpenSCADServiceWithConfig(DefaultConfig())
+}
+
+// NewOpenSCADServiceWithConfig creates a new OpenSCAD service with custom settings
+func NewOpenSCADServiceWithConfig(cfg Config) *OpenSCADService {
+	binaries, defaultVersion := resolveVersions(cfg)
 	return &OpenSCADService{
-		timeout: defaultTimeout,
+		binaries:        binaries,
+		defaultVersion:  defaultVersion,
+		tempDir:         cfg.TempDir,
+		fontDir:         cfg.FontDir,
+		maxFontBytes:    cfg.MaxFontBytes,
+		maxAssetBytes:   cfg.MaxAssetBytes,
+		maxAssets:       cfg.MaxAssets,
+		slots:           make(chan struct{}, max(cfg.MaxConcurrentRenders, 1)),
+		timeout:         cfg.Timeout,
+		webpQuality:     cfg.WebPQuality,
+		avifQuality:     cfg.AVIFQuality,
+		backend:         cfg.Backend,
+		features:        cfg.Features,
+		allowedFeatures: cfg.AllowedFeatures,
+		strictFonts:     cfg.StrictFonts,
+		info:            make(map[string]*models.OpenSCADInfo),
+		workDirs:        make(map[string]struct{}),
+		procs:           make(map[*exec.Cmd]struct{}),
+		abort:           make(chan struct{}),
 	}
 }
 
+// UpdateConfig applies the settings that are safe to change while renders are
+// running: the timeout, image qualities, default backend and features, the
+// feature allowlist and strict font mode. Binaries, TempDir, FontDir, the
+// font and asset limits and MaxConcurrentRenders only take effect on a new
+// service.
+func (s *OpenSCADService) UpdateConfig(cfg Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.timeout = cfg.Timeout
+	s.webpQuality = cfg.WebPQuality
+	s.avifQuality = cfg.AVIFQuality
+	s.backend = cfg.Backend
+	s.features = cfg.Features
+	s.allowedFeatures = cfg.AllowedFeatures
+	s.strictFonts = cfg.StrictFonts
+}
+
+// settings returns a consistent snapshot of the runtime-adjustable settings
+func (s *OpenSCADService) settings() (timeout time.Duration, webpQuality, avifQuality int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.timeout, s.webpQuality, s.avifQuality
+}
+
 // Export exports SCAD content to the specified format
-func (s *OpenSCADService) Export(req *models.ExportRequest) ([]byte, string, error) {
-	log.Printf("[OpenSCAD Export] Request: format=%s, options=%+v", req.Format, req.Options)
+func (s *OpenSCADService) Export(ctx context.Context, req *models.ExportRequest) (*ExportResult, error) {
+	logger := logging.FromContext(ctx).With("operation", "export", "format", req.Format)
+	logger.Debug("export requested", "options", req.Options)
 
 	// Validate format
 	if err := s.validateFormat(req.Format); err != nil {
-		return nil, "", err
+		return nil, err
+	}
+	if err := ValidateSummaryType(req.SummaryType); err != nil {
+		return nil, err
 	}
 
-	// Create temporary directory
-	tmpDir, err := os.MkdirTemp("", "scad-export-*")
-	log.Printf("[OpenSCAD Export] Created temp dir: %s", tmpDir)
+	version, binary, err := s.binaryFor(req.OpenSCADVersion)
 	if err != nil {
-		log.Printf("[OpenSCAD Export] Failed to create temp dir: %v", err)
-		return nil, "", fmt.Errorf("failed to create temp directory: %w", err)
+		return nil, err
 	}
-	defer func() {
-		if err := os.RemoveAll(tmpDir); err != nil {
-			// Log error but don't fail the operation
-			fmt.Fprintf(os.Stderr, "warning: failed to remove temp directory %s: %v\n", tmpDir, err)
-		}
-	}()
+	logger = logger.With("openscad_version", version)
 
-	// Write SCAD content to temporary file
-	scadFile := filepath.Join(tmpDir, "input.scad")
-	log.Printf("[OpenSCAD Export] Writing SCAD file: %s", scadFile)
-	if err := os.WriteFile(scadFile, []byte(req.ScadContent), 0644); err != nil {
-		log.Printf("[OpenSCAD Export] Failed to write SCAD file: %v", err)
-		return nil, "", fmt.Errorf("failed to write SCAD file: %w", err)
+	renderArgs, err := s.renderArgs(ctx, version, req.Backend, req.Features)
+	if err != nil {
+		return nil, err
 	}
+	paramArgs, err := parameterArgs(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateCamera(req.Options.PNG); err != nil {
+		return nil, err
+	}
+	if err := s.checkFonts(ctx, req.ScadContent); err != nil {
+		return nil, err
+	}
+	if err := s.validateAssets(req.Assets, req.Libraries); err != nil {
+		return nil, err
+	}
+
+	if err := s.beginWork(); err != nil {
+		return nil, err
+	}
+	defer s.endWork()
+
+	// Create temporary directory holding the SCAD input
+	tmpDir, scadFile, err := s.prepareWorkDir(ctx, logger, "scad-export-*", req.ScadContent, slices.Concat(req.Assets, req.Libraries))
+	if err != nil {
+		return nil, err
+	}
+	defer s.removeWorkDir(logger, tmpDir)
 
 	// Determine output file extension
 	outputExt, exportFormat := s.getOutputExtension(req.Format)
 	outputFile := filepath.Join(tmpDir, "output."+outputExt)
-	log.Printf("[OpenSCAD Export] Output file: %s", outputFile)
 
-	// Build OpenSCAD command arguments
-	args := []string{"--debug=all", "-o", outputFile}
-	log.Printf("[OpenSCAD Export] Initial args: %+v", args)
+	// Build OpenSCAD command arguments. OpenSCAD's own debug output is only
+	// requested when it will actually be logged.
+	args := []string{"-o", outputFile}
+	if logger.Enabled(ctx, slog.LevelDebug) {
+		args = append([]string{"--debug=all"}, args...)
+	}
 
 	// Add export format if needed
 	if exportFormat != "" {
 		args = append(args, "--export-format", exportFormat)
 	}
 
-	// Add format-specific options
-	formatOpts := s.buildExportOptions(req)
-	log.Printf("[OpenSCAD Export] Format-specific options: %+v", formatOpts)
-	args = append(args, formatOpts...)
+	// Add backend, experimental features, parameters and format-specific options
+	args = append(args, renderArgs...)
+	args = append(args, paramArgs...)
+	args = append(args, s.buildExportOptions(req)...)
+
+	// Write the summary in the same run when one is requested
+	summaryFile := filepath.Join(tmpDir, "summary.json")
+	if req.SummaryType != "" {
+		args = append(args, "--summary", req.SummaryType, "--summary-file", summaryFile)
+	}
 
 	// Add input file
 	args = append(args, scadFile)
-	log.Printf("[OpenSCAD Export] Final command: %s %v", openscadCmd, args)
 
 	// Execute OpenSCAD command
-	if err := s.executeCommand(args); err != nil {
-		return nil, "", err
+	if err := s.executeCommand(ctx, logger, binary, req.Format, args); err != nil {
+		return nil, err
 	}
 
 	// Read output file
-	log.Printf("[OpenSCAD Export] Attempting to read output file: %s", outputFile)
-	data, err := os.ReadFile(outputFile)
+	data, err := readOutput(ctx, outputFile)
 	if err != nil {
-		log.Printf("[OpenSCAD Export] Failed to read output file: %v", err)
-		return nil, "", fmt.Errorf("failed to read output file: %w", err)
+		logger.Error("failed to read output file", "path", outputFile, "error", err)
+		return nil, fmt.Errorf("failed to read output file: %w", err)
+	}
+	logger.Debug("read output file", "path", outputFile, "bytes", len(data))
+
+	var summary *models.SummaryResponse
+	if req.SummaryType != "" {
+		summaryData, err := readOutput(ctx, summaryFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read summary file: %w", err)
+		}
+		if summary, err = parseSummary(logger, summaryData); err != nil {
+			return nil, err
+		}
 	}
-	log.Printf("[OpenSCAD Export] Output file read successfully, size: %d bytes", len(data))
 
 	// Post-process: convert PNG to target format if needed
+	_, webpQuality, avifQuality := s.settings()
 	switch req.Format {
 	case "webp":
-		data, err = convertPNGToWebP(data)
+		data, err = convertPNGToWebP(ctx, data, webpQuality)
 		if err != nil {
-			log.Printf("[OpenSCAD Export] Failed to convert to WebP: %v", err)
-			return nil, "", fmt.Errorf("failed to convert to WebP: %w", err)
+			logger.Error("failed to convert to WebP", "error", err)
+			return nil, fmt.Errorf("failed to convert to WebP: %w", err)
 		}
 	case "avif":
-		data, err = convertPNGToAVIF(data)
+		data, err = convertPNGToAVIF(ctx, data, avifQuality)
 		if err != nil {
-			log.Printf("[OpenSCAD Export] Failed to convert to AVIF: %v", err)
-			return nil, "", fmt.Errorf("failed to convert to AVIF: %w", err)
+			logger.Error("failed to convert to AVIF", "error", err)
+			return nil, fmt.Errorf("failed to convert to AVIF: %w", err)
 		}
 	}
 
-	// Get content type
-	contentType := s.getContentType(req.Format)
+	metrics.OutputBytes.WithLabelValues(req.Format).Observe(float64(len(data)))
+	logger.Info("export completed", "bytes", len(data))
 
-	return data, contentType, nil
+	return &ExportResult{Data: data, ContentType: s.getContentType(req.Format), Summary: summary}, nil
 }
 
 // Summary generates summary information for SCAD content
-func (s *OpenSCADService) Summary(req *models.SummaryRequest) (*models.SummaryResponse, error) {
-	// Create temporary directory
-	tmpDir, err := os.MkdirTemp("", "scad-summary-*")
+func (s *OpenSCADService) Summary(ctx context.Context, req *models.SummaryRequest) (*models.SummaryResponse, error) {
+	logger := logging.FromContext(ctx).With("operation", "summary", "summary_type", req.SummaryType)
+
+	if err := ValidateSummaryType(req.SummaryType); err != nil {
+		return nil, err
+	}
+	version, binary, err := s.binaryFor(req.OpenSCADVersion)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+		return nil, err
 	}
-	defer func() {
-		if err := os.RemoveAll(tmpDir); err != nil {
-			// Log error but don't fail the operation
-			fmt.Fprintf(os.Stderr, "warning: failed to remove temp directory %s: %v\n", tmpDir, err)
-		}
-	}()
+	logger = logger.With("openscad_version", version)
 
-	// Write SCAD content to temporary file
-	scadFile := filepath.Join(tmpDir, "input.scad")
-	if err := os.WriteFile(scadFile, []byte(req.ScadContent), 0644); err != nil {
-		return nil, fmt.Errorf("failed to write SCAD file: %w", err)
+	renderArgs, err := s.renderArgs(ctx, version, req.Backend, req.Features)
+	if err != nil {
+		return nil, err
+	}
+	paramArgs, err := parameterArgs(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkFonts(ctx, req.ScadContent); err != nil {
+		return nil, err
+	}
+	if err := s.validateAssets(req.Assets, nil); err != nil {
+		return nil, err
+	}
+
+	if err := s.beginWork(); err != nil {
+		return nil, err
+	}
+	defer s.endWork()
+
+	// Create temporary directory holding the SCAD input
+	tmpDir, scadFile, err := s.prepareWorkDir(ctx, logger, "scad-summary-*", req.ScadContent, req.Assets)
+	if err != nil {
+		return nil, err
 	}
+	defer s.removeWorkDir(logger, tmpDir)
 
 	// Create summary output file
 	summaryFile := filepath.Join(tmpDir, "summary.json")
@@ -157,46 +377,30 @@ func (s *OpenSCADService) Summary(req *models.SummaryRequest) (*models.SummaryRe
 		summaryType = "all"
 	}
 
-	args := []string{
+	args := append(renderArgs, paramArgs...)
+	args = append(args,
 		"--summary", summaryType,
 		"--summary-file", summaryFile,
 		"-o", filepath.Join(tmpDir, "dummy.stl"),
 		scadFile,
-	}
+	)
 
 	// Execute OpenSCAD command
-	if err := s.executeCommand(args); err != nil {
+	if err := s.executeCommand(ctx, logger, binary, "summary", args); err != nil {
 		return nil, err
 	}
 
 	// Read summary file
-	data, err := os.ReadFile(summaryFile)
+	data, err := readOutput(ctx, summaryFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read summary file: %w", err)
 	}
 
-	// Parse summary JSON
-	var summary map[string]interface{}
-	if err := json.Unmarshal(data, &summary); err != nil {
-		return nil, fmt.Errorf("failed to parse summary JSON: %w", err)
-	}
-
-	return &models.SummaryResponse{Summary: summary}, nil
+	return parseSummary(logger, data)
 }
 
 func (s *OpenSCADService) validateFormat(format string) error {
-	validFormats := map[string]bool{
-		"png":        true,
-		"stl_binary": true,
-		"stl_ascii":  true,
-		"svg":        true,
-		"pdf":        true,
-		"3mf":        true,
-		"webp":       true,
-		"avif":       true,
-	}
-
-	if !validFormats[format] {
+	if !slices.Contains(supportedFormats, format) {
 		return fmt.Errorf("unsupported format: %s", format)
 	}
 
@@ -242,6 +446,9 @@ func (s *OpenSCADService) buildExportOptions(req *models.ExportRequest) []string
 				}
 				args = append(args, "--imgsize", fmt.Sprintf("%d,%d", width, height))
 			}
+			if req.Options.PNG.Camera != nil {
+				args = append(args, "--camera="+*req.Options.PNG.Camera)
+			}
 		}
 
 	case "stl_binary", "stl_ascii":
@@ -343,6 +550,16 @@ func (s *OpenSCADService) buildExportOptions(req *models.ExportRequest) []string
 	return args
 }
 
+// FileExtension returns the file name extension of files exported in format
+func FileExtension(format string) string {
+	switch format {
+	case "stl_binary", "stl_ascii":
+		return "stl"
+	default:
+		return format
+	}
+}
+
 func (s *OpenSCADService) getContentType(format string) string {
 	switch format {
 	case "png":
@@ -364,11 +581,51 @@ func (s *OpenSCADService) getContentType(format string) string {
 	}
 }
 
-func (s *OpenSCADService) executeCommand(args []string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+// acquireSlot blocks until an OpenSCAD process slot is free or ctx is done
+func (s *OpenSCADService) acquireSlot(ctx context.Context) (err error) {
+	_, span := tracing.Start(ctx, "openscad.wait_slot")
+	defer func() { tracing.End(span, err) }()
+
+	s.queued.Add(1)
+	metrics.RenderQueueDepth.Inc()
+	defer func() {
+		s.queued.Add(-1)
+		metrics.RenderQueueDepth.Dec()
+	}()
+
+	select {
+	case s.slots <- struct{}{}:
+		metrics.RendersInFlight.Inc()
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for render slot: %w", ctx.Err())
+	case <-s.abort:
+		return fmt.Errorf("waiting for render slot: %w", ErrShuttingDown)
+	}
+}
+
+// releaseSlot frees a slot taken by acquireSlot
+func (s *OpenSCADService) releaseSlot() {
+	metrics.RendersInFlight.Dec()
+	<-s.slots
+}
+
+// executeCommand runs the OpenSCAD binary with args; format labels the render metrics
+func (s *OpenSCADService) executeCommand(ctx context.Context, logger *slog.Logger, binary, format string, args []string) (err error) {
+	if err := s.acquireSlot(ctx); err != nil {
+		return err
+	}
+	defer s.releaseSlot()
+
+	ctx, span := tracing.Start(ctx, "openscad.exec", attribute.String("openscad.format", format))
+	defer func() { tracing.End(span, err) }()
+
+	timeout, _, _ := s.settings()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, openscadCmd, args...)
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Env = s.commandEnv()
 
 	// Set working directory to temp dir if available
 	if len(args) > 0 {
@@ -378,7 +635,6 @@ func (s *OpenSCADService) executeCommand(args []string) error {
 				dir := filepath.Dir(arg)
 				if _, err := os.Stat(dir); err == nil {
 					cmd.Dir = dir
-					log.Printf("[OpenSCAD Export] Set working directory: %s", dir)
 					break
 				}
 			}
@@ -389,17 +645,85 @@ func (s *OpenSCADService) executeCommand(args []string) error {
 	cmd.Stdout = &combinedOutput
 	cmd.Stderr = &combinedOutput
 
-	log.Printf("[OpenSCAD Export] Running command: %v (Dir: %s)", cmd.Args, cmd.Dir)
+	logger.Debug("running openscad", "args", cmd.Args, "dir", cmd.Dir)
 
-	err := cmd.Run()
-	log.Printf("[OpenSCAD Export] Combined output (exit code %d):\n%s", cmd.ProcessState.ExitCode(), combinedOutput.String())
-	if err != nil || cmd.ProcessState.ExitCode() != 0 {
+	start := time.Now()
+	runErr := s.run(cmd)
+	elapsed := time.Since(start)
+	recordRenderTime(ctx, elapsed)
+
+	// ProcessState is nil if the process could not be started
+	exitCode := -1
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+	metrics.RenderDuration.WithLabelValues(format).Observe(elapsed.Seconds())
+	metrics.RenderExitCodes.WithLabelValues(strconv.Itoa(exitCode)).Inc()
+	recordRenderResult(ctx, RenderResult{ExitCode: exitCode, Duration: elapsed, Output: combinedOutput.String()})
+	span.SetAttributes(attribute.Int("openscad.exit_code", exitCode))
+
+	logger.Debug("openscad output", "exit_code", exitCode, "output", combinedOutput.String())
+	if runErr != nil || exitCode != 0 {
+		if s.aborted() {
+			logger.Warn("openscad killed during shutdown", "duration", elapsed)
+			return fmt.Errorf("openscad command aborted: %w", ErrShuttingDown)
+		}
 		if ctx.Err() == context.DeadlineExceeded {
-			log.Printf("[OpenSCAD Export] Command timed out")
+			metrics.RenderTimeouts.Inc()
+			logger.Warn("openscad timed out", "timeout", timeout, "duration", elapsed)
 			return fmt.Errorf("openscad command timed out")
 		}
-		log.Printf("[OpenSCAD Export] Command failed: %v", err)
-		return fmt.Errorf("openscad command failed: %w, output: %s", err, combinedOutput.String())
+		logger.Warn("openscad failed", "exit_code", exitCode, "duration", elapsed, "error", runErr)
+		return fmt.Errorf("openscad command failed: %w, output: %s", runErr, combinedOutput.String())
 	}
+	logger.Info("openscad finished", "exit_code", exitCode, "duration", elapsed)
 	return nil
 }
+
+// prepareWorkDir creates a temp directory named after pattern and writes the
+// SCAD source and its assets into it, returning the directory and the input
+// file path
+func (s *OpenSCADService) prepareWorkDir(ctx context.Context, logger *slog.Logger, pattern, scadContent string, assets []models.Asset) (dir, scadFile string, err error) {
+	_, span := tracing.Start(ctx, "openscad.prepare_workdir")
+	defer func() { tracing.End(span, err) }()
+
+	dir, err = os.MkdirTemp(s.tempDir, pattern)
+	if err != nil {
+		metrics.TempDirFailures.Inc()
+		logger.Error("failed to create temp dir", "error", err)
+		return "", "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	s.trackDir(dir)
+	logger.Debug("created temp dir", "dir", dir)
+
+	// Write SCAD content to temporary file
+	scadFile = filepath.Join(dir, "input.scad")
+	if err := os.WriteFile(scadFile, []byte(scadContent), 0644); err != nil {
+		metrics.TempDirFailures.Inc()
+		logger.Error("failed to write SCAD file", "path", scadFile, "error", err)
+		s.removeWorkDir(logger, dir)
+		return "", "", fmt.Errorf("failed to write SCAD file: %w", err)
+	}
+
+	if err := writeAssets(dir, assets); err != nil {
+		metrics.TempDirFailures.Inc()
+		logger.Error("failed to write assets", "error", err)
+		s.removeWorkDir(logger, dir)
+		return "", "", err
+	}
+	if len(assets) > 0 {
+		logger.Debug("wrote assets", "count", len(assets))
+	}
+	return dir, scadFile, nil
+}
+
+// readOutput reads a file produced by OpenSCAD
+func readOutput(ctx context.Context, path string) (data []byte, err error) {
+	_, span := tracing.Start(ctx, "openscad.read_output")
+	defer func() {
+		span.SetAttributes(attribute.Int("output.bytes", len(data)))
+		tracing.End(span, err)
+	}()
+
+	return os.ReadFile(path)
+}
@@ -1,7 +1,10 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stevexciv/scad-server/models"
 )
@@ -124,6 +127,20 @@ func TestBuildExportOptions(t *testing.T) {
 		}
 	})
 
+	t.Run("PNG camera", func(t *testing.T) {
+		camera := "0,0,0,55,0,25,140"
+		req := &models.ExportRequest{
+			Format: "png",
+			Options: models.ExportOptions{
+				PNG: &models.PNGOptions{Camera: &camera},
+			},
+		}
+		args := service.buildExportOptions(req)
+		if len(args) != 1 || args[0] != "--camera=0,0,0,55,0,25,140" {
+			t.Errorf("Expected [--camera=0,0,0,55,0,25,140], got %v", args)
+		}
+	})
+
 	t.Run("SVG options", func(t *testing.T) {
 		fill := true
 		fillColor := "red"
@@ -265,3 +282,55 @@ func TestBuildExportOptions(t *testing.T) {
 		}
 	})
 }
+
+func TestAcquireSlot(t *testing.T) {
+	service := NewOpenSCADService()
+	service.slots = make(chan struct{}, 1)
+
+	if err := service.acquireSlot(context.Background()); err != nil {
+		t.Fatalf("Expected first slot to be acquired, got %v", err)
+	}
+
+	// With the only slot taken, a second caller waits until its context ends
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := service.acquireSlot(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded while waiting for slot, got %v", err)
+	}
+
+	service.releaseSlot()
+	if err := service.acquireSlot(context.Background()); err != nil {
+		t.Errorf("Expected slot to be acquired after release, got %v", err)
+	}
+}
+
+func TestUpdateConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxConcurrentRenders = 2
+	service := NewOpenSCADServiceWithConfig(cfg)
+
+	if cap(service.slots) != 2 {
+		t.Errorf("Expected 2 render slots, got %d", cap(service.slots))
+	}
+
+	cfg.Timeout = 30 * time.Second
+	cfg.WebPQuality = 50
+	cfg.MaxConcurrentRenders = 8
+	cfg.Binary = "openscad-nightly"
+	service.UpdateConfig(cfg)
+
+	timeout, webpQuality, _ := service.settings()
+	if timeout != 30*time.Second {
+		t.Errorf("Expected timeout 30s, got %s", timeout)
+	}
+	if webpQuality != 50 {
+		t.Errorf("Expected WebP quality 50, got %d", webpQuality)
+	}
+	// Settings that need a new service are left untouched
+	if cap(service.slots) != 2 {
+		t.Errorf("Expected render slots to stay at 2, got %d", cap(service.slots))
+	}
+	if service.binaries[DefaultVersionName] != defaultBinary {
+		t.Errorf("Expected binary to stay %s, got %s", defaultBinary, service.binaries[DefaultVersionName])
+	}
+}
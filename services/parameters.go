@@ -0,0 +1,93 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+// ErrInvalidParameter is returned when a request sets a customizer parameter
+// or camera that cannot be passed to OpenSCAD
+var ErrInvalidParameter = errors.New("invalid render parameter")
+
+var (
+	// parameterName matches OpenSCAD variable names, including special
+	// variables such as $fn
+	parameterName = regexp.MustCompile(`^\$?[A-Za-z_][A-Za-z0-9_]*$`)
+	// scadEscaper escapes a string for use in an OpenSCAD string literal
+	scadEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+)
+
+// parameterArgs returns the -D arguments overriding the top-level variables
+// in params, sorted by name so identical requests produce identical commands
+func parameterArgs(params map[string]any) ([]string, error) {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		if !parameterName.MatchString(name) {
+			return nil, fmt.Errorf("%w: %q is not a valid variable name", ErrInvalidParameter, name)
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	args := make([]string, 0, 2*len(names))
+	for _, name := range names {
+		value, err := scadLiteral(params[name])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidParameter, name, err)
+		}
+		args = append(args, "-D", name+"="+value)
+	}
+	return args, nil
+}
+
+// scadLiteral formats a JSON value as an OpenSCAD literal. Numbers, booleans,
+// strings and (nested) vectors of them are supported.
+func scadLiteral(v any) (string, error) {
+	switch t := v.(type) {
+	case float64:
+		return strconv.FormatFloat(t, 'g', -1, 64), nil
+	case int:
+		return strconv.Itoa(t), nil
+	case bool:
+		return strconv.FormatBool(t), nil
+	case string:
+		return `"` + scadEscaper.Replace(t) + `"`, nil
+	case []any:
+		items := make([]string, len(t))
+		for i, item := range t {
+			s, err := scadLiteral(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return "[" + strings.Join(items, ",") + "]", nil
+	default:
+		return "", fmt.Errorf("unsupported value %v, expected a number, boolean, string or vector", v)
+	}
+}
+
+// validateCamera checks that a PNG camera holds the numbers OpenSCAD's
+// --camera accepts: 7 for translation, rotation and distance, or 6 for eye and
+// center positions
+func validateCamera(opts *models.PNGOptions) error {
+	if opts == nil || opts.Camera == nil {
+		return nil
+	}
+	parts := strings.Split(*opts.Camera, ",")
+	if len(parts) != 6 && len(parts) != 7 {
+		return fmt.Errorf("%w: camera needs 6 or 7 comma-separated numbers, got %d", ErrInvalidParameter, len(parts))
+	}
+	for _, p := range parts {
+		if _, err := strconv.ParseFloat(strings.TrimSpace(p), 64); err != nil {
+			return fmt.Errorf("%w: camera value %q is not a number", ErrInvalidParameter, p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+func TestParameterArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]any
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "None",
+			params: nil,
+			want:   []string{},
+		},
+		{
+			name:   "Sorted scalars",
+			params: map[string]any{"width": 20.5, "$fn": 64.0, "hollow": true, "count": 3},
+			want:   []string{"-D", "$fn=64", "-D", "count=3", "-D", "hollow=true", "-D", "width=20.5"},
+		},
+		{
+			name:   "Escaped string",
+			params: map[string]any{"label": "say \"hi\"\\\n"},
+			want:   []string{"-D", `label="say \"hi\"\\\n"`},
+		},
+		{
+			name:   "Nested vectors",
+			params: map[string]any{"points": []any{[]any{0.0, 1.0}, []any{2.0, 3e-7}}},
+			want:   []string{"-D", "points=[[0,1],[2,3e-07]]"},
+		},
+		{
+			name:    "Expression as name",
+			params:  map[string]any{"x=1;y": 2.0},
+			wantErr: true,
+		},
+		{
+			name:    "Object value",
+			params:  map[string]any{"size": map[string]any{"x": 1.0}},
+			wantErr: true,
+		},
+		{
+			name:    "Null value",
+			params:  map[string]any{"size": nil},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parameterArgs(tt.params)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidParameter) {
+					t.Errorf("Expected ErrInvalidParameter, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateCamera(t *testing.T) {
+	tests := []struct {
+		name    string
+		camera  string
+		wantErr bool
+	}{
+		{"Gimbal", "0,0,0,55,0,25,140", false},
+		{"Vector", "10, 10, 10, 0, 0, 0", false},
+		{"Too few", "0,0,0", true},
+		{"Not a number", "0,0,0,55,0,25,far", true},
+		{"Option injection", "0,0,0,55,0,25,140 --render", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCamera(&models.PNGOptions{Camera: &tt.camera})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidParameter) {
+				t.Errorf("Expected ErrInvalidParameter, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+//go:build unix
+
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+func TestExport_Parameters(t *testing.T) {
+	// The fake OpenSCAD writes its arguments to the output file, one per line
+	service := newFakeService(t, `out=""
+for arg; do [ "$prev" = "-o" ] && out="$arg"; prev="$arg"; done
+printf '%s\n' "$@" > "$out"`)
+
+	result, err := service.Export(context.Background(), &models.ExportRequest{
+		ScadContent: "cube(size);",
+		Format:      "stl_ascii",
+		Parameters:  map[string]any{"size": 12.0, "label": `a "b"`},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if args := string(result.Data); !strings.Contains(args, "-D\nlabel=\"a \\\"b\\\"\"\n-D\nsize=12\n") {
+		t.Errorf("Expected -D arguments before the input file, got %q", args)
+	}
+
+	_, err = service.Export(context.Background(), &models.ExportRequest{
+		ScadContent: "cube(size);",
+		Format:      "stl_ascii",
+		Parameters:  map[string]any{"size; echo()": 1.0},
+	})
+	if err == nil || !strings.Contains(err.Error(), "not a valid variable name") {
+		t.Errorf("Expected invalid parameter error, got %v", err)
+	}
+}
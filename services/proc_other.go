@@ -0,0 +1,16 @@
+//go:build !unix
+
+package services
+
+import "os/exec"
+
+// configureProcessGroup is a no-op on platforms without process groups
+func configureProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills cmd's process
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/exec"
+)
+
+// ErrShuttingDown is returned for renders requested after Shutdown has begun
+// and for renders aborted when the shutdown grace period runs out
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// Drainer is implemented by exporters that can report a shutdown in progress
+type Drainer interface {
+	Draining() bool
+}
+
+// Draining reports whether Shutdown has been called
+func (s *OpenSCADService) Draining() bool {
+	s.workMu.Lock()
+	defer s.workMu.Unlock()
+
+	return s.draining
+}
+
+// Shutdown stops accepting renders and waits for in-flight ones to finish.
+// If ctx ends first, the remaining OpenSCAD process groups are killed, their
+// working directories removed and ctx's error returned.
+func (s *OpenSCADService) Shutdown(ctx context.Context) error {
+	s.workMu.Lock()
+	s.draining = true
+	s.workMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.workMu.Lock()
+	defer s.workMu.Unlock()
+
+	s.abortOnce.Do(func() { close(s.abort) })
+	for cmd := range s.procs {
+		if err := killProcessGroup(cmd); err != nil {
+			slog.Warn("failed to kill openscad", "pid", cmd.Process.Pid, "error", err)
+		}
+	}
+	for dir := range s.workDirs {
+		if err := os.RemoveAll(dir); err != nil {
+			slog.Warn("failed to remove temp dir", "dir", dir, "error", err)
+		}
+	}
+	slog.Warn("aborted in-flight renders", "processes", len(s.procs), "temp_dirs", len(s.workDirs))
+	return ctx.Err()
+}
+
+// beginWork registers a render with the drain tracking; every successful call
+// must be paired with endWork
+func (s *OpenSCADService) beginWork() error {
+	s.workMu.Lock()
+	defer s.workMu.Unlock()
+
+	if s.draining {
+		return ErrShuttingDown
+	}
+	s.inflight.Add(1)
+	return nil
+}
+
+// endWork marks a render registered by beginWork as finished
+func (s *OpenSCADService) endWork() {
+	s.inflight.Done()
+}
+
+// aborted reports whether Shutdown gave up waiting and killed renders
+func (s *OpenSCADService) aborted() bool {
+	select {
+	case <-s.abort:
+		return true
+	default:
+		return false
+	}
+}
+
+// trackDir records a render working directory for cleanup on abort
+func (s *OpenSCADService) trackDir(dir string) {
+	s.workMu.Lock()
+	defer s.workMu.Unlock()
+
+	s.workDirs[dir] = struct{}{}
+}
+
+// removeWorkDir deletes a render working directory, logging rather than
+// failing on error
+func (s *OpenSCADService) removeWorkDir(logger *slog.Logger, dir string) {
+	s.workMu.Lock()
+	delete(s.workDirs, dir)
+	s.workMu.Unlock()
+
+	if err := os.RemoveAll(dir); err != nil {
+		logger.Warn("failed to remove temp dir", "dir", dir, "error", err)
+	}
+}
+
+// run starts cmd in its own process group and waits for it, keeping it
+// registered so Shutdown can kill it along with any children it spawned
+func (s *OpenSCADService) run(cmd *exec.Cmd) error {
+	configureProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	s.workMu.Lock()
+	s.procs[cmd] = struct{}{}
+	if s.aborted() {
+		// Shutdown already swept the registered processes
+		_ = killProcessGroup(cmd)
+	}
+	s.workMu.Unlock()
+
+	defer func() {
+		s.workMu.Lock()
+		delete(s.procs, cmd)
+		s.workMu.Unlock()
+	}()
+
+	return cmd.Wait()
+}
@@ -0,0 +1,111 @@
+//go:build unix
+
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+// newFakeService returns a service whose OpenSCAD binary is a shell script
+// with the given body
+func newFakeService(t *testing.T, script string) *OpenSCADService {
+	t.Helper()
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "openscad")
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatalf("Failed to write fake openscad: %v", err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Binary = binary
+	cfg.TempDir = t.TempDir()
+	return NewOpenSCADServiceWithConfig(cfg)
+}
+
+// waitForProcess blocks until service has a running OpenSCAD process
+func waitForProcess(t *testing.T, service *OpenSCADService) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		service.workMu.Lock()
+		n := len(service.procs)
+		service.workMu.Unlock()
+		if n > 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Timed out waiting for openscad to start")
+}
+
+func TestShutdown_WaitsForRenders(t *testing.T) {
+	// The fake writes the summary file named after --summary-file
+	service := newFakeService(t, `sleep 0.2; echo '{"ok": true}' > "$4"`)
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := service.Summary(context.Background(), &models.SummaryRequest{ScadContent: "cube(1);"})
+		result <- err
+	}()
+	waitForProcess(t, service)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := service.Shutdown(ctx); err != nil {
+		t.Fatalf("Expected drain to complete, got %v", err)
+	}
+	if err := <-result; err != nil {
+		t.Errorf("Expected in-flight render to succeed, got %v", err)
+	}
+	if !service.Draining() {
+		t.Errorf("Expected service to report draining")
+	}
+
+	_, err := service.Summary(context.Background(), &models.SummaryRequest{ScadContent: "cube(1);"})
+	if !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("Expected ErrShuttingDown for new render, got %v", err)
+	}
+}
+
+func TestShutdown_KillsRendersAfterGracePeriod(t *testing.T) {
+	// The child sleep keeps the output pipe open, so the render only ends
+	// early if the whole process group is killed
+	service := newFakeService(t, "sleep 30")
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := service.Export(context.Background(), &models.ExportRequest{ScadContent: "cube(1);", Format: "stl_binary"})
+		result <- err
+	}()
+	waitForProcess(t, service)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := service.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrShuttingDown) {
+			t.Errorf("Expected ErrShuttingDown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected render to be killed")
+	}
+
+	entries, err := os.ReadDir(service.tempDir)
+	if err != nil {
+		t.Fatalf("Failed to read temp dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected temp dirs to be removed, found %d entries", len(entries))
+	}
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+// SummaryTypes lists the summary types OpenSCAD's --summary accepts
+var SummaryTypes = []string{"all", "cache", "time", "camera", "geometry", "bounding-box", "area"}
+
+// ErrUnknownSummaryType is returned for summary types OpenSCAD doesn't know
+var ErrUnknownSummaryType = errors.New("unknown summary type")
+
+// ValidateSummaryType checks that summaryType is one of SummaryTypes; ""
+// stands for "all"
+func ValidateSummaryType(summaryType string) error {
+	if summaryType != "" && !slices.Contains(SummaryTypes, summaryType) {
+		return fmt.Errorf("%w: %s, expected one of %s", ErrUnknownSummaryType, summaryType, strings.Join(SummaryTypes, ", "))
+	}
+	return nil
+}
+
+// parseSummary decodes the summary file OpenSCAD wrote. Sections that don't
+// decode into their typed form, for example because a newer OpenSCAD changed
+// them, are left out of the typed summary but stay in the raw JSON.
+func parseSummary(logger *slog.Logger, data []byte) (*models.SummaryResponse, error) {
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return nil, fmt.Errorf("failed to parse summary JSON: %w", err)
+	}
+
+	var raw bytes.Buffer
+	if err := json.Compact(&raw, data); err != nil {
+		return nil, fmt.Errorf("failed to parse summary JSON: %w", err)
+	}
+	summary := models.Summary{
+		Cache:       decodeSection[models.SummaryCache](logger, sections, "cache"),
+		Time:        decodeSection[models.SummaryTime](logger, sections, "time"),
+		Camera:      decodeSection[models.SummaryCamera](logger, sections, "camera"),
+		Geometry:    decodeSection[models.SummaryGeometry](logger, sections, "geometry"),
+		BoundingBox: decodeSection[models.SummaryBoundingBox](logger, sections, "bounding_box"),
+		Area:        decodeSection[models.SummaryArea](logger, sections, "area"),
+	}
+	return &models.SummaryResponse{Summary: summary, Raw: raw.Bytes()}, nil
+}
+
+// decodeSection decodes the named summary section, or returns nil if it is
+// missing or doesn't decode
+func decodeSection[T any](logger *slog.Logger, sections map[string]json.RawMessage, name string) *T {
+	section, ok := sections[name]
+	if !ok {
+		return nil
+	}
+	var v T
+	if err := json.Unmarshal(section, &v); err != nil {
+		logger.Warn("summary section not understood, only in raw summary", "section", name, "error", err)
+		return nil
+	}
+	return &v
+}
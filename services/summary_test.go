@@ -0,0 +1,84 @@
+package services
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestValidateSummaryType(t *testing.T) {
+	tests := []struct {
+		summaryType string
+		wantErr     bool
+	}{
+		{"", false},
+		{"all", false},
+		{"cache", false},
+		{"time", false},
+		{"camera", false},
+		{"geometry", false},
+		{"bounding-box", false},
+		{"area", false},
+		{"bounding_box", true},
+		{"volume", true},
+		{"ALL", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.summaryType, func(t *testing.T) {
+			err := ValidateSummaryType(tt.summaryType)
+			if tt.wantErr && !errors.Is(err, ErrUnknownSummaryType) {
+				t.Errorf("Expected ErrUnknownSummaryType, got %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestParseSummary(t *testing.T) {
+	data := []byte(`{
+		"cache": {"geometry_cache_entries": 3, "geometry_cache_size": 1584, "cgal_cache_entries": 0, "cgal_cache_size": 0},
+		"time": {"time": "0:00:00.042", "hours": 0, "minutes": 0, "seconds": 0.042},
+		"camera": {"translation": [0, 0, 0], "rotation": [55, 0, 25], "distance": 140, "fov": 22.5},
+		"geometry": {"dimensions": 3, "convex": true, "vertices": 8, "facets": 6,
+			"bounding_box": {"min": [0, 0, 0], "max": [10, 10, 10], "size": [10, 10, 10]}},
+		"bounding_box": {"min": [0, 0, 0], "max": [10, 10, 10], "size": [10, 10, 10]},
+		"area": "not yet understood",
+		"future": {"answer": 42}
+	}`)
+
+	response, err := parseSummary(slog.Default(), data)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	s := response.Summary
+	if s.Cache == nil || s.Cache.GeometryCacheSize != 1584 {
+		t.Errorf("Expected the cache section, got %+v", s.Cache)
+	}
+	if s.Time == nil || s.Time.Seconds != 0.042 || s.Time.Time != "0:00:00.042" {
+		t.Errorf("Expected the time section, got %+v", s.Time)
+	}
+	if s.Camera == nil || s.Camera.FOV != 22.5 || len(s.Camera.Rotation) != 3 {
+		t.Errorf("Expected the camera section, got %+v", s.Camera)
+	}
+	g := s.Geometry
+	if g == nil || g.Dimensions != 3 || g.Convex == nil || !*g.Convex || g.Facets == nil || *g.Facets != 6 || g.Contours != nil || g.BoundingBox == nil {
+		t.Errorf("Expected the 3D geometry section, got %+v", g)
+	}
+	if s.BoundingBox == nil || s.BoundingBox.Size[2] != 10 {
+		t.Errorf("Expected the bounding box section, got %+v", s.BoundingBox)
+	}
+	if s.Area != nil {
+		t.Errorf("Expected a section that doesn't decode to be left out, got %+v", s.Area)
+	}
+	if want := `"future":{"answer":42}`; !strings.Contains(string(response.Raw), want) {
+		t.Errorf("Expected the raw summary to keep %s, got %s", want, response.Raw)
+	}
+
+	if _, err := parseSummary(slog.Default(), []byte(`[1, 2]`)); err == nil {
+		t.Error("Expected an error for a summary that isn't an object")
+	}
+}
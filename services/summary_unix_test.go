@@ -0,0 +1,78 @@
+//go:build unix
+
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+func TestSummary_Typed(t *testing.T) {
+	service := newFakeService(t, `echo '{"geometry": {"dimensions": 2, "convex": true, "contours": 1}, "area": {"area": 100}}' > "$4"`)
+
+	response, err := service.Summary(context.Background(), &models.SummaryRequest{ScadContent: "square(10);", SummaryType: "all"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if g := response.Summary.Geometry; g == nil || g.Dimensions != 2 || g.Contours == nil || *g.Contours != 1 || g.Facets != nil {
+		t.Errorf("Expected the 2D geometry section, got %+v", g)
+	}
+	if a := response.Summary.Area; a == nil || a.Area != 100 {
+		t.Errorf("Expected the area section, got %+v", a)
+	}
+	if string(response.Raw) != `{"geometry":{"dimensions":2,"convex":true,"contours":1},"area":{"area":100}}` {
+		t.Errorf("Expected the compacted raw summary, got %s", response.Raw)
+	}
+}
+
+func TestSummary_UnknownTypeNotRendered(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "ran")
+	service := newFakeService(t, `touch "`+marker+`"`)
+
+	_, err := service.Summary(context.Background(), &models.SummaryRequest{ScadContent: "cube(1);", SummaryType: "volume"})
+	if !errors.Is(err, ErrUnknownSummaryType) {
+		t.Errorf("Expected ErrUnknownSummaryType, got %v", err)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("Expected OpenSCAD not to run for an unknown summary type")
+	}
+}
+
+func TestExport_WithSummary(t *testing.T) {
+	service := newFakeService(t, `while [ $# -gt 0 ]; do
+  case "$1" in
+    -o) out="$2"; shift ;;
+    --summary-file) summary="$2"; shift ;;
+  esac
+  shift
+done
+echo solid > "$out"
+[ -n "$summary" ] && echo '{"geometry": {"dimensions": 3, "facets": 6}}' > "$summary"
+exit 0`)
+
+	ctx := context.Background()
+	result, err := service.Export(ctx, &models.ExportRequest{ScadContent: "cube(1);", Format: "stl_ascii", SummaryType: "geometry"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(result.Data) != "solid\n" {
+		t.Errorf("Expected the exported file, got %q", result.Data)
+	}
+	if s := result.Summary; s == nil || s.Summary.Geometry == nil || *s.Summary.Geometry.Facets != 6 {
+		t.Fatalf("Expected the summary of the same run, got %+v", s)
+	}
+
+	// Without a summary type there is no summary
+	if result, err := service.Export(ctx, &models.ExportRequest{ScadContent: "cube(1);", Format: "stl_ascii"}); err != nil || result.Summary != nil {
+		t.Errorf("Expected no summary, got %+v, %v", result, err)
+	}
+
+	if _, err := service.Export(ctx, &models.ExportRequest{ScadContent: "cube(1);", Format: "stl_ascii", SummaryType: "volume"}); !errors.Is(err, ErrUnknownSummaryType) {
+		t.Errorf("Expected ErrUnknownSummaryType, got %v", err)
+	}
+}
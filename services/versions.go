@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+// DefaultVersionName names the installation built from Config.Binary when no
+// versions are configured
+const DefaultVersionName = "default"
+
+// ErrUnknownVersion is returned when a request names an OpenSCAD version that
+// is not installed
+var ErrUnknownVersion = errors.New("unknown openscad version")
+
+// resolveVersions returns the installations described by cfg and the name of
+// the default one
+func resolveVersions(cfg Config) (map[string]string, string) {
+	if len(cfg.Versions) == 0 {
+		return map[string]string{DefaultVersionName: cfg.Binary}, DefaultVersionName
+	}
+
+	binaries := maps.Clone(cfg.Versions)
+	defaultVersion := cfg.DefaultVersion
+	if _, ok := binaries[defaultVersion]; !ok {
+		defaultVersion = slices.Sorted(maps.Keys(binaries))[0]
+	}
+	return binaries, defaultVersion
+}
+
+// binaryFor resolves a requested version name, "" meaning the default, to the
+// installation name and its binary
+func (s *OpenSCADService) binaryFor(version string) (name, binary string, err error) {
+	if version == "" {
+		version = s.defaultVersion
+	}
+	binary, ok := s.binaries[version]
+	if !ok {
+		return "", "", fmt.Errorf("%w: %s", ErrUnknownVersion, version)
+	}
+	return version, binary, nil
+}
+
+// VersionNames returns the names of the configured installations in order
+func (s *OpenSCADService) VersionNames() []string {
+	return slices.Sorted(maps.Keys(s.binaries))
+}
+
+// DefaultVersion returns the installation used when a request doesn't choose one
+func (s *OpenSCADService) DefaultVersion() string {
+	return s.defaultVersion
+}
+
+// CheckBinaries runs every installation with --version and returns the
+// version line each printed. Failures are joined into one error.
+func (s *OpenSCADService) CheckBinaries(ctx context.Context) (map[string]string, error) {
+	versions := make(map[string]string, len(s.binaries))
+	var errs []error
+	for _, name := range s.VersionNames() {
+		line, err := s.CheckBinary(ctx, name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		versions[name] = line
+	}
+	return versions, errors.Join(errs...)
+}
+
+// Versions describes every configured installation
+func (s *OpenSCADService) Versions(ctx context.Context) ([]models.OpenSCADVersion, error) {
+	names := s.VersionNames()
+	versions := make([]models.OpenSCADVersion, 0, len(names))
+	for _, name := range names {
+		info, err := s.Info(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, models.OpenSCADVersion{
+			Name:    name,
+			Version: info.Version,
+			Default: name == s.defaultVersion,
+		})
+	}
+	return versions, nil
+}
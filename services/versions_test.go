@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+func TestResolveVersions(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         Config
+		wantDefault string
+		wantCount   int
+	}{
+		{"Single binary", Config{Binary: "openscad"}, DefaultVersionName, 1},
+		{"Configured default", Config{Versions: map[string]string{"stable": "a", "nightly": "b"}, DefaultVersion: "stable"}, "stable", 2},
+		{"First name when default missing", Config{Versions: map[string]string{"stable": "a", "nightly": "b"}}, "nightly", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			binaries, defaultVersion := resolveVersions(tt.cfg)
+			if defaultVersion != tt.wantDefault {
+				t.Errorf("Expected default %s, got %s", tt.wantDefault, defaultVersion)
+			}
+			if len(binaries) != tt.wantCount {
+				t.Errorf("Expected %d installations, got %d", tt.wantCount, len(binaries))
+			}
+		})
+	}
+}
+
+func TestBinaryFor(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Versions = map[string]string{"stable": "/usr/bin/openscad", "nightly": "/opt/nightly/openscad"}
+	cfg.DefaultVersion = "stable"
+	service := NewOpenSCADServiceWithConfig(cfg)
+
+	tests := []struct {
+		version    string
+		wantName   string
+		wantBinary string
+		wantErr    bool
+	}{
+		{"", "stable", "/usr/bin/openscad", false},
+		{"nightly", "nightly", "/opt/nightly/openscad", false},
+		{"2019.05", "", "", true},
+	}
+
+	for _, tt := range tests {
+		name, binary, err := service.binaryFor(tt.version)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("binaryFor(%q) error = %v, wantErr %v", tt.version, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr && !errors.Is(err, ErrUnknownVersion) {
+			t.Errorf("Expected ErrUnknownVersion, got %v", err)
+		}
+		if name != tt.wantName || binary != tt.wantBinary {
+			t.Errorf("binaryFor(%q) = %s, %s; want %s, %s", tt.version, name, binary, tt.wantName, tt.wantBinary)
+		}
+	}
+}
+
+func TestExport_UnknownVersion(t *testing.T) {
+	service := NewOpenSCADService()
+
+	_, err := service.Export(context.Background(), &models.ExportRequest{
+		ScadContent:     "cube(1);",
+		Format:          "png",
+		OpenSCADVersion: "nightly",
+	})
+	if !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Expected ErrUnknownVersion, got %v", err)
+	}
+
+	_, err = service.Summary(context.Background(), &models.SummaryRequest{
+		ScadContent:     "cube(1);",
+		OpenSCADVersion: "nightly",
+	})
+	if !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Expected ErrUnknownVersion, got %v", err)
+	}
+}
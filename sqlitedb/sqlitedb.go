@@ -0,0 +1,85 @@
+// Package sqlitedb opens the SQLite databases behind the render history and
+// saved designs and keeps their schemas current. Each store versions its
+// tables separately, so several stores can share one database file.
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	// Registers the pure Go "sqlite" database/sql driver
+	_ "modernc.org/sqlite"
+)
+
+// versionsTable records the schema version of each store in a database
+const versionsTable = `
+CREATE TABLE IF NOT EXISTS schema_versions (
+	component TEXT    PRIMARY KEY,
+	version   INTEGER NOT NULL
+)`
+
+// Open opens or creates the database at path with WAL journaling and a busy
+// timeout, so other handles on the same file wait for each other
+func Open(path string) (*sql.DB, error) {
+	if path == "" {
+		return nil, errors.New("database path must not be empty")
+	}
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() +
+		"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows one writer at a time; a single connection avoids busy errors
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+// Migrate brings the tables of component up to version by running schema,
+// which must only create what doesn't exist yet
+func Migrate(ctx context.Context, db *sql.DB, component string, version int, schema string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, versionsTable); err != nil {
+		return err
+	}
+	var current int
+	err = tx.QueryRowContext(ctx, "SELECT version FROM schema_versions WHERE component = ?", component).Scan(&current)
+	switch {
+	case err != nil && !errors.Is(err, sql.ErrNoRows):
+		return err
+	case current == version:
+		return nil
+	case current > version:
+		return fmt.Errorf("%s schema version %d is newer than supported version %d", component, current, version)
+	}
+
+	if _, err := tx.ExecContext(ctx, schema); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_versions (component, version) VALUES (?, ?)
+		ON CONFLICT (component) DO UPDATE SET version = excluded.version`, component, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// NullJSON encodes v for a nullable JSON column, storing NULL when null is set
+func NullJSON(v any, null bool) (sql.NullString, error) {
+	if null {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
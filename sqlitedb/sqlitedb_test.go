@@ -0,0 +1,46 @@
+package sqlitedb
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrate_SharedDatabase(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(filepath.Join(t.TempDir(), "shared.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// Two components keep their own tables and versions in one file
+	if err := Migrate(ctx, db, "a", 1, "CREATE TABLE IF NOT EXISTS a (x INTEGER)"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := Migrate(ctx, db, "b", 2, "CREATE TABLE IF NOT EXISTS b (y INTEGER)"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// An up-to-date component doesn't run its schema again
+	if err := Migrate(ctx, db, "a", 1, "not sql"); err != nil {
+		t.Errorf("Expected an up-to-date schema to be left alone, got %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO a (x) VALUES (1); INSERT INTO b (y) VALUES (2)"); err != nil {
+		t.Errorf("Expected both tables to exist, got %v", err)
+	}
+
+	err = Migrate(ctx, db, "b", 1, "CREATE TABLE IF NOT EXISTS b (y INTEGER)")
+	if err == nil || !strings.Contains(err.Error(), "newer than supported") {
+		t.Errorf("Expected an error for a newer schema, got %v", err)
+	}
+}
+
+func TestNullJSON(t *testing.T) {
+	if v, err := NullJSON(map[string]int{"a": 1}, false); err != nil || !v.Valid || v.String != `{"a":1}` {
+		t.Errorf("Expected encoded JSON, got %+v, %v", v, err)
+	}
+	if v, err := NullJSON(nil, true); err != nil || v.Valid {
+		t.Errorf("Expected NULL, got %+v, %v", v, err)
+	}
+}
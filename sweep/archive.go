@@ -0,0 +1,170 @@
+package sweep
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+const (
+	// ManifestJSON is the archive entry holding the JSON manifest
+	ManifestJSON = "manifest.json"
+	// ManifestCSV is the archive entry holding the CSV manifest
+	ManifestCSV = "manifest.csv"
+)
+
+// Archive writes a zip archive of the rendered files of a sweep as results
+// are added, followed by the manifest as JSON and as CSV on Close
+type Archive struct {
+	zw       *zip.Writer
+	manifest models.SweepManifest
+}
+
+// NewArchive creates an archive of outputs in format written to w
+func NewArchive(w io.Writer, format string) *Archive {
+	return &Archive{
+		zw:       zip.NewWriter(w),
+		manifest: models.SweepManifest{Format: format, Results: []models.SweepVariant{}},
+	}
+}
+
+// Add lists result in the manifest and, when it succeeded, writes its file
+func (a *Archive) Add(result Result) error {
+	a.manifest.Variants++
+	a.manifest.Results = append(a.manifest.Results, result.SweepVariant)
+	if result.Err != nil {
+		a.manifest.Failed++
+		return nil
+	}
+	a.manifest.Succeeded++
+	return writeEntry(a.zw, result.File, result.Data)
+}
+
+// Close writes the manifests and finishes the archive, returning the
+// manifest of the results added
+func (a *Archive) Close() (models.SweepManifest, error) {
+	data, err := json.MarshalIndent(a.manifest, "", "  ")
+	if err != nil {
+		return a.manifest, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := writeEntry(a.zw, ManifestJSON, data); err != nil {
+		return a.manifest, err
+	}
+
+	f, err := a.zw.Create(ManifestCSV)
+	if err != nil {
+		return a.manifest, fmt.Errorf("failed to add %s: %w", ManifestCSV, err)
+	}
+	if err := writeCSV(f, a.manifest.Results); err != nil {
+		return a.manifest, fmt.Errorf("failed to write %s: %w", ManifestCSV, err)
+	}
+	return a.manifest, a.zw.Close()
+}
+
+// writeEntry adds a file to zw
+func writeEntry(zw *zip.Writer, name string, data []byte) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// writeCSV writes a row per variant: its index, file, status, error, size
+// and duration, then a "param.<name>" column per parameter and a
+// "summary.<path>" column per summary value, with nested summary objects
+// flattened to dotted paths
+func writeCSV(w io.Writer, variants []models.SweepVariant) error {
+	params := make(map[string]bool)
+	summaries := make([]map[string]string, len(variants))
+	summaryKeys := make(map[string]bool)
+	for i, v := range variants {
+		for name := range v.Parameters {
+			params[name] = true
+		}
+		summaries[i] = make(map[string]string)
+		if v.Summary != nil {
+			var summary map[string]any
+			data, _ := json.Marshal(v.Summary)
+			json.Unmarshal(data, &summary)
+			flatten(summaries[i], "", summary)
+		}
+		for key := range summaries[i] {
+			summaryKeys[key] = true
+		}
+	}
+	paramNames := slices.Sorted(maps.Keys(params))
+	summaryNames := slices.Sorted(maps.Keys(summaryKeys))
+
+	cw := csv.NewWriter(w)
+	header := []string{"index", "file", "status", "error", "output_bytes", "duration_seconds"}
+	for _, name := range paramNames {
+		header = append(header, "param."+name)
+	}
+	for _, name := range summaryNames {
+		header = append(header, "summary."+name)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for i, v := range variants {
+		row := []string{
+			strconv.Itoa(v.Index),
+			v.File,
+			v.Status,
+			v.Error,
+			strconv.FormatInt(v.OutputBytes, 10),
+			strconv.FormatFloat(v.DurationSeconds, 'f', 3, 64),
+		}
+		for _, name := range paramNames {
+			value, ok := v.Parameters[name]
+			if !ok {
+				row = append(row, "")
+				continue
+			}
+			row = append(row, cell(value))
+		}
+		for _, name := range summaryNames {
+			row = append(row, summaries[i][name])
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// flatten adds the values of summary to cells under their dotted paths
+func flatten(cells map[string]string, prefix string, summary map[string]any) {
+	for key, value := range summary {
+		if nested, ok := value.(map[string]any); ok {
+			flatten(cells, prefix+key+".", nested)
+			continue
+		}
+		cells[prefix+key] = cell(value)
+	}
+}
+
+// cell renders a value for the CSV manifest, encoding lists and objects as
+// JSON
+func cell(value any) string {
+	switch value.(type) {
+	case []any, map[string]any:
+		data, _ := json.Marshal(value)
+		return string(data)
+	default:
+		return format(value)
+	}
+}
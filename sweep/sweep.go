@@ -0,0 +1,240 @@
+// Package sweep renders a model once per parameter set of a grid or list and
+// packs the outputs into an archive with a manifest
+package sweep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/services"
+)
+
+const (
+	// DefaultMaxVariants is the number of variants a sweep may render when
+	// none is configured
+	DefaultMaxVariants = 100
+	// DefaultFilename names outputs when the request doesn't
+	DefaultFilename = "variant_{index}"
+)
+
+// ErrInvalid is returned for sweeps that can't be planned
+var ErrInvalid = errors.New("invalid sweep")
+
+var (
+	// placeholder matches the {name} placeholders of a filename template
+	placeholder = regexp.MustCompile(`\{([^{}]*)\}`)
+	// unsafeChars matches characters replaced in output file names
+	unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// Variant is one parameter set of a sweep with its output file name
+type Variant struct {
+	Parameters map[string]any
+	File       string
+}
+
+// Result is the outcome of rendering a variant
+type Result struct {
+	models.SweepVariant
+	Data []byte
+	Err  error
+}
+
+// Plan expands the grid or parameter sets of req into variants, each with
+// the request's parameters overridden by its own and named by the request's
+// filename template. Grid parameters are combined in name order with the
+// last name varying fastest. Sweeps of more than maxVariants variants are
+// rejected.
+func Plan(req *models.SweepRequest, maxVariants int) ([]Variant, error) {
+	if err := services.ValidateSummaryType(req.SummaryType); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	sets, err := expand(req, maxVariants)
+	if err != nil {
+		return nil, err
+	}
+
+	template := req.Filename
+	if template == "" {
+		template = DefaultFilename
+	}
+	width := len(strconv.Itoa(len(sets)))
+	extension := "." + services.FileExtension(req.Format)
+
+	variants := make([]Variant, len(sets))
+	seen := make(map[string]int, len(sets))
+	for i, set := range sets {
+		params := maps.Clone(req.Parameters)
+		if params == nil {
+			params = make(map[string]any, len(set))
+		}
+		maps.Copy(params, set)
+
+		name, err := filename(template, fmt.Sprintf("%0*d", width, i+1), params)
+		if err != nil {
+			return nil, err
+		}
+		file := name + extension
+		if prev, ok := seen[file]; ok {
+			return nil, fmt.Errorf("%w: filename template gives variants %d and %d the name %s", ErrInvalid, prev, i+1, file)
+		}
+		seen[file] = i + 1
+		variants[i] = Variant{Parameters: params, File: file}
+	}
+	return variants, nil
+}
+
+// expand returns the parameter sets of req
+func expand(req *models.SweepRequest, maxVariants int) ([]map[string]any, error) {
+	switch {
+	case len(req.Grid) > 0 && len(req.Sets) > 0:
+		return nil, fmt.Errorf("%w: give either grid or sets, not both", ErrInvalid)
+	case len(req.Sets) > 0:
+		if len(req.Sets) > maxVariants {
+			return nil, fmt.Errorf("%w: %d parameter sets, limit is %d", ErrInvalid, len(req.Sets), maxVariants)
+		}
+		return req.Sets, nil
+	case len(req.Grid) == 0:
+		return nil, fmt.Errorf("%w: grid or sets is required", ErrInvalid)
+	}
+
+	names := slices.Sorted(maps.Keys(req.Grid))
+	count := 1
+	for _, name := range names {
+		values := req.Grid[name]
+		if len(values) == 0 {
+			return nil, fmt.Errorf("%w: grid parameter %s has no values", ErrInvalid, name)
+		}
+		count *= len(values)
+		if count > maxVariants {
+			return nil, fmt.Errorf("%w: grid has more than %d combinations", ErrInvalid, maxVariants)
+		}
+	}
+
+	sets := []map[string]any{{}}
+	for _, name := range names {
+		next := make([]map[string]any, 0, len(sets)*len(req.Grid[name]))
+		for _, set := range sets {
+			for _, value := range req.Grid[name] {
+				combined := maps.Clone(set)
+				combined[name] = value
+				next = append(next, combined)
+			}
+		}
+		sets = next
+	}
+	return sets, nil
+}
+
+// filename fills the {index} and {parameter} placeholders of template
+func filename(template, index string, params map[string]any) (string, error) {
+	var missing string
+	name := placeholder.ReplaceAllStringFunc(template, func(m string) string {
+		key := m[1 : len(m)-1]
+		if key == "index" {
+			return index
+		}
+		value, ok := params[key]
+		if !ok {
+			missing = key
+			return ""
+		}
+		if vector, ok := value.([]any); ok {
+			items := make([]string, len(vector))
+			for i, item := range vector {
+				items[i] = format(item)
+			}
+			return strings.Join(items, "x")
+		}
+		return format(value)
+	})
+	if missing != "" {
+		return "", fmt.Errorf("%w: filename template uses unknown parameter %q", ErrInvalid, missing)
+	}
+
+	name = strings.TrimLeft(unsafeChars.ReplaceAllString(name, "_"), ".")
+	if name == "" {
+		return "", fmt.Errorf("%w: filename template %q gives an empty name", ErrInvalid, template)
+	}
+	return name, nil
+}
+
+// format renders a parameter value for file names and the CSV manifest
+func format(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Stream renders every variant with the request's model, format and options,
+// at most workers at a time, and passes each result to emit in variant
+// order. Renders wait for emit to keep up, so no more than workers outputs
+// are held at once. With a summary type set, every variant's summary is
+// written in the same OpenSCAD run as its output.
+func Stream(ctx context.Context, exporter services.OpenSCADExporter, req *models.SweepRequest, variants []Variant, workers int, emit func(Result)) {
+	results := make([]chan Result, len(variants))
+	for i := range results {
+		results[i] = make(chan Result, 1)
+	}
+
+	// A slot is taken per render and given back once its result is emitted
+	slots := make(chan struct{}, max(workers, 1))
+	go func() {
+		for i, variant := range variants {
+			slots <- struct{}{}
+			go func() {
+				results[i] <- render(ctx, exporter, req, i+1, variant)
+			}()
+		}
+	}()
+	for i := range results {
+		emit(<-results[i])
+		<-slots
+	}
+}
+
+// render renders one variant
+func render(ctx context.Context, exporter services.OpenSCADExporter, req *models.SweepRequest, index int, variant Variant) Result {
+	result := Result{SweepVariant: models.SweepVariant{
+		Index:      index,
+		Parameters: variant.Parameters,
+		Status:     "succeeded",
+	}}
+
+	start := time.Now()
+	// The summary is written by the same OpenSCAD run as the output
+	output, err := exporter.Export(ctx, &models.ExportRequest{
+		ScadContent:     req.ScadContent,
+		Format:          req.Format,
+		OpenSCADVersion: req.OpenSCADVersion,
+		Backend:         req.Backend,
+		Features:        req.Features,
+		Parameters:      variant.Parameters,
+		Assets:          req.Assets,
+		Options:         req.Options,
+		SummaryType:     req.SummaryType,
+	})
+	result.DurationSeconds = time.Since(start).Seconds()
+	if err != nil {
+		result.Status, result.Error, result.Err = "failed", err.Error(), err
+		return result
+	}
+	result.File, result.Data, result.OutputBytes = variant.File, output.Data, int64(len(output.Data))
+	if output.Summary != nil {
+		result.Summary = &output.Summary.Summary
+	}
+	return result
+}
@@ -0,0 +1,259 @@
+package sweep
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stevexciv/scad-server/models"
+	"github.com/stevexciv/scad-server/services"
+)
+
+// fakeExporter renders the parameters as text, with a summary when one is
+// requested, and fails for hole = 5
+type fakeExporter struct {
+	running, peak atomic.Int32
+}
+
+func (f *fakeExporter) Export(ctx context.Context, req *models.ExportRequest) (*services.ExportResult, error) {
+	n := f.running.Add(1)
+	defer f.running.Add(-1)
+	for {
+		peak := f.peak.Load()
+		if n <= peak || f.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+
+	if req.Parameters["hole"] == 5.0 {
+		return nil, errors.New("hole too large")
+	}
+	result := &services.ExportResult{
+		Data:        fmt.Appendf(nil, "solid %v", req.Parameters["length"]),
+		ContentType: "application/octet-stream",
+	}
+	if req.SummaryType != "" {
+		facets := 12
+		result.Summary = &models.SummaryResponse{Summary: models.Summary{
+			Geometry:    &models.SummaryGeometry{Dimensions: 3, Facets: &facets},
+			BoundingBox: &models.SummaryBoundingBox{Min: []float64{0, 0, 0}, Max: []float64{1, 1, 1}, Size: []float64{1, 1, 1}},
+		}}
+	}
+	return result, nil
+}
+
+func (f *fakeExporter) Summary(ctx context.Context, req *models.SummaryRequest) (*models.SummaryResponse, error) {
+	return nil, errors.New("sweeps summarize in the export run")
+}
+
+func bracketSweep() *models.SweepRequest {
+	return &models.SweepRequest{
+		ScadContent: "cube([length, 20, hole]);",
+		Format:      "stl_binary",
+		Parameters:  map[string]any{"width": 20.0},
+		Grid: map[string][]any{
+			"length": {40.0, 60.0, 80.0},
+			"hole":   {3.0, 5.0},
+		},
+		Filename: "bracket_{length}_{hole}",
+	}
+}
+
+func TestPlan_Grid(t *testing.T) {
+	variants, err := Plan(bracketSweep(), DefaultMaxVariants)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	want := []string{
+		"bracket_40_3.stl", "bracket_60_3.stl", "bracket_80_3.stl",
+		"bracket_40_5.stl", "bracket_60_5.stl", "bracket_80_5.stl",
+	}
+	if len(variants) != len(want) {
+		t.Fatalf("Expected %d variants, got %d", len(want), len(variants))
+	}
+	for i, v := range variants {
+		if v.File != want[i] {
+			t.Errorf("Expected variant %d to be %s, got %s", i+1, want[i], v.File)
+		}
+		if v.Parameters["width"] != 20.0 {
+			t.Errorf("Expected the shared parameters in variant %d, got %v", i+1, v.Parameters)
+		}
+	}
+}
+
+func TestPlan_Sets(t *testing.T) {
+	req := &models.SweepRequest{
+		Format:     "png",
+		Parameters: map[string]any{"size": 1.0, "label": "A"},
+		Sets: []map[string]any{
+			{"size": 2.0},
+			{"label": "B/C"},
+			{"offset": []any{1.0, 2.5}},
+		},
+		Filename: "{index}-{label}-{size}",
+	}
+	variants, err := Plan(req, 3)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := []string{"1-A-2.png", "2-B_C-1.png", "3-A-1.png"}
+	for i, v := range variants {
+		if v.File != want[i] {
+			t.Errorf("Expected variant %d to be %s, got %s", i+1, want[i], v.File)
+		}
+	}
+	if req.Parameters["size"] != 1.0 {
+		t.Errorf("Expected the request's parameters to stay unchanged, got %v", req.Parameters)
+	}
+
+	req.Filename = "part_{offset}"
+	req.Sets = req.Sets[2:]
+	variants, _ = Plan(req, 3)
+	if variants[0].File != "part_1x2.5.png" {
+		t.Errorf("Expected vectors joined with x, got %s", variants[0].File)
+	}
+}
+
+func TestPlan_DefaultFilename(t *testing.T) {
+	req := &models.SweepRequest{Format: "3mf", Sets: make([]map[string]any, 10)}
+	variants, err := Plan(req, DefaultMaxVariants)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if variants[0].File != "variant_01.3mf" || variants[9].File != "variant_10.3mf" {
+		t.Errorf("Expected zero-padded indexes, got %s and %s", variants[0].File, variants[9].File)
+	}
+}
+
+func TestPlan_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(req *models.SweepRequest)
+		max    int
+	}{
+		{"no grid or sets", func(req *models.SweepRequest) { req.Grid = nil }, DefaultMaxVariants},
+		{"grid and sets", func(req *models.SweepRequest) { req.Sets = []map[string]any{{}} }, DefaultMaxVariants},
+		{"empty grid values", func(req *models.SweepRequest) { req.Grid["hole"] = nil }, DefaultMaxVariants},
+		{"too many combinations", func(req *models.SweepRequest) {}, 5},
+		{"too many sets", func(req *models.SweepRequest) { req.Grid, req.Sets = nil, make([]map[string]any, 3) }, 2},
+		{"unknown placeholder", func(req *models.SweepRequest) { req.Filename = "bracket_{depth}" }, DefaultMaxVariants},
+		{"duplicate names", func(req *models.SweepRequest) { req.Filename = "bracket_{length}" }, DefaultMaxVariants},
+		{"empty name", func(req *models.SweepRequest) { req.Filename = ".." }, DefaultMaxVariants},
+		{"unknown summary type", func(req *models.SweepRequest) { req.SummaryType = "volume" }, DefaultMaxVariants},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := bracketSweep()
+			tt.modify(req)
+			if _, err := Plan(req, tt.max); !errors.Is(err, ErrInvalid) {
+				t.Errorf("Expected ErrInvalid, got %v", err)
+			}
+		})
+	}
+}
+
+// collect streams every variant of req and returns the results in the order
+// they were emitted
+func collect(exporter services.OpenSCADExporter, req *models.SweepRequest, workers int) []Result {
+	variants, _ := Plan(req, DefaultMaxVariants)
+	var results []Result
+	Stream(context.Background(), exporter, req, variants, workers, func(result Result) {
+		results = append(results, result)
+	})
+	return results
+}
+
+func TestStream(t *testing.T) {
+	req := bracketSweep()
+	req.SummaryType = "geometry"
+
+	exporter := &fakeExporter{}
+	results := collect(exporter, req, 2)
+	if peak := exporter.peak.Load(); peak > 2 {
+		t.Errorf("Expected at most 2 renders at once, got %d", peak)
+	}
+
+	if len(results) != 6 {
+		t.Fatalf("Expected 6 results, got %d", len(results))
+	}
+	for i, result := range results {
+		if result.Index != i+1 {
+			t.Errorf("Expected result %d to be variant %d, got %d", i, i+1, result.Index)
+		}
+		failed := result.Parameters["hole"] == 5.0
+		if failed && (result.Status != "failed" || result.File != "" || result.Error != "hole too large") {
+			t.Errorf("Expected variant %d to fail without a file, got %+v", result.Index, result.SweepVariant)
+		}
+		if !failed && (result.Status != "succeeded" || result.OutputBytes == 0 || result.Summary == nil) {
+			t.Errorf("Expected variant %d to succeed with a summary, got %+v", result.Index, result.SweepVariant)
+		}
+	}
+}
+
+func TestArchive(t *testing.T) {
+	req := bracketSweep()
+	req.SummaryType = "all"
+
+	var buf bytes.Buffer
+	archive := NewArchive(&buf, req.Format)
+	for _, result := range collect(&fakeExporter{}, req, 4) {
+		if err := archive.Add(result); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	manifest, err := archive.Close()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if manifest.Variants != 6 || manifest.Succeeded != 3 || manifest.Failed != 3 {
+		t.Fatalf("Expected 3 of 6 variants to succeed, got %+v", manifest)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Expected a zip archive, got %v", err)
+	}
+
+	entries := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		entries[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+	if len(entries) != 5 || string(entries["bracket_60_3.stl"]) != "solid 60" {
+		t.Errorf("Expected 3 outputs and 2 manifests, got %d entries", len(entries))
+	}
+
+	var decoded models.SweepManifest
+	if err := json.Unmarshal(entries[ManifestJSON], &decoded); err != nil || len(decoded.Results) != 6 {
+		t.Errorf("Expected a JSON manifest of 6 variants, got %v", err)
+	}
+
+	rows, err := csv.NewReader(bytes.NewReader(entries[ManifestCSV])).ReadAll()
+	if err != nil {
+		t.Fatalf("Expected a CSV manifest, got %v", err)
+	}
+	wantHeader := []string{"index", "file", "status", "error", "output_bytes", "duration_seconds",
+		"param.hole", "param.length", "param.width", "summary.bounding_box.max", "summary.bounding_box.min",
+		"summary.bounding_box.size", "summary.geometry.dimensions", "summary.geometry.facets"}
+	if fmt.Sprint(rows[0]) != fmt.Sprint(wantHeader) {
+		t.Errorf("Expected header %v, got %v", wantHeader, rows[0])
+	}
+	if len(rows) != 7 {
+		t.Fatalf("Expected 6 rows after the header, got %d", len(rows)-1)
+	}
+	if row := rows[1]; row[1] != "bracket_40_3.stl" || row[6] != "3" || row[10] != "[0,0,0]" || row[13] != "12" {
+		t.Errorf("Expected the first variant with its parameters and summary, got %v", row)
+	}
+	if row := rows[4]; row[2] != "failed" || row[3] != "hole too large" || row[10] != "" {
+		t.Errorf("Expected the fourth variant to fail, got %v", row)
+	}
+}
@@ -0,0 +1,254 @@
+package templates
+
+import (
+	"bufio"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+var (
+	// groupComment matches customizer group headers such as /* [Size] */
+	groupComment = regexp.MustCompile(`^/\*\s*\[([^\]]+)\]\s*\*/$`)
+	// assignmentStart matches the variable and "=" of a top-level assignment
+	assignmentStart = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)\s*=\s*`)
+	// declaration matches the first module or function definition, after
+	// which OpenSCAD's customizer stops looking for parameters
+	declaration = regexp.MustCompile(`^(module|function)\s`)
+)
+
+// hiddenGroup holds parameters that the customizer doesn't show
+const hiddenGroup = "hidden"
+
+// parse extracts the description and customizer parameters of a SCAD
+// source the way OpenSCAD's customizer does: literal top-level assignments
+// before the first module or function, grouped by /* [Group] */ comments,
+// described by the comment line above them and constrained by the comment
+// after them
+func parse(source string) (string, []models.TemplateParameter) {
+	var (
+		description []string
+		parameters  []models.TemplateParameter
+		comment     []string
+		group       string
+		header      = true
+		inBlock     bool
+		depth       int
+	)
+
+	scanner := bufio.NewScanner(strings.NewReader(source))
+	scanner.Buffer(make([]byte, 64<<10), maxSourceBytes)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if inBlock {
+			inBlock = !strings.Contains(line, "*/")
+			continue
+		}
+		switch {
+		case line == "":
+			if header && len(comment) > 0 {
+				description, header = comment, false
+			}
+			comment = nil
+			continue
+		case strings.HasPrefix(line, "//"):
+			comment = append(comment, strings.TrimSpace(strings.TrimPrefix(line, "//")))
+			continue
+		case groupComment.MatchString(line):
+			group = strings.TrimSpace(groupComment.FindStringSubmatch(line)[1])
+			comment, header = nil, false
+			continue
+		case strings.HasPrefix(line, "/*"):
+			inBlock = !strings.Contains(line, "*/")
+			continue
+		case declaration.MatchString(line):
+			return strings.Join(description, " "), parameters
+		}
+
+		header = false
+		top := depth == 0
+		depth = max(depth+braces(line), 0)
+		if p, ok := parseAssignment(line); ok && top && !strings.EqualFold(group, hiddenGroup) {
+			p.Group = group
+			if len(comment) > 0 {
+				p.Description = comment[len(comment)-1]
+			}
+			parameters = append(parameters, p)
+		}
+		comment = nil
+	}
+	return strings.Join(description, " "), parameters
+}
+
+// braces returns how much line changes the brace nesting, ignoring braces in
+// strings and trailing comments
+func braces(line string) int {
+	n := 0
+	inString, escaped := false, false
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case inString && r == '\\':
+			escaped = true
+		case r == '"':
+			inString = !inString
+		case inString:
+		case r == '/' && strings.HasPrefix(line[i:], "//"):
+			return n
+		case r == '{':
+			n++
+		case r == '}':
+			n--
+		}
+	}
+	return n
+}
+
+// parseAssignment reads a "name = literal; // annotation" line. Assignments
+// of expressions aren't parameters.
+func parseAssignment(line string) (models.TemplateParameter, bool) {
+	m := assignmentStart.FindStringSubmatchIndex(line)
+	if m == nil {
+		return models.TemplateParameter{}, false
+	}
+	p := models.TemplateParameter{Name: line[m[2]:m[3]]}
+
+	rest := line[m[1]:]
+	end := literalEnd(rest)
+	if end < 0 {
+		return p, false
+	}
+	value, typ, ok := parseLiteral(strings.TrimSpace(rest[:end]))
+	if !ok {
+		return p, false
+	}
+	p.Default, p.Type = value, typ
+
+	after := strings.TrimSpace(rest[end+1:])
+	if strings.HasPrefix(after, "//") {
+		annotate(&p, strings.TrimSpace(strings.TrimPrefix(after, "//")))
+	} else if after != "" {
+		return p, false
+	}
+	return p, true
+}
+
+// literalEnd returns the index of the ";" ending the value at the start of
+// s, skipping semicolons in strings, or -1
+func literalEnd(s string) int {
+	inString, escaped := false, false
+	for i, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case inString && r == '\\':
+			escaped = true
+		case r == '"':
+			inString = !inString
+		case !inString && r == ';':
+			return i
+		}
+	}
+	return -1
+}
+
+// parseLiteral parses a number, string, boolean or vector of numbers
+func parseLiteral(s string) (any, string, bool) {
+	switch {
+	case s == "true" || s == "false":
+		return s == "true", "boolean", true
+	case strings.HasPrefix(s, `"`):
+		var v string
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			return nil, "", false
+		}
+		return v, "string", true
+	case strings.HasPrefix(s, "["):
+		items := strings.Split(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"), ",")
+		if !strings.HasSuffix(s, "]") || len(items) == 0 {
+			return nil, "", false
+		}
+		vector := make([]any, len(items))
+		for i, item := range items {
+			n, err := strconv.ParseFloat(strings.TrimSpace(item), 64)
+			if err != nil {
+				return nil, "", false
+			}
+			vector[i] = n
+		}
+		return vector, "vector", true
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, "", false
+	}
+	return n, "number", true
+}
+
+// annotate applies a customizer annotation: [min:max], [min:step:max] or
+// [max] for slider ranges, [a, b, c] or [value:Label, ...] for dropdowns, and
+// a bare number for the step of numbers or the maximum length of strings
+func annotate(p *models.TemplateParameter, annotation string) {
+	if !strings.HasPrefix(annotation, "[") || !strings.HasSuffix(annotation, "]") {
+		n, err := strconv.ParseFloat(annotation, 64)
+		switch {
+		case err != nil:
+		case p.Type == "number":
+			p.Step = &n
+		case p.Type == "string" && n >= 0:
+			length := int(n)
+			p.MaxLength = &length
+		}
+		return
+	}
+
+	if p.Type != "number" && p.Type != "string" {
+		return
+	}
+	inner := strings.TrimSpace(annotation[1 : len(annotation)-1])
+	if p.Type == "number" && !strings.Contains(inner, ",") {
+		if bounds, ok := numbers(strings.Split(inner, ":")); ok {
+			switch len(bounds) {
+			case 1:
+				zero := 0.0
+				p.Min, p.Max = &zero, &bounds[0]
+			case 2:
+				p.Min, p.Max = &bounds[0], &bounds[1]
+			case 3:
+				p.Min, p.Step, p.Max = &bounds[0], &bounds[1], &bounds[2]
+			}
+			return
+		}
+	}
+
+	for _, item := range strings.Split(inner, ",") {
+		value, label, _ := strings.Cut(strings.TrimSpace(item), ":")
+		option := models.TemplateOption{Value: strings.Trim(strings.TrimSpace(value), `"`), Label: strings.TrimSpace(label)}
+		if p.Type == "number" {
+			n, err := strconv.ParseFloat(option.Value.(string), 64)
+			if err != nil {
+				continue
+			}
+			option.Value = n
+		}
+		p.Options = append(p.Options, option)
+	}
+}
+
+// numbers parses all of items as numbers
+func numbers(items []string) ([]float64, bool) {
+	result := make([]float64, len(items))
+	for i, item := range items {
+		n, err := strconv.ParseFloat(strings.TrimSpace(item), 64)
+		if err != nil {
+			return nil, false
+		}
+		result[i] = n
+	}
+	return result, len(result) <= 3
+}
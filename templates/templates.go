@@ -0,0 +1,180 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/stevexciv/scad-server/models"
+)
+
+// maxSourceBytes bounds the size of a template file
+const maxSourceBytes = 1 << 20
+
+var (
+	// ErrNotFound is returned for templates that aren't in the catalog
+	ErrNotFound = errors.New("template not found")
+	// ErrInvalidParameter is returned for parameter values that the
+	// template doesn't accept
+	ErrInvalidParameter = errors.New("invalid template parameter")
+)
+
+// namePattern matches template names, the file names without ".scad"
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// Template is a parametric SCAD source with its customizer parameters
+type Template struct {
+	models.Template
+	source string
+}
+
+// Catalog holds the templates loaded from a directory
+type Catalog struct {
+	templates map[string]*Template
+}
+
+// Load reads every .scad file directly in dir as a template named after the
+// file. Files with names that aren't valid template names are skipped.
+func Load(dir string) (*Catalog, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template directory: %w", err)
+	}
+
+	c := &Catalog{templates: make(map[string]*Template)}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".scad")
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		if !namePattern.MatchString(name) {
+			slog.Warn("Skipping template with invalid name", "file", entry.Name())
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template %s: %w", name, err)
+		}
+		if info.Size() > maxSourceBytes {
+			return nil, fmt.Errorf("template %s is %d bytes, limit is %d", name, info.Size(), maxSourceBytes)
+		}
+		source, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template %s: %w", name, err)
+		}
+		c.templates[name] = New(name, string(source))
+	}
+	return c, nil
+}
+
+// New returns the template with the given name and source
+func New(name, source string) *Template {
+	description, parameters := parse(source)
+	if parameters == nil {
+		parameters = []models.TemplateParameter{}
+	}
+	return &Template{
+		Template: models.Template{
+			Name:        name,
+			Description: description,
+			Parameters:  parameters,
+		},
+		source: source,
+	}
+}
+
+// List returns the templates sorted by name
+func (c *Catalog) List() []models.Template {
+	list := make([]models.Template, 0, len(c.templates))
+	for _, t := range c.templates {
+		list = append(list, t.Template)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Get returns the template with the given name
+func (c *Catalog) Get(name string) (*Template, error) {
+	t, ok := c.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return t, nil
+}
+
+// Source returns the SCAD source of the template
+func (t *Template) Source() string {
+	return t.source
+}
+
+// Validate checks params against the template's parameters: every name must
+// be a parameter, and every value must have its type and lie within its
+// range, length and options
+func (t *Template) Validate(params map[string]any) error {
+	for name, value := range params {
+		i := slices.IndexFunc(t.Parameters, func(p models.TemplateParameter) bool { return p.Name == name })
+		if i < 0 {
+			return fmt.Errorf("%w: %s has no parameter %q", ErrInvalidParameter, t.Name, name)
+		}
+		if err := check(t.Parameters[i], value); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidParameter, name, err)
+		}
+	}
+	return nil
+}
+
+// check validates one parameter value
+func check(p models.TemplateParameter, value any) error {
+	switch p.Type {
+	case "number":
+		n, ok := value.(float64)
+		if !ok || math.IsNaN(n) || math.IsInf(n, 0) {
+			return fmt.Errorf("expected a number, got %v", value)
+		}
+		if p.Min != nil && n < *p.Min {
+			return fmt.Errorf("%v is below the minimum %v", n, *p.Min)
+		}
+		if p.Max != nil && n > *p.Max {
+			return fmt.Errorf("%v is above the maximum %v", n, *p.Max)
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("expected a string, got %v", value)
+		}
+		if p.MaxLength != nil && len([]rune(s)) > *p.MaxLength {
+			return fmt.Errorf("longer than %d characters", *p.MaxLength)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("expected true or false, got %v", value)
+		}
+	case "vector":
+		v, ok := value.([]any)
+		if !ok || len(v) != len(p.Default.([]any)) {
+			return fmt.Errorf("expected a vector of %d numbers, got %v", len(p.Default.([]any)), value)
+		}
+		for _, item := range v {
+			if _, ok := item.(float64); !ok {
+				return fmt.Errorf("expected a vector of %d numbers, got %v", len(v), value)
+			}
+		}
+	}
+
+	if len(p.Options) > 0 && !slices.ContainsFunc(p.Options, func(o models.TemplateOption) bool { return o.Value == value }) {
+		values := make([]string, len(p.Options))
+		for i, o := range p.Options {
+			values[i] = fmt.Sprint(o.Value)
+		}
+		return fmt.Errorf("%v is not one of %s", value, strings.Join(values, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package templates
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const cableClip = `// Snap-on clip for round cables
+// screwed to a flat surface
+
+/* [Size] */
+// Cable diameter in mm
+diameter = 6; // [2:0.5:20]
+// Number of cables side by side
+count = 1; // [1, 2, 3, 4]
+width = 10; // 0.5
+
+/* [Style] */
+shape = "round"; // [round:Round, square:Square]
+label = "CABLE"; // 12
+countersunk = true;
+offset = [0, 0, 1];
+height = diameter * 2;
+
+/* [Hidden] */
+$fn = 64;
+
+module clip(d) {
+    inner = 3;
+    cylinder(d = d);
+}
+
+after = 5;
+clip(diameter);
+`
+
+func TestNew_Parameters(t *testing.T) {
+	tmpl := New("cable_clip", cableClip)
+
+	if tmpl.Description != "Snap-on clip for round cables screwed to a flat surface" {
+		t.Errorf("Expected the leading comment as description, got %q", tmpl.Description)
+	}
+
+	var names []string
+	for _, p := range tmpl.Parameters {
+		names = append(names, p.Name)
+	}
+	want := []string{"diameter", "count", "width", "shape", "label", "countersunk", "offset"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("Expected parameters %v, got %v", want, names)
+	}
+
+	diameter := tmpl.Parameters[0]
+	if diameter.Type != "number" || diameter.Default != 6.0 || diameter.Group != "Size" || diameter.Description != "Cable diameter in mm" {
+		t.Errorf("Expected number 6 in Size with its description, got %+v", diameter)
+	}
+	if diameter.Min == nil || *diameter.Min != 2 || diameter.Step == nil || *diameter.Step != 0.5 || diameter.Max == nil || *diameter.Max != 20 {
+		t.Errorf("Expected range 2 to 20 in steps of 0.5, got %v %v %v", diameter.Min, diameter.Step, diameter.Max)
+	}
+
+	count := tmpl.Parameters[1]
+	if len(count.Options) != 4 || count.Options[3].Value != 4.0 {
+		t.Errorf("Expected numeric options 1 to 4, got %+v", count.Options)
+	}
+	if width := tmpl.Parameters[2]; width.Step == nil || *width.Step != 0.5 || width.Min != nil {
+		t.Errorf("Expected step 0.5 without range, got %+v", width)
+	}
+
+	shape := tmpl.Parameters[3]
+	if shape.Type != "string" || shape.Group != "Style" || len(shape.Options) != 2 || shape.Options[1].Value != "square" || shape.Options[1].Label != "Square" {
+		t.Errorf("Expected labelled string options in Style, got %+v", shape)
+	}
+	if label := tmpl.Parameters[4]; label.MaxLength == nil || *label.MaxLength != 12 {
+		t.Errorf("Expected max length 12, got %+v", label)
+	}
+	if countersunk := tmpl.Parameters[5]; countersunk.Type != "boolean" || countersunk.Default != true {
+		t.Errorf("Expected boolean true, got %+v", countersunk)
+	}
+	if offset := tmpl.Parameters[6]; offset.Type != "vector" || !reflect.DeepEqual(offset.Default, []any{0.0, 0.0, 1.0}) {
+		t.Errorf("Expected vector [0, 0, 1], got %+v", offset)
+	}
+}
+
+func TestNew_NoParameters(t *testing.T) {
+	tmpl := New("cube", "cube(10);")
+	if tmpl.Parameters == nil || len(tmpl.Parameters) != 0 || tmpl.Description != "" {
+		t.Errorf("Expected no description and an empty parameter list, got %+v", tmpl.Template)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tmpl := New("cable_clip", cableClip)
+
+	tests := []struct {
+		name    string
+		params  map[string]any
+		wantErr bool
+	}{
+		{"defaults", nil, false},
+		{"valid values", map[string]any{"diameter": 8.5, "count": 2.0, "shape": "square", "label": "USB", "countersunk": false, "offset": []any{1.0, 2.0, 3.0}}, false},
+		{"unknown parameter", map[string]any{"height": 5.0}, true},
+		{"hidden parameter", map[string]any{"$fn": 16.0}, true},
+		{"below minimum", map[string]any{"diameter": 1.0}, true},
+		{"above maximum", map[string]any{"diameter": 25.0}, true},
+		{"number as string", map[string]any{"diameter": "8"}, true},
+		{"not an option", map[string]any{"count": 5.0}, true},
+		{"string not an option", map[string]any{"shape": "oval"}, true},
+		{"string too long", map[string]any{"label": "THIRTEEN CHARS"}, true},
+		{"boolean as number", map[string]any{"countersunk": 1.0}, true},
+		{"short vector", map[string]any{"offset": []any{1.0, 2.0}}, true},
+		{"vector of strings", map[string]any{"offset": []any{"a", "b", "c"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tmpl.Validate(tt.params)
+			if tt.wantErr && !errors.Is(err, ErrInvalidParameter) {
+				t.Errorf("Expected ErrInvalidParameter, got %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"cable_clip.scad":  cableClip,
+		"standoff.scad":    "height = 5; // [1:30]\ncylinder(h = height);",
+		"bad name.scad":    "cube(1);",
+		"notes.txt":        "not a template",
+		"sub/nested.scad":  "cube(1);",
+		"sub/ignored.scad": "cube(1);",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	catalog, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	list := catalog.List()
+	if len(list) != 2 || list[0].Name != "cable_clip" || list[1].Name != "standoff" {
+		t.Fatalf("Expected cable_clip and standoff, got %+v", list)
+	}
+
+	standoff, err := catalog.Get("standoff")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if standoff.Source() != files["standoff.scad"] {
+		t.Errorf("Expected the file content as source, got %q", standoff.Source())
+	}
+	if _, err := catalog.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+}
@@ -0,0 +1,397 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stevexciv/scad-server/ids"
+	"github.com/stevexciv/scad-server/logging"
+	"github.com/stevexciv/scad-server/metrics"
+)
+
+const (
+	// DefaultMaxAttempts is how often a callback is tried before it is dead-lettered
+	DefaultMaxAttempts = 5
+	// DefaultInitialBackoff is the wait before the first retry; each further
+	// retry waits twice as long
+	DefaultInitialBackoff = time.Second
+	// DefaultMaxBackoff caps the wait between retries
+	DefaultMaxBackoff = time.Minute
+	// DefaultTimeout bounds each delivery attempt
+	DefaultTimeout = 10 * time.Second
+	// DefaultMaxPending caps the callbacks rendering or awaiting delivery at
+	// once
+	DefaultMaxPending = 100
+)
+
+// Headers sent with every callback
+const (
+	HeaderID        = "X-Callback-ID"
+	HeaderAttempt   = "X-Callback-Attempt"
+	HeaderTimestamp = "X-Callback-Timestamp"
+	HeaderSignature = "X-Callback-Signature"
+)
+
+var (
+	// ErrInvalidURL is returned for callback URLs that aren't absolute http(s) URLs
+	ErrInvalidURL = errors.New("callback_url must be an absolute http(s) URL")
+	// ErrHostNotAllowed is returned for callback URLs outside the allowlist
+	ErrHostNotAllowed = errors.New("callback host not allowed")
+	// ErrClosed is returned by Dispatch once the dispatcher is closing
+	ErrClosed = errors.New("callback dispatcher is shutting down")
+	// ErrQueueFull is returned by Dispatch while the maximum number of
+	// callbacks is pending
+	ErrQueueFull = errors.New("too many callbacks pending")
+)
+
+// Config contains callback delivery settings
+type Config struct {
+	// AllowedHosts lists the hosts callbacks may be sent to, as "host",
+	// "host:port" or "*.domain" for any subdomain
+	AllowedHosts []string
+	// Secret is the HMAC key signing each callback
+	Secret []byte
+	// MaxAttempts is how often a callback is tried before it is dead-lettered
+	MaxAttempts int
+	// InitialBackoff and MaxBackoff bound the exponential wait between tries
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Timeout bounds each delivery attempt
+	Timeout time.Duration
+	// MaxPending caps the callbacks rendering or awaiting delivery at once
+	MaxPending int
+	// DeadLetterFile receives a JSON line for every callback that could not be
+	// delivered; when empty they are only logged
+	DeadLetterFile string
+}
+
+// DeadLetter records a callback that could not be delivered
+type DeadLetter struct {
+	ID       string          `json:"id"`
+	URL      string          `json:"url"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error"`
+	FailedAt time.Time       `json:"failed_at"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+// Dispatcher runs work in the background and POSTs its result to a callback
+// URL, retrying failed deliveries with exponential backoff
+type Dispatcher struct {
+	client       *http.Client
+	allowedHosts []string
+	secret       []byte
+	maxAttempts  int
+	backoff      time.Duration
+	maxBackoff   time.Duration
+	maxPending   int
+	deadLetters  string
+
+	// ctx is cancelled when Close gives up waiting for pending work
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// mu guards closed and pending and serializes dead letter writes
+	mu      sync.Mutex
+	closed  bool
+	pending int
+	wg      sync.WaitGroup
+	now     func() time.Time
+}
+
+// New creates a dispatcher for cfg, filling in defaults for unset retry
+// settings
+func New(cfg Config) (*Dispatcher, error) {
+	if len(cfg.Secret) == 0 {
+		return nil, errors.New("callback signing secret must not be empty")
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = DefaultInitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.MaxPending <= 0 {
+		cfg.MaxPending = DefaultMaxPending
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			// Redirects could lead outside the allowlist
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		allowedHosts: cfg.AllowedHosts,
+		secret:       cfg.Secret,
+		maxAttempts:  cfg.MaxAttempts,
+		backoff:      cfg.InitialBackoff,
+		maxBackoff:   cfg.MaxBackoff,
+		maxPending:   cfg.MaxPending,
+		deadLetters:  cfg.DeadLetterFile,
+		ctx:          ctx,
+		cancel:       cancel,
+		now:          time.Now,
+	}, nil
+}
+
+// Check reports whether callbacks may be sent to target
+func (d *Dispatcher) Check(target string) error {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return ErrInvalidURL
+	}
+	if !hostAllowed(d.allowedHosts, u) {
+		return fmt.Errorf("%w: %s", ErrHostNotAllowed, u.Host)
+	}
+	return nil
+}
+
+// Dispatch checks target, then calls run in the background and delivers the
+// JSON encoding of its result to target. It returns the callback ID, which run
+// also receives, or ErrQueueFull while the maximum number of callbacks is
+// pending. The context passed to run keeps the values of ctx but isn't
+// cancelled with it.
+func (d *Dispatcher) Dispatch(ctx context.Context, target string, run func(ctx context.Context, id string) any) (string, error) {
+	if err := d.Check(target); err != nil {
+		return "", err
+	}
+	id, err := ids.New()
+	if err != nil {
+		return "", err
+	}
+
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return "", ErrClosed
+	}
+	if d.pending >= d.maxPending {
+		d.mu.Unlock()
+		return "", ErrQueueFull
+	}
+	d.pending++
+	d.wg.Add(1)
+	d.mu.Unlock()
+
+	go func() {
+		defer d.wg.Done()
+		defer func() {
+			d.mu.Lock()
+			d.pending--
+			d.mu.Unlock()
+		}()
+
+		ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		defer cancel()
+		stop := context.AfterFunc(d.ctx, cancel)
+		defer stop()
+
+		payload, err := json.Marshal(run(ctx, id))
+		if err != nil {
+			logging.FromContext(ctx).Error("failed to encode callback", "callback_id", id, "error", err)
+			return
+		}
+		d.deliver(ctx, id, target, payload)
+	}()
+	return id, nil
+}
+
+// Close stops accepting work and waits for pending renders and deliveries.
+// When ctx is done first, pending deliveries are abandoned to the dead
+// letter log and ctx's error is returned.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Sign returns the signature header value for a callback body sent at
+// timestamp (Unix seconds): "sha256=" and the hex HMAC-SHA256 of
+// "<timestamp>.<body>"
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// permanentError marks delivery failures that retrying won't fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// deliver POSTs payload to target until it is accepted, the attempts run out
+// or ctx is done, dead-lettering it on failure
+func (d *Dispatcher) deliver(ctx context.Context, id, target string, payload []byte) {
+	logger := logging.FromContext(ctx).With("callback_id", id, "url", target)
+	backoff := d.backoff
+
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		err = d.post(ctx, id, target, payload, attempt)
+		if err == nil {
+			metrics.WebhookDeliveries.WithLabelValues("delivered").Inc()
+			logger.Info("callback delivered", "attempt", attempt)
+			return
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) || attempt == d.maxAttempts {
+			break
+		}
+		metrics.WebhookDeliveries.WithLabelValues("retried").Inc()
+		logger.Warn("callback delivery failed, retrying", "attempt", attempt, "retry_in", backoff, "error", err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			err = fmt.Errorf("%w; gave up: %w", err, ctx.Err())
+		case <-timer.C:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		backoff = min(backoff*2, d.maxBackoff)
+	}
+
+	metrics.WebhookDeliveries.WithLabelValues("failed").Inc()
+	logger.Error("callback delivery failed permanently", "attempts", attempt, "error", err)
+	d.deadLetter(DeadLetter{
+		ID:       id,
+		URL:      target,
+		Attempts: attempt,
+		Error:    err.Error(),
+		FailedAt: d.now().UTC(),
+		Payload:  payload,
+	})
+}
+
+// post makes one delivery attempt. Timeouts, 408, 429 and 5xx responses are
+// worth retrying; other non-2xx responses are permanent failures.
+func (d *Dispatcher) post(ctx context.Context, id, target string, payload []byte, attempt int) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(payload))
+	if err != nil {
+		return &permanentError{err}
+	}
+	timestamp := strconv.FormatInt(d.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "scad-server-webhooks")
+	req.Header.Set(HeaderID, id)
+	req.Header.Set(HeaderAttempt, strconv.Itoa(attempt))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(d.secret, timestamp, payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("callback returned %s", resp.Status)
+	default:
+		return &permanentError{fmt.Errorf("callback returned %s", resp.Status)}
+	}
+}
+
+// deadLetter appends a failed delivery to the dead letter file
+func (d *Dispatcher) deadLetter(entry DeadLetter) {
+	if d.deadLetters == "" {
+		return
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		slog.Error("Failed to encode dead letter", "callback_id", entry.ID, "error", err)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	f, err := os.OpenFile(d.deadLetters, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err == nil {
+		_, err = f.Write(append(line, '\n'))
+		err = errors.Join(err, f.Close())
+	}
+	if err != nil {
+		slog.Error("Failed to write dead letter", "callback_id", entry.ID, "file", d.deadLetters, "error", err)
+	}
+}
+
+// hostAllowed reports whether u's host matches one of patterns. A pattern
+// without a port matches any port, and "*.domain" matches subdomains of
+// domain but not domain itself.
+func hostAllowed(patterns []string, u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+
+	for _, pattern := range patterns {
+		name, patternPort, err := net.SplitHostPort(strings.ToLower(pattern))
+		if err != nil {
+			name, patternPort = strings.ToLower(pattern), ""
+		}
+		if patternPort != "" && patternPort != port {
+			continue
+		}
+		if domain, ok := strings.CutPrefix(name, "*."); ok {
+			if strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		} else if host == strings.Trim(name, "[]") {
+			return true
+		}
+	}
+	return false
+}